@@ -2,58 +2,266 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
+	// База часовых поясов для X-Timezone в быстром добавлении, если в
+	// системе ее нет
+	_ "time/tzdata"
 
+	"todo/internal/auth"
+	"todo/internal/config"
+	"todo/internal/domain"
+	"todo/internal/events"
+	"todo/internal/health"
 	"todo/internal/http/handler"
 	"todo/internal/http/middleware"
+	"todo/internal/logging"
+	"todo/internal/metrics"
 	"todo/internal/repository"
+	"todo/internal/tracing"
 	"todo/internal/usecase"
+	"todo/internal/webhook"
 )
 
 func main() {
+	cfg, printConfig, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+	if printConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	// Уровень журнала меняется при перезагрузке конфигурации
+	level := new(slog.LevelVar)
+	log, err := setupLogger(cfg.Log, level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// Логгер по умолчанию используют use case и репозиторий
+	slog.SetDefault(log)
+
+	// Проверки состояния
+	checks := health.NewRegistry()
+
 	// Инициализация зависимостей
-	todoRepo := repository.NewInMemoryTodoRepository()
-	todoUseCase := usecase.NewTodoUseCase(todoRepo)
-	todoHandler := handler.NewTodoHandler(todoUseCase)
+	todoRepo, err := setupRepository(cfg.Storage, checks)
+	if err != nil {
+		log.Error("Failed to open storage", "error", err)
+		os.Exit(1)
+	}
+	webhookRepo, err := setupWebhookRepository(cfg.Storage)
+	if err != nil {
+		log.Error("Failed to open webhook storage", "error", err)
+		os.Exit(1)
+	}
+	filterRepo, err := setupFilterRepository(cfg.Storage)
+	if err != nil {
+		log.Error("Failed to open filter storage", "error", err)
+		os.Exit(1)
+	}
+	bus := events.NewBus(cfg.Events.ReplaySize)
+	todoUseCase := usecase.NewTodoUseCase(todoRepo, usecase.WithEventPublisher(bus))
+	todoHandler := handler.NewTodoHandler(todoUseCase, handler.WithMaxBodyBytes(cfg.Requests.MaxBodyBytes))
+	webhookNetworks := allowedNetworks(cfg.Webhooks)
+	webhookHandler := handler.NewWebhookHandler(usecase.NewWebhookUseCase(webhookRepo, todoRepo, webhookNetworks), cfg.Requests.MaxBodyBytes)
+	filterUseCase := usecase.NewFilterUseCase(filterRepo, todoRepo)
+	filterHandler := handler.NewFilterHandler(filterUseCase, cfg.Requests.MaxBodyBytes)
+
+	// Трассировка
+	tracer, err := setupTracer(cfg.Tracing)
+	if err != nil {
+		log.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	// Метрики
+	registry := metrics.NewRegistry()
+	registry.Register(metrics.RuntimeCollector())
+	registry.Register(metrics.TodoCollector(todoUseCase, 5*time.Second))
 
-	log := setupLogger()
 	// Настройка роутера
 	mux := http.NewServeMux()
 
 	// Регистрация эндпоинтов
 	mux.HandleFunc("/todos", todoHandler.HandleTodos)
 	mux.HandleFunc("/todos/", todoHandler.HandleTodoByID)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "OK")
-		w.WriteHeader(http.StatusOK)
-	})
+	mux.HandleFunc("/todos/search", todoHandler.HandleSearch)
+	mux.HandleFunc("/todos/quick", todoHandler.HandleQuickAdd)
+	mux.HandleFunc("/sync", todoHandler.HandleSync)
+	mux.HandleFunc("/webhooks", webhookHandler.HandleWebhooks)
+	mux.HandleFunc("/webhooks/", webhookHandler.HandleWebhookByID)
+	mux.HandleFunc("/filters", filterHandler.HandleFilters)
+	mux.HandleFunc("/filters/", filterHandler.HandleFilterByID)
+	mux.Handle("/todos/events", handler.NewEventsHandler(bus, time.Duration(cfg.Events.Heartbeat)))
+	mux.Handle("/metrics", registry)
+	mux.Handle("/livez", checks.LivezHandler())
+	mux.Handle("/readyz", checks.ReadyzHandler())
+	mux.Handle("/health", checks.LivezHandler())
+
+	// Настройки, которые применяются без перезапуска по SIGHUP
+	var current atomic.Pointer[config.Config]
+	current.Store(&cfg)
+	var timeouts atomic.Pointer[middleware.TimeoutConfig]
+	timeouts.Store(ptr(timeoutConfig(cfg.Requests)))
+	keys := auth.NewKeySet(cfg.Auth.APIKeys)
+
+	idempotency := middleware.NewIdempotencyStore(time.Duration(cfg.Idempotency.TTL), cfg.Idempotency.MaxEntries)
+	limiter := middleware.NewRateLimiter(rateLimitConfig(cfg.RateLimit))
+	var cors atomic.Pointer[middleware.CORSConfig]
+	cors.Store(ptr(corsConfig(cfg.CORS)))
+	publicPaths := []string{"/livez", "/readyz", "/health", "/metrics"}
+
+	// WebSocket принимает страницы с origin из списка CORS и закрывает
+	// соединение, если его ключ отозван перезагрузкой конфигурации
+	mux.Handle("/todos/ws", handler.NewWebSocketHandler(todoUseCase, bus,
+		handler.WebSocketConfig{
+			PingInterval:    time.Duration(cfg.WebSocket.PingInterval),
+			WriteTimeout:    time.Duration(cfg.WebSocket.WriteTimeout),
+			CommandTimeout:  time.Duration(cfg.Requests.Timeout),
+			MaxMessageBytes: cfg.Requests.MaxBodyBytes,
+		},
+		handler.WithOriginCheck(func(origin string) bool { return cors.Load().AllowsOrigin(origin) }),
+		handler.WithCredentialCheck(func(r *http.Request) bool {
+			if !keys.Enabled() {
+				return true
+			}
+			principal, ok := keys.Authenticate(middleware.APIKey(r))
+			return ok && principal == auth.PrincipalFromContext(r.Context())
+		}),
+	))
+
+	compress := func(next http.Handler) http.Handler { return next }
+	if cfg.Compression.Enabled {
+		compress = middleware.Compress(cfg.Compression.MinSize)
+	}
 
 	// Применение middleware
-	handlerWithMiddleware := middleware.Logger(
-		middleware.Recovery(
-			middleware.Timeout(30 * time.Second)(mux),
+	routes := knownRoutes(filterUseCase)
+	handlerWithMiddleware := middleware.RequestID(
+		middleware.Tracing(tracer, routes)(
+			middleware.Logger(log)(
+				middleware.Metrics(registry, routes)(
+					middleware.Recovery(log)(
+						compress(
+							middleware.CORS(func() middleware.CORSConfig { return *cors.Load() })(
+								middleware.RateLimit(limiter, keys, publicPaths...)(
+									middleware.Auth(keys, publicPaths...)(
+										middleware.Idempotency(idempotency, cfg.Requests.MaxBodyBytes)(
+											middleware.DynamicTimeout(func() middleware.TimeoutConfig { return *timeouts.Load() })(mux),
+										),
+									),
+								),
+							),
+						),
+					),
+				),
+			),
 		),
 	)
 
+	reload := func() {
+		next, _, err := config.Load(os.Args[1:], os.LookupEnv)
+		if err != nil {
+			log.Error("Configuration reload failed, keeping current configuration", "error", err)
+			return
+		}
+		// Логируются только имена ключей: значения могут содержать секреты
+		live, restart := config.Changes(*current.Load(), next)
+		if len(restart) > 0 {
+			log.Error("Configuration reload rejected: changed keys require a restart", "keys", restart)
+			return
+		}
+		if len(live) == 0 {
+			log.Info("Configuration reloaded, nothing changed")
+			return
+		}
+
+		lvl, _ := logging.ParseLevel(next.Log.Level) // проверено в Validate
+		level.Set(lvl.Level())
+		timeouts.Store(ptr(timeoutConfig(next.Requests)))
+		keys.Update(next.Auth.APIKeys)
+		limiter.Update(rateLimitConfig(next.RateLimit))
+		cors.Store(ptr(corsConfig(next.CORS)))
+		current.Store(&next)
+		log.Info("Configuration reloaded", "changed", live)
+	}
+
+	// Базовый контекст запросов: отменяется, если graceful shutdown не успел
+	// дождаться их завершения, и тогда обработчики отвечают 503
+	baseCtx, cancelBase := context.WithCancelCause(context.Background())
+	defer cancelBase(nil)
+
 	// Настройка сервера
 	server := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Server.Addr,
 		Handler:      handlerWithMiddleware,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
 	}
+	// Потоки событий сами не завершатся: закрываем их в начале остановки
+	server.RegisterOnShutdown(bus.Close)
 
 	// Канал для graceful shutdown
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP перечитывает конфигурацию
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload()
+		}
+	}()
+
+	// Доставка вебхуков. Шина будит отправителя сразу после изменения, а
+	// очередь в хранилище гарантирует, что событие не потеряется. Зависший
+	// отправитель проваливает /livez.
+	dispatcherBeat := health.NewHeartbeat(max(time.Minute, 10*time.Duration(cfg.Webhooks.PollInterval)))
+	checks.AddLivenessCheck("webhook-dispatcher", dispatcherBeat)
+	dispatcher := webhook.NewDispatcher(webhookRepo, todoRepo, webhook.Config{
+		Timeout:         time.Duration(cfg.Webhooks.Timeout),
+		InitialBackoff:  time.Duration(cfg.Webhooks.InitialBackoff),
+		MaxBackoff:      time.Duration(cfg.Webhooks.MaxBackoff),
+		MaxFailures:     cfg.Webhooks.MaxFailures,
+		PollInterval:    time.Duration(cfg.Webhooks.PollInterval),
+		AllowedNetworks: webhookNetworks,
+		Heartbeat:       dispatcherBeat,
+	})
+	wakeups, _, _ := bus.Subscribe(bus.LastID(), nil)
+	go func() {
+		for range wakeups.C {
+			dispatcher.Notify()
+		}
+	}()
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		dispatcher.Run(dispatchCtx)
+	}()
+
 	// Запуск сервера в отдельной горутине
 	go func() {
 		log.Info("Starting server on", "address", server.Addr)
@@ -66,18 +274,149 @@ func main() {
 
 	// Ожидание сигнала для остановки
 	<-done
+	signal.Stop(hup)
 	log.Info("Server stopping...")
+	cfg = *current.Load()
+
+	// Сначала сообщаем балансировщику о неготовности и даем ему время
+	// убрать нас из ротации, затем перестаем принимать соединения
+	checks.SetShuttingDown()
+	time.Sleep(time.Duration(cfg.Server.DrainDelay))
 
 	// Graceful shutdown с таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error("Server shutdown failed:", "error", err)
+		// Прерываем оставшиеся запросы
+		cancelBase(domain.ErrUnavailable)
+	}
+
+	// Прерванные остановкой попытки доставки повторятся после запуска
+	stopDispatch()
+	<-dispatchDone
+
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.Error("Tracer shutdown failed:", "error", err)
 	}
 
 	log.Info("Server stopped gracefully")
 }
-func setupLogger() *slog.Logger {
-	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+func setupLogger(cfg config.LogConfig, level *slog.LevelVar) (*slog.Logger, error) {
+	lvl, err := logging.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	level.Set(lvl.Level())
+	return logging.New(os.Stdout, cfg.Format, level)
+}
+
+// setupRepository открывает выбранное хранилище и регистрирует его проверки
+func setupRepository(cfg config.StorageConfig, checks *health.Registry) (domain.TodoRepository, error) {
+	var repo domain.TodoRepository
+	switch cfg.Driver {
+	case config.StorageFile:
+		fileRepo, err := repository.NewFileTodoRepository(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		checks.AddReadinessCheck("disk", health.DiskSpaceChecker(filepath.Dir(cfg.Path), cfg.MinFreeBytes))
+		repo = fileRepo
+	default:
+		repo = repository.NewInMemoryTodoRepository()
+	}
+
+	checks.AddReadinessCheck("repository", health.RepositoryChecker(repo))
+	return repo, nil
+}
+
+// setupWebhookRepository открывает хранилище подписок на вебхуки. Для
+// файлового хранилища подписки лежат рядом с файлом задач:
+// todos.json -> todos.webhooks.json.
+func setupWebhookRepository(cfg config.StorageConfig) (domain.WebhookRepository, error) {
+	if cfg.Driver != config.StorageFile {
+		return repository.NewInMemoryWebhookRepository(), nil
+	}
+	return repository.NewFileWebhookRepository(strings.TrimSuffix(cfg.Path, filepath.Ext(cfg.Path)) + ".webhooks.json")
+}
+
+// setupFilterRepository открывает хранилище сохраненных фильтров; для
+// файлового хранилища это todos.filters.json рядом с файлом задач
+func setupFilterRepository(cfg config.StorageConfig) (domain.FilterRepository, error) {
+	if cfg.Driver != config.StorageFile {
+		return repository.NewInMemoryFilterRepository(), nil
+	}
+	return repository.NewFileFilterRepository(strings.TrimSuffix(cfg.Path, filepath.Ext(cfg.Path)) + ".filters.json")
+}
+
+// setupTracer создает трассировщик. Без файла спаны не экспортируются, но
+// контекст трассы по-прежнему передается через traceparent.
+func setupTracer(cfg config.TracingConfig) (*tracing.Tracer, error) {
+	if cfg.File == "" {
+		return tracing.NewTracer(tracing.ParentBased(tracing.NeverSample()), nil), nil
+	}
+
+	exporter, err := tracing.NewFileExporter(cfg.File, "todo")
+	if err != nil {
+		return nil, err
+	}
+	return tracing.NewTracer(tracing.ParentBased(tracing.TraceIDRatio(cfg.SampleRatio)), exporter), nil
+}
+
+// knownRoutes возвращает шаблоны маршрутов для метрик и трассировки; они
+// должны следовать за эндпоинтами, зарегистрированными в main
+func knownRoutes(filters *usecase.FilterUseCase) *middleware.Routes {
+	routes := []string{
+		"/todos", "/todos/{id}", "/todos/{id}/move", "/todos/search", "/todos/quick",
+		"/todos/events", "/todos/ws", "/sync",
+		"/webhooks", "/webhooks/{id}", "/webhooks/{id}/deliveries",
+		"/filters", "/filters/{id}", "/filters/{id}/todos",
+		"/metrics", "/livez", "/readyz", "/health",
+	}
+	for _, list := range filters.SmartLists() {
+		routes = append(routes, "/filters/"+list.ID, "/filters/"+list.ID+"/todos")
+	}
+	return middleware.NewRoutes(routes...)
+}
+
+// allowedNetworks разбирает сети, проверенные config.Validate
+func allowedNetworks(cfg config.WebhooksConfig) []netip.Prefix {
+	networks := make([]netip.Prefix, 0, len(cfg.AllowedNetworks))
+	for _, network := range cfg.AllowedNetworks {
+		networks = append(networks, netip.MustParsePrefix(network))
+	}
+	return networks
+}
+
+func timeoutConfig(cfg config.RequestsConfig) middleware.TimeoutConfig {
+	routes := make(map[string]time.Duration, len(cfg.RouteTimeouts))
+	for route, d := range cfg.RouteTimeouts {
+		routes[route] = time.Duration(d)
+	}
+	return middleware.TimeoutConfig{Default: time.Duration(cfg.Timeout), Routes: routes}
+}
+
+func rateLimitConfig(cfg config.RateLimitConfig) middleware.RateLimitConfig {
+	return middleware.RateLimitConfig{
+		Read:        middleware.TokenBucket{Rate: cfg.Read.Rate, Burst: cfg.Read.Burst},
+		Write:       middleware.TokenBucket{Rate: cfg.Write.Rate, Burst: cfg.Write.Burst},
+		IdleTimeout: time.Duration(cfg.IdleTimeout),
+	}
+}
+
+func corsConfig(cfg config.CORSConfig) middleware.CORSConfig {
+	return middleware.CORSConfig{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           time.Duration(cfg.MaxAge),
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
 }
@@ -0,0 +1,75 @@
+// Package auth определяет принципала запроса и хранит API ключи, которые
+// можно заменять на лету.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"sync/atomic"
+)
+
+// Anonymous - принципал запросов, когда аутентификация отключена
+const Anonymous = "anonymous"
+
+type principalKey struct{}
+
+// WithPrincipal возвращает контекст с принципалом запроса
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext возвращает принципала запроса. Если он не задан,
+// возвращается Anonymous.
+func PrincipalFromContext(ctx context.Context) string {
+	if p, ok := ctx.Value(principalKey{}).(string); ok && p != "" {
+		return p
+	}
+	return Anonymous
+}
+
+// KeySet - набор API ключей с принципалами. Безопасен для конкурентного
+// использования; Update атомарно заменяет весь набор.
+type KeySet struct {
+	keys atomic.Pointer[[]keyEntry]
+}
+
+type keyEntry struct {
+	hash      [sha256.Size]byte
+	principal string
+}
+
+// NewKeySet создает набор из отображения ключ -> принципал
+func NewKeySet(keys map[string]string) *KeySet {
+	s := &KeySet{}
+	s.Update(keys)
+	return s
+}
+
+// Update заменяет набор ключей
+func (s *KeySet) Update(keys map[string]string) {
+	entries := make([]keyEntry, 0, len(keys))
+	for key, principal := range keys {
+		entries = append(entries, keyEntry{hash: sha256.Sum256([]byte(key)), principal: principal})
+	}
+	s.keys.Store(&entries)
+}
+
+// Enabled сообщает, что задан хотя бы один ключ
+func (s *KeySet) Enabled() bool {
+	return len(*s.keys.Load()) > 0
+}
+
+// Authenticate возвращает принципала для ключа. Ключи сравниваются по хешу
+// за постоянное время, чтобы не раскрывать их через тайминги.
+func (s *KeySet) Authenticate(key string) (string, bool) {
+	hash := sha256.Sum256([]byte(key))
+
+	principal, found := "", false
+	for _, e := range *s.keys.Load() {
+		if subtle.ConstantTimeCompare(hash[:], e.hash[:]) == 1 {
+			principal, found = e.principal, true
+		}
+	}
+	return principal, found
+}
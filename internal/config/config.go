@@ -0,0 +1,754 @@
+// Package config загружает настройки приложения. Источники применяются в
+// порядке возрастания приоритета:
+//
+//  1. значения по умолчанию (Default);
+//  2. файл конфигурации в формате JSON (флаг -config или TODO_CONFIG);
+//  3. переменные окружения TODO_<КЛЮЧ>, например TODO_SERVER_ADDR;
+//  4. флаги командной строки.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/internal/logging"
+)
+
+// EnvPrefix - префикс переменных окружения
+const EnvPrefix = "TODO_"
+
+// Драйверы хранилища
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// Config - полная конфигурация приложения
+type Config struct {
+	Server   ServerConfig   `json:"server"`
+	Log      LogConfig      `json:"log"`
+	Storage  StorageConfig  `json:"storage"`
+	Requests RequestsConfig `json:"requests"`
+	Tracing  TracingConfig  `json:"tracing"`
+	Auth     AuthConfig     `json:"auth"`
+
+	Idempotency IdempotencyConfig `json:"idempotency"`
+	RateLimit   RateLimitConfig   `json:"rate_limit"`
+	CORS        CORSConfig        `json:"cors"`
+	Compression CompressionConfig `json:"compression"`
+	Events      EventsConfig      `json:"events"`
+	WebSocket   WebSocketConfig   `json:"websocket"`
+	Webhooks    WebhooksConfig    `json:"webhooks"`
+}
+
+// ServerConfig - настройки HTTP сервера
+type ServerConfig struct {
+	Addr            string   `json:"addr"`
+	ReadTimeout     Duration `json:"read_timeout"`
+	WriteTimeout    Duration `json:"write_timeout"`
+	IdleTimeout     Duration `json:"idle_timeout"`
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	DrainDelay      Duration `json:"drain_delay"`
+}
+
+// LogConfig - настройки журнала
+type LogConfig struct {
+	Format string `json:"format"`
+	Level  string `json:"level"`
+}
+
+// StorageConfig - выбор и настройки хранилища задач
+type StorageConfig struct {
+	Driver       string `json:"driver"`
+	Path         string `json:"path"`
+	MinFreeBytes uint64 `json:"min_free_bytes"`
+}
+
+// RequestsConfig - ограничения обработки запросов. Ключи RouteTimeouts - пути
+// или префиксы путей, как в middleware.TimeoutConfig; 0 отключает таймаут.
+type RequestsConfig struct {
+	Timeout       Duration            `json:"timeout"`
+	RouteTimeouts map[string]Duration `json:"route_timeouts,omitempty"`
+	MaxBodyBytes  int64               `json:"max_body_bytes"`
+}
+
+// TracingConfig - настройки трассировки
+type TracingConfig struct {
+	File        string  `json:"file"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// AuthConfig - настройки аутентификации. APIKeys сопоставляет API ключ с
+// принципалом; пустой набор отключает проверку ключей.
+type AuthConfig struct {
+	APIKeys map[string]string `json:"api_keys,omitempty"`
+}
+
+// IdempotencyConfig - настройки ключей идемпотентности. TTL - сколько
+// хранится ответ на запрос с Idempotency-Key, MaxEntries - сколько ответов
+// хранится всего; при переполнении вытесняются самые старые.
+type IdempotencyConfig struct {
+	TTL        Duration `json:"ttl"`
+	MaxEntries int      `json:"max_entries"`
+}
+
+// RateLimitConfig - ограничение частоты запросов клиента отдельно для
+// чтения и изменения данных. Корзины, простаивающие IdleTimeout, удаляются.
+type RateLimitConfig struct {
+	Read        BucketConfig `json:"read"`
+	Write       BucketConfig `json:"write"`
+	IdleTimeout Duration     `json:"idle_timeout"`
+}
+
+// BucketConfig - корзина токенов: Rate запросов в секунду с запасом Burst.
+// Rate 0 снимает ограничение.
+type BucketConfig struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// CORSConfig - политика CORS для браузерных клиентов с другого origin.
+// AllowedOrigins содержит точные origin, шаблоны поддоменов вида
+// "https://*.example.com" или "*"; пустой список отключает CORS.
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           Duration `json:"max_age"`
+}
+
+// CompressionConfig - сжатие ответов gzip/deflate. Ответы короче MinSize байт
+// не сжимаются.
+type CompressionConfig struct {
+	Enabled bool `json:"enabled"`
+	MinSize int  `json:"min_size"`
+}
+
+// EventsConfig - поток изменений задач: сколько последних событий хранится
+// для возобновления и как часто отправляется пульс
+type EventsConfig struct {
+	ReplaySize int      `json:"replay_size"`
+	Heartbeat  Duration `json:"heartbeat"`
+}
+
+// WebSocketConfig - соединения /todos/ws: период ping и срок отправки одного
+// сообщения клиенту
+type WebSocketConfig struct {
+	PingInterval Duration `json:"ping_interval"`
+	WriteTimeout Duration `json:"write_timeout"`
+}
+
+// WebhooksConfig - доставка вебхуков: срок одной попытки, пауза перед
+// повтором (удваивается с каждой неудачей до MaxBackoff), число неудач
+// подряд до отключения подписки и период проверки очереди. AllowedNetworks -
+// внутренние сети в нотации CIDR, в которые разрешены вебхуки (например,
+// 127.0.0.0/8 для получателя на той же машине); остальные внутренние
+// адреса запрещены.
+type WebhooksConfig struct {
+	Timeout         Duration `json:"timeout"`
+	InitialBackoff  Duration `json:"initial_backoff"`
+	MaxBackoff      Duration `json:"max_backoff"`
+	MaxFailures     int      `json:"max_failures"`
+	PollInterval    Duration `json:"poll_interval"`
+	AllowedNetworks []string `json:"allowed_networks"`
+}
+
+// Default возвращает конфигурацию по умолчанию
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(15 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(30 * time.Second),
+			DrainDelay:      Duration(5 * time.Second),
+		},
+		Log: LogConfig{
+			Format: logging.FormatText,
+			Level:  "debug",
+		},
+		Storage: StorageConfig{
+			Driver:       StorageMemory,
+			Path:         "todos.json",
+			MinFreeBytes: 64 << 20,
+		},
+		Requests: RequestsConfig{
+			Timeout: Duration(30 * time.Second),
+			// Потоку событий и WebSocket таймаут обработки не подходит
+			RouteTimeouts: map[string]Duration{"/todos/events": 0, "/todos/ws": 0},
+			MaxBodyBytes:  1 << 20,
+		},
+		Tracing: TracingConfig{
+			SampleRatio: 1,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:        Duration(24 * time.Hour),
+			MaxEntries: 100000,
+		},
+		RateLimit: RateLimitConfig{
+			Read:        BucketConfig{Rate: 50, Burst: 100},
+			Write:       BucketConfig{Rate: 10, Burst: 20},
+			IdleTimeout: Duration(10 * time.Minute),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{
+				"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key",
+				"If-Match", "If-None-Match", "X-Request-ID", "X-Timezone", "traceparent", "tracestate",
+			},
+			ExposedHeaders: []string{
+				"ETag", "Location", "X-Request-ID", "Retry-After", "Idempotent-Replayed",
+				"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+			},
+			MaxAge: Duration(10 * time.Minute),
+		},
+		Compression: CompressionConfig{
+			Enabled: true,
+			MinSize: 1024,
+		},
+		Events: EventsConfig{
+			ReplaySize: 1024,
+			Heartbeat:  Duration(15 * time.Second),
+		},
+		WebSocket: WebSocketConfig{
+			PingInterval: Duration(30 * time.Second),
+			WriteTimeout: Duration(10 * time.Second),
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:         Duration(10 * time.Second),
+			InitialBackoff:  Duration(time.Second),
+			MaxBackoff:      Duration(time.Hour),
+			MaxFailures:     10,
+			PollInterval:    Duration(time.Second),
+			AllowedNetworks: []string{},
+		},
+	}
+}
+
+// Validate проверяет значения и возвращает все найденные ошибки
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Addr == "" {
+		add("server.addr must not be empty")
+	}
+	for key, d := range map[string]Duration{
+		"server.read_timeout":     c.Server.ReadTimeout,
+		"server.write_timeout":    c.Server.WriteTimeout,
+		"server.idle_timeout":     c.Server.IdleTimeout,
+		"server.shutdown_timeout": c.Server.ShutdownTimeout,
+		"server.drain_delay":      c.Server.DrainDelay,
+		"requests.timeout":        c.Requests.Timeout,
+	} {
+		if d < 0 {
+			add("%s must not be negative", key)
+		}
+	}
+	for key, b := range map[string]BucketConfig{"rate_limit.read": c.RateLimit.Read, "rate_limit.write": c.RateLimit.Write} {
+		if b.Rate < 0 {
+			add("%s.rate must not be negative", key)
+		}
+		if b.Rate > 0 && b.Burst < 1 {
+			add("%s.burst must be at least 1", key)
+		}
+	}
+	if c.RateLimit.IdleTimeout <= 0 {
+		add("rate_limit.idle_timeout must be positive")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				add("cors.allowed_origins: * cannot be combined with cors.allow_credentials")
+			}
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || (scheme != "http" && scheme != "https") || host == "" ||
+			strings.Contains(strings.TrimPrefix(host, "*."), "*") || strings.Contains(host, "/") {
+			add("cors.allowed_origins: invalid origin %q", origin)
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		add("cors.max_age must not be negative")
+	}
+	if c.Events.ReplaySize <= 0 {
+		add("events.replay_size must be positive")
+	}
+	if c.Events.Heartbeat <= 0 {
+		add("events.heartbeat must be positive")
+	}
+	if c.WebSocket.PingInterval <= 0 {
+		add("websocket.ping_interval must be positive")
+	}
+	if c.WebSocket.WriteTimeout <= 0 {
+		add("websocket.write_timeout must be positive")
+	}
+	for key, d := range map[string]Duration{
+		"webhooks.timeout":         c.Webhooks.Timeout,
+		"webhooks.initial_backoff": c.Webhooks.InitialBackoff,
+		"webhooks.max_backoff":     c.Webhooks.MaxBackoff,
+		"webhooks.poll_interval":   c.Webhooks.PollInterval,
+	} {
+		if d <= 0 {
+			add("%s must be positive", key)
+		}
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.InitialBackoff {
+		add("webhooks.max_backoff must not be less than webhooks.initial_backoff")
+	}
+	if c.Webhooks.MaxFailures < 1 {
+		add("webhooks.max_failures must be at least 1")
+	}
+	for _, network := range c.Webhooks.AllowedNetworks {
+		if _, err := netip.ParsePrefix(network); err != nil {
+			add("webhooks.allowed_networks: %q is not a CIDR network", network)
+		}
+	}
+	if c.Compression.MinSize < 0 {
+		add("compression.min_size must not be negative")
+	}
+	if c.Idempotency.TTL <= 0 {
+		add("idempotency.ttl must be positive")
+	}
+	if c.Idempotency.MaxEntries <= 0 {
+		add("idempotency.max_entries must be positive")
+	}
+	if c.Requests.MaxBodyBytes <= 0 {
+		add("requests.max_body_bytes must be positive")
+	}
+	for route, d := range c.Requests.RouteTimeouts {
+		if !strings.HasPrefix(route, "/") {
+			add("requests.route_timeouts: route %q must start with /", route)
+		}
+		if d < 0 {
+			add("requests.route_timeouts: timeout for %q must not be negative", route)
+		}
+	}
+
+	if f := strings.ToLower(c.Log.Format); f != logging.FormatText && f != logging.FormatJSON {
+		add("log.format must be %q or %q, got %q", logging.FormatText, logging.FormatJSON, c.Log.Format)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		add("log.level: %v", err)
+	}
+
+	switch c.Storage.Driver {
+	case StorageMemory:
+	case StorageFile:
+		if c.Storage.Path == "" {
+			add("storage.path is required for the %q driver", StorageFile)
+		}
+	default:
+		add("storage.driver must be %q or %q, got %q", StorageMemory, StorageFile, c.Storage.Driver)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+
+	for key, principal := range c.Auth.APIKeys {
+		if key == "" || principal == "" {
+			add("auth.api_keys: keys and principals must not be empty")
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Load собирает конфигурацию из всех источников. args - аргументы командной
+// строки без имени программы, env - функция чтения окружения (os.LookupEnv).
+// Если указан --print-config, printConfig возвращается равным true.
+func Load(args []string, env func(string) (string, bool)) (cfg Config, printConfig bool, err error) {
+	cfg = Default()
+
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a JSON config file (env "+EnvPrefix+"CONFIG)")
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective configuration and exit")
+
+	// Флаги применяются последними, поэтому сначала только запоминаем их
+	defaults := Default()
+	deferred := make(map[string]*deferredValue)
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		value := f.bind(&defaults)
+		b, isBool := value.(interface{ IsBoolFlag() bool })
+		d := &deferredValue{value: value.String(), isBool: isBool && b.IsBoolFlag()}
+		deferred[f.flag] = d
+		fs.Var(d, f.flag, f.usage+" (env "+envName(f.key)+")")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, false, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, false, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	path := *configPath
+	if path == "" {
+		path, _ = env(EnvPrefix + "CONFIG")
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, false, err
+		}
+	}
+
+	for _, f := range fields {
+		if value, ok := env(envName(f.key)); ok {
+			if err := f.bind(&cfg).Set(value); err != nil {
+				return cfg, false, fmt.Errorf("%s: %w", envName(f.key), err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		d, ok := deferred[fl.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		for _, f := range fields {
+			if f.flag == fl.Name {
+				if err := f.bind(&cfg).Set(d.value); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", fl.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, false, flagErr
+	}
+
+	return cfg, printConfig, cfg.Validate()
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("parse config %s: unexpected data after the top-level object", path)
+	}
+	return nil
+}
+
+// redacted заменяет секреты при выводе конфигурации
+const redacted = "<redacted>"
+
+// Print выводит конфигурацию в формате файла конфигурации. Значения API
+// ключей скрываются.
+func (c Config) Print(w io.Writer) error {
+	if len(c.Auth.APIKeys) > 0 {
+		keys := make(map[string]string, len(c.Auth.APIKeys))
+		i := 0
+		for _, principal := range c.Auth.APIKeys {
+			i++
+			keys[fmt.Sprintf("%s-%d", redacted, i)] = principal
+		}
+		c.Auth.APIKeys = keys
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// reloadable - ключи, которые можно менять без перезапуска
+var reloadable = map[string]bool{
+	"log.level":               true,
+	"requests.timeout":        true,
+	"requests.route_timeouts": true,
+	"auth.api_keys":           true,
+	"rate_limit.read":         true,
+	"rate_limit.write":        true,
+	"rate_limit.idle_timeout": true,
+	"cors.allowed_origins":    true,
+	"cors.allowed_methods":    true,
+	"cors.allowed_headers":    true,
+	"cors.exposed_headers":    true,
+	"cors.allow_credentials":  true,
+	"cors.max_age":            true,
+	"server.shutdown_timeout": true,
+	"server.drain_delay":      true,
+}
+
+// Changes сравнивает конфигурации и возвращает измененные ключи, разделив
+// их на применимые на лету и требующие перезапуска
+func Changes(old, new Config) (live, restart []string) {
+	before, after := flatten(old), flatten(new)
+
+	keys := make([]string, 0, len(after))
+	for key := range after {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if before[key] == after[key] {
+			continue
+		}
+		if reloadable[key] {
+			live = append(live, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	return live, restart
+}
+
+// flatten представляет конфигурацию как ключ раздела.поле -> JSON значение
+func flatten(c Config) map[string]string {
+	data, _ := json.Marshal(c)
+
+	var sections map[string]map[string]json.RawMessage
+	json.Unmarshal(data, &sections)
+
+	out := make(map[string]string)
+	for section, fields := range sections {
+		for name, value := range fields {
+			out[section+"."+name] = string(value)
+		}
+	}
+	// Поля с omitempty могут отсутствовать: пустое значение тоже сравниваем
+	for key := range reloadable {
+		if _, ok := out[key]; !ok {
+			out[key] = ""
+		}
+	}
+	return out
+}
+
+// field описывает настройку, задаваемую через окружение и флаг
+type field struct {
+	key   string
+	flag  string
+	usage string
+	bind  func(c *Config) flag.Value
+}
+
+var fields = []field{
+	{"server.addr", "addr", "HTTP listen address", func(c *Config) flag.Value { return (*stringValue)(&c.Server.Addr) }},
+	{"server.read_timeout", "read-timeout", "maximum duration for reading a request", func(c *Config) flag.Value { return &c.Server.ReadTimeout }},
+	{"server.write_timeout", "write-timeout", "maximum duration for writing a response", func(c *Config) flag.Value { return &c.Server.WriteTimeout }},
+	{"server.idle_timeout", "idle-timeout", "keep-alive idle timeout", func(c *Config) flag.Value { return &c.Server.IdleTimeout }},
+	{"server.shutdown_timeout", "shutdown-timeout", "how long graceful shutdown waits for in-flight requests", func(c *Config) flag.Value { return &c.Server.ShutdownTimeout }},
+	{"server.drain_delay", "shutdown-drain-delay", "how long /readyz reports failure before the server stops accepting requests", func(c *Config) flag.Value { return &c.Server.DrainDelay }},
+	{"log.format", "log-format", "log output format: text or json", func(c *Config) flag.Value { return (*stringValue)(&c.Log.Format) }},
+	{"log.level", "log-level", "minimum log level: debug, info, warn or error", func(c *Config) flag.Value { return (*stringValue)(&c.Log.Level) }},
+	{"storage.driver", "storage", "todo storage: memory or file", func(c *Config) flag.Value { return (*stringValue)(&c.Storage.Driver) }},
+	{"storage.path", "storage-path", "data file for the file storage", func(c *Config) flag.Value { return (*stringValue)(&c.Storage.Path) }},
+	{"storage.min_free_bytes", "storage-min-free-bytes", "readiness fails when less disk space is free", func(c *Config) flag.Value { return (*uint64Value)(&c.Storage.MinFreeBytes) }},
+	{"requests.timeout", "request-timeout", "default request processing timeout, 0 disables", func(c *Config) flag.Value { return &c.Requests.Timeout }},
+	{"requests.max_body_bytes", "max-body-bytes", "maximum size of a request body", func(c *Config) flag.Value { return (*int64Value)(&c.Requests.MaxBodyBytes) }},
+	{"tracing.file", "trace-file", "write finished spans as OTLP JSON lines to this file", func(c *Config) flag.Value { return (*stringValue)(&c.Tracing.File) }},
+	{"idempotency.ttl", "idempotency-ttl", "how long responses to requests with Idempotency-Key are kept", func(c *Config) flag.Value { return &c.Idempotency.TTL }},
+	{"idempotency.max_entries", "idempotency-max-entries", "maximum number of stored responses to requests with Idempotency-Key", func(c *Config) flag.Value { return (*intValue)(&c.Idempotency.MaxEntries) }},
+	{"rate_limit.read.rate", "rate-limit-read", "read requests per second per client, 0 disables", func(c *Config) flag.Value { return (*float64Value)(&c.RateLimit.Read.Rate) }},
+	{"rate_limit.read.burst", "rate-limit-read-burst", "read request burst per client", func(c *Config) flag.Value { return (*intValue)(&c.RateLimit.Read.Burst) }},
+	{"rate_limit.write.rate", "rate-limit-write", "write requests per second per client, 0 disables", func(c *Config) flag.Value { return (*float64Value)(&c.RateLimit.Write.Rate) }},
+	{"rate_limit.write.burst", "rate-limit-write-burst", "write request burst per client", func(c *Config) flag.Value { return (*intValue)(&c.RateLimit.Write.Burst) }},
+	{"rate_limit.idle_timeout", "rate-limit-idle-timeout", "forget clients idle for this long", func(c *Config) flag.Value { return &c.RateLimit.IdleTimeout }},
+	{"cors.allowed_origins", "cors-origins", "comma-separated origins allowed to call the API, e.g. https://*.example.com", func(c *Config) flag.Value { return (*listValue)(&c.CORS.AllowedOrigins) }},
+	{"cors.allowed_methods", "", "comma-separated methods allowed in CORS requests", func(c *Config) flag.Value { return (*listValue)(&c.CORS.AllowedMethods) }},
+	{"cors.allowed_headers", "", "comma-separated request headers allowed in CORS requests", func(c *Config) flag.Value { return (*listValue)(&c.CORS.AllowedHeaders) }},
+	{"cors.exposed_headers", "", "comma-separated response headers exposed to browsers", func(c *Config) flag.Value { return (*listValue)(&c.CORS.ExposedHeaders) }},
+	{"cors.allow_credentials", "cors-allow-credentials", "allow cookies and Authorization in CORS requests", func(c *Config) flag.Value { return (*boolValue)(&c.CORS.AllowCredentials) }},
+	{"cors.max_age", "cors-max-age", "how long browsers may cache preflight responses", func(c *Config) flag.Value { return &c.CORS.MaxAge }},
+	{"compression.enabled", "compress", "compress responses with gzip or deflate", func(c *Config) flag.Value { return (*boolValue)(&c.Compression.Enabled) }},
+	{"compression.min_size", "compress-min-size", "do not compress responses smaller than this many bytes", func(c *Config) flag.Value { return (*intValue)(&c.Compression.MinSize) }},
+	{"events.replay_size", "events-replay-size", "how many recent events are kept for stream resumption", func(c *Config) flag.Value { return (*intValue)(&c.Events.ReplaySize) }},
+	{"events.heartbeat", "events-heartbeat", "interval of keep-alive comments in the event stream", func(c *Config) flag.Value { return &c.Events.Heartbeat }},
+	{"websocket.ping_interval", "ws-ping-interval", "interval of WebSocket pings; silent connections are closed after two intervals", func(c *Config) flag.Value { return &c.WebSocket.PingInterval }},
+	{"websocket.write_timeout", "ws-write-timeout", "deadline for sending one WebSocket message", func(c *Config) flag.Value { return &c.WebSocket.WriteTimeout }},
+	{"webhooks.timeout", "webhook-timeout", "deadline for one webhook delivery attempt", func(c *Config) flag.Value { return &c.Webhooks.Timeout }},
+	{"webhooks.initial_backoff", "webhook-initial-backoff", "delay before the first webhook retry, doubled after each failure", func(c *Config) flag.Value { return &c.Webhooks.InitialBackoff }},
+	{"webhooks.max_backoff", "webhook-max-backoff", "maximum delay between webhook retries", func(c *Config) flag.Value { return &c.Webhooks.MaxBackoff }},
+	{"webhooks.max_failures", "webhook-max-failures", "consecutive failed deliveries after which a webhook is disabled", func(c *Config) flag.Value { return (*intValue)(&c.Webhooks.MaxFailures) }},
+	{"webhooks.poll_interval", "webhook-poll-interval", "how often the webhook outbox and retry schedule are checked", func(c *Config) flag.Value { return &c.Webhooks.PollInterval }},
+	{"webhooks.allowed_networks", "webhook-allowed-networks", "comma-separated internal CIDR networks webhooks may target, e.g. 127.0.0.0/8", func(c *Config) flag.Value { return (*listValue)(&c.Webhooks.AllowedNetworks) }},
+	{"auth.api_keys", "", "comma-separated key=principal pairs", func(c *Config) flag.Value { return (*keyValues)(&c.Auth.APIKeys) }},
+	{"tracing.sample_ratio", "trace-sample-ratio", "fraction of new traces to record, 0..1", func(c *Config) flag.Value { return (*float64Value)(&c.Tracing.SampleRatio) }},
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// deferredValue запоминает значение флага до применения
+type deferredValue struct {
+	value  string
+	isBool bool
+}
+
+func (d *deferredValue) String() string     { return d.value }
+func (d *deferredValue) IsBoolFlag() bool   { return d.isBool }
+func (d *deferredValue) Set(s string) error { d.value = s; return nil }
+
+type stringValue string
+
+func (v *stringValue) String() string     { return string(*v) }
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+
+type uint64Value uint64
+
+func (v *uint64Value) String() string { return strconv.FormatUint(uint64(*v), 10) }
+func (v *uint64Value) Set(s string) error {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = uint64Value(n)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+// listValue разбирает список через запятую
+type listValue []string
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+func (v *listValue) Set(s string) error {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*v = list
+	return nil
+}
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+type int64Value int64
+
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+func (v *int64Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = int64Value(n)
+	return nil
+}
+
+type float64Value float64
+
+func (v *float64Value) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+func (v *float64Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = float64Value(f)
+	return nil
+}
+
+// keyValues разбирает список вида "k1=v1,k2=v2"
+type keyValues map[string]string
+
+func (v *keyValues) String() string {
+	pairs := make([]string, 0, len(*v))
+	for key, value := range *v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v *keyValues) Set(s string) error {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	*v = m
+	return nil
+}
+
+// Duration - time.Duration, который в файле конфигурации записывается
+// строкой вида "30s" или "1m30s"
+type Duration time.Duration
+
+// String форматирует длительность
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// Set разбирает длительность (реализует flag.Value)
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON записывает длительность строкой
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON читает длительность из строки
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	return d.Set(s)
+}
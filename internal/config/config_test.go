@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func envFrom(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, printConfig, err := Load(nil, envFrom(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if printConfig {
+		t.Error("expected printConfig to be false")
+	}
+	if cfg.Server.Addr != ":8080" || time.Duration(cfg.Requests.Timeout) != 30*time.Second {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, `{
+		"server": {"addr": ":7000", "read_timeout": "5s"},
+		"log": {"level": "warn", "format": "json"},
+		"requests": {"route_timeouts": {"/todos/events": "0s"}}
+	}`)
+
+	env := envFrom(map[string]string{
+		"TODO_CONFIG":      path,
+		"TODO_SERVER_ADDR": ":7001",
+		"TODO_LOG_LEVEL":   "error",
+	})
+
+	cfg, _, err := Load([]string{"-addr", ":7002", "--print-config"}, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Флаг важнее окружения, окружение важнее файла, файл важнее умолчаний
+	if cfg.Server.Addr != ":7002" {
+		t.Errorf("expected flag to win, got addr %q", cfg.Server.Addr)
+	}
+	if cfg.Log.Level != "error" {
+		t.Errorf("expected env to win over file, got level %q", cfg.Log.Level)
+	}
+	if cfg.Log.Format != "json" || time.Duration(cfg.Server.ReadTimeout) != 5*time.Second {
+		t.Errorf("expected file values, got %+v", cfg)
+	}
+	if time.Duration(cfg.Server.WriteTimeout) != 15*time.Second {
+		t.Errorf("expected default write timeout, got %s", cfg.Server.WriteTimeout)
+	}
+	if d, ok := cfg.Requests.RouteTimeouts["/todos/events"]; !ok || d != 0 {
+		t.Errorf("expected route timeout from file, got %v", cfg.Requests.RouteTimeouts)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr string
+	}{
+		{name: "неизвестное поле в файле", file: `{"server": {"adr": ":1"}}`, wantErr: "unknown field"},
+		{name: "данные после объекта", file: `{} {}`, wantErr: "unexpected data"},
+		{name: "некорректная длительность", env: map[string]string{"TODO_REQUESTS_TIMEOUT": "soon"}, wantErr: "invalid duration"},
+		{name: "некорректный флаг", args: []string{"-trace-sample-ratio", "x"}, wantErr: "invalid number"},
+		{name: "неизвестный драйвер", args: []string{"-storage", "redis"}, wantErr: "storage.driver"},
+		{name: "неизвестный уровень", args: []string{"-log-level", "loud"}, wantErr: "log.level"},
+		{name: "доля вне диапазона", args: []string{"-trace-sample-ratio", "2"}, wantErr: "sample_ratio"},
+		{name: "отрицательный таймаут", args: []string{"-read-timeout", "-1s"}, wantErr: "server.read_timeout"},
+		{name: "сеть вебхуков не в CIDR", args: []string{"-webhook-allowed-networks", "127.0.0.1"}, wantErr: "webhooks.allowed_networks"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := map[string]string{}
+			for k, v := range tc.env {
+				env[k] = v
+			}
+			if tc.file != "" {
+				env["TODO_CONFIG"] = writeConfig(t, tc.file)
+			}
+
+			_, _, err := Load(tc.args, envFrom(env))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_PrintRoundTrip(t *testing.T) {
+	cfg := Default()
+	cfg.Requests.RouteTimeouts = map[string]Duration{"/slow/": Duration(time.Minute)}
+
+	var buf strings.Builder
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, _, err := Load([]string{"-config", writeConfig(t, buf.String())}, envFrom(nil))
+	if err != nil {
+		t.Fatalf("printed config must load back: %v", err)
+	}
+	if time.Duration(loaded.Requests.RouteTimeouts["/slow/"]) != time.Minute {
+		t.Errorf("unexpected route timeouts %v", loaded.Requests.RouteTimeouts)
+	}
+}
+
+func TestLoad_APIKeysFromEnv(t *testing.T) {
+	cfg, _, err := Load(nil, envFrom(map[string]string{"TODO_AUTH_API_KEYS": "k1=alice, k2=bob"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Auth.APIKeys["k1"] != "alice" || cfg.Auth.APIKeys["k2"] != "bob" {
+		t.Errorf("unexpected api keys %v", cfg.Auth.APIKeys)
+	}
+
+	var buf strings.Builder
+	cfg.Print(&buf)
+	if strings.Contains(buf.String(), "k1") || !strings.Contains(buf.String(), "alice") {
+		t.Errorf("printed config must hide keys but keep principals:\n%s", buf.String())
+	}
+}
+
+func TestChanges(t *testing.T) {
+	old := Default()
+
+	next := Default()
+	next.Log.Level = "warn"
+	next.Auth.APIKeys = map[string]string{"secret": "alice"}
+	next.Requests.RouteTimeouts = map[string]Duration{"/slow/": Duration(time.Minute)}
+
+	live, restart := Changes(old, next)
+	if strings.Join(live, ",") != "auth.api_keys,log.level,requests.route_timeouts" {
+		t.Errorf("unexpected live changes %v", live)
+	}
+	if len(restart) != 0 {
+		t.Errorf("unexpected restart changes %v", restart)
+	}
+
+	next.Server.Addr = ":9090"
+	next.Storage.Driver = StorageFile
+	_, restart = Changes(old, next)
+	if strings.Join(restart, ",") != "server.addr,storage.driver" {
+		t.Errorf("unexpected restart changes %v", restart)
+	}
+
+	if live, restart := Changes(old, Default()); len(live)+len(restart) != 0 {
+		t.Errorf("expected no changes, got %v %v", live, restart)
+	}
+}
+
+func TestLoad_CORS(t *testing.T) {
+	cfg, _, err := Load([]string{"-cors-origins", "https://a.com, https://*.b.com", "-cors-allow-credentials"}, envFrom(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.CORS.AllowedOrigins) != 2 || cfg.CORS.AllowedOrigins[1] != "https://*.b.com" || !cfg.CORS.AllowCredentials {
+		t.Errorf("unexpected cors config %+v", cfg.CORS)
+	}
+
+	for _, origins := range []string{"*", "a.com", "https://*.*.b.com", "ftp://a.com"} {
+		_, _, err := Load([]string{"-cors-origins", origins, "-cors-allow-credentials"}, envFrom(nil))
+		if err == nil || !strings.Contains(err.Error(), "cors.allowed_origins") {
+			t.Errorf("expected %q to be rejected, got %v", origins, err)
+		}
+	}
+}
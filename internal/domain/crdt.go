@@ -0,0 +1,382 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Модель слияния реплик задачи, которые правились независимо (например, на
+// двух устройствах без связи). Каждое поле - отдельный LWW регистр с меткой
+// гибридных логических часов, поэтому правки разных полей не теряются, а
+// теги - OR-set, в котором параллельные добавление и удаление тега не
+// затирают друг друга. Merge коммутативен, ассоциативен и идемпотентен:
+// реплики, получившие одни и те же изменения в любом порядке и с повторами,
+// сходятся к одному состоянию.
+
+// Timestamp - метка гибридных логических часов (HLC): физическое время в
+// наносекундах, логический счетчик для событий внутри одного тика и
+// идентификатор реплики, который делает метки разных реплик различимыми.
+// Каждая запись получает уникальную метку - на этом держится
+// детерминированность слияния.
+type Timestamp struct {
+	Wall    int64  `json:"wall"`
+	Logical uint32 `json:"logical"`
+	Node    string `json:"node"`
+}
+
+// Compare упорядочивает метки: по физическому времени, затем по счетчику,
+// затем по реплике
+func (t Timestamp) Compare(u Timestamp) int {
+	if c := cmp.Compare(t.Wall, u.Wall); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(t.Logical, u.Logical); c != 0 {
+		return c
+	}
+	return cmp.Compare(t.Node, u.Node)
+}
+
+// IsZero сообщает, что метка не задана
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+// Clock - гибридные логические часы реплики. Метки монотонно растут, даже
+// если системное время идет назад, и остаются близки к физическому времени,
+// поэтому "последняя запись" совпадает с интуитивной там, где часы
+// устройств примерно синхронны. Безопасны для конкурентного использования.
+type Clock struct {
+	mu   sync.Mutex
+	node string
+	now  func() time.Time
+	last Timestamp
+}
+
+// NewClock создает часы реплики node. now - источник физического времени,
+// nil означает time.Now.
+func NewClock(node string, now func() time.Time) *Clock {
+	if now == nil {
+		now = time.Now
+	}
+	return &Clock{node: node, now: now, last: Timestamp{Node: node}}
+}
+
+// Now возвращает метку для локального изменения
+func (c *Clock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if wall := c.now().UnixNano(); wall > c.last.Wall {
+		c.last.Wall, c.last.Logical = wall, 0
+	} else {
+		c.last.Logical++
+	}
+	return c.last
+}
+
+// Observe учитывает метку, полученную от другой реплики, чтобы следующие
+// локальные метки были больше нее
+func (c *Clock) Observe(remote Timestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wall := max(c.last.Wall, remote.Wall, c.now().UnixNano())
+	switch {
+	case wall == c.last.Wall && wall == remote.Wall:
+		c.last.Logical = max(c.last.Logical, remote.Logical) + 1
+	case wall == c.last.Wall:
+		c.last.Logical++
+	case wall == remote.Wall:
+		c.last.Logical = remote.Logical + 1
+	default:
+		c.last.Logical = 0
+	}
+	c.last.Wall = wall
+}
+
+// LWW - регистр "побеждает последняя запись": значение с наибольшей меткой
+type LWW[T any] struct {
+	Value T         `json:"value"`
+	Time  Timestamp `json:"time"`
+}
+
+// Set записывает значение, если метка новее текущей
+func (r *LWW[T]) Set(value T, ts Timestamp) {
+	if ts.Compare(r.Time) > 0 {
+		r.Value, r.Time = value, ts
+	}
+}
+
+// Merge возвращает регистр с более новой записью. Метка однозначно
+// определяет запись, поэтому при равных метках значения совпадают.
+func (r LWW[T]) Merge(other LWW[T]) LWW[T] {
+	if other.Time.Compare(r.Time) > 0 {
+		return other
+	}
+	return r
+}
+
+// ORTag - добавление элемента в OR-set, помеченное меткой записи
+type ORTag struct {
+	Elem string    `json:"elem"`
+	Time Timestamp `json:"time"`
+}
+
+func compareORTags(a, b ORTag) int {
+	if c := cmp.Compare(a.Elem, b.Elem); c != 0 {
+		return c
+	}
+	return a.Time.Compare(b.Time)
+}
+
+// ORSet - множество строк с наблюдаемым удалением (observed-remove set).
+// Удаление отменяет только те добавления элемента, которые реплика уже
+// видела, поэтому при параллельных добавлении и удалении побеждает
+// добавление. Adds хранит живые добавления, Removes - отмененные; оба
+// списка отсортированы и без повторов, так что равные множества равны и
+// побайтно.
+type ORSet struct {
+	Adds    []ORTag `json:"adds,omitempty"`
+	Removes []ORTag `json:"removes,omitempty"`
+}
+
+// Add добавляет элемент
+func (s *ORSet) Add(elem string, ts Timestamp) {
+	tag := ORTag{Elem: elem, Time: ts}
+	if _, removed := slices.BinarySearchFunc(s.Removes, tag, compareORTags); removed {
+		return
+	}
+	if i, found := slices.BinarySearchFunc(s.Adds, tag, compareORTags); !found {
+		s.Adds = slices.Insert(s.Adds, i, tag)
+	}
+}
+
+// Remove удаляет элемент, отменяя все его добавления, видимые реплике
+func (s *ORSet) Remove(elem string) {
+	kept := s.Adds[:0:0]
+	for _, tag := range s.Adds {
+		if tag.Elem == elem {
+			s.Removes = insertORTag(s.Removes, tag)
+		} else {
+			kept = append(kept, tag)
+		}
+	}
+	s.Adds = nilIfEmpty(kept)
+}
+
+// Contains сообщает, есть ли элемент в множестве
+func (s ORSet) Contains(elem string) bool {
+	i, _ := slices.BinarySearchFunc(s.Adds, ORTag{Elem: elem}, compareORTags)
+	return i < len(s.Adds) && s.Adds[i].Elem == elem
+}
+
+// Elems возвращает элементы множества по возрастанию
+func (s ORSet) Elems() []string {
+	var elems []string
+	for _, tag := range s.Adds {
+		if len(elems) == 0 || elems[len(elems)-1] != tag.Elem {
+			elems = append(elems, tag.Elem)
+		}
+	}
+	return elems
+}
+
+// Merge объединяет множества: добавления и удаления обеих реплик, за
+// вычетом отмененных добавлений
+func (s ORSet) Merge(other ORSet) ORSet {
+	removes := unionORTags(s.Removes, other.Removes)
+	var adds []ORTag
+	for _, tag := range unionORTags(s.Adds, other.Adds) {
+		if _, removed := slices.BinarySearchFunc(removes, tag, compareORTags); !removed {
+			adds = append(adds, tag)
+		}
+	}
+	return ORSet{Adds: adds, Removes: removes}
+}
+
+func insertORTag(tags []ORTag, tag ORTag) []ORTag {
+	i, found := slices.BinarySearchFunc(tags, tag, compareORTags)
+	if found {
+		return tags
+	}
+	return slices.Insert(tags, i, tag)
+}
+
+// unionORTags сливает два отсортированных списка без повторов
+func unionORTags(a, b []ORTag) []ORTag {
+	out := make([]ORTag, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch c := compareORTags(a[0], b[0]); {
+		case c < 0:
+			out, a = append(out, a[0]), a[1:]
+		case c > 0:
+			out, b = append(out, b[0]), b[1:]
+		default:
+			out, a, b = append(out, a[0]), a[1:], b[1:]
+		}
+	}
+	out = append(append(out, a...), b...)
+	return nilIfEmpty(out)
+}
+
+func nilIfEmpty[T any](s []T) []T {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// ReplicatedTodo - реплика задачи для слияния независимых правок. Удаление
+// необратимо: задача, удаленная хотя бы на одной реплике, после слияния
+// удалена, даже если параллельно ее правили.
+type ReplicatedTodo struct {
+	ID          int             `json:"id"`
+	Title       LWW[string]     `json:"title"`
+	Description LWW[string]     `json:"description"`
+	Completed   LWW[bool]       `json:"completed"`
+	DueDate     LWW[*time.Time] `json:"due_date"`
+	Project     LWW[string]     `json:"project"`
+	Tags        ORSet           `json:"tags"`
+	Priority    LWW[Priority]   `json:"priority"`
+	Recurrence  LWW[string]     `json:"recurrence"`
+	Deleted     LWW[bool]       `json:"deleted"`
+}
+
+// NewReplicatedTodo создает реплику задачи, все поля которой записаны с
+// меткой ts
+func NewReplicatedTodo(todo *Todo, ts Timestamp) *ReplicatedTodo {
+	r := &ReplicatedTodo{ID: todo.ID}
+	r.Title.Set(todo.Title, ts)
+	r.Description.Set(todo.Description, ts)
+	r.Completed.Set(todo.Completed, ts)
+	r.DueDate.Set(cloneTime(todo.DueDate), ts)
+	r.Project.Set(todo.Project, ts)
+	r.Priority.Set(todo.Priority, ts)
+	r.Recurrence.Set(todo.Recurrence, ts)
+	for _, tag := range todo.Tags {
+		r.Tags.Add(tag, ts)
+	}
+	return r
+}
+
+// Edit записывает локальную правку: поля, отличающиеся от текущего
+// состояния, получают метку ts, новые теги добавляются, отсутствующие в
+// todo - удаляются. Неизмененные поля сохраняют старые метки, поэтому не
+// перекрывают параллельные правки этих полей на других репликах.
+func (r *ReplicatedTodo) Edit(todo *Todo, ts Timestamp) {
+	if todo.Title != r.Title.Value {
+		r.Title.Set(todo.Title, ts)
+	}
+	if todo.Description != r.Description.Value {
+		r.Description.Set(todo.Description, ts)
+	}
+	if todo.Completed != r.Completed.Value {
+		r.Completed.Set(todo.Completed, ts)
+	}
+	if !equalTime(todo.DueDate, r.DueDate.Value) {
+		r.DueDate.Set(cloneTime(todo.DueDate), ts)
+	}
+	if todo.Project != r.Project.Value {
+		r.Project.Set(todo.Project, ts)
+	}
+	if todo.Priority != r.Priority.Value {
+		r.Priority.Set(todo.Priority, ts)
+	}
+	if todo.Recurrence != r.Recurrence.Value {
+		r.Recurrence.Set(todo.Recurrence, ts)
+	}
+
+	for _, tag := range r.Tags.Elems() {
+		if !todo.HasTag(tag) {
+			r.Tags.Remove(tag)
+		}
+	}
+	for _, tag := range todo.Tags {
+		if !r.Tags.Contains(tag) {
+			r.Tags.Add(tag, ts)
+		}
+	}
+}
+
+// Clone возвращает независимую копию реплики
+func (r *ReplicatedTodo) Clone() *ReplicatedTodo {
+	return Merge(r, r)
+}
+
+// Latest возвращает наибольшую метку записей реплики
+func (r *ReplicatedTodo) Latest() Timestamp {
+	latest := Timestamp{}
+	for _, ts := range []Timestamp{
+		r.Title.Time, r.Description.Time, r.Completed.Time, r.DueDate.Time, r.Project.Time,
+		r.Priority.Time, r.Recurrence.Time, r.Deleted.Time,
+	} {
+		if ts.Compare(latest) > 0 {
+			latest = ts
+		}
+	}
+	for _, tags := range [][]ORTag{r.Tags.Adds, r.Tags.Removes} {
+		for _, tag := range tags {
+			if tag.Time.Compare(latest) > 0 {
+				latest = tag.Time
+			}
+		}
+	}
+	return latest
+}
+
+// Delete помечает задачу удаленной
+func (r *ReplicatedTodo) Delete(ts Timestamp) {
+	r.Deleted.Set(true, ts)
+}
+
+// Todo возвращает текущее состояние реплики. Теги упорядочены по алфавиту:
+// OR-set порядка не хранит.
+func (r *ReplicatedTodo) Todo() *Todo {
+	return &Todo{
+		ID:          r.ID,
+		Title:       r.Title.Value,
+		Description: r.Description.Value,
+		Completed:   r.Completed.Value,
+		DueDate:     cloneTime(r.DueDate.Value),
+		Project:     r.Project.Value,
+		Tags:        r.Tags.Elems(),
+		Priority:    r.Priority.Value,
+		Recurrence:  r.Recurrence.Value,
+	}
+}
+
+// Merge сливает две реплики одной задачи. Результат не разделяет состояние
+// с аргументами, которые не изменяются.
+func Merge(a, b *ReplicatedTodo) *ReplicatedTodo {
+	m := &ReplicatedTodo{
+		ID:          a.ID,
+		Title:       a.Title.Merge(b.Title),
+		Description: a.Description.Merge(b.Description),
+		Completed:   a.Completed.Merge(b.Completed),
+		DueDate:     a.DueDate.Merge(b.DueDate),
+		Project:     a.Project.Merge(b.Project),
+		Tags:        a.Tags.Merge(b.Tags),
+		Priority:    a.Priority.Merge(b.Priority),
+		Recurrence:  a.Recurrence.Merge(b.Recurrence),
+		Deleted:     a.Deleted.Merge(b.Deleted),
+	}
+	m.DueDate.Value = cloneTime(m.DueDate.Value)
+	return m
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
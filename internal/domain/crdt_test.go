@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"math/rand"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// fakeNow возвращает источник времени, который идет по заданным шагам:
+// часы устройств могут стоять и идти назад
+func fakeNow(steps ...time.Duration) func() time.Time {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		if len(steps) > 0 {
+			now, steps = now.Add(steps[0]), steps[1:]
+		}
+		return now
+	}
+}
+
+func TestClock(t *testing.T) {
+	c := NewClock("a", fakeNow(time.Second, 0, -time.Minute, time.Second))
+
+	var prev Timestamp
+	for i := range 4 {
+		ts := c.Now()
+		if ts.Compare(prev) <= 0 || ts.Node != "a" {
+			t.Fatalf("step %d: %+v is not after %+v", i, ts, prev)
+		}
+		prev = ts
+	}
+
+	remote := Timestamp{Wall: prev.Wall + int64(time.Hour), Logical: 7, Node: "b"}
+	c.Observe(remote)
+	if ts := c.Now(); ts.Compare(remote) <= 0 {
+		t.Errorf("timestamp %+v after Observe must follow the remote %+v", ts, remote)
+	}
+}
+
+// replicas - состояния одной задачи на нескольких репликах после случайной
+// истории правок и обменов. Генерируется для testing/quick.
+type replicas []*ReplicatedTodo
+
+var (
+	genTitles = []string{"Купить хлеб", "Купить батон", "Позвонить", ""}
+	genTags   = []string{"дом", "работа", "срочно", "покупки"}
+	genPrio   = []Priority{"", PriorityLow, PriorityHigh}
+)
+
+func (replicas) Generate(rnd *rand.Rand, size int) reflect.Value {
+	const n = 3
+	base := NewReplicatedTodo(&Todo{ID: 1, Title: "Купить хлеб", Tags: []string{"дом"}}, Timestamp{Wall: 1, Node: "base"})
+
+	clocks := make([]*Clock, n)
+	rs := make(replicas, n)
+	for i := range rs {
+		// Часы реплик расходятся и иногда идут назад
+		steps := make([]time.Duration, 4*size+8)
+		for j := range steps {
+			steps[j] = time.Duration(rnd.Intn(5)-1) * time.Millisecond
+		}
+		clocks[i] = NewClock("r"+strconv.Itoa(i), fakeNow(steps...))
+		rs[i] = Merge(base, base)
+	}
+
+	for range rnd.Intn(4*size + 1) {
+		i := rnd.Intn(n)
+		r, clock := rs[i], clocks[i]
+		todo := r.Todo()
+		switch rnd.Intn(8) {
+		case 0:
+			todo.Title = genTitles[rnd.Intn(len(genTitles))]
+		case 1:
+			todo.Completed = !todo.Completed
+		case 2:
+			due := time.Date(2026, 2, rnd.Intn(28)+1, 0, 0, 0, 0, time.UTC)
+			todo.DueDate = &due
+		case 3:
+			todo.Tags = append(todo.Tags, genTags[rnd.Intn(len(genTags))])
+		case 4:
+			if len(todo.Tags) > 0 {
+				todo.Tags = slices.Delete(todo.Tags, 0, 1)
+			}
+		case 5:
+			r.Delete(clock.Now())
+			continue
+		case 6:
+			todo.Priority = genPrio[rnd.Intn(len(genPrio))]
+		case 7:
+			// Обмен состоянием с другой репликой
+			other := rs[rnd.Intn(n)]
+			clock.Observe(other.Title.Time)
+			rs[i] = Merge(r, other)
+			continue
+		}
+		r.Edit(todo, clock.Now())
+	}
+	return reflect.ValueOf(rs)
+}
+
+func TestMerge_Properties(t *testing.T) {
+	config := &quick.Config{MaxCount: 500}
+
+	commutative := func(rs replicas) bool {
+		return reflect.DeepEqual(Merge(rs[0], rs[1]), Merge(rs[1], rs[0]))
+	}
+	associative := func(rs replicas) bool {
+		return reflect.DeepEqual(Merge(Merge(rs[0], rs[1]), rs[2]), Merge(rs[0], Merge(rs[1], rs[2])))
+	}
+	idempotent := func(rs replicas) bool {
+		m := Merge(rs[0], rs[1])
+		return reflect.DeepEqual(Merge(rs[0], rs[0]), rs[0]) && reflect.DeepEqual(Merge(m, rs[1]), m)
+	}
+
+	for name, property := range map[string]func(replicas) bool{
+		"коммутативность": commutative,
+		"ассоциативность": associative,
+		"идемпотентность": idempotent,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := quick.Check(property, config); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMerge_ConcurrentEdits(t *testing.T) {
+	base := NewReplicatedTodo(&Todo{ID: 1, Title: "Купить хлеб", Tags: []string{"дом", "покупки"}}, Timestamp{Wall: 1, Node: "base"})
+	phone, laptop := Merge(base, base), Merge(base, base)
+	phoneClock, laptopClock := NewClock("phone", fakeNow(time.Second)), NewClock("laptop", fakeNow(2*time.Second))
+
+	// Телефон переименовывает задачу и убирает тег, ноутбук отмечает ее
+	// выполненной и снова добавляет тот же тег
+	edit := phone.Todo()
+	edit.Title, edit.Tags = "Купить батон", []string{"дом"}
+	phone.Edit(edit, phoneClock.Now())
+
+	edit = laptop.Todo()
+	edit.Completed, edit.Tags = true, []string{"дом", "покупки", "срочно"}
+	laptop.Edit(edit, laptopClock.Now())
+	laptop.Tags.Remove("покупки")
+	laptop.Tags.Add("покупки", laptopClock.Now())
+
+	got := Merge(phone, laptop).Todo()
+	want := &Todo{ID: 1, Title: "Купить батон", Completed: true, Tags: []string{"дом", "покупки", "срочно"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged todo = %+v, want %+v", got, want)
+	}
+	if base.Title.Value != "Купить хлеб" || !base.Tags.Contains("покупки") {
+		t.Error("Merge must not modify its arguments")
+	}
+
+	// Правка одного поля на обеих репликах: побеждает более поздняя
+	edit = phone.Todo()
+	edit.Title = "Купить багет"
+	phone.Edit(edit, phoneClock.Now())
+	edit = laptop.Todo()
+	edit.Title = "Купить булку"
+	laptop.Edit(edit, laptopClock.Now())
+	if got := Merge(phone, laptop).Title.Value; got != "Купить булку" {
+		t.Errorf("title = %q, want the later write to win", got)
+	}
+
+	laptop.Delete(laptopClock.Now())
+	if !Merge(phone, laptop).Deleted.Value {
+		t.Error("deletion must survive the merge")
+	}
+}
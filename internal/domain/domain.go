@@ -3,25 +3,143 @@ package domain
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Todo представляет сущность задачи
 type Todo struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Project     string     `json:"project,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Priority    Priority   `json:"priority,omitempty"`
+	// Recurrence - правило повторения в виде RRULE (см. ParseRecurrence)
+	Recurrence string `json:"recurrence,omitempty"`
+	// Rank - место задачи в ручном порядке ее списка: задачи одного
+	// проекта упорядочены по возрастанию рангов (см. RankBetween).
+	// Назначается хранилищем и меняется только перестановкой.
+	Rank string `json:"rank,omitempty"`
+	// Version - номер изменения в журнале хранилища, которым задача
+	// получила текущее состояние; назначается хранилищем
+	Version uint64 `json:"version"`
 }
 
-// Validate проверяет корректность данных задачи
+// Priority - приоритет задачи; пустая строка - приоритет не задан
+type Priority string
+
+// Приоритеты задачи
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+)
+
+// Valid сообщает, что приоритет пуст или входит в список известных
+func (p Priority) Valid() bool {
+	switch p {
+	case "", PriorityLow, PriorityMedium, PriorityHigh:
+		return true
+	}
+	return false
+}
+
+// Clone возвращает независимую копию задачи
+func (t *Todo) Clone() *Todo {
+	c := *t
+	if t.DueDate != nil {
+		due := *t.DueDate
+		c.DueDate = &due
+	}
+	if t.Tags != nil {
+		c.Tags = append([]string(nil), t.Tags...)
+	}
+	return &c
+}
+
+// HasTag сообщает, отмечена ли задача тегом tag
+func (t *Todo) HasTag(tag string) bool {
+	for _, tg := range t.Tags {
+		if tg == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Ограничения полей задачи (длины в символах)
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 2000
+	MaxProjectLength     = 100
+	MaxTagLength         = 50
+	MaxTags              = 20
+)
+
+// Validate проверяет корректность данных задачи и возвращает
+// *ValidationError со всеми найденными нарушениями
 func (t *Todo) Validate() error {
-	if t.Title == "" {
-		return errors.New("title cannot be empty")
+	var verr ValidationError
+
+	switch {
+	case strings.TrimSpace(t.Title) == "":
+		verr.Add("title", CodeRequired, "title cannot be empty")
+	case utf8.RuneCountInString(t.Title) > MaxTitleLength:
+		verr.Add("title", CodeTooLong, fmt.Sprintf("title must be at most %d characters", MaxTitleLength))
+	}
+	if utf8.RuneCountInString(t.Description) > MaxDescriptionLength {
+		verr.Add("description", CodeTooLong, fmt.Sprintf("description must be at most %d characters", MaxDescriptionLength))
+	}
+	if t.DueDate != nil && t.DueDate.IsZero() {
+		verr.Add("due_date", CodeInvalid, "due_date must be a valid date")
+	}
+	if utf8.RuneCountInString(t.Project) > MaxProjectLength {
+		verr.Add("project", CodeTooLong, fmt.Sprintf("project must be at most %d characters", MaxProjectLength))
+	}
+	if len(t.Tags) > MaxTags {
+		verr.Add("tags", CodeTooLong, fmt.Sprintf("at most %d tags are allowed", MaxTags))
+	}
+	for i, tag := range t.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case strings.TrimSpace(tag) == "":
+			verr.Add(field, CodeRequired, "tag cannot be empty")
+		case utf8.RuneCountInString(tag) > MaxTagLength:
+			verr.Add(field, CodeTooLong, fmt.Sprintf("tag must be at most %d characters", MaxTagLength))
+		}
 	}
-	return nil
+	if !t.Priority.Valid() {
+		verr.Add("priority", CodeInvalid, "priority must be low, medium or high")
+	}
+	if t.Recurrence != "" {
+		if _, err := ParseRecurrence(t.Recurrence); err != nil {
+			verr.Add("recurrence", CodeInvalid, err.Error())
+		}
+	}
+
+	return verr.Err()
+}
+
+// IsOverdue сообщает, что незавершенная задача просрочена на момент now
+func (t *Todo) IsOverdue(now time.Time) bool {
+	return !t.Completed && t.DueDate != nil && t.DueDate.Before(now)
+}
+
+// TodoStats содержит сводную статистику по задачам
+type TodoStats struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Overdue   int `json:"overdue"`
 }
 
-// TodoRepository определяет интерфейс для работы с хранилищем задач
+// TodoRepository определяет интерфейс для работы с хранилищем задач.
+// Каждое изменение получает следующий номер журнала, который записывается
+// в Version задачи.
 type TodoRepository interface {
 	Create(ctx context.Context, todo *Todo) error
 	GetAll(ctx context.Context) ([]*Todo, error)
@@ -29,6 +147,56 @@ type TodoRepository interface {
 	Update(ctx context.Context, todo *Todo) error
 	Delete(ctx context.Context, id int) error
 	Exists(ctx context.Context, id int) bool
+	// Changes возвращает журнал изменений после номера since (см. Change)
+	// не длиннее limit и последний номер журнала
+	Changes(ctx context.Context, since uint64, limit int) ([]Change, uint64, error)
+	// Search ищет задачи по словам названия и описания и возвращает не
+	// больше limit лучших совпадений по убыванию релевантности и общее
+	// число найденных задач. Синтаксис запроса описан в search.Parse.
+	Search(ctx context.Context, query string, limit int) ([]SearchHit, int, error)
+	// Find возвращает задачи, подходящие под разобранный запрос (см.
+	// ParseQuery), по возрастанию ID
+	Find(ctx context.Context, expr QueryExpr) ([]*Todo, error)
+	// Move ставит задачу id в ручном порядке ее списка рядом с anchor и
+	// возвращает ее и все измененные задачи. Обычно меняется только ранг
+	// самой задачи, а если она уже на месте - ничего. Если ранг вышел бы
+	// длиннее MaxRankLength, ранги всего списка перераспределяются (см.
+	// Ranks). Неверный сосед - ErrInvalidMove.
+	Move(ctx context.Context, id int, anchor Anchor) (moved *Todo, changed []*Todo, err error)
+	// Merge сливает правку todo, сделанную независимо от других (например,
+	// на устройстве без связи) поверх версии base, с правками задачи id,
+	// записанными после base (см. ReplicatedTodo). Правки разных полей
+	// сохраняются все, а одного поля - побеждает более поздняя. concurrent
+	// сообщает, что после base задача менялась. Если base нет в истории
+	// слияния - ErrVersionUnavailable; если результат не проходит
+	// валидацию - *ValidationError.
+	Merge(ctx context.Context, id int, base uint64, todo *Todo) (merged *Todo, concurrent bool, err error)
+	// Каждое изменение записывает событие в очередь исходящих событий
+	Outbox
+}
+
+// EventType - вид изменения задачи
+type EventType string
+
+// Виды изменений задач
+const (
+	EventTodoCreated EventType = "todo.created"
+	EventTodoUpdated EventType = "todo.updated"
+	EventTodoDeleted EventType = "todo.deleted"
+)
+
+// TodoEvent - изменение задачи. Todo - снимок задачи после изменения, а для
+// удаления - последнее состояние перед ним.
+type TodoEvent struct {
+	ID   uint64    `json:"id"`
+	Type EventType `json:"type"`
+	Todo *Todo     `json:"todo"`
+	Time time.Time `json:"time"`
+}
+
+// EventPublisher получает изменения задач. ID события назначает издатель.
+type EventPublisher interface {
+	Publish(event TodoEvent)
 }
 
 // Предопределенные ошибки
@@ -36,4 +204,78 @@ var (
 	ErrTodoNotFound      = errors.New("todo not found")
 	ErrTodoAlreadyExists = errors.New("todo with this ID already exists")
 	ErrInvalidTodoData   = errors.New("invalid todo data")
+	ErrSyncTokenExpired  = errors.New("sync token is older than the change log")
+	// ErrVersionUnavailable - версии задачи нет в истории слияния (см.
+	// TodoRepository.Merge): она слишком старая или не существовала
+	ErrVersionUnavailable = errors.New("todo version is not available for merging")
+	ErrInvalidQuery       = errors.New("invalid query")
+
+	// Ошибки прерывания операций через context.Context
+	ErrCanceled         = errors.New("operation canceled")
+	ErrDeadlineExceeded = errors.New("operation deadline exceeded")
+	ErrUnavailable      = errors.New("service unavailable")
+)
+
+// Коды нарушений валидации
+const (
+	CodeRequired = "required"
+	CodeTooLong  = "too_long"
+	CodeInvalid  = "invalid"
 )
+
+// FieldViolation - нарушение правила валидации для поля
+type FieldViolation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// ValidationError собирает все нарушения валидации. Соответствует
+// ErrInvalidTodoData при проверке через errors.Is.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+// Add добавляет нарушение
+func (e *ValidationError) Add(field, code, message string) {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Code: code, Message: message})
+}
+
+// Err возвращает e, если есть нарушения, иначе nil
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return ErrInvalidTodoData.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidTodoData
+}
+
+// ContextErr возвращает nil, если контекст активен, иначе ошибку отмены,
+// приведенную к доменной: ErrDeadlineExceeded при истечении дедлайна,
+// ErrUnavailable если контекст отменен с этой причиной (например, при остановке
+// сервера) и ErrCanceled в остальных случаях. Исходная ошибка контекста
+// сохраняется в цепочке, поэтому errors.Is(err, context.Canceled) тоже работает.
+func ContextErr(ctx context.Context) error {
+	err := ctx.Err()
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrDeadlineExceeded, err)
+	case errors.Is(context.Cause(ctx), ErrUnavailable):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+}
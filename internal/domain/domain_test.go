@@ -0,0 +1,220 @@
+package domain
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTodo_Validate(t *testing.T) {
+	var zero time.Time
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		todo  Todo
+		codes []string // ожидаемые "поле:код"
+	}{
+		{"корректная задача", Todo{Title: "Купить молоко", DueDate: &due}, nil},
+		{"пустой заголовок", Todo{Title: "   "}, []string{"title:required"}},
+		{"длинный заголовок", Todo{Title: strings.Repeat("я", MaxTitleLength+1)}, []string{"title:too_long"}},
+		{"заголовок на границе", Todo{Title: strings.Repeat("я", MaxTitleLength)}, nil},
+		{
+			"проект и теги",
+			Todo{Title: "a", Project: strings.Repeat("p", MaxProjectLength+1), Tags: []string{"ok", " ", strings.Repeat("t", MaxTagLength+1)}},
+			[]string{"project:too_long", "tags[1]:required", "tags[2]:too_long"},
+		},
+		{
+			"приоритет и повторение",
+			Todo{Title: "a", Priority: "urgent", Recurrence: "FREQ=HOURLY"},
+			[]string{"priority:invalid", "recurrence:invalid"},
+		},
+		{"повторение по дням недели", Todo{Title: "a", Priority: PriorityHigh, Recurrence: "FREQ=WEEKLY;BYDAY=MO,FR"}, nil},
+		{
+			"все нарушения сразу",
+			Todo{Description: strings.Repeat("a", MaxDescriptionLength+1), DueDate: &zero},
+			[]string{"title:required", "description:too_long", "due_date:invalid"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.todo.Validate()
+			if tc.codes == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected *ValidationError, got %v", err)
+			}
+			if !errors.Is(err, ErrInvalidTodoData) {
+				t.Error("validation error must match ErrInvalidTodoData")
+			}
+
+			var got []string
+			for _, v := range verr.Violations {
+				got = append(got, v.Field+":"+v.Code)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.codes, ",") {
+				t.Errorf("expected violations %v, got %v", tc.codes, got)
+			}
+		})
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=weekly;interval=1;byday=su,mo", "FREQ=WEEKLY;BYDAY=MO,SU"},
+		{"FREQ=MONTHLY;INTERVAL=3", "FREQ=MONTHLY;INTERVAL=3"},
+		{"INTERVAL=2;FREQ=YEARLY", "FREQ=YEARLY;INTERVAL=2"},
+	}
+	for _, tc := range tests {
+		rule, err := ParseRecurrence(tc.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got := rule.String(); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.in, tc.want, got)
+		}
+	}
+
+	for _, in := range []string{"", "INTERVAL=2", "FREQ=DAILY;FREQ=WEEKLY", "FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;COUNT=3", "FREQ"} {
+		if _, err := ParseRecurrence(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestRankBetween(t *testing.T) {
+	between := func(t *testing.T, prev, next string) string {
+		t.Helper()
+		rank := RankBetween(prev, next)
+		if !ValidRank(rank) || (prev != "" && rank <= prev) || (next != "" && rank >= next) {
+			t.Fatalf("RankBetween(%q, %q) = %q is not a valid rank between them", prev, next, rank)
+		}
+		return rank
+	}
+
+	tests := []struct {
+		prev, next, want string
+	}{
+		{"", "", "V"},
+		{"V", "", "V01"},
+		{"", "V", "Uzz"},
+		{"V", "W", "VV"},
+		{"V", "V1", "V0V"},
+		{"zzz", "", "zzzV"},
+		{"", "001", "000V"},
+	}
+	for _, tc := range tests {
+		if got := between(t, tc.prev, tc.next); got != tc.want {
+			t.Errorf("RankBetween(%q, %q): expected %q, got %q", tc.prev, tc.next, tc.want, got)
+		}
+	}
+
+	t.Run("добавление в конец не удлиняет ранг", func(t *testing.T) {
+		rank := ""
+		for range 10000 {
+			rank = between(t, rank, "")
+		}
+		if len(rank) > rankStep {
+			t.Errorf("expected at most %d digits, got %q", rankStep, rank)
+		}
+	})
+
+	t.Run("перестановки в один промежуток", func(t *testing.T) {
+		// Каждая новая задача встает сразу после первой: ранг растет медленно
+		prev, next := between(t, "", ""), ""
+		next = between(t, prev, next)
+		n := 0
+		for len(next) <= MaxRankLength {
+			next = between(t, prev, next)
+			n++
+		}
+		if n < 100 {
+			t.Errorf("expected at least 100 moves before rebalancing, got %d", n)
+		}
+	})
+}
+
+func TestRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 61, 62, 1000, 300000} {
+		ranks := Ranks(n)
+		if len(ranks) != n {
+			t.Fatalf("Ranks(%d): expected %d ranks, got %d", n, n, len(ranks))
+		}
+		for i, rank := range ranks {
+			if !ValidRank(rank) || (i > 0 && rank <= ranks[i-1]) {
+				t.Fatalf("Ranks(%d): rank %d %q is invalid or out of order", n, i, rank)
+			}
+		}
+		// Между соседями остается место для перестановок без удлинения
+		if n > 1 && len(RankBetween(ranks[0], ranks[1])) > len(ranks[0])+1 {
+			t.Errorf("Ranks(%d): no room between %q and %q", n, ranks[0], ranks[1])
+		}
+	}
+}
+
+func TestAnchor_Validate(t *testing.T) {
+	tests := []struct {
+		anchor Anchor
+		want   string
+	}{
+		{Anchor{Before: 1}, ""},
+		{Anchor{After: 2}, ""},
+		{Anchor{}, "before:required"},
+		{Anchor{Before: 1, After: 2}, "after:invalid"},
+		{Anchor{Before: -1}, "before:invalid"},
+	}
+	for _, tc := range tests {
+		err := tc.anchor.Validate()
+		var verr *ValidationError
+		got := ""
+		if errors.As(err, &verr) {
+			got = verr.Violations[0].Field + ":" + verr.Violations[0].Code
+		}
+		if got != tc.want {
+			t.Errorf("%+v: expected %q, got %q (%v)", tc.anchor, tc.want, got, err)
+		}
+	}
+}
+
+func TestWebhook_Validate(t *testing.T) {
+	local := []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	tests := []struct {
+		url     string
+		allowed []netip.Prefix
+		valid   bool
+	}{
+		{"https://example.com/hook", nil, true},
+		{"https://93.184.216.34/hook", nil, true},
+		{"ftp://example.com/hook", nil, false},
+		{"http://127.0.0.1:8080/hook", nil, false},
+		{"http://localhost/hook", nil, false},
+		{"http://[::1]/hook", nil, false},
+		{"http://[::ffff:10.0.0.1]/hook", nil, false},
+		{"http://169.254.169.254/latest/meta-data", nil, false},
+		{"http://192.168.1.10/hook", nil, false},
+		{"http://0.0.0.0/hook", nil, false},
+		{"http://127.0.0.1:8080/hook", local, true},
+		{"http://localhost/hook", local, true},
+		{"http://10.0.0.1/hook", local, false},
+	}
+	for _, tc := range tests {
+		hook := Webhook{URL: tc.url, Secret: strings.Repeat("s", MinWebhookSecretLength)}
+		if err := hook.Validate(tc.allowed...); (err == nil) != tc.valid {
+			t.Errorf("%s (allowed %v): expected valid=%v, got %v", tc.url, tc.allowed, tc.valid, err)
+		}
+	}
+}
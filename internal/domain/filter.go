@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Ограничения сохраненных фильтров (длины в символах)
+const (
+	MaxFilterNameLength  = 100
+	MaxFilterQueryLength = 500
+)
+
+// Filter - сохраненный фильтр: запрос к задачам (см. ParseQuery) и порядок
+// результатов (см. SortTodos). Фильтр принадлежит создавшему его
+// принципалу Owner, другим он не виден.
+type Filter struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Query     string    `json:"query"`
+	Sort      string    `json:"sort"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Clone возвращает независимую копию фильтра
+func (f *Filter) Clone() *Filter {
+	c := *f
+	return &c
+}
+
+// Validate проверяет фильтр и возвращает *ValidationError со всеми
+// найденными нарушениями. Запрос должен разбираться без ошибок.
+func (f *Filter) Validate() error {
+	var verr ValidationError
+
+	if strings.TrimSpace(f.Name) == "" {
+		verr.Add("name", CodeRequired, "name cannot be empty")
+	} else if utf8.RuneCountInString(f.Name) > MaxFilterNameLength {
+		verr.Add("name", CodeTooLong, fmt.Sprintf("name must be at most %d characters", MaxFilterNameLength))
+	}
+
+	var qerr *QueryError
+	switch {
+	case strings.TrimSpace(f.Query) == "":
+		verr.Add("query", CodeRequired, "query cannot be empty")
+	case utf8.RuneCountInString(f.Query) > MaxFilterQueryLength:
+		verr.Add("query", CodeTooLong, fmt.Sprintf("query must be at most %d characters", MaxFilterQueryLength))
+	default:
+		if _, err := ParseQuery(f.Query, time.Now()); errors.As(err, &qerr) {
+			verr.Add("query", CodeInvalid, fmt.Sprintf("query: %s at position %d", qerr.Message, qerr.Pos))
+		}
+	}
+
+	if !ValidSort(f.Sort) {
+		verr.Add("sort", CodeInvalid, fmt.Sprintf("sort must be one of %s, optionally prefixed with -", strings.Join(sortFields, ", ")))
+	}
+
+	return verr.Err()
+}
+
+// SortRank - ручной порядок задач (см. SortTodos)
+const SortRank = "rank"
+
+// sortFields - поля, по которым упорядочиваются задачи
+var sortFields = []string{"id", "title", "due", "project", SortRank}
+
+// ValidSort сообщает, что order - допустимый порядок для SortTodos
+func ValidSort(order string) bool {
+	if order == "" {
+		return true
+	}
+	return slices.Contains(sortFields, strings.TrimPrefix(order, "-"))
+}
+
+// SortTodos упорядочивает задачи по полю order: id, title, due, project или
+// rank (ручной порядок: по проекту, а в проекте - по рангу), с минусом
+// впереди - по убыванию; пустой order - по ID. Задачи без срока при
+// сортировке по due идут последними в обоих направлениях, а при равных
+// значениях порядок определяет ID.
+func SortTodos(todos []*Todo, order string) {
+	desc := strings.HasPrefix(order, "-")
+	field := strings.TrimPrefix(order, "-")
+
+	slices.SortFunc(todos, func(a, b *Todo) int {
+		if field == "due" && (a.DueDate == nil) != (b.DueDate == nil) {
+			if a.DueDate == nil {
+				return 1
+			}
+			return -1
+		}
+
+		var c int
+		switch field {
+		case "title":
+			c = strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		case "project":
+			c = strings.Compare(strings.ToLower(a.Project), strings.ToLower(b.Project))
+		case SortRank:
+			c = strings.Compare(strings.ToLower(a.Project), strings.ToLower(b.Project))
+			if c == 0 {
+				c = strings.Compare(a.Rank, b.Rank)
+			}
+		case "due":
+			if a.DueDate != nil {
+				c = a.DueDate.Compare(*b.DueDate)
+			}
+		default:
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		return c
+	})
+}
+
+// FilterRepository хранит сохраненные фильтры
+type FilterRepository interface {
+	CreateFilter(ctx context.Context, filter *Filter) error
+	// GetFilters возвращает фильтры принципала owner по возрастанию ID
+	GetFilters(ctx context.Context, owner string) ([]*Filter, error)
+	GetFilter(ctx context.Context, id int) (*Filter, error)
+	// UpdateFilter заменяет фильтр; Owner и CreatedAt не меняются
+	UpdateFilter(ctx context.Context, filter *Filter) error
+	DeleteFilter(ctx context.Context, id int) error
+}
+
+// ErrFilterNotFound - фильтра с таким ID нет
+var ErrFilterNotFound = errors.New("filter not found")
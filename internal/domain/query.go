@@ -0,0 +1,251 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"todo/internal/search"
+)
+
+// QueryExpr - узел дерева разобранного запроса (см. ParseQuery). Match
+// проверяет задачу; время, от которого отсчитываются относительные сроки,
+// зафиксировано при разборе. String возвращает запрос в канонической
+// записи со скобками вокруг каждой операции.
+type QueryExpr interface {
+	Match(todo *Todo) bool
+	String() string
+}
+
+// AndExpr выполняется, если выполнены все Terms
+type AndExpr struct {
+	Terms []QueryExpr
+}
+
+// Match проверяет задачу
+func (e *AndExpr) Match(todo *Todo) bool {
+	for _, t := range e.Terms {
+		if !t.Match(todo) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *AndExpr) String() string {
+	return joinExprs(e.Terms, " AND ")
+}
+
+// OrExpr выполняется, если выполнено хотя бы одно из Terms
+type OrExpr struct {
+	Terms []QueryExpr
+}
+
+// Match проверяет задачу
+func (e *OrExpr) Match(todo *Todo) bool {
+	for _, t := range e.Terms {
+		if t.Match(todo) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *OrExpr) String() string {
+	return joinExprs(e.Terms, " OR ")
+}
+
+func joinExprs(terms []QueryExpr, sep string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// NotExpr выполняется, если не выполнено Expr
+type NotExpr struct {
+	Expr QueryExpr
+}
+
+// Match проверяет задачу
+func (e *NotExpr) Match(todo *Todo) bool {
+	return !e.Expr.Match(todo)
+}
+
+func (e *NotExpr) String() string {
+	return "NOT " + e.Expr.String()
+}
+
+// QueryField - поле задачи в условии запроса
+type QueryField string
+
+// Поля запроса
+const (
+	// FieldStatus - состояние: open, done или overdue
+	FieldStatus QueryField = "status"
+	// FieldProject - проект без учета регистра
+	FieldProject QueryField = "project"
+	// FieldTag - один из тегов без учета регистра
+	FieldTag QueryField = "tag"
+	// FieldDue - срок выполнения
+	FieldDue QueryField = "due"
+)
+
+// QueryOp - оператор сравнения в условии
+type QueryOp string
+
+// Операторы сравнения
+const (
+	OpEq QueryOp = ":"
+	OpLt QueryOp = "<"
+	OpLe QueryOp = "<="
+	OpGt QueryOp = ">"
+	OpGe QueryOp = ">="
+)
+
+// Значения поля status
+const (
+	StatusOpen    = "open"
+	StatusDone    = "done"
+	StatusOverdue = "overdue"
+)
+
+// FieldExpr - условие на поле задачи. Value - значение из запроса
+// (состояние - в нижнем регистре); для due оно разобрано в Due.
+type FieldExpr struct {
+	Field QueryField
+	Op    QueryOp
+	Value string
+	Due   DueRange
+	// now - момент разбора для status:overdue
+	now time.Time
+}
+
+// Match проверяет задачу
+func (e *FieldExpr) Match(todo *Todo) bool {
+	switch e.Field {
+	case FieldStatus:
+		switch e.Value {
+		case StatusOpen:
+			return !todo.Completed
+		case StatusDone:
+			return todo.Completed
+		default:
+			return todo.IsOverdue(e.now)
+		}
+	case FieldProject:
+		return strings.EqualFold(todo.Project, e.Value)
+	case FieldTag:
+		for _, tag := range todo.Tags {
+			if strings.EqualFold(tag, e.Value) {
+				return true
+			}
+		}
+		return false
+	case FieldDue:
+		return e.Due.Compare(e.Op, todo.DueDate)
+	}
+	return false
+}
+
+func (e *FieldExpr) String() string {
+	return string(e.Field) + string(e.Op) + quoteValue(e.Value)
+}
+
+// quoteValue заключает значение в кавычки, если без них оно не
+// разбирается обратно тем же
+func quoteValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n\"()\\") {
+		return v
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+}
+
+// DueRange - значение условия на срок: промежуток [From, To) для дня
+// (2025-01-31, today) или момент From = To (7d, 2025-01-31T10:00:00Z). None
+// задает условие due:none (срока нет), Any - due:any (срок есть).
+type DueRange struct {
+	From, To  time.Time
+	None, Any bool
+}
+
+// Compare сравнивает срок задачи due (nil - срока нет) со значением по
+// оператору op. Для дня "<" означает раньше его начала, "<=" - не позже
+// его конца, а ":" - в течение дня; для момента ":" - в тот же день.
+func (r DueRange) Compare(op QueryOp, due *time.Time) bool {
+	switch {
+	case r.None:
+		return due == nil
+	case r.Any:
+		return due != nil
+	case due == nil:
+		return false
+	}
+	point := r.From.Equal(r.To)
+	switch op {
+	case OpLt:
+		return due.Before(r.From)
+	case OpLe:
+		if point {
+			return !due.After(r.To)
+		}
+		return due.Before(r.To)
+	case OpGt:
+		if point {
+			return due.After(r.To)
+		}
+		return !due.Before(r.To)
+	case OpGe:
+		return !due.Before(r.From)
+	default:
+		from, to := r.From, r.To
+		if point {
+			from = startOfDay(r.From)
+			to = from.AddDate(0, 0, 1)
+		}
+		return !due.Before(from) && due.Before(to)
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// TextExpr - полнотекстовое условие на название и описание: все слова
+// Text в любой форме, фраза в кавычках целиком или слово* по началу, как
+// в поиске (search.Parse)
+type TextExpr struct {
+	Text   string
+	Phrase bool
+	Query  search.Query
+}
+
+// Match проверяет задачу
+func (e *TextExpr) Match(todo *Todo) bool {
+	return search.Matches(e.Query, todo.Title, todo.Description)
+}
+
+func (e *TextExpr) String() string {
+	if e.Phrase {
+		return quoteValue(e.Text)
+	}
+	return e.Text
+}
+
+// QueryError - ошибка разбора запроса. Pos - номер символа запроса, с
+// которого начинается ошибка, считая с 1. Соответствует ErrInvalidQuery
+// при проверке через errors.Is.
+type QueryError struct {
+	Pos     int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s: %s at position %d", ErrInvalidQuery, e.Message, e.Pos)
+}
+
+func (e *QueryError) Unwrap() error {
+	return ErrInvalidQuery
+}
@@ -0,0 +1,400 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"todo/internal/search"
+)
+
+// ParseQuery разбирает запрос к задачам, например
+//
+//	status:open AND (tag:urgent OR due<7d) AND NOT project:personal
+//
+// Условия на поля записываются как поле, оператор и значение без пробелов;
+// значение с пробелами заключается в кавычки (project:"дом и сад").
+// Поддерживаются поля:
+//
+//   - status:open, status:done, status:overdue;
+//   - project:имя и tag:имя - без учета регистра;
+//   - due с операторами : < <= > >= и значениями: дата 2025-01-31 или
+//     today, tomorrow, yesterday (день целиком), момент RFC 3339, now или
+//     смещение от now вида 7d, -2h, 1w (h - часы, d - дни, w - недели);
+//     due:none - без срока, due:any - со сроком.
+//
+// Остальные слова ищутся в названии и описании, как в поиске: слово в любой
+// форме, "фраза" в кавычках или слово* по началу.
+//
+// Условия объединяются операторами AND, OR и NOT (только прописными
+// буквами) и группируются скобками; NOT связывает сильнее AND, а AND -
+// сильнее OR. Условия подряд без оператора объединяются через AND.
+// Относительные сроки отсчитываются от now, дни - в его часовом поясе.
+//
+// Ошибка разбора - *QueryError с позицией ошибки.
+func ParseQuery(input string, now time.Time) (QueryExpr, error) {
+	tokens, err := lexQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, now: now}
+	if p.peek().kind == tokEOF {
+		return nil, &QueryError{Pos: 1, Message: "empty query"}
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, t.unexpected()
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	// tokWord - слово без кавычек, tokString - строка в кавычках
+	tokWord
+	tokString
+	// tokField - условие на поле: text - имя поля
+	tokField
+)
+
+// queryToken - лексема запроса. Позиции - номера символов, считая с 1.
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+
+	// Оператор и значение условия на поле
+	op       QueryOp
+	opPos    int
+	value    string
+	valuePos int
+}
+
+// unexpected возвращает ошибку о лексеме, которой не может быть на ее месте
+func (t queryToken) unexpected() error {
+	switch t.kind {
+	case tokEOF:
+		return &QueryError{Pos: t.pos, Message: "unexpected end of query"}
+	case tokRParen:
+		return &QueryError{Pos: t.pos, Message: "unexpected ')'"}
+	default:
+		return &QueryError{Pos: t.pos, Message: fmt.Sprintf("unexpected %s", t.text)}
+	}
+}
+
+// lexQuery разбивает запрос на лексемы
+func lexQuery(input string) ([]queryToken, error) {
+	runes := []rune(input)
+	var tokens []queryToken
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokLParen, text: "(", pos: i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokRParen, text: ")", pos: i + 1})
+			i++
+		case r == '"':
+			s, next, err := lexString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: tokString, text: s, pos: i + 1})
+			i = next
+		default:
+			t, next, err := lexWord(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i = next
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, pos: len(runes) + 1}), nil
+}
+
+// lexString читает строку в кавычках, начиная с открывающей кавычки в
+// позиции start; внутри \" - кавычка, \\ - обратная косая черта
+func lexString(runes []rune, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '"':
+			return b.String(), i + 1, nil
+		case r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+			i++
+			b.WriteRune(runes[i])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", 0, &QueryError{Pos: start + 1, Message: "unterminated quoted string"}
+}
+
+// lexWord читает слово, ключевое слово или условие на поле - имя из
+// латинских букв, за которым сразу идет оператор
+func lexWord(runes []rune, start int) (queryToken, int, error) {
+	isDelim := func(r rune) bool { return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' }
+
+	i := start
+	for i < len(runes) && (runes[i] >= 'a' && runes[i] <= 'z' || runes[i] >= 'A' && runes[i] <= 'Z' || runes[i] == '_') {
+		i++
+	}
+	if i > start && i < len(runes) && (runes[i] == ':' || runes[i] == '<' || runes[i] == '>') {
+		t := queryToken{kind: tokField, text: string(runes[start:i]), pos: start + 1, opPos: i + 1}
+		op := string(runes[i])
+		i++
+		if op != ":" && i < len(runes) && runes[i] == '=' {
+			op += "="
+			i++
+		}
+		t.op = QueryOp(op)
+		t.valuePos = i + 1
+
+		switch {
+		case i < len(runes) && runes[i] == '"':
+			value, next, err := lexString(runes, i)
+			if err != nil {
+				return queryToken{}, 0, err
+			}
+			t.value = value
+			return t, next, nil
+		case i == len(runes) || isDelim(runes[i]):
+			return queryToken{}, 0, &QueryError{Pos: t.valuePos, Message: fmt.Sprintf("missing value for %s", t.text)}
+		}
+		end := i
+		for end < len(runes) && !isDelim(runes[end]) {
+			end++
+		}
+		t.value = string(runes[i:end])
+		return t, end, nil
+	}
+
+	for i < len(runes) && !isDelim(runes[i]) {
+		i++
+	}
+	t := queryToken{kind: tokWord, text: string(runes[start:i]), pos: start + 1}
+	switch t.text {
+	case "AND":
+		t.kind = tokAnd
+	case "OR":
+		t.kind = tokOr
+	case "NOT":
+		t.kind = tokNot
+	}
+	return t, i, nil
+}
+
+// queryParser - разбор рекурсивным спуском:
+//
+//	or   = and { "OR" and }
+//	and  = not { ["AND"] not }
+//	not  = "NOT" not | term
+//	term = "(" or ")" | поле | слово | строка
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	now    time.Time
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) parseOr() (QueryExpr, error) {
+	var terms []QueryExpr
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, expr)
+		if p.peek().kind != tokOr {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &OrExpr{Terms: terms}, nil
+}
+
+func (p *queryParser) parseAnd() (QueryExpr, error) {
+	var terms []QueryExpr
+	for {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, expr)
+
+		// Условие без оператора тоже продолжает AND
+		if k := p.peek().kind; k == tokAnd {
+			p.next()
+		} else if k == tokOr || k == tokRParen || k == tokEOF {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &AndExpr{Terms: terms}, nil
+}
+
+func (p *queryParser) parseNot() (QueryExpr, error) {
+	if p.peek().kind != tokNot {
+		return p.parseTerm()
+	}
+	p.next()
+	expr, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return &NotExpr{Expr: expr}, nil
+}
+
+func (p *queryParser) parseTerm() (QueryExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			if p.peek().kind == tokEOF {
+				return nil, &QueryError{Pos: t.pos, Message: "unclosed '('"}
+			}
+			return nil, p.peek().unexpected()
+		}
+		p.next()
+		return expr, nil
+	case tokField:
+		return p.parseField(t)
+	case tokWord:
+		return textExpr(t, search.Parse(t.text), false)
+	case tokString:
+		return textExpr(t, search.Parse(`"`+t.text+`"`), true)
+	default:
+		return nil, t.unexpected()
+	}
+}
+
+// textExpr возвращает полнотекстовое условие, если в нем есть слова для
+// поиска
+func textExpr(t queryToken, q search.Query, phrase bool) (QueryExpr, error) {
+	if q.IsEmpty() {
+		msg := fmt.Sprintf("no words to search in %s", quoteValue(t.text))
+		if !phrase && (t.text == "and" || t.text == "or" || t.text == "not") {
+			msg += ", operators are written in uppercase"
+		}
+		return nil, &QueryError{Pos: t.pos, Message: msg}
+	}
+	return &TextExpr{Text: t.text, Phrase: phrase, Query: q}, nil
+}
+
+func (p *queryParser) parseField(t queryToken) (QueryExpr, error) {
+	expr := &FieldExpr{Field: QueryField(strings.ToLower(t.text)), Op: t.op, Value: t.value, now: p.now}
+	switch expr.Field {
+	case FieldStatus, FieldProject, FieldTag:
+		if expr.Op != OpEq {
+			return nil, &QueryError{Pos: t.opPos, Message: fmt.Sprintf("operator %s is not supported for %s", expr.Op, expr.Field)}
+		}
+	case FieldDue:
+	default:
+		return nil, &QueryError{Pos: t.pos, Message: fmt.Sprintf("unknown field %q, want status, project, tag or due", t.text)}
+	}
+
+	switch expr.Field {
+	case FieldStatus:
+		expr.Value = strings.ToLower(expr.Value)
+		switch expr.Value {
+		case StatusOpen, StatusDone, StatusOverdue:
+		default:
+			return nil, &QueryError{Pos: t.valuePos, Message: fmt.Sprintf("invalid status %s, want open, done or overdue", quoteValue(t.value))}
+		}
+	case FieldDue:
+		due, msg := parseDue(t.value, expr.Op, p.now)
+		if msg != "" {
+			return nil, &QueryError{Pos: t.valuePos, Message: msg}
+		}
+		expr.Due = due
+	}
+	return expr, nil
+}
+
+// relativeDue - смещение срока от текущего момента: 7d, -2h, +1w
+var relativeDue = regexp.MustCompile(`^([+-]?)(\d{1,5})([hdw])$`)
+
+// parseDue разбирает значение условия на срок; при ошибке возвращает ее
+// описание
+func parseDue(value string, op QueryOp, now time.Time) (DueRange, string) {
+	day := func(t time.Time) DueRange {
+		start := startOfDay(t)
+		return DueRange{From: start, To: start.AddDate(0, 0, 1)}
+	}
+	point := func(t time.Time) DueRange { return DueRange{From: t, To: t} }
+
+	switch v := strings.ToLower(value); v {
+	case "none", "any":
+		if op != OpEq {
+			return DueRange{}, fmt.Sprintf("operator %s is not supported for due:%s", op, v)
+		}
+		return DueRange{None: v == "none", Any: v == "any"}, ""
+	case "now":
+		return point(now), ""
+	case "today":
+		return day(now), ""
+	case "tomorrow":
+		return day(now.AddDate(0, 0, 1)), ""
+	case "yesterday":
+		return day(now.AddDate(0, 0, -1)), ""
+	}
+
+	if m := relativeDue.FindStringSubmatch(strings.ToLower(value)); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		if m[1] == "-" {
+			n = -n
+		}
+		switch m[3] {
+		case "h":
+			return point(now.Add(time.Duration(n) * time.Hour)), ""
+		case "d":
+			return point(now.AddDate(0, 0, n)), ""
+		default:
+			return point(now.AddDate(0, 0, 7*n)), ""
+		}
+	}
+	if d, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return day(d), ""
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return point(t), ""
+	}
+	return DueRange{}, fmt.Sprintf("invalid due date %s, want a date like 2025-01-31, today, 7d or none", quoteValue(value))
+}
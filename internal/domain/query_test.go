@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query string
+		want  string // каноническая запись дерева
+	}{
+		{"status:open", "status:open"},
+		{"Status:DONE", "status:done"},
+		{"status:open AND (tag:urgent OR due<7d) AND NOT project:personal", "(status:open AND (tag:urgent OR due<7d) AND NOT project:personal)"},
+		{"milk OR bread eggs", "(milk OR (bread AND eggs))"},
+		{"NOT NOT tag:x", "NOT NOT tag:x"},
+		{"NOT milk bread", "(NOT milk AND bread)"},
+		{`project:"дом и сад"`, `project:"дом и сад"`},
+		{`"купить молоко" молок*`, `("купить молоко" AND молок*)`},
+		{`tag:"a\"b"`, `tag:"a\"b"`},
+		{"due>=2025-03-01 due<=today", "(due>=2025-03-01 AND due<=today)"},
+		{"((milk))", "milk"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := ParseQuery(tc.query, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := expr.String(); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"", 1, "empty query"},
+		{"status:open)", 12, "unexpected ')'"},
+		{"(status:open", 1, "unclosed '('"},
+		{"status:open AND", 16, "unexpected end of query"},
+		{"OR tag:x", 1, "unexpected OR"},
+		{"foo:bar", 1, `unknown field "foo", want status, project, tag or due`},
+		{"tag:x project<a", 14, "operator < is not supported for project"},
+		{"status:closed", 8, `invalid status closed, want open, done or overdue`},
+		{"due<soon", 5, "invalid due date soon, want a date like 2025-01-31, today, 7d or none"},
+		{"due>none", 5, "operator > is not supported for due:none"},
+		{"tag: x", 5, "missing value for tag"},
+		{`молоко "купить`, 8, "unterminated quoted string"},
+		{"milk and bread", 6, `no words to search in and, operators are written in uppercase`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			_, err := ParseQuery(tc.query, time.Now())
+			var qerr *QueryError
+			if !errors.As(err, &qerr) {
+				t.Fatalf("expected *QueryError, got %v", err)
+			}
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Error("query error must match ErrInvalidQuery")
+			}
+			if qerr.Pos != tc.pos || qerr.Message != tc.msg {
+				t.Errorf("expected %q at %d, got %q at %d", tc.msg, tc.pos, qerr.Message, qerr.Pos)
+			}
+		})
+	}
+}
+
+func TestQueryExpr_Match(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, loc)
+	at := func(day, hour int) *time.Time {
+		d := time.Date(2025, 3, day, hour, 0, 0, 0, loc)
+		return &d
+	}
+
+	todos := []*Todo{
+		{ID: 1, Title: "Купить молоко", Project: "Home", Tags: []string{"Urgent"}, DueDate: at(9, 18)},
+		{ID: 2, Title: "Написать отчет", Description: "квартальный отчет", Project: "work", DueDate: at(10, 23)},
+		{ID: 3, Title: "Позвонить маме", Project: "personal", Tags: []string{"family"}, DueDate: at(14, 10)},
+		{ID: 4, Title: "Прочитать книгу", Completed: true, Tags: []string{"urgent"}, DueDate: at(1, 9)},
+		{ID: 5, Title: "Buy milk"},
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"status:open", []int{1, 2, 3, 5}},
+		{"status:done", []int{4}},
+		{"status:overdue", []int{1}},
+		{"project:HOME", []int{1}},
+		{`project:""`, []int{4, 5}},
+		{"tag:urgent", []int{1, 4}},
+		{"due:today", []int{2}},
+		{"due:yesterday", []int{1}},
+		{"due<today", []int{1, 4}},
+		{"due<=today", []int{1, 2, 4}},
+		{"due>today", []int{3}},
+		{"due>=2025-03-10", []int{2, 3}},
+		{"due<now", []int{1, 4}},
+		{"due<7d due>now", []int{2, 3}},
+		{"due<-1w", []int{4}},
+		{"due:2025-03-14T07:00:00Z", []int{3}},
+		{"due:none", []int{5}},
+		{"due:any", []int{1, 2, 3, 4}},
+		{"отчеты", []int{2}},
+		{`"квартальный отчет"`, []int{2}},
+		{"mil*", []int{5}},
+		{"status:open AND (tag:urgent OR due<7d) AND NOT project:personal", []int{1, 2}},
+		{"NOT tag:urgent", []int{2, 3, 5}},
+		{"tag:family OR status:done", []int{3, 4}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := ParseQuery(tc.query, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []int
+			for _, todo := range todos {
+				if expr.Match(todo) {
+					got = append(got, todo.ID)
+				}
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Ранг задает ручной порядок задач в списке (см. Todo.Rank). Это дробная
+// часть числа в системе счисления по основанию 62, записанная цифрами
+// rankDigits: "V" - 0.5, "V1" чуть больше. Цифры упорядочены как байты
+// ASCII, поэтому ранги сравниваются как строки, а между любыми двумя
+// рангами есть третий. Ранг не оканчивается нулем: "V0" и "V" - одно и то
+// же число.
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// MaxRankLength - длина ранга, после которой ранги списка
+// перераспределяются (см. Ranks). Каждая перестановка в один и тот же
+// промежуток удлиняет ранг примерно на символ за шесть перестановок.
+const MaxRankLength = 24
+
+// rankStep - разряд, в котором увеличивается ранг при добавлении задачи в
+// конец списка: так в конец помещается 62^3 задач с промежутками для
+// перестановок без удлинения рангов
+const rankStep = 3
+
+// ErrInvalidMove - перестановка задачи, которую нельзя выполнить: соседа
+// нет, он в другом списке или это сама задача
+var ErrInvalidMove = errors.New("invalid move")
+
+// Anchor - сосед, рядом с которым ставится переставляемая задача: сразу
+// перед задачей Before или сразу после задачи After. Задается ровно одно
+// из полей; сосед должен быть в том же списке (проекте).
+type Anchor struct {
+	Before int `json:"before,omitempty"`
+	After  int `json:"after,omitempty"`
+}
+
+// Validate проверяет, что задан ровно один сосед
+func (a Anchor) Validate() error {
+	var verr ValidationError
+	switch {
+	case a.Before < 0:
+		verr.Add("before", CodeInvalid, "before must be a todo ID")
+	case a.After < 0:
+		verr.Add("after", CodeInvalid, "after must be a todo ID")
+	case a.Before == 0 && a.After == 0:
+		verr.Add("before", CodeRequired, "either before or after is required")
+	case a.Before != 0 && a.After != 0:
+		verr.Add("after", CodeInvalid, "only one of before and after may be set")
+	}
+	return verr.Err()
+}
+
+// ID возвращает ID соседа
+func (a Anchor) ID() int {
+	if a.Before != 0 {
+		return a.Before
+	}
+	return a.After
+}
+
+// ValidRank сообщает, что rank - непустая строка из цифр ранга, которая не
+// оканчивается нулем
+func ValidRank(rank string) bool {
+	if rank == "" || rank[len(rank)-1] == rankDigits[0] {
+		return false
+	}
+	for i := range len(rank) {
+		if strings.IndexByte(rankDigits, rank[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RankBetween возвращает ранг строго между prev и next; пустая строка
+// означает край списка. prev должен быть меньше next. В конец и в начало
+// списка ранг подбирается с шагом rankStep, а между соседями - посередине.
+func RankBetween(prev, next string) string {
+	switch {
+	case prev == "" && next == "":
+		return rankDigits[len(rankDigits)/2 : len(rankDigits)/2+1]
+	case next == "":
+		if rank, ok := stepRank(prev, 1); ok {
+			return rank
+		}
+	case prev == "":
+		if rank, ok := stepRank(next, -1); ok && rank < next {
+			return rank
+		}
+	}
+	return midRank(prev, next)
+}
+
+// stepRank прибавляет к рангу, округленному до rankStep разрядов вниз,
+// единицу младшего из них (delta = 1) или округляет его вниз и вычитает
+// единицу, если округление ничего не изменило (delta = -1). ok = false,
+// если результат вышел за пределы (0, 1).
+func stepRank(rank string, delta int) (string, bool) {
+	digits := make([]int, rankStep)
+	for i := range digits {
+		if i < len(rank) {
+			digits[i] = strings.IndexByte(rankDigits, rank[i])
+		}
+	}
+	if delta < 0 && len(rank) > rankStep {
+		// Отброшенные разряды ненулевые: округленный ранг уже меньше
+		delta = 0
+	}
+	for i := len(digits) - 1; i >= 0 && delta != 0; i-- {
+		digits[i] += delta
+		delta = 0
+		switch {
+		case digits[i] >= len(rankDigits):
+			digits[i] -= len(rankDigits)
+			delta = 1
+		case digits[i] < 0:
+			digits[i] += len(rankDigits)
+			delta = -1
+		}
+	}
+	if delta != 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	for _, d := range digits {
+		b.WriteByte(rankDigits[d])
+	}
+	result := strings.TrimRight(b.String(), rankDigits[:1])
+	return result, result != ""
+}
+
+// midRank возвращает ранг посередине между a и b; b = "" - единица.
+// Общее начало сохраняется, а следующий разряд берется посередине, если
+// между разрядами есть место, иначе ранг удлиняется.
+func midRank(a, b string) string {
+	if b != "" {
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midRank(tail(a, n), b[n:])
+		}
+	}
+
+	lo := 0
+	if a != "" {
+		lo = strings.IndexByte(rankDigits, a[0])
+	}
+	hi := len(rankDigits)
+	if b != "" {
+		hi = strings.IndexByte(rankDigits, b[0])
+	}
+	if hi-lo > 1 {
+		return rankDigits[(lo+hi+1)/2 : (lo+hi+1)/2+1]
+	}
+	if len(b) > 1 {
+		// Первый разряд b без остальных меньше b, но больше a
+		return b[:1]
+	}
+	return rankDigits[lo:lo+1] + midRank(tail(a, 1), "")
+}
+
+// digitAt возвращает разряд i ранга, дополненного нулями
+func digitAt(rank string, i int) byte {
+	if i < len(rank) {
+		return rank[i]
+	}
+	return rankDigits[0]
+}
+
+func tail(rank string, n int) string {
+	if n >= len(rank) {
+		return ""
+	}
+	return rank[n:]
+}
+
+// Ranks возвращает n возрастающих рангов не длиннее необходимого, равномерно
+// распределенных по (0, 1), с промежутками для дальнейших перестановок
+func Ranks(n int) []string {
+	// Длина, при которой промежуток между соседними рангами не меньше
+	// len(rankDigits) единиц младшего разряда
+	width, capacity := 1, uint64(len(rankDigits))
+	for capacity < uint64(n+1)*uint64(len(rankDigits)) {
+		width++
+		capacity *= uint64(len(rankDigits))
+	}
+
+	ranks := make([]string, n)
+	buf := make([]byte, width)
+	for i := range ranks {
+		v := capacity / uint64(n+1) * uint64(i+1)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = rankDigits[v%uint64(len(rankDigits))]
+			v /= uint64(len(rankDigits))
+		}
+		ranks[i] = strings.TrimRight(string(buf), rankDigits[:1])
+	}
+	return ranks
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency - единица периода повторения задачи
+type Frequency string
+
+// Периоды повторения (значения FREQ из RFC 5545)
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+	FreqYearly  Frequency = "YEARLY"
+)
+
+// MaxRecurrenceInterval ограничивает INTERVAL правила повторения
+const MaxRecurrenceInterval = 999
+
+// rruleDays - имена дней недели в BYDAY, индекс - time.Weekday
+var rruleDays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Recurrence - правило повторения задачи: подмножество RRULE из RFC 5545
+// с частями FREQ, INTERVAL и BYDAY (только для WEEKLY)
+type Recurrence struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []time.Weekday
+}
+
+// ParseRecurrence разбирает правило вида "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH".
+// Префикс "RRULE:" допускается; INTERVAL по умолчанию 1.
+func ParseRecurrence(s string) (Recurrence, error) {
+	rule := Recurrence{Interval: 1}
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	seen := make(map[string]bool)
+	for part := range strings.SplitSeq(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Recurrence{}, fmt.Errorf("recurrence part %q must look like NAME=VALUE", part)
+		}
+		if seen[name] {
+			return Recurrence{}, fmt.Errorf("recurrence part %s is repeated", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = f
+			default:
+				return Recurrence{}, fmt.Errorf("recurrence FREQ %s is not supported, want DAILY, WEEKLY, MONTHLY or YEARLY", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > MaxRecurrenceInterval {
+				return Recurrence{}, fmt.Errorf("recurrence INTERVAL must be between 1 and %d", MaxRecurrenceInterval)
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for day := range strings.SplitSeq(value, ",") {
+				wd := slices.Index(rruleDays[:], day)
+				if wd < 0 {
+					return Recurrence{}, fmt.Errorf("recurrence BYDAY has unknown day %q", day)
+				}
+				if !slices.Contains(rule.ByDay, time.Weekday(wd)) {
+					rule.ByDay = append(rule.ByDay, time.Weekday(wd))
+				}
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("recurrence part %s is not supported", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Recurrence{}, fmt.Errorf("recurrence must have FREQ")
+	}
+	if rule.ByDay != nil && rule.Freq != FreqWeekly {
+		return Recurrence{}, fmt.Errorf("recurrence BYDAY is only supported with FREQ=WEEKLY")
+	}
+	return rule, nil
+}
+
+// String возвращает правило в каноническом виде: INTERVAL опускается, если
+// равен 1, дни BYDAY идут с понедельника
+func (r Recurrence) String() string {
+	var b strings.Builder
+	b.WriteString("FREQ=" + string(r.Freq))
+	if r.Interval > 1 {
+		b.WriteString(";INTERVAL=" + strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := slices.Clone(r.ByDay)
+		// Неделя в RRULE начинается с понедельника
+		slices.SortFunc(days, func(a, b time.Weekday) int { return (int(a)+6)%7 - (int(b)+6)%7 })
+		names := make([]string, len(days))
+		for i, d := range days {
+			names[i] = rruleDays[d]
+		}
+		b.WriteString(";BYDAY=" + strings.Join(names, ","))
+	}
+	return b.String()
+}
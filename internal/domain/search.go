@@ -0,0 +1,18 @@
+package domain
+
+// SearchHit - задача, найденная полнотекстовым поиском. Score - оценка
+// релевантности BM25: сравнима только между результатами одного запроса.
+type SearchHit struct {
+	Todo     *Todo          `json:"todo"`
+	Score    float64        `json:"score"`
+	Snippets SearchSnippets `json:"snippets"`
+}
+
+// SearchSnippets - фрагменты полей задачи, в которых найденные слова
+// обрамлены <mark> и </mark>, а остальной текст экранирован как HTML.
+// Description - часть описания вокруг первого совпадения; пуст, если в
+// описании совпадений нет.
+type SearchSnippets struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
@@ -0,0 +1,60 @@
+package domain
+
+// Change - запись журнала изменений для синхронизации клиентов. Журнал
+// хранится сжатым: для каждой задачи только последнее изменение, поэтому
+// Seq совпадает с Todo.Version. Удаленная задача остается в журнале
+// надгробием: Deleted равен true, а Todo пуст.
+type Change struct {
+	Seq     uint64 `json:"seq"`
+	ID      int    `json:"id"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Todo    *Todo  `json:"todo,omitempty"`
+}
+
+// SyncOp - операция, которую офлайн-клиент отправляет при синхронизации
+type SyncOp string
+
+// Операции синхронизации
+const (
+	SyncCreate SyncOp = "create"
+	SyncUpdate SyncOp = "update"
+	SyncDelete SyncOp = "delete"
+)
+
+// SyncChange - изменение, сделанное клиентом без связи. BaseVersion -
+// версия задачи, которую клиент видел перед изменением.
+type SyncChange struct {
+	Op          SyncOp
+	ID          int
+	BaseVersion uint64
+	Todo        *Todo
+}
+
+// SyncStatus - итог применения изменения клиента
+type SyncStatus string
+
+// Итоги применения изменения
+const (
+	// SyncApplied - изменение применено
+	SyncApplied SyncStatus = "applied"
+	// SyncMerged - задача менялась после BaseVersion, и изменение слито с
+	// этими правками (см. TodoRepository.Merge); результат содержит
+	// состояние после слияния
+	SyncMerged SyncStatus = "merged"
+	// SyncConflict - изменение не применено: BaseVersion слишком старая,
+	// чтобы слить его, и результат содержит текущее состояние, или задача
+	// удалена (Deleted)
+	SyncConflict SyncStatus = "conflict"
+	// SyncRejected - изменение некорректно (например, не прошло валидацию)
+	SyncRejected SyncStatus = "rejected"
+)
+
+// SyncResult - результат применения одного изменения. Todo - состояние
+// задачи после применения или, при конфликте, текущее состояние на сервере;
+// Deleted сообщает, что задачи на сервере нет.
+type SyncResult struct {
+	Status  SyncStatus
+	Todo    *Todo
+	Deleted bool
+	Err     error
+}
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Outbox - очередь исходящих событий изменений задач. Хранилище записывает
+// событие в той же операции, что и само изменение, поэтому событие не
+// теряется, если процесс упадет сразу после изменения. ID события - номер
+// изменения в журнале хранилища.
+type Outbox interface {
+	// OutboxEvents возвращает события с ID больше after по порядку, не
+	// больше limit (0 - без ограничения), и ID последнего записанного события
+	OutboxEvents(ctx context.Context, after uint64, limit int) ([]TodoEvent, uint64, error)
+	// AckOutbox удаляет из очереди события с ID не больше upTo
+	AckOutbox(ctx context.Context, upTo uint64) error
+}
+
+// Ограничения подписок на вебхуки
+const (
+	MaxWebhookURLLength    = 2000
+	MinWebhookSecretLength = 16
+	MaxWebhookSecretLength = 256
+)
+
+// Webhook - подписка внешнего получателя на изменения задач. События
+// отправляются POST запросом на URL и подписываются секретом (HMAC-SHA256).
+// Пустой Events означает подписку на все виды событий.
+type Webhook struct {
+	ID        int          `json:"id"`
+	URL       string       `json:"url"`
+	Events    []EventType  `json:"events"`
+	Secret    string       `json:"secret"`
+	Active    bool         `json:"active"`
+	CreatedAt time.Time    `json:"created_at"`
+	State     WebhookState `json:"state"`
+}
+
+// WebhookState - состояние доставки подписки, которое ведет отправитель.
+// Cursor - ID последнего обработанного события очереди, Failures - число
+// неудачных попыток подряд. Disabled выставляется, когда неудач слишком
+// много; такая подписка не получает событий, пока ее не включат снова.
+type WebhookState struct {
+	Cursor     uint64     `json:"cursor"`
+	Failures   int        `json:"failures"`
+	Disabled   bool       `json:"disabled"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+// Deliverable сообщает, нужно ли отправлять подписке события
+func (w *Webhook) Deliverable() bool {
+	return w.Active && !w.State.Disabled
+}
+
+// Subscribed сообщает, подписан ли получатель на события вида eventType
+func (w *Webhook) Subscribed(eventType EventType) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// Clone возвращает независимую копию подписки
+func (w *Webhook) Clone() *Webhook {
+	c := *w
+	c.Events = slices.Clone(w.Events)
+	if w.State.DisabledAt != nil {
+		at := *w.State.DisabledAt
+		c.State.DisabledAt = &at
+	}
+	return &c
+}
+
+// Validate проверяет настройки подписки и возвращает *ValidationError со
+// всеми найденными нарушениями. URL с адресом внутренней сети отклоняется,
+// если адрес не входит в allowed (см. WebhookTargetAllowed); имена хостов,
+// кроме localhost, проверяет отправитель при соединении.
+func (w *Webhook) Validate(allowed ...netip.Prefix) error {
+	var verr ValidationError
+
+	if w.URL == "" {
+		verr.Add("url", CodeRequired, "url cannot be empty")
+	} else if len(w.URL) > MaxWebhookURLLength {
+		verr.Add("url", CodeTooLong, fmt.Sprintf("url must be at most %d characters", MaxWebhookURLLength))
+	} else if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		verr.Add("url", CodeInvalid, "url must be an absolute http or https URL")
+	} else if ip, ok := hostAddr(u.Hostname()); ok && !WebhookTargetAllowed(ip, allowed) {
+		verr.Add("url", CodeInvalid, "url must not point to a loopback, link-local, private or unspecified address")
+	}
+	for i, eventType := range w.Events {
+		switch eventType {
+		case EventTodoCreated, EventTodoUpdated, EventTodoDeleted:
+		default:
+			verr.Add(fmt.Sprintf("events[%d]", i), CodeInvalid, fmt.Sprintf("unknown event type %q", eventType))
+		}
+	}
+	switch n := utf8.RuneCountInString(w.Secret); {
+	case n < MinWebhookSecretLength:
+		verr.Add("secret", CodeInvalid, fmt.Sprintf("secret must be at least %d characters", MinWebhookSecretLength))
+	case n > MaxWebhookSecretLength:
+		verr.Add("secret", CodeTooLong, fmt.Sprintf("secret must be at most %d characters", MaxWebhookSecretLength))
+	}
+
+	return verr.Err()
+}
+
+// WebhookTargetAllowed сообщает, можно ли отправлять вебхуки на адрес ip.
+// Адреса loopback, link-local, частных сетей и неопределенный адрес
+// запрещены, чтобы подписка не открывала доступ к внутренним сервисам
+// (SSRF), кроме входящих в allowed - например, для получателя на той же
+// машине.
+func WebhookTargetAllowed(ip netip.Addr, allowed []netip.Prefix) bool {
+	ip = ip.Unmap().WithZone("")
+	for _, network := range allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsPrivate() && !ip.IsUnspecified()
+}
+
+// hostAddr возвращает адрес хоста URL, если он задан адресом или именем
+// localhost, которое всегда означает loopback
+func hostAddr(host string) (netip.Addr, bool) {
+	if host = strings.ToLower(strings.TrimSuffix(host, ".")); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return netip.AddrFrom4([4]byte{127, 0, 0, 1}), true
+	}
+	ip, err := netip.ParseAddr(host)
+	return ip, err == nil
+}
+
+// WebhookDelivery - попытка доставки события получателю для журнала
+// подписки. StatusCode равен нулю, если ответа не было; тогда причина в
+// Error.
+type WebhookDelivery struct {
+	EventID    uint64    `json:"event_id"`
+	EventType  EventType `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	Success    bool      `json:"success"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Time       time.Time `json:"time"`
+}
+
+// MaxWebhookDeliveries - сколько последних попыток хранится в журнале
+// подписки
+const MaxWebhookDeliveries = 100
+
+// WebhookRepository хранит подписки на вебхуки и журналы их доставки
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, hook *Webhook) error
+	GetWebhooks(ctx context.Context) ([]*Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*Webhook, error)
+	// UpdateWebhook заменяет настройки подписки; State не меняется
+	UpdateWebhook(ctx context.Context, hook *Webhook) error
+	// SetWebhookState заменяет состояние доставки подписки
+	SetWebhookState(ctx context.Context, id int, state WebhookState) error
+	DeleteWebhook(ctx context.Context, id int) error
+	// AddDelivery добавляет попытку в журнал подписки, храня не больше
+	// MaxWebhookDeliveries последних
+	AddDelivery(ctx context.Context, id int, delivery WebhookDelivery) error
+	// Deliveries возвращает журнал подписки, начиная с последней попытки
+	Deliveries(ctx context.Context, id int) ([]WebhookDelivery, error)
+}
+
+// ErrWebhookNotFound - подписки с таким ID нет
+var ErrWebhookNotFound = errors.New("webhook not found")
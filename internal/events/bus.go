@@ -0,0 +1,168 @@
+// Package events содержит шину изменений задач внутри процесса: use case
+// публикует события, а потоковые обработчики (SSE, WebSocket) на них
+// подписываются.
+package events
+
+import (
+	"sync"
+
+	"todo/internal/domain"
+)
+
+// Параметры по умолчанию
+const (
+	DefaultReplaySize       = 1024
+	defaultSubscriberBuffer = 64
+)
+
+// Filter отбирает события для подписчика; nil пропускает все
+type Filter func(event domain.TodoEvent) bool
+
+// Bus раздает события подписчикам и хранит последние события для
+// возобновления потока после переподключения. Медленный подписчик, чей буфер
+// переполнен, отключается: ему безопаснее переподключиться и получить
+// пропущенное из буфера повтора, чем тормозить остальных.
+type Bus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	replay      []domain.TodoEvent // кольцевой буфер
+	start       int                // индекс самого старого события
+	size        int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBus создает шину, помнящую replaySize последних событий
+func NewBus(replaySize int) *Bus {
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+	return &Bus{
+		nextID:      1,
+		replay:      make([]domain.TodoEvent, replaySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription - подписка на события. Канал C закрывается при Close
+// подписки или шины и при отключении медленного подписчика.
+type Subscription struct {
+	C <-chan domain.TodoEvent
+
+	bus     *Bus
+	ch      chan domain.TodoEvent
+	filter  Filter
+	dropped bool
+}
+
+// Close отменяет подписку
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
+
+// Dropped сообщает, что подписка закрыта из-за переполнения буфера, а не
+// отменой или закрытием шины
+func (s *Subscription) Dropped() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	return s.dropped
+}
+
+// Publish назначает событию следующий ID, запоминает его и рассылает
+// подписчикам
+func (b *Bus) Publish(event domain.TodoEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	event.ID = b.nextID
+	b.nextID++
+
+	if b.size < len(b.replay) {
+		b.replay[(b.start+b.size)%len(b.replay)] = event
+		b.size++
+	} else {
+		b.replay[b.start] = event
+		b.start = (b.start + 1) % len(b.replay)
+	}
+
+	for s := range b.subscribers {
+		if s.filter != nil && !s.filter(event) {
+			continue
+		}
+		select {
+		case s.ch <- event:
+		default:
+			s.dropped = true
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe подписывается на события после lastID (0 - только новые) и
+// возвращает уже произошедшие события после lastID из буфера повтора.
+// complete равен false, если часть событий после lastID уже вытеснена из
+// буфера и клиенту нужно заново загрузить состояние.
+func (b *Bus) Subscribe(lastID uint64, filter Filter) (sub *Subscription, replay []domain.TodoEvent, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan domain.TodoEvent, defaultSubscriberBuffer)
+	sub = &Subscription{C: ch, bus: b, ch: ch, filter: filter}
+	if b.closed {
+		close(ch)
+		return sub, nil, true
+	}
+	b.subscribers[sub] = struct{}{}
+
+	complete = true
+	if lastID > 0 {
+		oldest := b.nextID - uint64(b.size)
+		// Клиент мог получить события от прошлого запуска процесса с
+		// большими ID; их продолжение неизвестно
+		if lastID+1 < oldest || lastID >= b.nextID {
+			complete = false
+		}
+		for i := 0; i < b.size; i++ {
+			event := b.replay[(b.start+i)%len(b.replay)]
+			if event.ID > lastID && (filter == nil || filter(event)) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return sub, replay, complete
+}
+
+// LastID возвращает ID последнего опубликованного события
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.nextID - 1
+}
+
+// Close закрывает все подписки; последующие события отбрасываются
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s)
+	}
+}
+
+// remove закрывает канал подписки; вызывается под b.mu
+func (b *Bus) remove(s *Subscription) {
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"testing"
+
+	"todo/internal/domain"
+)
+
+func publish(b *Bus, n int, project string) {
+	for i := 0; i < n; i++ {
+		b.Publish(domain.TodoEvent{Type: domain.EventTodoCreated, Todo: &domain.Todo{ID: i + 1, Project: project}})
+	}
+}
+
+func TestBus_Subscribe(t *testing.T) {
+	b := NewBus(4)
+	sub, replay, complete := b.Subscribe(0, nil)
+	defer sub.Close()
+
+	if len(replay) != 0 || !complete {
+		t.Fatalf("new subscriber must get no replay, got %v %v", replay, complete)
+	}
+
+	publish(b, 2, "")
+	for want := uint64(1); want <= 2; want++ {
+		if event := <-sub.C; event.ID != want {
+			t.Errorf("expected event %d, got %d", want, event.ID)
+		}
+	}
+	if b.LastID() != 2 {
+		t.Errorf("expected last ID 2, got %d", b.LastID())
+	}
+}
+
+func TestBus_Replay(t *testing.T) {
+	b := NewBus(4)
+	publish(b, 6, "") // события 1 и 2 вытеснены
+
+	tests := []struct {
+		lastID       uint64
+		wantFirst    uint64
+		wantLen      int
+		wantComplete bool
+	}{
+		{lastID: 6, wantLen: 0, wantComplete: true},
+		{lastID: 3, wantFirst: 4, wantLen: 3, wantComplete: true},
+		{lastID: 2, wantFirst: 3, wantLen: 4, wantComplete: true},
+		{lastID: 1, wantFirst: 3, wantLen: 4, wantComplete: false},
+		{lastID: 100, wantLen: 0, wantComplete: false},
+	}
+	for _, tc := range tests {
+		sub, replay, complete := b.Subscribe(tc.lastID, nil)
+		sub.Close()
+
+		if len(replay) != tc.wantLen || complete != tc.wantComplete {
+			t.Errorf("lastID %d: expected %d events (complete %v), got %d (%v)",
+				tc.lastID, tc.wantLen, tc.wantComplete, len(replay), complete)
+			continue
+		}
+		if tc.wantLen > 0 && replay[0].ID != tc.wantFirst {
+			t.Errorf("lastID %d: expected replay to start at %d, got %d", tc.lastID, tc.wantFirst, replay[0].ID)
+		}
+	}
+}
+
+func TestBus_Filter(t *testing.T) {
+	b := NewBus(8)
+	publish(b, 1, "work")
+	publish(b, 1, "home")
+
+	onlyWork := func(e domain.TodoEvent) bool { return e.Todo.Project == "work" }
+	sub, replay, _ := b.Subscribe(0, onlyWork)
+	defer sub.Close()
+	if len(replay) != 0 {
+		t.Fatalf("lastID 0 must not replay, got %d events", len(replay))
+	}
+
+	publish(b, 1, "home")
+	publish(b, 1, "work")
+	if event := <-sub.C; event.Todo.Project != "work" || event.ID != 4 {
+		t.Errorf("expected only work events, got %+v", event)
+	}
+
+	_, replay, _ = b.Subscribe(1, onlyWork)
+	if len(replay) != 1 || replay[0].ID != 4 {
+		t.Errorf("replay must be filtered too, got %+v", replay)
+	}
+}
+
+func TestBus_SlowSubscriberDropped(t *testing.T) {
+	b := NewBus(8)
+	slow, _, _ := b.Subscribe(0, nil)
+
+	publish(b, defaultSubscriberBuffer+1, "")
+
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != defaultSubscriberBuffer {
+		t.Errorf("slow subscriber must be closed after a full buffer, got %d events", n)
+	}
+	if !slow.Dropped() {
+		t.Error("Dropped must report an overflowed subscription")
+	}
+}
+
+func TestBus_Close(t *testing.T) {
+	b := NewBus(8)
+	sub, _, _ := b.Subscribe(0, nil)
+
+	b.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription must be closed")
+	}
+	sub.Close() // повторное закрытие безопасно
+	if sub.Dropped() {
+		t.Error("a subscription closed with the bus is not dropped")
+	}
+
+	publish(b, 1, "")
+	if b.LastID() != 0 {
+		t.Error("closed bus must drop events")
+	}
+	late, _, _ := b.Subscribe(0, nil)
+	if _, ok := <-late.C; ok {
+		t.Error("subscription to a closed bus must be closed")
+	}
+}
@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"todo/internal/domain"
+)
+
+// Pinger реализуется хранилищами, умеющими дешево проверить соединение
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// RepositoryChecker проверяет доступность репозитория. Если репозиторий
+// реализует Pinger, используется Ping, иначе выполняется чтение
+// несуществующей задачи: ErrTodoNotFound означает, что хранилище отвечает.
+func RepositoryChecker(repo domain.TodoRepository) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if p, ok := repo.(Pinger); ok {
+			return p.Ping(ctx)
+		}
+
+		_, err := repo.GetByID(ctx, 0)
+		if err == nil || errors.Is(err, domain.ErrTodoNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// Heartbeat отслеживает, что фоновый цикл (планировщик, воркер) продолжает
+// работать: цикл вызывает Beat на каждой итерации, а проверка проваливается,
+// если последний сигнал старше maxAge
+type Heartbeat struct {
+	maxAge time.Duration
+	last   atomic.Int64
+}
+
+// NewHeartbeat создает монитор; до первого Beat отсчет идет от создания
+func NewHeartbeat(maxAge time.Duration) *Heartbeat {
+	h := &Heartbeat{maxAge: maxAge}
+	h.Beat()
+	return h
+}
+
+// Beat отмечает, что цикл жив
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Check проверяет давность последнего сигнала
+func (h *Heartbeat) Check(ctx context.Context) error {
+	age := time.Since(time.Unix(0, h.last.Load()))
+	if age > h.maxAge {
+		return fmt.Errorf("no heartbeat for %s", age.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// DiskSpaceChecker проверяет, что на файловой системе с path свободно не
+// меньше minFree байт
+func DiskSpaceChecker(path string, minFree uint64) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		free, err := freeSpace(path)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", path, err)
+		}
+		if free < minFree {
+			return fmt.Errorf("only %d bytes free on %s, need %d", free, path, minFree)
+		}
+		return nil
+	})
+}
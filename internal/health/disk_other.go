@@ -0,0 +1,10 @@
+//go:build !unix
+
+package health
+
+import "errors"
+
+// freeSpace не поддерживается на этой платформе
+func freeSpace(path string) (uint64, error) {
+	return 0, errors.New("disk space check is not supported on this platform")
+}
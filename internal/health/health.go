@@ -0,0 +1,198 @@
+// Package health реализует пробы живости (/livez) и готовности (/readyz) с
+// подключаемыми проверками зависимостей.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"todo/internal/http/problem"
+)
+
+// DefaultCheckTimeout ограничивает время одной проверки
+const DefaultCheckTimeout = 2 * time.Second
+
+// ErrShuttingDown - причина неготовности во время graceful shutdown
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Checker проверяет состояние зависимости
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc позволяет использовать функцию как Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check вызывает f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Status - итог проверки
+type Status string
+
+// Возможные статусы
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// CheckResult - результат одной проверки
+type CheckResult struct {
+	Status   Status `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report - ответ пробы
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Registry хранит проверки живости и готовности
+type Registry struct {
+	timeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  map[string]Checker
+	readiness map[string]Checker
+
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry создает реестр проверок с таймаутом DefaultCheckTimeout
+func NewRegistry() *Registry {
+	return &Registry{
+		timeout:   DefaultCheckTimeout,
+		liveness:  make(map[string]Checker),
+		readiness: make(map[string]Checker),
+	}
+}
+
+// AddLivenessCheck добавляет проверку живости. Провал означает, что процесс
+// завис и его нужно перезапустить, поэтому здесь не место внешним
+// зависимостям.
+func (r *Registry) AddLivenessCheck(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.liveness[name] = c
+}
+
+// AddReadinessCheck добавляет проверку готовности принимать трафик
+func (r *Registry) AddReadinessCheck(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.readiness[name] = c
+}
+
+// SetShuttingDown переводит готовность в состояние отказа, чтобы балансировщик
+// перестал направлять новые запросы до остановки сервера
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Live выполняет проверки живости
+func (r *Registry) Live(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := copyChecks(r.liveness)
+	r.mu.RUnlock()
+
+	return r.run(ctx, checks)
+}
+
+// Ready выполняет проверки готовности
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := copyChecks(r.readiness)
+	r.mu.RUnlock()
+
+	if r.shuttingDown.Load() {
+		checks["shutdown"] = CheckerFunc(func(context.Context) error { return ErrShuttingDown })
+	}
+	return r.run(ctx, checks)
+}
+
+// LivezHandler отдает результат проверок живости
+func (r *Registry) LivezHandler() http.Handler {
+	return reportHandler(r.Live)
+}
+
+// ReadyzHandler отдает результат проверок готовности
+func (r *Registry) ReadyzHandler() http.Handler {
+	return reportHandler(r.Ready)
+}
+
+// run выполняет проверки параллельно
+func (r *Registry) run(ctx context.Context, checks map[string]Checker) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for name, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.Check(ctx)
+			result := CheckResult{Status: StatusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func copyChecks(src map[string]Checker) map[string]Checker {
+	dst := make(map[string]Checker, len(src)+1)
+	for name, c := range src {
+		dst[name] = c
+	}
+	return dst
+}
+
+func reportHandler(probe func(ctx context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			problem.Error(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		report := probe(r.Context())
+
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"todo/internal/http/problem"
+	"todo/internal/repository"
+)
+
+func probe(t *testing.T, h http.Handler) (int, Report) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("expected JSON report: %v", err)
+	}
+	return rec.Code, report
+}
+
+func TestRegistry_Readyz(t *testing.T) {
+	reg := NewRegistry()
+	reg.AddReadinessCheck("repository", RepositoryChecker(repository.NewInMemoryTodoRepository()))
+
+	t.Run("все проверки проходят", func(t *testing.T) {
+		code, report := probe(t, reg.ReadyzHandler())
+
+		if code != http.StatusOK || report.Status != StatusOK {
+			t.Errorf("expected ok, got %d %+v", code, report)
+		}
+		if report.Checks["repository"].Status != StatusOK {
+			t.Errorf("expected repository check to pass, got %+v", report.Checks["repository"])
+		}
+	})
+
+	t.Run("проверка не проходит", func(t *testing.T) {
+		reg.AddReadinessCheck("broken", CheckerFunc(func(context.Context) error {
+			return errors.New("connection refused")
+		}))
+		defer reg.AddReadinessCheck("broken", CheckerFunc(func(context.Context) error { return nil }))
+
+		code, report := probe(t, reg.ReadyzHandler())
+
+		if code != http.StatusServiceUnavailable || report.Status != StatusFail {
+			t.Errorf("expected fail, got %d %+v", code, report)
+		}
+		if report.Checks["broken"].Error != "connection refused" {
+			t.Errorf("expected error detail, got %+v", report.Checks["broken"])
+		}
+	})
+
+	t.Run("остановка сервера", func(t *testing.T) {
+		reg.SetShuttingDown()
+
+		code, report := probe(t, reg.ReadyzHandler())
+
+		if code != http.StatusServiceUnavailable || report.Checks["shutdown"].Status != StatusFail {
+			t.Errorf("expected readiness to fail during shutdown, got %d %+v", code, report)
+		}
+
+		// Живость от остановки не зависит
+		if code, _ := probe(t, reg.LivezHandler()); code != http.StatusOK {
+			t.Errorf("expected liveness to stay ok, got %d", code)
+		}
+	})
+}
+
+func TestRegistry_MethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewRegistry().LivezHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/livez", nil))
+
+	var p problem.Problem
+	json.NewDecoder(rec.Body).Decode(&p)
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Content-Type") != problem.ContentType ||
+		p.Code != problem.CodeMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("expected 405 problem, got %d %q %+v", rec.Code, rec.Header().Get("Content-Type"), p)
+	}
+}
+
+func TestRegistry_Timeout(t *testing.T) {
+	reg := NewRegistry()
+	reg.timeout = 10 * time.Millisecond
+	reg.AddLivenessCheck("stuck", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	code, report := probe(t, reg.LivezHandler())
+	if code != http.StatusServiceUnavailable || report.Checks["stuck"].Status != StatusFail {
+		t.Errorf("expected stuck check to fail, got %d %+v", code, report)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	hb := NewHeartbeat(20 * time.Millisecond)
+	if err := hb.Check(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := hb.Check(context.Background()); err == nil {
+		t.Error("expected stale heartbeat to fail")
+	}
+
+	hb.Beat()
+	if err := hb.Check(context.Background()); err != nil {
+		t.Errorf("unexpected error after beat: %v", err)
+	}
+}
+
+func TestRegistry_LivezHeartbeat(t *testing.T) {
+	reg := NewRegistry()
+	hb := NewHeartbeat(20 * time.Millisecond)
+	reg.AddLivenessCheck("scheduler", hb)
+
+	if code, report := probe(t, reg.LivezHandler()); code != http.StatusOK {
+		t.Fatalf("expected live scheduler, got %d %+v", code, report)
+	}
+
+	// Цикл перестал подавать сигналы: процесс считается зависшим
+	time.Sleep(30 * time.Millisecond)
+	code, report := probe(t, reg.LivezHandler())
+	if code != http.StatusServiceUnavailable || report.Checks["scheduler"].Status != StatusFail {
+		t.Fatalf("expected stale heartbeat to fail /livez, got %d %+v", code, report)
+	}
+
+	hb.Beat()
+	if code, report := probe(t, reg.LivezHandler()); code != http.StatusOK {
+		t.Errorf("expected /livez to recover after a beat, got %d %+v", code, report)
+	}
+}
+
+func TestDiskSpaceChecker(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := DiskSpaceChecker(dir, 1).Check(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := DiskSpaceChecker(dir, 1<<62).Check(context.Background()); err == nil {
+		t.Error("expected failure for unrealistic free space requirement")
+	}
+}
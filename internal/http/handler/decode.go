@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+// DefaultMaxBodyBytes - ограничение размера тела запроса по умолчанию
+const DefaultMaxBodyBytes = 1 << 20
+
+// Коды нарушений полей, которые выявляются при разборе тела, а не доменной
+// валидацией
+const (
+	codeUnknownField = "unknown"
+	codeReadOnly     = "read_only"
+)
+
+// todoRequest - тело запросов на создание и обновление задачи. ID назначает
+// сервер, поэтому в теле он допустим только равным нулю или, при
+// обновлении, идентификатору из пути. Version и Rank тоже назначает сервер
+// (ранг меняет только POST /todos/{id}/move); поля принимаются и
+// игнорируются, чтобы клиент мог отправить задачу в том виде, в каком
+// получил ее.
+type todoRequest struct {
+	ID          int        `json:"id"`
+	Version     uint64     `json:"version"`
+	Rank        string     `json:"rank"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueDate     *time.Time `json:"due_date"`
+	Project     string     `json:"project"`
+	Tags        []string   `json:"tags"`
+	Priority    string     `json:"priority"`
+	Recurrence  string     `json:"recurrence"`
+}
+
+// checkID проверяет, что клиент не назначает идентификатор сам. id - ID из
+// пути запроса или 0 при создании.
+func (req *todoRequest) checkID(id int) *problem.Problem {
+	if req.ID == 0 || req.ID == id {
+		return nil
+	}
+	p := invalidBody("id is assigned by the server and cannot be set in the request body")
+	p.Errors = []problem.FieldError{{Field: "id", Code: codeReadOnly, Message: "id is read-only"}}
+	return p
+}
+
+func (req *todoRequest) todo() *domain.Todo {
+	return &domain.Todo{
+		Title:       req.Title,
+		Description: req.Description,
+		Completed:   req.Completed,
+		DueDate:     req.DueDate,
+		Project:     req.Project,
+		Tags:        req.Tags,
+		Priority:    domain.Priority(req.Priority),
+		Recurrence:  req.Recurrence,
+	}
+}
+
+// decodeJSON читает тело запроса в dst (см. decodeJSONBody)
+func (h *TodoHandler) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) *problem.Problem {
+	return decodeJSONBody(w, r, dst, h.maxBodyBytes)
+}
+
+// decodeJSONBody читает тело запроса как единственный JSON объект в dst.
+// Проверяются Content-Type, размер тела (не больше maxBytes), неизвестные
+// поля и данные после объекта; ошибка разбора указывает смещение в теле.
+// При ошибке возвращается готовая к отправке проблема.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) *problem.Problem {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia,
+			"Content-Type must be application/json")
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeProblem(err, dec.InputOffset())
+	}
+	end := dec.InputOffset()
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return invalidBody(fmt.Sprintf("unexpected data after JSON object ending at offset %d", end))
+	}
+	return nil
+}
+
+func decodeProblem(err error, offset int64) *problem.Problem {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+		timeErr   *time.ParseError
+	)
+
+	switch {
+	case errors.As(err, &maxErr):
+		return problem.New(http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+			fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+	case errors.Is(err, io.EOF):
+		return invalidBody("request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return invalidBody(fmt.Sprintf("unexpected end of JSON at offset %d", offset))
+	case errors.As(err, &syntaxErr):
+		return invalidBody(fmt.Sprintf("malformed JSON at offset %d: %v", syntaxErr.Offset, syntaxErr))
+	case errors.As(err, &typeErr):
+		p := invalidBody(fmt.Sprintf("field %q must be %s, got %s at offset %d", typeErr.Field, typeErr.Type, typeErr.Value, typeErr.Offset))
+		if typeErr.Field != "" {
+			p.Errors = []problem.FieldError{{Field: typeErr.Field, Code: domain.CodeInvalid, Message: "must be " + typeErr.Type.String()}}
+		}
+		return p
+	case errors.As(err, &timeErr):
+		p := invalidBody(fmt.Sprintf("invalid date before offset %d: expected RFC 3339", offset))
+		p.Errors = []problem.FieldError{{Field: "due_date", Code: domain.CodeInvalid, Message: "due_date must be an RFC 3339 date"}}
+		return p
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		p := invalidBody(fmt.Sprintf("unknown field %q before offset %d", field, offset))
+		p.Errors = []problem.FieldError{{Field: field, Code: codeUnknownField, Message: "unknown field"}}
+		return p
+	default:
+		return invalidBody(err.Error())
+	}
+}
+
+func invalidBody(detail string) *problem.Problem {
+	return problem.New(http.StatusBadRequest, problem.CodeInvalidBody, detail)
+}
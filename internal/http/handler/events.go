@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/events"
+	"todo/internal/http/problem"
+)
+
+// DefaultHeartbeatInterval - период комментариев-пульса в потоке событий
+const DefaultHeartbeatInterval = 15 * time.Second
+
+// eventReset сообщает клиенту, что часть событий потеряна и состояние нужно
+// загрузить заново
+const eventReset = "reset"
+
+// EventsHandler отдает изменения задач потоком Server-Sent Events
+type EventsHandler struct {
+	bus       *events.Bus
+	heartbeat time.Duration
+}
+
+// NewEventsHandler создает обработчик потока событий
+func NewEventsHandler(bus *events.Bus, heartbeat time.Duration) *EventsHandler {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeatInterval
+	}
+	return &EventsHandler{bus: bus, heartbeat: heartbeat}
+}
+
+// ServeHTTP обрабатывает GET /todos/events. Параметры project и tag (можно
+// несколько) ограничивают поток задачами проекта и задачами хотя бы с одним
+// из тегов. Last-Event-ID (или параметр last_event_id) возобновляет поток
+// после переподключения; если пропущенные события уже недоступны, первым
+// приходит событие reset.
+func (h *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithMethods(w, r, http.MethodGet)
+		return
+	}
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidID, "Last-Event-ID must be a non-negative integer")
+		return
+	}
+
+	sub, replay, complete := h.bus.Subscribe(lastID, eventFilter(r))
+	defer sub.Close()
+
+	// Поток живет дольше WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+	if !complete {
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {}\n\n", h.bus.LastID(), eventReset)
+	}
+	for _, event := range replay {
+		writeEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				// Сервер останавливается или клиент не успевает читать:
+				// закрываем поток, клиент переподключится с Last-Event-ID
+				return
+			}
+			writeEvent(w, event)
+		case <-ticker.C:
+			io.WriteString(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w io.Writer, event domain.TodoEvent) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+func lastEventID(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// eventFilter строит фильтр по параметрам project и tag. Как и в запросах
+// (см. domain.ParseQuery), проект и теги сравниваются без учета регистра.
+func eventFilter(r *http.Request) events.Filter {
+	query := r.URL.Query()
+	project, tags := query.Get("project"), query["tag"]
+	if project == "" && len(tags) == 0 {
+		return nil
+	}
+
+	return func(event domain.TodoEvent) bool {
+		if project != "" && !strings.EqualFold(event.Todo.Project, project) {
+			return false
+		}
+		if len(tags) == 0 {
+			return true
+		}
+		for _, tag := range tags {
+			for _, t := range event.Todo.Tags {
+				if strings.EqualFold(t, tag) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/events"
+	"todo/internal/repository"
+	"todo/internal/usecase"
+)
+
+// sseEvent - разобранное событие потока
+type sseEvent struct {
+	id, event, data string
+}
+
+// readEvents читает из потока n событий, пропуская комментарии и retry
+func readEvents(t *testing.T, r *bufio.Reader, n int, heartbeats *int) []sseEvent {
+	t.Helper()
+
+	var result []sseEvent
+	var cur sseEvent
+	for len(result) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended after %d events: %v", len(result), err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if cur.event != "" {
+				result = append(result, cur)
+			}
+			cur = sseEvent{}
+		case strings.HasPrefix(line, ": heartbeat"):
+			if heartbeats != nil {
+				*heartbeats++
+			}
+		case strings.HasPrefix(line, "id: "):
+			cur.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			cur.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return result
+}
+
+func TestEventsHandler(t *testing.T) {
+	bus := events.NewBus(16)
+	uc := usecase.NewTodoUseCase(repository.NewInMemoryTodoRepository(), usecase.WithEventPublisher(bus))
+	server := httptest.NewServer(NewEventsHandler(bus, 50*time.Millisecond))
+	defer server.Close()
+
+	ctx := context.Background()
+	open := func(t *testing.T, query string, header ...string) *bufio.Reader {
+		t.Helper()
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/todos/events"+query, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("unexpected response %d %v", resp.StatusCode, resp.Header)
+		}
+		return bufio.NewReader(resp.Body)
+	}
+
+	t.Run("поток изменений с фильтром", func(t *testing.T) {
+		// Подписка создается до ответа, поэтому события ниже не потеряются.
+		// Проект и теги сравниваются без учета регистра.
+		stream := open(t, "?project=Work&tag=urgent&tag=TODAY")
+
+		uc.CreateTodo(ctx, &domain.Todo{Title: "home", Project: "home", Tags: []string{"urgent"}})
+		uc.CreateTodo(ctx, &domain.Todo{Title: "untagged", Project: "work"})
+		work, _ := uc.CreateTodo(ctx, &domain.Todo{Title: "work", Project: "work", Tags: []string{"today"}})
+		uc.UpdateTodo(ctx, work.ID, &domain.Todo{Title: "work 2", Project: "work", Tags: []string{"today"}})
+		uc.DeleteTodo(ctx, work.ID)
+
+		got := readEvents(t, stream, 3, nil)
+		types := []domain.EventType{domain.EventTodoCreated, domain.EventTodoUpdated, domain.EventTodoDeleted}
+		for i, event := range got {
+			var payload domain.TodoEvent
+			if err := json.Unmarshal([]byte(event.data), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if event.event != string(types[i]) || payload.Type != types[i] || payload.Todo.ID != work.ID {
+				t.Errorf("event %d: unexpected %+v", i, event)
+			}
+		}
+	})
+
+	t.Run("возобновление по Last-Event-ID", func(t *testing.T) {
+		last := bus.LastID()
+		uc.CreateTodo(ctx, &domain.Todo{Title: "a"})
+		uc.CreateTodo(ctx, &domain.Todo{Title: "b"})
+
+		got := readEvents(t, open(t, "", "Last-Event-ID", itoa(last)), 2, nil)
+		if got[0].id != itoa(last+1) || got[1].id != itoa(last+2) {
+			t.Errorf("expected events after %d, got %+v", last, got)
+		}
+	})
+
+	t.Run("сброс, если события потеряны", func(t *testing.T) {
+		got := readEvents(t, open(t, "?last_event_id=100000"), 1, nil)
+		if got[0].event != eventReset {
+			t.Errorf("expected reset event, got %+v", got[0])
+		}
+	})
+
+	t.Run("пульс", func(t *testing.T) {
+		stream := open(t, "")
+		heartbeats := 0
+		go func() {
+			time.Sleep(120 * time.Millisecond)
+			uc.CreateTodo(ctx, &domain.Todo{Title: "after heartbeat"})
+		}()
+		readEvents(t, stream, 1, &heartbeats)
+		if heartbeats == 0 {
+			t.Error("expected heartbeat comments while idle")
+		}
+	})
+
+	t.Run("неверный Last-Event-ID", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/todos/events?last_event_id=abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("закрытие при остановке", func(t *testing.T) {
+		stream := open(t, "")
+		bus.Close()
+
+		done := make(chan error)
+		go func() {
+			for {
+				if _, err := stream.ReadString('\n'); err != nil {
+					done <- err
+					return
+				}
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("stream must end when the bus is closed")
+		}
+	})
+}
+
+func itoa(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"todo/internal/auth"
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+	"todo/internal/usecase"
+)
+
+// FilterHandler обрабатывает запросы к сохраненным фильтрам и встроенным
+// спискам. Фильтры принадлежат принципалу запроса.
+type FilterHandler struct {
+	useCase      *usecase.FilterUseCase
+	maxBodyBytes int64
+}
+
+// NewFilterHandler создает обработчик фильтров; тело запроса ограничено
+// maxBodyBytes (0 - DefaultMaxBodyBytes)
+func NewFilterHandler(uc *usecase.FilterUseCase, maxBodyBytes int64) *FilterHandler {
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	return &FilterHandler{useCase: uc, maxBodyBytes: maxBodyBytes}
+}
+
+// filterRequest - тело запросов на создание и замену фильтра
+type filterRequest struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Sort  string `json:"sort"`
+}
+
+func (req *filterRequest) filter() *domain.Filter {
+	return &domain.Filter{Name: req.Name, Query: req.Query, Sort: req.Sort}
+}
+
+// HandleFilters обрабатывает /filters эндпоинт
+func (h *FilterHandler) HandleFilters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.CreateFilter(w, r)
+	case http.MethodGet:
+		h.GetFilters(w, r)
+	default:
+		respondWithMethods(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleFilterByID обрабатывает /filters/{id} и /filters/{id}/todos. Вместо
+// числового ID можно указать встроенный список: today, upcoming, overdue
+// или no-due-date.
+func (h *FilterHandler) HandleFilterByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 3 || (len(parts) == 3 && parts[2] != "todos") {
+		respondWithError(w, r, http.StatusNotFound, problem.CodeNotFound, "Not found")
+		return
+	}
+	todos := len(parts) == 3
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		// Встроенные списки только читаются
+		if r.Method != http.MethodGet {
+			respondWithMethods(w, r, http.MethodGet)
+			return
+		}
+		if todos {
+			h.GetSmartListTodos(w, r, parts[1])
+		} else {
+			h.GetSmartList(w, r, parts[1])
+		}
+		return
+	}
+
+	if todos {
+		if r.Method != http.MethodGet {
+			respondWithMethods(w, r, http.MethodGet)
+			return
+		}
+		h.GetFilterTodos(w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.GetFilter(w, r, id)
+	case http.MethodPut:
+		h.UpdateFilter(w, r, id)
+	case http.MethodDelete:
+		h.DeleteFilter(w, r, id)
+	default:
+		respondWithMethods(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// CreateFilter сохраняет фильтр (POST /filters)
+func (h *FilterHandler) CreateFilter(w http.ResponseWriter, r *http.Request) {
+	var req filterRequest
+	if p := decodeJSONBody(w, r, &req, h.maxBodyBytes); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	filter, err := h.useCase.CreateFilter(r.Context(), auth.PrincipalFromContext(r.Context()), req.filter())
+	if err != nil {
+		respondWithFilterError(w, r, err, "Failed to create filter")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, filter)
+}
+
+// GetFilters возвращает фильтры принципала (GET /filters)
+func (h *FilterHandler) GetFilters(w http.ResponseWriter, r *http.Request) {
+	filters, err := h.useCase.GetFilters(r.Context(), auth.PrincipalFromContext(r.Context()))
+	if err != nil {
+		respondWithFilterError(w, r, err, "Failed to fetch filters")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, filters)
+}
+
+// GetFilter возвращает фильтр (GET /filters/{id})
+func (h *FilterHandler) GetFilter(w http.ResponseWriter, r *http.Request, id int) {
+	filter, err := h.useCase.GetFilter(r.Context(), auth.PrincipalFromContext(r.Context()), id)
+	if err != nil {
+		respondWithFilterError(w, r, err, "Failed to fetch filter")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, filter)
+}
+
+// UpdateFilter заменяет фильтр (PUT /filters/{id})
+func (h *FilterHandler) UpdateFilter(w http.ResponseWriter, r *http.Request, id int) {
+	var req filterRequest
+	if p := decodeJSONBody(w, r, &req, h.maxBodyBytes); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	filter, err := h.useCase.UpdateFilter(r.Context(), auth.PrincipalFromContext(r.Context()), id, req.filter())
+	if err != nil {
+		respondWithFilterError(w, r, err, "Failed to update filter")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, filter)
+}
+
+// DeleteFilter удаляет фильтр (DELETE /filters/{id})
+func (h *FilterHandler) DeleteFilter(w http.ResponseWriter, r *http.Request, id int) {
+	if err := h.useCase.DeleteFilter(r.Context(), auth.PrincipalFromContext(r.Context()), id); err != nil {
+		respondWithFilterError(w, r, err, "Failed to delete filter")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFilterTodos возвращает задачи, подходящие под фильтр сейчас
+// (GET /filters/{id}/todos)
+func (h *FilterHandler) GetFilterTodos(w http.ResponseWriter, r *http.Request, id int) {
+	todos, err := h.useCase.FilterTodos(r.Context(), auth.PrincipalFromContext(r.Context()), id)
+	if err != nil {
+		respondWithFilterError(w, r, err, "Failed to fetch todos")
+		return
+	}
+
+	respondWithTodos(w, todos)
+}
+
+// GetSmartList возвращает встроенный список с запросом на текущий момент
+// (GET /filters/today)
+func (h *FilterHandler) GetSmartList(w http.ResponseWriter, r *http.Request, id string) {
+	list, err := h.useCase.SmartList(id)
+	if err != nil {
+		respondWithFilterError(w, r, err, "Failed to fetch filter")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// GetSmartListTodos возвращает задачи встроенного списка
+// (GET /filters/today/todos)
+func (h *FilterHandler) GetSmartListTodos(w http.ResponseWriter, r *http.Request, id string) {
+	todos, err := h.useCase.SmartListTodos(r.Context(), id)
+	if err != nil {
+		respondWithFilterError(w, r, err, "Failed to fetch todos")
+		return
+	}
+
+	respondWithTodos(w, todos)
+}
+
+// respondWithTodos отвечает списком задач; пустой список - [], а не null
+func respondWithTodos(w http.ResponseWriter, todos []*domain.Todo) {
+	if todos == nil {
+		todos = []*domain.Todo{}
+	}
+	respondWithJSON(w, http.StatusOK, todos)
+}
+
+// respondWithFilterError - respondWithDomainError с описанием ошибки
+// валидации, относящимся к фильтру
+func respondWithFilterError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	p := domainProblem(err, detail)
+	if p.Code == problem.CodeValidationFailed {
+		p.Detail = "The filter has invalid fields"
+	}
+	problem.Write(w, r, p)
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"todo/internal/auth"
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+	"todo/internal/repository"
+	"todo/internal/usecase"
+)
+
+func setupFilterHandler() (*FilterHandler, *repository.InMemoryTodoRepository) {
+	todos := repository.NewInMemoryTodoRepository()
+	uc := usecase.NewFilterUseCase(repository.NewInMemoryFilterRepository(), todos)
+	return NewFilterHandler(uc, 0), todos
+}
+
+func serveFilter(h *FilterHandler, principal, method, path, body string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	rec := httptest.NewRecorder()
+	if path == "/filters" {
+		h.HandleFilters(rec, req)
+	} else {
+		h.HandleFilterByID(rec, req)
+	}
+	return rec
+}
+
+func TestFilterHandler_CRUD(t *testing.T) {
+	handler, todos := setupFilterHandler()
+	ctx := context.Background()
+	for _, todo := range []*domain.Todo{
+		{Title: "Отчет", Project: "work"},
+		{Title: "Бюджет", Project: "work"},
+		{Title: "Молоко", Project: "home"},
+	} {
+		todos.Create(ctx, todo)
+	}
+
+	rec := serveFilter(handler, "alice", http.MethodPost, "/filters", `{"name": "Работа", "query": "project:work", "sort": "title"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created domain.Filter
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.ID <= 0 || created.Owner != "alice" || created.Sort != "title" {
+		t.Fatalf("unexpected filter %+v", created)
+	}
+	path := "/filters/" + strconv.Itoa(created.ID)
+
+	t.Run("выполнение фильтра", func(t *testing.T) {
+		rec := serveFilter(handler, "alice", http.MethodGet, path+"/todos", "")
+		var got []domain.Todo
+		json.NewDecoder(rec.Body).Decode(&got)
+		if rec.Code != http.StatusOK || len(got) != 2 || got[0].Title != "Бюджет" || got[1].Title != "Отчет" {
+			t.Errorf("unexpected response %d %+v", rec.Code, got)
+		}
+	})
+
+	t.Run("фильтры других принципалов не видны", func(t *testing.T) {
+		for _, p := range []string{path, path + "/todos"} {
+			rec := serveFilter(handler, "bob", http.MethodGet, p, "")
+			var prob problem.Problem
+			json.NewDecoder(rec.Body).Decode(&prob)
+			if rec.Code != http.StatusNotFound || prob.Detail != "Filter not found" {
+				t.Errorf("%s: unexpected response %d %+v", p, rec.Code, prob)
+			}
+		}
+		rec := serveFilter(handler, "bob", http.MethodGet, "/filters", "")
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+			t.Errorf("expected an empty list, got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("замена", func(t *testing.T) {
+		rec := serveFilter(handler, "alice", http.MethodPut, path, `{"name": "Дом", "query": "project:home"}`)
+		var got domain.Filter
+		json.NewDecoder(rec.Body).Decode(&got)
+		if rec.Code != http.StatusOK || got.Name != "Дом" || got.Owner != "alice" {
+			t.Fatalf("unexpected response %d %+v", rec.Code, got)
+		}
+		rec = serveFilter(handler, "alice", http.MethodGet, path+"/todos", "")
+		var found []domain.Todo
+		json.NewDecoder(rec.Body).Decode(&found)
+		if len(found) != 1 || found[0].Title != "Молоко" {
+			t.Errorf("expected the new query to apply, got %+v", found)
+		}
+	})
+
+	t.Run("неверный запрос", func(t *testing.T) {
+		rec := serveFilter(handler, "alice", http.MethodPost, "/filters", `{"name": "x", "query": "status:open)"}`)
+		var prob problem.Problem
+		json.NewDecoder(rec.Body).Decode(&prob)
+		if rec.Code != http.StatusBadRequest || prob.Detail != "The filter has invalid fields" ||
+			len(prob.Errors) != 1 || prob.Errors[0].Field != "query" {
+			t.Errorf("unexpected response %d %+v", rec.Code, prob)
+		}
+	})
+
+	t.Run("удаление", func(t *testing.T) {
+		if rec := serveFilter(handler, "alice", http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		if rec := serveFilter(handler, "alice", http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", rec.Code)
+		}
+	})
+}
+
+func TestFilterHandler_SmartLists(t *testing.T) {
+	handler, todos := setupFilterHandler()
+	ctx := context.Background()
+	overdue := time.Now().Add(-48 * time.Hour)
+	todos.Create(ctx, &domain.Todo{Title: "Просрочена", DueDate: &overdue})
+	todos.Create(ctx, &domain.Todo{Title: "Без срока"})
+
+	t.Run("описание списка", func(t *testing.T) {
+		rec := serveFilter(handler, "alice", http.MethodGet, "/filters/overdue", "")
+		var list usecase.SmartList
+		json.NewDecoder(rec.Body).Decode(&list)
+		if rec.Code != http.StatusOK || list.Name != "Overdue" || list.Query != "status:overdue" {
+			t.Errorf("unexpected response %d %+v", rec.Code, list)
+		}
+	})
+
+	t.Run("задачи списка", func(t *testing.T) {
+		for path, want := range map[string]string{
+			"/filters/overdue/todos":     "Просрочена",
+			"/filters/no-due-date/todos": "Без срока",
+		} {
+			rec := serveFilter(handler, "alice", http.MethodGet, path, "")
+			var got []domain.Todo
+			json.NewDecoder(rec.Body).Decode(&got)
+			if rec.Code != http.StatusOK || len(got) != 1 || got[0].Title != want {
+				t.Errorf("%s: unexpected response %d %+v", path, rec.Code, got)
+			}
+		}
+	})
+
+	t.Run("встроенные списки только читаются", func(t *testing.T) {
+		rec := serveFilter(handler, "alice", http.MethodDelete, "/filters/today", "")
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, OPTIONS" {
+			t.Errorf("expected 405 with Allow: GET, OPTIONS, got %d %q", rec.Code, rec.Header().Get("Allow"))
+		}
+	})
+
+	t.Run("неизвестный список", func(t *testing.T) {
+		if rec := serveFilter(handler, "alice", http.MethodGet, "/filters/someday/todos", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+// moveRequest - тело POST /todos/{id}/move: ровно один из соседей
+type moveRequest struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// MoveTodo ставит задачу в ручном порядке ее списка сразу перед задачей
+// before или сразу после задачи after (POST /todos/{id}/move) и возвращает
+// ее с новым рангом
+func (h *TodoHandler) MoveTodo(w http.ResponseWriter, r *http.Request, id int) {
+	var req moveRequest
+	if p := h.decodeJSON(w, r, &req); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	todo, err := h.useCase.MoveTodo(r.Context(), id, domain.Anchor{Before: req.Before, After: req.After})
+	if err != nil {
+		respondWithDomainError(w, r, err, "Failed to move todo")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, todo)
+}
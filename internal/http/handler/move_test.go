@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+func TestTodoHandler_MoveTodo(t *testing.T) {
+	handler := setupTestHandler()
+	ctx := context.Background()
+
+	var ids []int
+	for _, todo := range []*domain.Todo{
+		{Title: "A", Project: "work"},
+		{Title: "B", Project: "work"},
+		{Title: "C", Project: "work"},
+		{Title: "X", Project: "home"},
+	} {
+		created, err := handler.useCase.CreateTodo(ctx, todo)
+		if err != nil {
+			t.Fatalf("failed to create todo: %v", err)
+		}
+		ids = append(ids, created.ID)
+	}
+
+	move := func(method string, id int, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, fmt.Sprintf("/todos/%d/move", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.HandleTodoByID(rec, req)
+		return rec
+	}
+
+	t.Run("перестановка", func(t *testing.T) {
+		rec := move(http.MethodPost, ids[2], fmt.Sprintf(`{"before": %d}`, ids[0]))
+		var moved domain.Todo
+		json.NewDecoder(rec.Body).Decode(&moved)
+		if rec.Code != http.StatusOK || moved.ID != ids[2] || moved.Rank == "" {
+			t.Fatalf("unexpected response %d %+v", rec.Code, moved)
+		}
+
+		// Список возвращается в новом порядке
+		rec = httptest.NewRecorder()
+		handler.HandleTodos(rec, httptest.NewRequest(http.MethodGet, "/todos", nil))
+		var todos []domain.Todo
+		json.NewDecoder(rec.Body).Decode(&todos)
+		var titles []string
+		for _, todo := range todos {
+			titles = append(titles, todo.Title)
+		}
+		if got := strings.Join(titles, ""); got != "XCAB" {
+			t.Errorf("expected XCAB, got %s", got)
+		}
+	})
+
+	t.Run("ошибки", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			id     int
+			body   string
+			status int
+			code   string
+		}{
+			{"без соседа", ids[0], `{}`, http.StatusBadRequest, problem.CodeValidationFailed},
+			{"два соседа", ids[0], fmt.Sprintf(`{"before": %d, "after": %d}`, ids[1], ids[2]), http.StatusBadRequest, problem.CodeValidationFailed},
+			{"сосед в другом проекте", ids[0], fmt.Sprintf(`{"after": %d}`, ids[3]), http.StatusBadRequest, problem.CodeInvalidMove},
+			{"рядом с собой", ids[0], fmt.Sprintf(`{"after": %d}`, ids[0]), http.StatusBadRequest, problem.CodeInvalidMove},
+			{"нет задачи", 999, fmt.Sprintf(`{"after": %d}`, ids[0]), http.StatusNotFound, problem.CodeNotFound},
+			{"неизвестное поле", ids[0], `{"rank": "V"}`, http.StatusBadRequest, problem.CodeInvalidBody},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := move(http.MethodPost, tc.id, tc.body)
+				var p problem.Problem
+				json.NewDecoder(rec.Body).Decode(&p)
+				if rec.Code != tc.status || p.Code != tc.code {
+					t.Errorf("expected %d %s, got %d %+v", tc.status, tc.code, rec.Code, p)
+				}
+			})
+		}
+	})
+
+	t.Run("только POST", func(t *testing.T) {
+		rec := move(http.MethodGet, ids[0], "")
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST, OPTIONS" {
+			t.Errorf("expected 405 with Allow: POST, OPTIONS, got %d %q", rec.Code, rec.Header().Get("Allow"))
+		}
+	})
+
+	t.Run("неизвестный подресурс", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/todos/%d/archive", ids[0]), nil)
+		rec := httptest.NewRecorder()
+		handler.HandleTodoByID(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"todo/internal/http/problem"
+)
+
+// MaxQueryLength ограничивает длину запроса GET /todos?q=, символов
+const MaxQueryLength = 500
+
+// QueryTodos возвращает задачи, подходящие под запрос q, по возрастанию ID
+// (GET /todos?q=status:open AND tag:urgent). Синтаксис описан в
+// domain.ParseQuery; ошибка разбора возвращается с кодом invalid_query и
+// позицией ошибки в position.
+func (h *TodoHandler) QueryTodos(w http.ResponseWriter, r *http.Request, q string) {
+	if utf8.RuneCountInString(q) > MaxQueryLength {
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter,
+			fmt.Sprintf("q must be at most %d characters", MaxQueryLength))
+		return
+	}
+
+	todos, err := h.useCase.QueryTodos(r.Context(), q)
+	if err != nil {
+		respondWithDomainError(w, r, err, "Failed to query todos")
+		return
+	}
+	respondWithTodos(w, todos)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+func TestTodoHandler_Query(t *testing.T) {
+	handler := setupTestHandler()
+	ctx := context.Background()
+	for _, todo := range []*domain.Todo{
+		{Title: "Купить молоко", Project: "home", Tags: []string{"urgent"}},
+		{Title: "Написать отчет", Project: "work", Tags: []string{"urgent"}},
+		{Title: "Позвонить маме", Project: "personal", Completed: true},
+	} {
+		if _, err := handler.useCase.CreateTodo(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.HandleTodos(rec, httptest.NewRequest(http.MethodGet, "/todos?q="+url.QueryEscape(query), nil))
+		return rec
+	}
+
+	t.Run("отбор по запросу", func(t *testing.T) {
+		rec := get("tag:urgent AND NOT project:work")
+		var todos []domain.Todo
+		if err := json.NewDecoder(rec.Body).Decode(&todos); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || len(todos) != 1 || todos[0].Title != "Купить молоко" {
+			t.Errorf("unexpected response %d %+v", rec.Code, todos)
+		}
+	})
+
+	t.Run("пустой результат", func(t *testing.T) {
+		rec := get("status:done AND tag:urgent")
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+			t.Errorf("expected an empty array, got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("пустой запрос возвращает все задачи", func(t *testing.T) {
+		rec := get("  ")
+		var todos []domain.Todo
+		json.NewDecoder(rec.Body).Decode(&todos)
+		if rec.Code != http.StatusOK || len(todos) != 3 {
+			t.Errorf("expected all todos, got %d %+v", rec.Code, todos)
+		}
+	})
+
+	t.Run("ошибка разбора с позицией", func(t *testing.T) {
+		rec := get("status:open AND (tag:urgent")
+		var p problem.Problem
+		json.NewDecoder(rec.Body).Decode(&p)
+		if rec.Code != http.StatusBadRequest || p.Code != problem.CodeInvalidQuery || p.Position != 17 {
+			t.Fatalf("expected 400 invalid_query at 17, got %d %+v", rec.Code, p)
+		}
+		if !strings.Contains(p.Detail, "unclosed '('") {
+			t.Errorf("unexpected detail %q", p.Detail)
+		}
+	})
+
+	t.Run("слишком длинный запрос", func(t *testing.T) {
+		rec := get(strings.Repeat("a", MaxQueryLength+1))
+		var p problem.Problem
+		json.NewDecoder(rec.Body).Decode(&p)
+		if rec.Code != http.StatusBadRequest || p.Code != problem.CodeInvalidParameter {
+			t.Errorf("expected 400 invalid_parameter, got %d %+v", rec.Code, p)
+		}
+	})
+}
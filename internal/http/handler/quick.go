@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+// MaxQuickAddLength ограничивает длину текста быстрого добавления
+const MaxQuickAddLength = 500
+
+// TimezoneHeader - заголовок с часовым поясом клиента (имя IANA, например
+// Europe/Moscow), в котором понимаются даты быстрого добавления
+const TimezoneHeader = "X-Timezone"
+
+// quickAddRequest - тело POST /todos/quick. Timezone, если задан, важнее
+// заголовка X-Timezone.
+type quickAddRequest struct {
+	Text     string `json:"text"`
+	Timezone string `json:"timezone"`
+}
+
+// HandleQuickAdd обрабатывает /todos/quick эндпоинт
+func (h *TodoHandler) HandleQuickAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithMethods(w, r, http.MethodPost)
+		return
+	}
+	h.QuickAdd(w, r)
+}
+
+// QuickAdd создает задачу из строки текста вроде "Call Anna tomorrow 3pm
+// #work !high" (POST /todos/quick). Даты понимаются в часовом поясе
+// клиента, а без него - в поясе сервера. С ?preview=true разобранная
+// задача возвращается без сохранения.
+func (h *TodoHandler) QuickAdd(w http.ResponseWriter, r *http.Request) {
+	preview := false
+	if value := r.URL.Query().Get("preview"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "preview must be true or false")
+			return
+		}
+		preview = b
+	}
+
+	var req quickAddRequest
+	if p := h.decodeJSON(w, r, &req); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	var verr domain.ValidationError
+	switch {
+	case strings.TrimSpace(req.Text) == "":
+		verr.Add("text", domain.CodeRequired, "text cannot be empty")
+	case utf8.RuneCountInString(req.Text) > MaxQuickAddLength:
+		verr.Add("text", domain.CodeTooLong, fmt.Sprintf("text must be at most %d characters", MaxQuickAddLength))
+	}
+	if err := verr.Err(); err != nil {
+		respondWithDomainError(w, r, err, "Failed to create todo")
+		return
+	}
+
+	name := req.Timezone
+	if name == "" {
+		name = r.Header.Get(TimezoneHeader)
+	}
+	loc := time.Local
+	if name != "" {
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter,
+				fmt.Sprintf("unknown timezone %q, want an IANA name like Europe/Moscow", name))
+			return
+		}
+	}
+
+	todo, err := h.useCase.QuickAdd(r.Context(), req.Text, loc, preview)
+	if err != nil {
+		respondWithDomainError(w, r, err, "Failed to create todo")
+		return
+	}
+
+	if preview {
+		respondWithJSON(w, http.StatusOK, todo)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, todo)
+}
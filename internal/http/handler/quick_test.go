@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+func TestTodoHandler_QuickAdd(t *testing.T) {
+	handler := setupTestHandler()
+
+	post := func(query, body, timezone string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/todos/quick"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if timezone != "" {
+			req.Header.Set(TimezoneHeader, timezone)
+		}
+		rec := httptest.NewRecorder()
+		handler.HandleQuickAdd(rec, req)
+		return rec
+	}
+
+	t.Run("предпросмотр не сохраняет задачу", func(t *testing.T) {
+		rec := post("?preview=true", `{"text": "Call Anna tomorrow 3pm #work !high every monday"}`, "Asia/Tokyo")
+		var todo domain.Todo
+		json.NewDecoder(rec.Body).Decode(&todo)
+		if rec.Code != http.StatusOK || todo.ID != 0 || todo.Title != "Call Anna" {
+			t.Fatalf("unexpected response %d %+v", rec.Code, todo)
+		}
+		if len(todo.Tags) != 1 || todo.Tags[0] != "work" || todo.Priority != domain.PriorityHigh ||
+			todo.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+			t.Errorf("unexpected fields %+v", todo)
+		}
+		if _, offset := todo.DueDate.Zone(); todo.DueDate.Hour() != 15 || offset != 9*60*60 {
+			t.Errorf("expected 15:00 in Asia/Tokyo, got %v", todo.DueDate)
+		}
+		if todos, _ := handler.useCase.GetAllTodos(context.Background()); len(todos) != 0 {
+			t.Errorf("expected nothing to be saved, got %+v", todos)
+		}
+	})
+
+	t.Run("создание", func(t *testing.T) {
+		// Пояс из тела важнее заголовка
+		rec := post("", `{"text": "Позвонить маме завтра в 19:00", "timezone": "Asia/Kolkata"}`, "Asia/Tokyo")
+		var todo domain.Todo
+		json.NewDecoder(rec.Body).Decode(&todo)
+		if rec.Code != http.StatusCreated || todo.ID == 0 || todo.Title != "Позвонить маме" {
+			t.Fatalf("unexpected response %d %+v", rec.Code, todo)
+		}
+		if _, offset := todo.DueDate.Zone(); todo.DueDate.Hour() != 19 || offset != 5*60*60+30*60 {
+			t.Errorf("expected 19:00 in Asia/Kolkata, got %v", todo.DueDate)
+		}
+		if saved, err := handler.useCase.GetTodoByID(context.Background(), todo.ID); err != nil || !saved.DueDate.Equal(*todo.DueDate) {
+			t.Errorf("expected the todo to be saved, got %+v %v", saved, err)
+		}
+	})
+
+	t.Run("ошибки", func(t *testing.T) {
+		tests := []struct {
+			name, query, body, timezone string
+			code, field                 string
+		}{
+			{"неизвестный пояс", "", `{"text": "Milk"}`, "Mars/Olympus", problem.CodeInvalidParameter, ""},
+			{"неверный preview", "?preview=maybe", `{"text": "Milk"}`, "", problem.CodeInvalidParameter, ""},
+			{"пустой текст", "", `{"text": "  "}`, "", problem.CodeValidationFailed, "text"},
+			{"длинный текст", "", `{"text": "` + strings.Repeat("a", MaxQuickAddLength+1) + `"}`, "", problem.CodeValidationFailed, "text"},
+			{"без названия", "?preview=true", `{"text": "tomorrow #work"}`, "", problem.CodeValidationFailed, "title"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := post(tc.query, tc.body, tc.timezone)
+				var p problem.Problem
+				json.NewDecoder(rec.Body).Decode(&p)
+				if rec.Code != http.StatusBadRequest || p.Code != tc.code {
+					t.Fatalf("expected 400 %s, got %d %+v", tc.code, rec.Code, p)
+				}
+				if tc.field != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tc.field) {
+					t.Errorf("expected a violation of %s, got %+v", tc.field, p.Errors)
+				}
+			})
+		}
+	})
+
+	t.Run("только POST", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.HandleQuickAdd(rec, httptest.NewRequest(http.MethodGet, "/todos/quick", nil))
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST, OPTIONS" {
+			t.Errorf("expected 405 with Allow: POST, OPTIONS, got %d %q", rec.Code, rec.Header().Get("Allow"))
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+// Ограничения поиска
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	MaxSearchQuery     = 500
+)
+
+// searchResponse - ответ GET /todos/search. Total - число всех найденных
+// задач, Results - лучшие из них по убыванию релевантности.
+type searchResponse struct {
+	Query   string             `json:"query"`
+	Total   int                `json:"total"`
+	Results []domain.SearchHit `json:"results"`
+}
+
+// HandleSearch обрабатывает /todos/search эндпоинт
+func (h *TodoHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithMethods(w, r, http.MethodGet)
+		return
+	}
+	h.SearchTodos(w, r)
+}
+
+// SearchTodos ищет задачи по названию и описанию (GET /todos/search?q=).
+// Все слова запроса должны встретиться в задаче в любой форме; "фраза в
+// кавычках" ищется целиком, а слово* - по началу.
+func (h *TodoHandler) SearchTodos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if strings.TrimSpace(q) == "" {
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "q is required")
+		return
+	}
+	if utf8.RuneCountInString(q) > MaxSearchQuery {
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter,
+			fmt.Sprintf("q must be at most %d characters", MaxSearchQuery))
+		return
+	}
+	limit := DefaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxSearchLimit {
+			respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter,
+				fmt.Sprintf("limit must be between 1 and %d", MaxSearchLimit))
+			return
+		}
+		limit = n
+	}
+
+	hits, total, err := h.useCase.SearchTodos(r.Context(), q, limit)
+	if err != nil {
+		respondWithDomainError(w, r, err, "Failed to search todos")
+		return
+	}
+
+	resp := searchResponse{Query: q, Total: total, Results: hits}
+	if resp.Results == nil {
+		resp.Results = []domain.SearchHit{}
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+func TestTodoHandler_Search(t *testing.T) {
+	handler := setupTestHandler()
+	ctx := context.Background()
+	for _, todo := range []*domain.Todo{
+		{Title: "Купить молоко", Description: "И <свежий> хлеб"},
+		{Title: "Buy milk", Description: "Running out of milk"},
+		{Title: "Позвонить маме", Description: "Спросить про молоко"},
+	} {
+		if _, err := handler.useCase.CreateTodo(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query string) (*httptest.ResponseRecorder, searchResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/todos/search?"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.HandleSearch(rec, req)
+
+		var resp searchResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec, resp
+	}
+
+	t.Run("поиск с фрагментами", func(t *testing.T) {
+		rec, resp := search("q=" + url.QueryEscape("молока хлеб"))
+		if rec.Code != http.StatusOK || resp.Total != 1 || len(resp.Results) != 1 {
+			t.Fatalf("unexpected response %d %+v", rec.Code, resp)
+		}
+		hit := resp.Results[0]
+		if hit.Todo.Title != "Купить молоко" || hit.Score <= 0 {
+			t.Errorf("unexpected hit %+v", hit)
+		}
+		if hit.Snippets.Title != "Купить <mark>молоко</mark>" || hit.Snippets.Description != "И &lt;свежий&gt; <mark>хлеб</mark>" {
+			t.Errorf("unexpected snippets %+v", hit.Snippets)
+		}
+	})
+
+	t.Run("ограничение и общее число", func(t *testing.T) {
+		rec, resp := search("q=" + url.QueryEscape("мол*") + "&limit=1")
+		if rec.Code != http.StatusOK || resp.Total != 2 || len(resp.Results) != 1 {
+			t.Errorf("unexpected response %d %+v", rec.Code, resp)
+		}
+	})
+
+	t.Run("пустой результат", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.HandleSearch(rec, httptest.NewRequest(http.MethodGet, "/todos/search?q=kefir", nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"results":[]`) {
+			t.Errorf("expected an empty results array, got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"без запроса", ""},
+		{"пустой запрос", "q=%20%20"},
+		{"слишком длинный запрос", "q=" + strings.Repeat("a", MaxSearchQuery+1)},
+		{"неверный limit", "q=milk&limit=0"},
+		{"слишком большой limit", "q=milk&limit=1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := search(tt.query)
+			var p problem.Problem
+			json.NewDecoder(rec.Body).Decode(&p)
+			if rec.Code != http.StatusBadRequest || p.Code != problem.CodeInvalidParameter {
+				t.Errorf("expected 400 invalid_parameter, got %d %+v", rec.Code, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+// Ограничения синхронизации
+const (
+	DefaultSyncLimit = 500
+	MaxSyncLimit     = 1000
+	MaxSyncBatch     = 500
+)
+
+// syncResponse - ответ GET /sync. Token передается в следующий запрос как
+// since; пока HasMore равен true, изменения нужно дочитать сразу.
+type syncResponse struct {
+	Changes []domain.Change `json:"changes"`
+	Token   string          `json:"token"`
+	HasMore bool            `json:"has_more"`
+}
+
+// syncRequest - тело POST /sync
+type syncRequest struct {
+	Changes []syncChangeRequest `json:"changes"`
+}
+
+// syncChangeRequest - изменение клиента. ClientID возвращается в результате,
+// чтобы клиент сопоставил созданную задачу со своей локальной записью.
+type syncChangeRequest struct {
+	ClientID    string       `json:"client_id"`
+	Op          string       `json:"op"`
+	ID          int          `json:"id"`
+	BaseVersion uint64       `json:"base_version"`
+	Todo        *todoRequest `json:"todo"`
+}
+
+// syncResult - результат одного изменения в ответе POST /sync
+type syncResult struct {
+	Index    int               `json:"index"`
+	ClientID string            `json:"client_id,omitempty"`
+	Status   domain.SyncStatus `json:"status"`
+	Todo     *domain.Todo      `json:"todo,omitempty"`
+	Deleted  bool              `json:"deleted,omitempty"`
+	Error    *problem.Problem  `json:"error,omitempty"`
+}
+
+// HandleSync обрабатывает /sync эндпоинт
+func (h *TodoHandler) HandleSync(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.PullChanges(w, r)
+	case http.MethodPost:
+		h.PushChanges(w, r)
+	default:
+		respondWithMethods(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// PullChanges возвращает изменения после токена (GET /sync?since=<token>).
+// Без since возвращаются все задачи. Токен, который сервер больше не может
+// продолжить, дает 410: клиенту нужна полная синхронизация.
+func (h *TodoHandler) PullChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var since uint64
+	if token := query.Get("since"); token != "" {
+		var err error
+		if since, err = strconv.ParseUint(token, 10, 64); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "since must be a token returned by GET /sync")
+			return
+		}
+	}
+	limit := DefaultSyncLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxSyncLimit {
+			respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidParameter,
+				fmt.Sprintf("limit must be between 1 and %d", MaxSyncLimit))
+			return
+		}
+		limit = n
+	}
+
+	changes, latest, err := h.useCase.Changes(r.Context(), since, limit)
+	if err != nil {
+		respondWithDomainError(w, r, err, "Failed to fetch changes")
+		return
+	}
+
+	resp := syncResponse{Changes: changes, Token: strconv.FormatUint(latest, 10)}
+	if resp.Changes == nil {
+		resp.Changes = []domain.Change{}
+	}
+	if n := len(changes); n == limit && changes[n-1].Seq < latest {
+		resp.Token = strconv.FormatUint(changes[n-1].Seq, 10)
+		resp.HasMore = true
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// PushChanges применяет изменения офлайн-клиента (POST /sync). Ответ 200
+// содержит результат каждого изменения в порядке запроса; слияние
+// параллельных правок описано в usecase.TodoUseCase.ApplyChanges.
+func (h *TodoHandler) PushChanges(w http.ResponseWriter, r *http.Request) {
+	var req syncRequest
+	if p := h.decodeJSON(w, r, &req); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+	if len(req.Changes) > MaxSyncBatch {
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidBody,
+			fmt.Sprintf("at most %d changes are accepted per request", MaxSyncBatch))
+		return
+	}
+
+	results := make([]syncResult, len(req.Changes))
+	var (
+		changes []domain.SyncChange
+		indexes []int
+	)
+	for i, c := range req.Changes {
+		results[i] = syncResult{Index: i, ClientID: c.ClientID}
+		change, p := c.change()
+		if p != nil {
+			results[i].Status, results[i].Error = domain.SyncRejected, p
+			continue
+		}
+		changes = append(changes, change)
+		indexes = append(indexes, i)
+	}
+
+	for j, res := range h.useCase.ApplyChanges(r.Context(), changes) {
+		result := &results[indexes[j]]
+		result.Status, result.Todo, result.Deleted = res.Status, res.Todo, res.Deleted
+		if res.Err != nil {
+			result.Error = domainProblem(res.Err, "Failed to apply change")
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string][]syncResult{"results": results})
+}
+
+// change проверяет поля, обязательные для операции, и возвращает изменение
+// для use case
+func (c *syncChangeRequest) change() (domain.SyncChange, *problem.Problem) {
+	change := domain.SyncChange{Op: domain.SyncOp(c.Op), ID: c.ID, BaseVersion: c.BaseVersion}
+
+	switch change.Op {
+	case domain.SyncCreate:
+		if c.ID != 0 {
+			return change, syncFieldProblem("id", codeReadOnly, "id is assigned by the server")
+		}
+	case domain.SyncUpdate, domain.SyncDelete:
+		if c.ID <= 0 {
+			return change, syncFieldProblem("id", domain.CodeRequired, "id must be a positive integer")
+		}
+		// Без базовой версии правку не слить с параллельными; удаление
+		// побеждает их и без нее
+		if change.Op == domain.SyncUpdate && c.BaseVersion == 0 {
+			return change, syncFieldProblem("base_version", domain.CodeRequired, "base_version is required")
+		}
+	default:
+		return change, syncFieldProblem("op", domain.CodeInvalid, "op must be create, update or delete")
+	}
+
+	if change.Op == domain.SyncDelete {
+		return change, nil
+	}
+	if c.Todo == nil {
+		return change, syncFieldProblem("todo", domain.CodeRequired, "todo is required")
+	}
+	if p := c.Todo.checkID(c.ID); p != nil {
+		return change, p
+	}
+	change.Todo = c.Todo.todo()
+	return change, nil
+}
+
+func syncFieldProblem(field, code, message string) *problem.Problem {
+	p := invalidBody(message)
+	p.Errors = []problem.FieldError{{Field: field, Code: code, Message: message}}
+	return p
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+)
+
+func pull(t *testing.T, h *TodoHandler, query string) (*httptest.ResponseRecorder, syncResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/sync"+query, nil)
+	rec := httptest.NewRecorder()
+	h.HandleSync(rec, req)
+
+	var resp syncResponse
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec, resp
+}
+
+func push(t *testing.T, h *TodoHandler, body string) []syncResult {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.HandleSync(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Results []syncResult `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Results
+}
+
+func TestTodoHandler_Sync(t *testing.T) {
+	handler := setupTestHandler()
+
+	results := push(t, handler, `{"changes": [
+		{"client_id": "local-1", "op": "create", "todo": {"title": "Купить хлеб"}},
+		{"client_id": "local-2", "op": "create", "todo": {"title": "Позвонить"}}
+	]}`)
+	if len(results) != 2 || results[0].Status != domain.SyncApplied || results[0].ClientID != "local-1" ||
+		results[0].Todo == nil || results[0].Todo.Version == 0 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	bread, call := results[0].Todo, results[1].Todo
+
+	rec, full := pull(t, handler, "")
+	if rec.Code != http.StatusOK || len(full.Changes) != 2 || full.HasMore || full.Token == "" {
+		t.Fatalf("unexpected full sync %d %+v", rec.Code, full)
+	}
+
+	results = push(t, handler, `{"changes": [
+		{"op": "update", "id": `+strconv.Itoa(bread.ID)+`, "base_version": `+strconv.FormatUint(bread.Version, 10)+`, "todo": {"title": "Купить батон"}},
+		{"op": "update", "id": `+strconv.Itoa(bread.ID)+`, "base_version": `+strconv.FormatUint(bread.Version, 10)+`, "todo": {"title": "Купить багет"}},
+		{"op": "delete", "id": `+strconv.Itoa(call.ID)+`, "base_version": `+strconv.FormatUint(call.Version, 10)+`},
+		{"op": "update", "id": `+strconv.Itoa(bread.ID)+`, "todo": {"title": "без версии"}},
+		{"op": "archive", "id": 1},
+		{"op": "update", "id": `+strconv.Itoa(call.ID)+`, "base_version": `+strconv.FormatUint(call.Version, 10)+`, "todo": {"title": "Перезвонить"}}
+	]}`)
+	want := []domain.SyncStatus{domain.SyncApplied, domain.SyncMerged, domain.SyncApplied, domain.SyncRejected, domain.SyncRejected, domain.SyncConflict}
+	for i, status := range want {
+		if results[i].Status != status || results[i].Index != i {
+			t.Errorf("result %d: expected %s, got %+v", i, status, results[i])
+		}
+	}
+	// Параллельные правки одного поля: побеждает пришедшая позже
+	if results[1].Todo == nil || results[1].Todo.Title != "Купить багет" {
+		t.Errorf("merge must return the merged state, got %+v", results[1].Todo)
+	}
+	if results[3].Error == nil || results[3].Error.Errors[0].Field != "base_version" {
+		t.Errorf("expected base_version to be required, got %+v", results[3].Error)
+	}
+	// Правка удаленной задачи не применяется, клиент получает надгробие
+	if !results[5].Deleted || results[5].Todo != nil {
+		t.Errorf("expected a tombstone for the update of the deleted todo, got %+v", results[5])
+	}
+
+	rec, delta := pull(t, handler, "?since="+full.Token)
+	if rec.Code != http.StatusOK || len(delta.Changes) != 2 {
+		t.Fatalf("unexpected delta %d %+v", rec.Code, delta)
+	}
+	if delta.Changes[0].ID != bread.ID || delta.Changes[0].Todo.Title != "Купить багет" {
+		t.Errorf("expected the updated todo first, got %+v", delta.Changes[0])
+	}
+	if delta.Changes[1].ID != call.ID || !delta.Changes[1].Deleted {
+		t.Errorf("expected a tombstone for the deleted todo, got %+v", delta.Changes[1])
+	}
+
+	// Постраничное чтение
+	_, page := pull(t, handler, "?since="+full.Token+"&limit=1")
+	if len(page.Changes) != 1 || !page.HasMore {
+		t.Fatalf("expected one change and has_more, got %+v", page)
+	}
+	_, rest := pull(t, handler, "?since="+page.Token+"&limit=1")
+	if len(rest.Changes) != 1 || rest.HasMore || rest.Token != delta.Token {
+		t.Errorf("expected the last change and the final token, got %+v", rest)
+	}
+
+	_, empty := pull(t, handler, "?since="+delta.Token)
+	if len(empty.Changes) != 0 || empty.Token != delta.Token {
+		t.Errorf("expected no changes after the latest token, got %+v", empty)
+	}
+}
+
+func TestTodoHandler_SyncErrors(t *testing.T) {
+	handler := setupTestHandler()
+
+	tests := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"?since=abc", http.StatusBadRequest, problem.CodeInvalidParameter},
+		{"?limit=0", http.StatusBadRequest, problem.CodeInvalidParameter},
+		{"?since=100", http.StatusGone, problem.CodeSyncTokenExpired},
+	}
+	for _, tt := range tests {
+		rec, _ := pull(t, handler, tt.query)
+		var p problem.Problem
+		json.NewDecoder(rec.Body).Decode(&p)
+		if rec.Code != tt.status || p.Code != tt.code {
+			t.Errorf("%s: expected %d %s, got %d %+v", tt.query, tt.status, tt.code, rec.Code, p)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(`{"changes": [], "token": "1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.HandleSync(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown fields must be rejected, got %d", rec.Code)
+	}
+}
@@ -8,19 +8,38 @@ import (
 	"strings"
 
 	"todo/internal/domain"
+	"todo/internal/http/problem"
 	"todo/internal/usecase"
 )
 
+// StatusClientClosedRequest - нестандартный статус (nginx) для запросов,
+// отмененных клиентом до получения ответа
+const StatusClientClosedRequest = 499
+
 // TodoHandler обрабатывает HTTP запросы для задач
 type TodoHandler struct {
-	useCase *usecase.TodoUseCase
+	useCase      *usecase.TodoUseCase
+	maxBodyBytes int64
+}
+
+// Option настраивает TodoHandler
+type Option func(*TodoHandler)
+
+// WithMaxBodyBytes ограничивает размер тела запроса
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *TodoHandler) { h.maxBodyBytes = n }
 }
 
 // NewTodoHandler создает новый обработчик
-func NewTodoHandler(uc *usecase.TodoUseCase) *TodoHandler {
-	return &TodoHandler{
-		useCase: uc,
+func NewTodoHandler(uc *usecase.TodoUseCase, opts ...Option) *TodoHandler {
+	h := &TodoHandler{
+		useCase:      uc,
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // HandleTodos обрабатывает /todos эндпоинт
@@ -31,7 +50,7 @@ func (h *TodoHandler) HandleTodos(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		h.GetAllTodos(w, r)
 	default:
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithMethods(w, r, http.MethodGet, http.MethodPost)
 	}
 }
 
@@ -40,7 +59,20 @@ func (h *TodoHandler) HandleTodoByID(w http.ResponseWriter, r *http.Request) {
 	// Извлекаем ID из URL
 	id, err := extractIDFromPath(r.URL.Path)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid todo ID")
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidID, "Invalid todo ID")
+		return
+	}
+
+	if sub := subresource(r.URL.Path); sub != "" {
+		if sub != "move" {
+			respondWithError(w, r, http.StatusNotFound, problem.CodeNotFound, "Not found")
+			return
+		}
+		if r.Method != http.MethodPost {
+			respondWithMethods(w, r, http.MethodPost)
+			return
+		}
+		h.MoveTodo(w, r, id)
 		return
 	}
 
@@ -52,36 +84,42 @@ func (h *TodoHandler) HandleTodoByID(w http.ResponseWriter, r *http.Request) {
 	case http.MethodDelete:
 		h.DeleteTodo(w, r, id)
 	default:
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithMethods(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
 	}
 }
 
 // CreateTodo создает новую задачу (POST /todos)
 func (h *TodoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
-	var todo domain.Todo
-	if err := json.NewDecoder(r.Body).Decode(&todo); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	var req todoRequest
+	if p := h.decodeJSON(w, r, &req); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+	if p := req.checkID(0); p != nil {
+		problem.Write(w, r, p)
 		return
 	}
 
-	createdTodo, err := h.useCase.CreateTodo(r.Context(), &todo)
+	createdTodo, err := h.useCase.CreateTodo(r.Context(), req.todo())
 	if err != nil {
-		if errors.Is(err, domain.ErrTodoAlreadyExists) {
-			respondWithError(w, http.StatusConflict, err.Error())
-			return
-		}
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithDomainError(w, r, err, "Failed to create todo")
 		return
 	}
 
 	respondWithJSON(w, http.StatusCreated, createdTodo)
 }
 
-// GetAllTodos возвращает все задачи (GET /todos)
+// GetAllTodos возвращает все задачи (GET /todos) или задачи, подходящие
+// под запрос (GET /todos?q=, см. QueryTodos)
 func (h *TodoHandler) GetAllTodos(w http.ResponseWriter, r *http.Request) {
+	if q := r.URL.Query().Get("q"); strings.TrimSpace(q) != "" {
+		h.QueryTodos(w, r, q)
+		return
+	}
+
 	todos, err := h.useCase.GetAllTodos(r.Context())
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to fetch todos")
+		respondWithDomainError(w, r, err, "Failed to fetch todos")
 		return
 	}
 
@@ -92,11 +130,7 @@ func (h *TodoHandler) GetAllTodos(w http.ResponseWriter, r *http.Request) {
 func (h *TodoHandler) GetTodoByID(w http.ResponseWriter, r *http.Request, id int) {
 	todo, err := h.useCase.GetTodoByID(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, domain.ErrTodoNotFound) {
-			respondWithError(w, http.StatusNotFound, "Todo not found")
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to fetch todo")
+		respondWithDomainError(w, r, err, "Failed to fetch todo")
 		return
 	}
 
@@ -105,19 +139,19 @@ func (h *TodoHandler) GetTodoByID(w http.ResponseWriter, r *http.Request, id int
 
 // UpdateTodo обновляет задачу (PUT /todos/{id})
 func (h *TodoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request, id int) {
-	var todo domain.Todo
-	if err := json.NewDecoder(r.Body).Decode(&todo); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	var req todoRequest
+	if p := h.decodeJSON(w, r, &req); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+	if p := req.checkID(id); p != nil {
+		problem.Write(w, r, p)
 		return
 	}
 
-	updatedTodo, err := h.useCase.UpdateTodo(r.Context(), id, &todo)
+	updatedTodo, err := h.useCase.UpdateTodo(r.Context(), id, req.todo())
 	if err != nil {
-		if errors.Is(err, domain.ErrTodoNotFound) {
-			respondWithError(w, http.StatusNotFound, "Todo not found")
-			return
-		}
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		respondWithDomainError(w, r, err, "Failed to update todo")
 		return
 	}
 
@@ -128,11 +162,7 @@ func (h *TodoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request, id int)
 func (h *TodoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request, id int) {
 	err := h.useCase.DeleteTodo(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, domain.ErrTodoNotFound) {
-			respondWithError(w, http.StatusNotFound, "Todo not found")
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "Failed to delete todo")
+		respondWithDomainError(w, r, err, "Failed to delete todo")
 		return
 	}
 
@@ -150,12 +180,79 @@ func extractIDFromPath(path string) (int, error) {
 	return strconv.Atoi(parts[1])
 }
 
+// subresource возвращает часть пути после /todos/{id}/, например "move"
+func subresource(path string) string {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(payload)
 }
 
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
+// respondWithMethods отвечает на OPTIONS списком методов ресурса, а на
+// остальные неподдерживаемые методы - 405 с тем же списком в Allow
+func respondWithMethods(w http.ResponseWriter, r *http.Request, methods ...string) {
+	w.Header().Set("Allow", strings.Join(append(methods, http.MethodOptions), ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	respondWithError(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "Method not allowed")
+}
+
+func respondWithError(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	problem.Error(w, r, status, code, detail)
+}
+
+// respondWithDomainError переводит ошибку use case в ответ problem+json
+func respondWithDomainError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	problem.Write(w, r, domainProblem(err, detail))
+}
+
+// domainProblem переводит ошибку use case в проблему. Для неизвестных ошибок
+// возвращается 500 с detail, не раскрывающим внутренние подробности.
+func domainProblem(err error, detail string) *problem.Problem {
+	var verr *domain.ValidationError
+	var qerr *domain.QueryError
+	switch {
+	case errors.Is(err, domain.ErrDeadlineExceeded):
+		return problem.New(http.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
+	case errors.Is(err, domain.ErrUnavailable):
+		return problem.New(http.StatusServiceUnavailable, problem.CodeUnavailable, "Service unavailable")
+	case errors.Is(err, domain.ErrCanceled):
+		return problem.New(StatusClientClosedRequest, problem.CodeCanceled, "Request canceled")
+	case errors.As(err, &verr):
+		p := problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "The todo has invalid fields")
+		for _, v := range verr.Violations {
+			p.Errors = append(p.Errors, problem.FieldError{Field: v.Field, Code: v.Code, Message: v.Message})
+		}
+		return p
+	case errors.As(err, &qerr):
+		p := problem.New(http.StatusBadRequest, problem.CodeInvalidQuery, err.Error())
+		p.Position = qerr.Pos
+		return p
+	case errors.Is(err, domain.ErrInvalidMove):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidMove, err.Error())
+	case errors.Is(err, domain.ErrInvalidTodoData):
+		return problem.New(http.StatusBadRequest, problem.CodeValidationFailed, err.Error())
+	case errors.Is(err, domain.ErrTodoNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Todo not found")
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Webhook not found")
+	case errors.Is(err, domain.ErrFilterNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeNotFound, "Filter not found")
+	case errors.Is(err, domain.ErrTodoAlreadyExists):
+		return problem.New(http.StatusConflict, problem.CodeAlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrSyncTokenExpired):
+		return problem.New(http.StatusGone, problem.CodeSyncTokenExpired,
+			"The sync token is no longer valid, start over without since")
+	default:
+		return problem.New(http.StatusInternalServerError, problem.CodeInternal, detail)
+	}
 }
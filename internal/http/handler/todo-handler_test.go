@@ -2,13 +2,16 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"todo/internal/domain"
+	"todo/internal/http/problem"
 	"todo/internal/repository"
 	"todo/internal/usecase"
 )
@@ -31,6 +34,7 @@ func TestTodoHandler_CreateTodo(t *testing.T) {
 
 		body, _ := json.Marshal(todo)
 		req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 
 		handler.HandleTodos(rec, req)
@@ -59,6 +63,7 @@ func TestTodoHandler_CreateTodo(t *testing.T) {
 
 		body, _ := json.Marshal(todo)
 		req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 
 		handler.HandleTodos(rec, req)
@@ -70,6 +75,7 @@ func TestTodoHandler_CreateTodo(t *testing.T) {
 
 	t.Run("создание задачи с некорректным JSON", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBufferString("invalid json"))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 
 		handler.HandleTodos(rec, req)
@@ -79,23 +85,16 @@ func TestTodoHandler_CreateTodo(t *testing.T) {
 		}
 	})
 
-	t.Run("создание задачи с дублирующим ID", func(t *testing.T) {
-		// Создаем первую задачу
-		todo1 := domain.Todo{ID: 999, Title: "First"}
-		body1, _ := json.Marshal(todo1)
-		req1 := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body1))
-		rec1 := httptest.NewRecorder()
-		handler.HandleTodos(rec1, req1)
-
-		// Пытаемся создать вторую задачу с тем же ID
-		todo2 := domain.Todo{ID: 999, Title: "Second"}
-		body2, _ := json.Marshal(todo2)
-		req2 := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body2))
-		rec2 := httptest.NewRecorder()
-		handler.HandleTodos(rec2, req2)
-
-		if rec2.Code != http.StatusConflict {
-			t.Errorf("expected status %d, got %d", http.StatusConflict, rec2.Code)
+	t.Run("создание задачи с ID клиента", func(t *testing.T) {
+		// ID назначает сервер, поэтому запрос отклоняется до попытки создания
+		body, _ := json.Marshal(domain.Todo{ID: 999, Title: "First"})
+		req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.HandleTodos(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
 		}
 	})
 }
@@ -108,6 +107,7 @@ func TestTodoHandler_GetAllTodos(t *testing.T) {
 		todo := domain.Todo{Title: fmt.Sprintf("Todo %d", i)}
 		body, _ := json.Marshal(todo)
 		req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 		handler.HandleTodos(rec, req)
 	}
@@ -137,6 +137,7 @@ func TestTodoHandler_GetTodoByID(t *testing.T) {
 	todo := domain.Todo{Title: "Test"}
 	body, _ := json.Marshal(todo)
 	createReq := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
 	createRec := httptest.NewRecorder()
 	handler.HandleTodos(createRec, createReq)
 
@@ -184,6 +185,7 @@ func TestTodoHandler_UpdateTodo(t *testing.T) {
 	todo := domain.Todo{Title: "Original"}
 	body, _ := json.Marshal(todo)
 	createReq := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
 	createRec := httptest.NewRecorder()
 	handler.HandleTodos(createRec, createReq)
 
@@ -199,6 +201,7 @@ func TestTodoHandler_UpdateTodo(t *testing.T) {
 
 		body, _ := json.Marshal(updated)
 		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/todos/%d", created.ID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 
 		handler.HandleTodoByID(rec, req)
@@ -213,6 +216,7 @@ func TestTodoHandler_UpdateTodo(t *testing.T) {
 
 		body, _ := json.Marshal(updated)
 		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/todos/%d", created.ID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 
 		handler.HandleTodoByID(rec, req)
@@ -227,6 +231,7 @@ func TestTodoHandler_UpdateTodo(t *testing.T) {
 
 		body, _ := json.Marshal(updated)
 		req := httptest.NewRequest(http.MethodPut, "/todos/9999", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 
 		handler.HandleTodoByID(rec, req)
@@ -244,6 +249,7 @@ func TestTodoHandler_DeleteTodo(t *testing.T) {
 	todo := domain.Todo{Title: "To Delete"}
 	body, _ := json.Marshal(todo)
 	createReq := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+	createReq.Header.Set("Content-Type", "application/json")
 	createRec := httptest.NewRecorder()
 	handler.HandleTodos(createRec, createReq)
 
@@ -272,3 +278,174 @@ func TestTodoHandler_DeleteTodo(t *testing.T) {
 		}
 	})
 }
+
+func TestTodoHandler_ContextErrors(t *testing.T) {
+	handler := setupTestHandler()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), 0)
+	defer cancelExpired()
+
+	shutdown, cancelShutdown := context.WithCancelCause(context.Background())
+	cancelShutdown(domain.ErrUnavailable)
+
+	testCases := []struct {
+		name           string
+		ctx            context.Context
+		expectedStatus int
+	}{
+		{name: "запрос отменен клиентом", ctx: canceled, expectedStatus: StatusClientClosedRequest},
+		{name: "истек дедлайн", ctx: expired, expectedStatus: http.StatusGatewayTimeout},
+		{name: "сервер останавливается", ctx: shutdown, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/todos", nil).WithContext(tc.ctx)
+			rec := httptest.NewRecorder()
+
+			handler.HandleTodos(rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTodoHandler_ProblemDetails(t *testing.T) {
+	handler := setupTestHandler()
+
+	t.Run("ошибки валидации", func(t *testing.T) {
+		body := fmt.Sprintf(`{"title": "", "description": %q}`, strings.Repeat("a", domain.MaxDescriptionLength+1))
+		req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handler.HandleTodos(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+			t.Errorf("expected content type %q, got %q", problem.ContentType, ct)
+		}
+
+		var p problem.Problem
+		if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Code != problem.CodeValidationFailed || p.Type != problem.TypeBase+problem.CodeValidationFailed ||
+			p.Status != http.StatusBadRequest || p.Instance != "/todos" || p.Title == "" {
+			t.Errorf("unexpected problem %+v", p)
+		}
+		if len(p.Errors) != 2 || p.Errors[0].Field != "title" || p.Errors[1].Field != "description" ||
+			p.Errors[1].Code != domain.CodeTooLong {
+			t.Errorf("expected errors for title and description, got %+v", p.Errors)
+		}
+	})
+
+	t.Run("задача не найдена", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/todos/404", nil)
+		rec := httptest.NewRecorder()
+
+		handler.HandleTodoByID(rec, req)
+
+		var p problem.Problem
+		json.NewDecoder(rec.Body).Decode(&p)
+		if rec.Code != http.StatusNotFound || p.Code != problem.CodeNotFound || p.Instance != "/todos/404" {
+			t.Errorf("unexpected response %d %+v", rec.Code, p)
+		}
+	})
+}
+
+func TestTodoHandler_StrictDecoding(t *testing.T) {
+	handler := NewTodoHandler(usecase.NewTodoUseCase(repository.NewInMemoryTodoRepository()), WithMaxBodyBytes(64))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantDetail  string
+		wantField   string
+	}{
+		{"без Content-Type", http.MethodPost, "/todos", "", `{"title":"a"}`, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia, "", ""},
+		{"не JSON", http.MethodPost, "/todos", "text/plain", `{"title":"a"}`, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMedia, "", ""},
+		{"JSON с charset", http.MethodPost, "/todos", "application/json; charset=utf-8", `{"title":"a"}`, http.StatusCreated, "", "", ""},
+		{"слишком большое тело", http.MethodPost, "/todos", "application/json", `{"title":"` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge, "64 bytes", ""},
+		{"пустое тело", http.MethodPost, "/todos", "application/json", ``, http.StatusBadRequest, problem.CodeInvalidBody, "empty", ""},
+		{"неизвестное поле", http.MethodPost, "/todos", "application/json", `{"title":"a","owner":"x"}`, http.StatusBadRequest, problem.CodeInvalidBody, `"owner"`, "owner"},
+		{"данные после объекта", http.MethodPost, "/todos", "application/json", `{"title":"a"} {}`, http.StatusBadRequest, problem.CodeInvalidBody, "offset 13", ""},
+		{"синтаксическая ошибка", http.MethodPost, "/todos", "application/json", `{"title":"a",}`, http.StatusBadRequest, problem.CodeInvalidBody, "offset 14", ""},
+		{"неверный тип", http.MethodPost, "/todos", "application/json", `{"title":1}`, http.StatusBadRequest, problem.CodeInvalidBody, "offset 10", "title"},
+		{"неверная дата", http.MethodPost, "/todos", "application/json", `{"title":"a","due_date":"tomorrow"}`, http.StatusBadRequest, problem.CodeInvalidBody, "RFC 3339", "due_date"},
+		{"ID в теле", http.MethodPost, "/todos", "application/json", `{"id":5,"title":"a"}`, http.StatusBadRequest, problem.CodeInvalidBody, "assigned by the server", "id"},
+		{"чужой ID при обновлении", http.MethodPut, "/todos/1", "application/json", `{"id":2,"title":"b"}`, http.StatusBadRequest, problem.CodeInvalidBody, "", "id"},
+		{"тот же ID при обновлении", http.MethodPut, "/todos/1", "application/json", `{"id":1,"title":"b"}`, http.StatusOK, "", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			if tc.method == http.MethodPost {
+				handler.HandleTodos(rec, req)
+			} else {
+				handler.HandleTodoByID(rec, req)
+			}
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tc.wantCode == "" {
+				return
+			}
+
+			var p problem.Problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tc.wantCode || !strings.Contains(p.Detail, tc.wantDetail) {
+				t.Errorf("expected code %q with detail containing %q, got %q %q", tc.wantCode, tc.wantDetail, p.Code, p.Detail)
+			}
+			if tc.wantField != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tc.wantField) {
+				t.Errorf("expected error for field %q, got %+v", tc.wantField, p.Errors)
+			}
+		})
+	}
+}
+
+func TestTodoHandler_Options(t *testing.T) {
+	handler := setupTestHandler()
+
+	tests := []struct {
+		method, path string
+		serve        func(http.ResponseWriter, *http.Request)
+		wantStatus   int
+		wantAllow    string
+	}{
+		{http.MethodOptions, "/todos", handler.HandleTodos, http.StatusNoContent, "GET, POST, OPTIONS"},
+		{http.MethodOptions, "/todos/1", handler.HandleTodoByID, http.StatusNoContent, "GET, PUT, DELETE, OPTIONS"},
+		{http.MethodPatch, "/todos", handler.HandleTodos, http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rec := httptest.NewRecorder()
+		tc.serve(rec, req)
+
+		if rec.Code != tc.wantStatus || rec.Header().Get("Allow") != tc.wantAllow {
+			t.Errorf("%s %s: expected %d with Allow %q, got %d %q",
+				tc.method, tc.path, tc.wantStatus, tc.wantAllow, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+}
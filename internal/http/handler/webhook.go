@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+	"todo/internal/usecase"
+)
+
+// WebhookHandler обрабатывает запросы к подпискам на вебхуки
+type WebhookHandler struct {
+	useCase      *usecase.WebhookUseCase
+	maxBodyBytes int64
+}
+
+// NewWebhookHandler создает обработчик подписок; тело запроса ограничено
+// maxBodyBytes (0 - DefaultMaxBodyBytes)
+func NewWebhookHandler(uc *usecase.WebhookUseCase, maxBodyBytes int64) *WebhookHandler {
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	return &WebhookHandler{useCase: uc, maxBodyBytes: maxBodyBytes}
+}
+
+// webhookRequest - тело запросов на создание и замену подписки. Без active
+// подписка включена; без secret при создании секрет генерируется, а при
+// замене остается прежним.
+type webhookRequest struct {
+	URL    string             `json:"url"`
+	Events []domain.EventType `json:"events"`
+	Secret string             `json:"secret"`
+	Active *bool              `json:"active"`
+}
+
+func (req *webhookRequest) webhook() *domain.Webhook {
+	return &domain.Webhook{
+		URL:    req.URL,
+		Events: req.Events,
+		Secret: req.Secret,
+		Active: req.Active == nil || *req.Active,
+	}
+}
+
+// webhookResponse - подписка в ответе. Секрет возвращается только при
+// создании.
+type webhookResponse struct {
+	ID        int                 `json:"id"`
+	URL       string              `json:"url"`
+	Events    []domain.EventType  `json:"events"`
+	Secret    string              `json:"secret,omitempty"`
+	Active    bool                `json:"active"`
+	CreatedAt time.Time           `json:"created_at"`
+	State     domain.WebhookState `json:"state"`
+}
+
+func newWebhookResponse(hook *domain.Webhook) webhookResponse {
+	resp := webhookResponse{
+		ID:        hook.ID,
+		URL:       hook.URL,
+		Events:    hook.Events,
+		Active:    hook.Active,
+		CreatedAt: hook.CreatedAt,
+		State:     hook.State,
+	}
+	if resp.Events == nil {
+		resp.Events = []domain.EventType{}
+	}
+	return resp
+}
+
+// HandleWebhooks обрабатывает /webhooks эндпоинт
+func (h *WebhookHandler) HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.CreateWebhook(w, r)
+	case http.MethodGet:
+		h.GetWebhooks(w, r)
+	default:
+		respondWithMethods(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleWebhookByID обрабатывает /webhooks/{id} и /webhooks/{id}/deliveries
+func (h *WebhookHandler) HandleWebhookByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 3 || (len(parts) == 3 && parts[2] != "deliveries") {
+		respondWithError(w, r, http.StatusNotFound, problem.CodeNotFound, "Not found")
+		return
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidID, "Invalid webhook ID")
+		return
+	}
+
+	if len(parts) == 3 {
+		if r.Method != http.MethodGet {
+			respondWithMethods(w, r, http.MethodGet)
+			return
+		}
+		h.GetDeliveries(w, r, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.GetWebhook(w, r, id)
+	case http.MethodPut:
+		h.UpdateWebhook(w, r, id)
+	case http.MethodDelete:
+		h.DeleteWebhook(w, r, id)
+	default:
+		respondWithMethods(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// CreateWebhook создает подписку (POST /webhooks)
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if p := decodeJSONBody(w, r, &req, h.maxBodyBytes); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	hook, err := h.useCase.CreateWebhook(r.Context(), req.webhook())
+	if err != nil {
+		respondWithWebhookError(w, r, err, "Failed to create webhook")
+		return
+	}
+
+	resp := newWebhookResponse(hook)
+	resp.Secret = hook.Secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// GetWebhooks возвращает все подписки (GET /webhooks)
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.useCase.GetWebhooks(r.Context())
+	if err != nil {
+		respondWithWebhookError(w, r, err, "Failed to fetch webhooks")
+		return
+	}
+
+	resp := make([]webhookResponse, len(hooks))
+	for i, hook := range hooks {
+		resp[i] = newWebhookResponse(hook)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// GetWebhook возвращает подписку (GET /webhooks/{id})
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	hook, err := h.useCase.GetWebhook(r.Context(), id)
+	if err != nil {
+		respondWithWebhookError(w, r, err, "Failed to fetch webhook")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWebhookResponse(hook))
+}
+
+// UpdateWebhook заменяет настройки подписки (PUT /webhooks/{id})
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	var req webhookRequest
+	if p := decodeJSONBody(w, r, &req, h.maxBodyBytes); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	hook, err := h.useCase.UpdateWebhook(r.Context(), id, req.webhook())
+	if err != nil {
+		respondWithWebhookError(w, r, err, "Failed to update webhook")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWebhookResponse(hook))
+}
+
+// DeleteWebhook удаляет подписку (DELETE /webhooks/{id})
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request, id int) {
+	if err := h.useCase.DeleteWebhook(r.Context(), id); err != nil {
+		respondWithWebhookError(w, r, err, "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries возвращает журнал доставки подписки, начиная с последней
+// попытки (GET /webhooks/{id}/deliveries)
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request, id int) {
+	deliveries, err := h.useCase.Deliveries(r.Context(), id)
+	if err != nil {
+		respondWithWebhookError(w, r, err, "Failed to fetch deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []domain.WebhookDelivery{}
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// respondWithWebhookError - respondWithDomainError с описанием ошибки
+// валидации, относящимся к подписке
+func respondWithWebhookError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	p := domainProblem(err, detail)
+	if p.Code == problem.CodeValidationFailed {
+		p.Detail = "The webhook has invalid fields"
+	}
+	problem.Write(w, r, p)
+}
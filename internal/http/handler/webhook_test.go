@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"todo/internal/domain"
+	"todo/internal/http/problem"
+	"todo/internal/repository"
+	"todo/internal/usecase"
+)
+
+func setupWebhookHandler() (*WebhookHandler, *repository.InMemoryWebhookRepository, *repository.InMemoryTodoRepository) {
+	hooks := repository.NewInMemoryWebhookRepository()
+	todos := repository.NewInMemoryTodoRepository()
+	return NewWebhookHandler(usecase.NewWebhookUseCase(hooks, todos, nil), 0), hooks, todos
+}
+
+func serveWebhook(h *WebhookHandler, method, path, body string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	if path == "/webhooks" {
+		h.HandleWebhooks(rec, req)
+	} else {
+		h.HandleWebhookByID(rec, req)
+	}
+	return rec
+}
+
+func TestWebhookHandler_CRUD(t *testing.T) {
+	handler, hooks, todos := setupWebhookHandler()
+	ctx := context.Background()
+	// Подписка получает только события после создания
+	todos.Create(ctx, &domain.Todo{Title: "До подписки"})
+
+	rec := serveWebhook(handler, http.MethodPost, "/webhooks", `{"url": "https://example.com/hook", "events": ["todo.created"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created webhookResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.ID <= 0 || !created.Active || len(created.Secret) != 64 || created.State.Cursor != 1 {
+		t.Fatalf("unexpected webhook %+v", created)
+	}
+	path := "/webhooks/" + itoa(uint64(created.ID))
+
+	t.Run("секрет не возвращается при чтении", func(t *testing.T) {
+		rec := serveWebhook(handler, http.MethodGet, path, "")
+		var got webhookResponse
+		json.NewDecoder(rec.Body).Decode(&got)
+		if rec.Code != http.StatusOK || got.Secret != "" || got.URL != "https://example.com/hook" {
+			t.Errorf("unexpected response %d %+v", rec.Code, got)
+		}
+
+		rec = serveWebhook(handler, http.MethodGet, "/webhooks", "")
+		var list []webhookResponse
+		json.NewDecoder(rec.Body).Decode(&list)
+		if len(list) != 1 || list[0].Secret != "" {
+			t.Errorf("unexpected list %+v", list)
+		}
+	})
+
+	t.Run("замена без секрета сохраняет прежний", func(t *testing.T) {
+		rec := serveWebhook(handler, http.MethodPut, path, `{"url": "https://example.com/other", "active": false}`)
+		var got webhookResponse
+		json.NewDecoder(rec.Body).Decode(&got)
+		if rec.Code != http.StatusOK || got.URL != "https://example.com/other" || got.Active || len(got.Events) != 0 {
+			t.Fatalf("unexpected response %d %+v", rec.Code, got)
+		}
+		stored, _ := hooks.GetWebhook(ctx, created.ID)
+		if stored.Secret != created.Secret {
+			t.Errorf("expected the secret to be kept")
+		}
+	})
+
+	t.Run("включение начинает с новых событий", func(t *testing.T) {
+		todos.Create(ctx, &domain.Todo{Title: "Пока выключена"})
+
+		rec := serveWebhook(handler, http.MethodPut, path, `{"url": "https://example.com/other"}`)
+		var got webhookResponse
+		json.NewDecoder(rec.Body).Decode(&got)
+		if rec.Code != http.StatusOK || !got.Active || got.State.Cursor != 2 {
+			t.Errorf("expected the cursor to move to the outbox head, got %d %+v", rec.Code, got)
+		}
+	})
+
+	t.Run("журнал доставки", func(t *testing.T) {
+		hooks.AddDelivery(ctx, created.ID, domain.WebhookDelivery{EventID: 3, Attempt: 1, StatusCode: 500})
+
+		rec := serveWebhook(handler, http.MethodGet, path+"/deliveries", "")
+		var log []domain.WebhookDelivery
+		json.NewDecoder(rec.Body).Decode(&log)
+		if rec.Code != http.StatusOK || len(log) != 1 || log[0].StatusCode != 500 {
+			t.Errorf("unexpected response %d %+v", rec.Code, log)
+		}
+	})
+
+	t.Run("удаление", func(t *testing.T) {
+		if rec := serveWebhook(handler, http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		for _, p := range []string{path, path + "/deliveries"} {
+			rec := serveWebhook(handler, http.MethodGet, p, "")
+			var prob problem.Problem
+			json.NewDecoder(rec.Body).Decode(&prob)
+			if rec.Code != http.StatusNotFound || prob.Detail != "Webhook not found" {
+				t.Errorf("%s: unexpected response %d %+v", p, rec.Code, prob)
+			}
+		}
+	})
+}
+
+func TestWebhookHandler_Errors(t *testing.T) {
+	handler, _, _ := setupWebhookHandler()
+
+	t.Run("ошибки валидации", func(t *testing.T) {
+		rec := serveWebhook(handler, http.MethodPost, "/webhooks", `{"url": "ftp://example.com", "events": ["todo.archived"], "secret": "short"}`)
+		var p problem.Problem
+		json.NewDecoder(rec.Body).Decode(&p)
+		if rec.Code != http.StatusBadRequest || p.Code != problem.CodeValidationFailed || p.Detail != "The webhook has invalid fields" {
+			t.Fatalf("unexpected response %d %+v", rec.Code, p)
+		}
+		fields := make([]string, len(p.Errors))
+		for i, e := range p.Errors {
+			fields[i] = e.Field
+		}
+		if strings.Join(fields, ",") != "url,events[0],secret" {
+			t.Errorf("expected errors for url, events[0] and secret, got %v", fields)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"неизвестное поле", http.MethodPost, "/webhooks", `{"url": "https://example.com", "retries": 3}`, http.StatusBadRequest, problem.CodeInvalidBody},
+		{"неверный ID", http.MethodGet, "/webhooks/abc", "", http.StatusBadRequest, problem.CodeInvalidID},
+		{"неизвестный путь", http.MethodGet, "/webhooks/1/retries", "", http.StatusNotFound, problem.CodeNotFound},
+		{"замена несуществующей", http.MethodPut, "/webhooks/404", `{"url": "https://example.com"}`, http.StatusNotFound, problem.CodeNotFound},
+		{"неподдерживаемый метод", http.MethodPost, "/webhooks/1/deliveries", "", http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveWebhook(handler, tt.method, tt.path, tt.body)
+			var p problem.Problem
+			json.NewDecoder(rec.Body).Decode(&p)
+			if rec.Code != tt.status || p.Code != tt.code {
+				t.Errorf("expected %d %s, got %d %+v", tt.status, tt.code, rec.Code, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/events"
+	"todo/internal/http/problem"
+	"todo/internal/http/websocket"
+	"todo/internal/usecase"
+)
+
+// Параметры WebSocket по умолчанию
+const (
+	DefaultPingInterval   = 30 * time.Second
+	DefaultWSWriteTimeout = 10 * time.Second
+	DefaultCommandTimeout = 5 * time.Second
+
+	// wsReplyBuffer - сколько ответов на команды ждут отправки; когда буфер
+	// полон, команды перестают читаться из соединения
+	wsReplyBuffer = 16
+	// wsCloseTimeout - сколько ждать ответного close от клиента
+	wsCloseTimeout = 5 * time.Second
+)
+
+// Типы сообщений сервера
+const (
+	wsTypeEvent  = "event"
+	wsTypeReset  = "reset"
+	wsTypeResult = "result"
+	wsTypeError  = "error"
+)
+
+// Команды клиента
+const (
+	wsOpCreate = "create"
+	wsOpUpdate = "update"
+	wsOpDelete = "delete"
+)
+
+// WebSocketConfig задает параметры соединений WebSocket
+type WebSocketConfig struct {
+	// PingInterval - период ping; соединение, не приславшее ничего за два
+	// периода, закрывается
+	PingInterval time.Duration
+	// WriteTimeout - срок отправки одного сообщения клиенту
+	WriteTimeout time.Duration
+	// CommandTimeout ограничивает выполнение одной команды
+	CommandTimeout time.Duration
+	// MaxMessageBytes ограничивает размер сообщения клиента
+	MaxMessageBytes int64
+}
+
+// WebSocketHandler открывает двусторонний канал: сервер отправляет события
+// изменения задач и ответы, клиент - команды create, update и delete
+type WebSocketHandler struct {
+	useCase     *usecase.TodoUseCase
+	bus         *events.Bus
+	config      WebSocketConfig
+	allowOrigin func(origin string) bool
+	authorized  func(r *http.Request) bool
+}
+
+// WebSocketOption настраивает WebSocketHandler
+type WebSocketOption func(*WebSocketHandler)
+
+// WithOriginCheck разрешает открывать соединение со страниц с другим
+// origin, если allow его принимает. Без этой опции допустимы только запросы
+// без Origin и с origin самого сервера.
+func WithOriginCheck(allow func(origin string) bool) WebSocketOption {
+	return func(h *WebSocketHandler) { h.allowOrigin = allow }
+}
+
+// WithCredentialCheck перепроверяет учетные данные запроса, открывшего
+// соединение, перед каждым ping; если check вернул false (например, ключ
+// отозван при перезагрузке конфигурации), соединение закрывается
+func WithCredentialCheck(check func(r *http.Request) bool) WebSocketOption {
+	return func(h *WebSocketHandler) { h.authorized = check }
+}
+
+// NewWebSocketHandler создает обработчик WebSocket
+func NewWebSocketHandler(uc *usecase.TodoUseCase, bus *events.Bus, config WebSocketConfig, opts ...WebSocketOption) *WebSocketHandler {
+	if config.PingInterval <= 0 {
+		config.PingInterval = DefaultPingInterval
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = DefaultWSWriteTimeout
+	}
+	if config.CommandTimeout <= 0 {
+		config.CommandTimeout = DefaultCommandTimeout
+	}
+	if config.MaxMessageBytes <= 0 {
+		config.MaxMessageBytes = DefaultMaxBodyBytes
+	}
+
+	h := &WebSocketHandler{useCase: uc, bus: bus, config: config}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// wsCommand - сообщение клиента
+type wsCommand struct {
+	ID     string       `json:"id"`
+	Op     string       `json:"op"`
+	TodoID int          `json:"todo_id"`
+	Todo   *todoRequest `json:"todo"`
+}
+
+// wsMessage - сообщение сервера
+type wsMessage struct {
+	Type        string            `json:"type"`
+	ID          string            `json:"id,omitempty"`
+	Event       *domain.TodoEvent `json:"event,omitempty"`
+	LastEventID uint64            `json:"last_event_id,omitempty"`
+	Todo        *domain.Todo      `json:"todo,omitempty"`
+	Error       *problem.Problem  `json:"error,omitempty"`
+}
+
+// ServeHTTP обрабатывает GET /todos/ws. Параметры project, tag и
+// last_event_id работают как у /todos/events. Соединение принадлежит
+// принципалу, открывшему его: команды выполняются от его имени.
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithMethods(w, r, http.MethodGet)
+		return
+	}
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, problem.CodeInvalidID, "last_event_id must be a non-negative integer")
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !h.originAllowed(r, origin) {
+		respondWithError(w, r, http.StatusForbidden, problem.CodeOriginNotAllowed, "Origin is not allowed")
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return // ответ уже отправлен
+	}
+	defer conn.Close()
+	conn.SetReadLimit(h.config.MaxMessageBytes)
+	conn.SetWriteTimeout(h.config.WriteTimeout)
+
+	sub, replay, complete := h.bus.Subscribe(lastID, eventFilter(r))
+	defer sub.Close()
+
+	s := &wsSession{
+		h:       h,
+		conn:    conn,
+		r:       r,
+		replies: make(chan wsMessage, wsReplyBuffer),
+		done:    make(chan struct{}),
+	}
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeLoop(sub, replay, complete)
+	}()
+
+	s.readLoop(writerDone)
+	close(s.done)
+	<-writerDone
+}
+
+// originAllowed пропускает origin самого сервера и origin, принятые
+// allowOrigin
+func (h *WebSocketHandler) originAllowed(r *http.Request, origin string) bool {
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return h.allowOrigin != nil && h.allowOrigin(origin)
+}
+
+// wsSession - состояние одного соединения. Команды читает и выполняет
+// горутина обработчика, а пишет в соединение только writeLoop.
+type wsSession struct {
+	h       *WebSocketHandler
+	conn    *websocket.Conn
+	r       *http.Request
+	replies chan wsMessage
+	done    chan struct{} // закрывается, когда readLoop завершился
+}
+
+// readLoop читает команды, пока клиент не закроет соединение, не нарушит
+// протокол или не перестанет отвечать на ping. Ответ ставится в очередь
+// writeLoop; если очередь полна, чтение останавливается, и клиент, не
+// читающий ответы, упирается в TCP окно, а не в память сервера.
+func (s *wsSession) readLoop(writerDone <-chan struct{}) {
+	idle := 2 * s.h.config.PingInterval
+	s.conn.SetReadDeadline(time.Now().Add(idle))
+	s.conn.SetPongHandler(func() { s.conn.SetReadDeadline(time.Now().Add(idle)) })
+
+	for {
+		typ, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(idle))
+
+		if typ != websocket.TextMessage {
+			s.conn.WriteClose(websocket.CloseUnsupportedData, "only text messages are supported")
+			s.conn.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+			continue // дочитываем до ответного close
+		}
+
+		reply := s.handle(data)
+		select {
+		case s.replies <- reply:
+		case <-writerDone:
+			return
+		}
+	}
+}
+
+// handle выполняет команду и возвращает ответ на нее
+func (s *wsSession) handle(data []byte) wsMessage {
+	var cmd wsCommand
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cmd); err != nil {
+		return wsError(cmd.ID, decodeProblem(err, dec.InputOffset()))
+	}
+	if end := dec.InputOffset(); !isEOF(dec) {
+		return wsError(cmd.ID, invalidBody(fmt.Sprintf("unexpected data after JSON object ending at offset %d", end)))
+	}
+
+	ctx, cancel := context.WithTimeout(s.r.Context(), s.h.config.CommandTimeout)
+	defer cancel()
+
+	switch cmd.Op {
+	case wsOpCreate:
+		if p := cmd.checkTodo(0); p != nil {
+			return wsError(cmd.ID, p)
+		}
+		todo, err := s.h.useCase.CreateTodo(ctx, cmd.Todo.todo())
+		if err != nil {
+			return wsError(cmd.ID, domainProblem(err, "Failed to create todo"))
+		}
+		return wsMessage{Type: wsTypeResult, ID: cmd.ID, Todo: todo}
+	case wsOpUpdate:
+		if p := cmd.checkTodoID(); p != nil {
+			return wsError(cmd.ID, p)
+		}
+		if p := cmd.checkTodo(cmd.TodoID); p != nil {
+			return wsError(cmd.ID, p)
+		}
+		todo, err := s.h.useCase.UpdateTodo(ctx, cmd.TodoID, cmd.Todo.todo())
+		if err != nil {
+			return wsError(cmd.ID, domainProblem(err, "Failed to update todo"))
+		}
+		return wsMessage{Type: wsTypeResult, ID: cmd.ID, Todo: todo}
+	case wsOpDelete:
+		if p := cmd.checkTodoID(); p != nil {
+			return wsError(cmd.ID, p)
+		}
+		if err := s.h.useCase.DeleteTodo(ctx, cmd.TodoID); err != nil {
+			return wsError(cmd.ID, domainProblem(err, "Failed to delete todo"))
+		}
+		return wsMessage{Type: wsTypeResult, ID: cmd.ID}
+	default:
+		p := invalidBody(fmt.Sprintf("unknown op %q, expected create, update or delete", cmd.Op))
+		p.Errors = []problem.FieldError{{Field: "op", Code: domain.CodeInvalid, Message: "op must be create, update or delete"}}
+		return wsError(cmd.ID, p)
+	}
+}
+
+func (cmd *wsCommand) checkTodoID() *problem.Problem {
+	if cmd.TodoID <= 0 {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidID, "todo_id must be a positive integer")
+	}
+	return nil
+}
+
+func (cmd *wsCommand) checkTodo(id int) *problem.Problem {
+	if cmd.Todo == nil {
+		p := invalidBody("todo is required")
+		p.Errors = []problem.FieldError{{Field: "todo", Code: domain.CodeRequired, Message: "todo is required"}}
+		return p
+	}
+	return cmd.Todo.checkID(id)
+}
+
+func isEOF(dec *json.Decoder) bool {
+	_, err := dec.Token()
+	return errors.Is(err, io.EOF)
+}
+
+func wsError(id string, p *problem.Problem) wsMessage {
+	return wsMessage{Type: wsTypeError, ID: id, Error: p}
+}
+
+// writeLoop отправляет пропущенные события, затем новые события, ответы на
+// команды и ping. Если шина отключила подписку из-за переполнения (клиент
+// читает медленнее, чем появляются события), соединение закрывается с кодом
+// 1013, и клиент переподключается с last_event_id.
+func (s *wsSession) writeLoop(sub *events.Subscription, replay []domain.TodoEvent, complete bool) {
+	if !complete {
+		if !s.send(wsMessage{Type: wsTypeReset, LastEventID: s.h.bus.LastID()}) {
+			return
+		}
+	}
+	for i := range replay {
+		if !s.send(wsMessage{Type: wsTypeEvent, Event: &replay[i]}) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(s.h.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case reply := <-s.replies:
+			if !s.send(reply) {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					s.close(websocket.CloseTryAgainLater, "client is too slow to receive events")
+				} else {
+					s.close(websocket.CloseGoingAway, "server is shutting down")
+				}
+				return
+			}
+			if !s.send(wsMessage{Type: wsTypeEvent, Event: &event}) {
+				return
+			}
+		case <-ticker.C:
+			if s.h.authorized != nil && !s.h.authorized(s.r) {
+				s.close(websocket.ClosePolicyViolation, "credentials are no longer valid")
+				return
+			}
+			if err := s.conn.Ping(nil); err != nil {
+				s.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// send отправляет сообщение. При ошибке записи соединение закрывается, чтобы
+// прервать чтение, и возвращается false.
+func (s *wsSession) send(msg wsMessage) bool {
+	data, _ := json.Marshal(msg)
+	err := s.conn.WriteMessage(websocket.TextMessage, data)
+	switch {
+	case errors.Is(err, websocket.ErrCloseSent):
+		// Закрытие уже начато, readLoop дождется ответа клиента
+		return false
+	case err != nil:
+		s.conn.Close()
+		return false
+	}
+	return true
+}
+
+// close начинает закрытие и ждет ответного close от клиента, после чего
+// readLoop завершается; не дождавшись, закрывает соединение сам
+func (s *wsSession) close(code int, reason string) {
+	if err := s.conn.WriteClose(code, reason); err != nil {
+		s.conn.Close()
+		return
+	}
+
+	timer := time.NewTimer(wsCloseTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.done:
+	case <-timer.C:
+		s.conn.Close()
+	}
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/events"
+	"todo/internal/http/websocket"
+	"todo/internal/repository"
+	"todo/internal/usecase"
+)
+
+func newWebSocketServer(t *testing.T, bus *events.Bus, config WebSocketConfig, opts ...WebSocketOption) *httptest.Server {
+	t.Helper()
+	uc := usecase.NewTodoUseCase(repository.NewInMemoryTodoRepository(), usecase.WithEventPublisher(bus))
+	srv := httptest.NewServer(NewWebSocketHandler(uc, bus, config, opts...))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, query string, header http.Header) *websocket.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+query, header)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func sendCommand(t *testing.T, conn *websocket.Conn, cmd string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(cmd)); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	var msg wsMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("bad message %s: %v", data, err)
+	}
+	return msg
+}
+
+// readUntil читает сообщения, пока не встретит сообщение типа typ.
+// Ответ на команду и событие о ней приходят в произвольном порядке.
+func readUntil(t *testing.T, conn *websocket.Conn, typ string) wsMessage {
+	t.Helper()
+	for {
+		if msg := readMessage(t, conn); msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+func TestWebSocketHandler_Commands(t *testing.T) {
+	srv := newWebSocketServer(t, events.NewBus(16), WebSocketConfig{})
+	conn := dialWebSocket(t, srv, "", nil)
+	observer := dialWebSocket(t, srv, "", nil)
+
+	sendCommand(t, conn, `{"id":"1","op":"create","todo":{"title":"Купить хлеб"}}`)
+	created := readUntil(t, conn, wsTypeResult)
+	if created.ID != "1" || created.Todo == nil || created.Todo.ID == 0 {
+		t.Fatalf("create result = %+v", created)
+	}
+
+	event := readUntil(t, observer, wsTypeEvent)
+	if event.Event.Type != domain.EventTodoCreated || event.Event.Todo.Title != "Купить хлеб" {
+		t.Errorf("observer got %+v", event.Event)
+	}
+
+	sendCommand(t, conn, `{"id":"2","op":"update","todo_id":`+strconv.Itoa(created.Todo.ID)+`,"todo":{"title":"Купить батон","completed":true}}`)
+	if updated := readUntil(t, conn, wsTypeResult); updated.ID != "2" || !updated.Todo.Completed {
+		t.Errorf("update result = %+v", updated)
+	}
+
+	sendCommand(t, conn, `{"id":"3","op":"delete","todo_id":`+strconv.Itoa(created.Todo.ID)+`}`)
+	if deleted := readUntil(t, conn, wsTypeResult); deleted.ID != "3" || deleted.Todo != nil {
+		t.Errorf("delete result = %+v", deleted)
+	}
+
+	for _, want := range []domain.EventType{domain.EventTodoUpdated, domain.EventTodoDeleted} {
+		if event := readUntil(t, observer, wsTypeEvent); event.Event.Type != want {
+			t.Errorf("observer event = %s, want %s", event.Event.Type, want)
+		}
+	}
+}
+
+func TestWebSocketHandler_CommandErrors(t *testing.T) {
+	srv := newWebSocketServer(t, events.NewBus(16), WebSocketConfig{})
+	conn := dialWebSocket(t, srv, "", nil)
+
+	tests := []struct {
+		name string
+		cmd  string
+		code string
+	}{
+		{"неизвестная операция", `{"id":"a","op":"archive"}`, "invalid_body"},
+		{"неизвестное поле", `{"id":"b","op":"create","todo":{"title":"x","color":"red"}}`, "invalid_body"},
+		{"невалидная задача", `{"id":"c","op":"create","todo":{"title":""}}`, "validation_failed"},
+		{"без todo", `{"id":"d","op":"create"}`, "invalid_body"},
+		{"без todo_id", `{"id":"e","op":"delete"}`, "invalid_id"},
+		{"нет задачи", `{"id":"f","op":"update","todo_id":42,"todo":{"title":"x"}}`, "not_found"},
+		{"ID в теле", `{"id":"g","op":"update","todo_id":42,"todo":{"id":7,"title":"x"}}`, "invalid_body"},
+		{"битый JSON", `{"id":"h",`, "invalid_body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sendCommand(t, conn, tt.cmd)
+			msg := readMessage(t, conn)
+			if msg.Type != wsTypeError || msg.Error == nil || msg.Error.Code != tt.code {
+				t.Fatalf("got %+v, want error %s", msg, tt.code)
+			}
+		})
+	}
+}
+
+func TestWebSocketHandler_Resume(t *testing.T) {
+	bus := events.NewBus(2)
+	srv := newWebSocketServer(t, bus, WebSocketConfig{})
+	conn := dialWebSocket(t, srv, "", nil)
+
+	for _, title := range []string{"a", "b", "c"} {
+		sendCommand(t, conn, `{"op":"create","todo":{"title":"`+title+`"}}`)
+		readUntil(t, conn, wsTypeResult)
+	}
+
+	resumed := dialWebSocket(t, srv, "?last_event_id=2", nil)
+	if msg := readMessage(t, resumed); msg.Type != wsTypeEvent || msg.Event.ID != 3 {
+		t.Errorf("resume got %+v, want event 3", msg)
+	}
+
+	gap := dialWebSocket(t, srv, "?last_event_id=100", nil)
+	if msg := readMessage(t, gap); msg.Type != wsTypeReset || msg.LastEventID != 3 {
+		t.Errorf("unknown last_event_id got %+v, want reset", msg)
+	}
+}
+
+func TestWebSocketHandler_Origin(t *testing.T) {
+	srv := newWebSocketServer(t, events.NewBus(16), WebSocketConfig{},
+		WithOriginCheck(func(origin string) bool { return origin == "https://board.example.com" }))
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{srv.URL, true},
+		{"https://board.example.com", true},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, resp, err := websocket.Dial(context.Background(), url, header)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("origin %q: %v", tt.origin, err)
+				continue
+			}
+			conn.Close()
+			continue
+		}
+		if !errors.Is(err, websocket.ErrBadHandshake) || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("origin %q: got %v, want 403", tt.origin, err)
+		}
+	}
+}
+
+func TestWebSocketHandler_CredentialsRevoked(t *testing.T) {
+	var valid atomic.Bool
+	valid.Store(true)
+	srv := newWebSocketServer(t, events.NewBus(16), WebSocketConfig{PingInterval: 20 * time.Millisecond},
+		WithCredentialCheck(func(*http.Request) bool { return valid.Load() }))
+	conn := dialWebSocket(t, srv, "", nil)
+
+	sendCommand(t, conn, `{"op":"create","todo":{"title":"x"}}`)
+	readUntil(t, conn, wsTypeResult)
+	valid.Store(false)
+
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+			t.Fatalf("got %v, want close %d", err, websocket.ClosePolicyViolation)
+		}
+		return
+	}
+}
+
+func TestWebSocketHandler_ShutdownClosesConnection(t *testing.T) {
+	bus := events.NewBus(16)
+	srv := newWebSocketServer(t, bus, WebSocketConfig{})
+	conn := dialWebSocket(t, srv, "", nil)
+
+	// Дожидаемся подписки: ответ на команду приходит после нее
+	sendCommand(t, conn, `{"op":"delete","todo_id":1}`)
+	readMessage(t, conn)
+	bus.Close()
+
+	_, _, err := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("got %v, want close %d", err, websocket.CloseGoingAway)
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"todo/internal/auth"
+	"todo/internal/http/problem"
+)
+
+// APIKeyHeader - альтернативный заголовок с API ключом
+const APIKeyHeader = "X-API-Key"
+
+// APIKey возвращает API ключ запроса из Authorization: Bearer <ключ> или
+// X-API-Key
+func APIKey(r *http.Request) string {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return r.Header.Get(APIKeyHeader)
+}
+
+// Auth проверяет API ключ из заголовка Authorization: Bearer <ключ> или
+// X-API-Key и кладет принципала в контекст запроса. Пока в наборе нет ключей,
+// все запросы выполняются от имени auth.Anonymous. Пути publicPaths (пробы,
+// метрики) доступны без ключа.
+func Auth(keys *auth.KeySet, publicPaths ...string) func(http.Handler) http.Handler {
+	public := make(map[string]bool, len(publicPaths))
+	for _, p := range publicPaths {
+		public[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !keys.Enabled() || public[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := APIKey(r)
+			principal, ok := keys.Authenticate(key)
+			if key == "" || !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="todo"`)
+				problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "Invalid or missing API key")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"todo/internal/auth"
+)
+
+func TestAuth(t *testing.T) {
+	keys := auth.NewKeySet(nil)
+	handler := Auth(keys, "/livez")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.PrincipalFromContext(r.Context())))
+	}))
+
+	do := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Без ключей аутентификация выключена
+	if rec := do("/todos"); rec.Code != http.StatusOK || rec.Body.String() != auth.Anonymous {
+		t.Fatalf("expected anonymous access, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	keys.Update(map[string]string{"k1": "alice"})
+
+	tests := []struct {
+		name      string
+		path      string
+		header    []string
+		wantCode  int
+		principal string
+	}{
+		{"missing key", "/todos", nil, http.StatusUnauthorized, ""},
+		{"wrong key", "/todos", []string{APIKeyHeader, "nope"}, http.StatusUnauthorized, ""},
+		{"api key header", "/todos", []string{APIKeyHeader, "k1"}, http.StatusOK, "alice"},
+		{"bearer", "/todos", []string{"Authorization", "Bearer k1"}, http.StatusOK, "alice"},
+		{"public path", "/livez", nil, http.StatusOK, auth.Anonymous},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := do(tc.path, tc.header...)
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, rec.Code)
+			}
+			if tc.wantCode == http.StatusUnauthorized {
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Error("expected WWW-Authenticate header")
+				}
+				return
+			}
+			if rec.Body.String() != tc.principal {
+				t.Errorf("expected principal %q, got %q", tc.principal, rec.Body.String())
+			}
+		})
+	}
+
+	// Отозванный ключ перестает действовать сразу
+	keys.Update(map[string]string{"k2": "bob"})
+	if rec := do("/todos", APIKeyHeader, "k1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected rotated key to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Поддерживаемые кодировки ответа
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// DefaultCompressMinSize - ответы короче этого размера не сжимаются
+const DefaultCompressMinSize = 1024
+
+// Пулы кодировщиков: их создание дорого, а Reset позволяет переиспользовать
+var (
+	gzipPool    = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	deflatePool = sync.Pool{New: func() any { return zlib.NewWriter(io.Discard) }}
+)
+
+// encoder - общий интерфейс gzip.Writer и zlib.Writer
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compress сжимает ответы gzip или deflate, если клиент указал их в
+// Accept-Encoding. Первые minSize байт ответа буферизуются: более короткие
+// ответы отправляются как есть. Не сжимаются ответы с уже заданным
+// Content-Encoding, сжатыми типами содержимого (изображения, архивы), без
+// тела, на HEAD и Upgrade запросы. Flush отправляет уже сжатые данные, поэтому
+// потоковые ответы доходят до клиента без задержки.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding выбирает кодировку с наибольшим q из Accept-Encoding;
+// при равенстве предпочитается gzip. Пустая строка - сжатие не принимается.
+func negotiateEncoding(header string) string {
+	var gzipQ, deflateQ, anyQ float64 = -1, -1, -1
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encodingGzip, "x-gzip":
+			gzipQ = q
+		case encodingDeflate:
+			deflateQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if gzipQ < 0 {
+		gzipQ = anyQ
+	}
+	if deflateQ < 0 {
+		deflateQ = anyQ
+	}
+
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return encodingGzip
+	case deflateQ > 0:
+		return encodingDeflate
+	default:
+		return ""
+	}
+}
+
+// compressible сообщает, есть ли смысл сжимать содержимое этого типа
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	major, minor, _ := strings.Cut(mediaType, "/")
+	switch major {
+	case "text":
+		return true
+	case "image":
+		return minor == "svg+xml"
+	case "video", "audio", "font":
+		return false
+	}
+	switch minor {
+	case "zip", "gzip", "x-gzip", "zstd", "x-bzip2", "x-7z-compressed", "x-rar-compressed", "octet-stream", "pdf", "wasm":
+		return false
+	}
+	return true
+}
+
+// compressWriter откладывает решение о сжатии до накопления minSize байт,
+// Flush или конца ответа
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	enc         encoder // nil, если ответ не сжимается
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	// Информационные ответы (103 Early Hints) не завершают заголовки
+	if code >= 100 && code < 200 {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		return cw.target().Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush принимает решение о сжатии, не дожидаясь minSize, и отправляет
+// накопленные данные клиенту
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap открывает исходный writer для http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide отправляет заголовки и буфер, включая сжатие, если compress и
+// ответ ему подходит
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Иначе net/http определит тип по уже сжатым данным
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if compress && bodyAllowed(cw.status) && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if cw.encoding == encodingGzip {
+			cw.enc = gzipPool.Get().(*gzip.Writer)
+		} else {
+			cw.enc = deflatePool.Get().(*zlib.Writer)
+		}
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	_, err := cw.target().Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressWriter) target() io.Writer {
+	if cw.enc != nil {
+		return cw.enc
+	}
+	return cw.ResponseWriter
+}
+
+// close завершает ответ: короткий ответ отправляется без сжатия, а
+// кодировщик дописывает окончание потока и возвращается в пул
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			return // обработчик ничего не записал
+		}
+		cw.decide(false)
+	}
+	if cw.enc == nil {
+		return
+	}
+
+	cw.enc.Close()
+	cw.enc.Reset(io.Discard)
+	if cw.encoding == encodingGzip {
+		gzipPool.Put(cw.enc)
+	} else {
+		deflatePool.Put(cw.enc)
+	}
+	cw.enc = nil
+}
+
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"gzip":                      "gzip",
+		"deflate":                   "deflate",
+		"gzip, deflate, br":         "gzip",
+		"deflate;q=1, gzip;q=0.5":   "deflate",
+		"gzip;q=0, deflate":         "deflate",
+		"gzip;q=0":                  "",
+		"*":                         "gzip",
+		"*;q=0.1, gzip;q=0":         "deflate",
+		"identity":                  "",
+		"br, GZIP ; q=0.8":          "gzip",
+		"gzip;q=abc, deflate;q=0.2": "deflate",
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"title":"Купить молоко"},`, 100)
+
+	serve := func(h http.HandlerFunc, method, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/todos", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		Compress(DefaultCompressMinSize)(h).ServeHTTP(rec, req)
+		return rec
+	}
+	write := func(contentType, body string, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			w.Header().Set("Content-Length", "999")
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+		}
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		rec := serve(write("application/json", large, http.StatusOK), http.MethodGet, "gzip")
+
+		if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("unexpected headers %v", rec.Header())
+		}
+		if rec.Header().Get("Content-Length") != "" {
+			t.Error("Content-Length of the uncompressed body must be removed")
+		}
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(zr)
+		if string(body) != large {
+			t.Error("decompressed body differs from the original")
+		}
+	})
+
+	t.Run("deflate", func(t *testing.T) {
+		rec := serve(write("application/json", large, http.StatusOK), http.MethodGet, "deflate")
+
+		if rec.Header().Get("Content-Encoding") != "deflate" {
+			t.Fatalf("unexpected headers %v", rec.Header())
+		}
+		zr, err := zlib.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(zr)
+		if string(body) != large {
+			t.Error("decompressed body differs from the original")
+		}
+	})
+
+	t.Run("без сжатия", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			handler http.HandlerFunc
+			method  string
+			accept  string
+		}{
+			{"клиент не принимает", write("application/json", large, http.StatusOK), http.MethodGet, ""},
+			{"короткий ответ", write("application/json", `{"id":1}`, http.StatusOK), http.MethodGet, "gzip"},
+			{"сжатый тип", write("image/png", large, http.StatusOK), http.MethodGet, "gzip"},
+			{"уже закодирован", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				io.WriteString(w, large)
+			}, http.MethodGet, "gzip"},
+			{"HEAD", write("application/json", "", http.StatusOK), http.MethodHead, "gzip"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				rec := serve(tc.handler, tc.method, tc.accept)
+				if ce := rec.Header().Get("Content-Encoding"); ce == "gzip" {
+					t.Error("response must not be compressed")
+				}
+				if rec.Header().Get("Vary") != "Accept-Encoding" {
+					t.Error("Vary: Accept-Encoding must always be set")
+				}
+			})
+		}
+	})
+
+	t.Run("статус и пустое тело", func(t *testing.T) {
+		rec := serve(write("", "", http.StatusNoContent), http.MethodDelete, "gzip")
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+			t.Errorf("unexpected response %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+		}
+
+		rec = serve(write("application/json", large, http.StatusCreated), http.MethodPost, "gzip")
+		if rec.Code != http.StatusCreated || rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("status must be preserved, got %d %v", rec.Code, rec.Header())
+		}
+	})
+
+	t.Run("тип содержимого определяется до сжатия", func(t *testing.T) {
+		rec := serve(write("", "<html><body>"+large+"</body></html>", http.StatusOK), http.MethodGet, "gzip")
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("expected sniffed text/html, got %q", rec.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("потоковый ответ", func(t *testing.T) {
+		event := "data: created\n\n"
+		rec := serve(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, event)
+			http.NewResponseController(w).Flush()
+
+			// Событие уже должно быть доступно клиенту до конца ответа
+			zr, err := gzip.NewReader(bytes.NewReader(w.(*compressWriter).ResponseWriter.(*httptest.ResponseRecorder).Body.Bytes()))
+			if err != nil {
+				t.Fatalf("flushed data must be a valid gzip stream: %v", err)
+			}
+			got := make([]byte, len(event))
+			if _, err := io.ReadFull(zr, got); err != nil || string(got) != event {
+				t.Errorf("expected flushed event, got %q (%v)", got, err)
+			}
+		}, http.MethodGet, "gzip")
+
+		if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("expected flushed gzip stream, got %v", rec.Header())
+		}
+	})
+
+	t.Run("вместе с Logger", func(t *testing.T) {
+		var logs bytes.Buffer
+		log := slog.New(slog.NewJSONHandler(&logs, nil))
+		req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+
+		Logger(log)(Compress(DefaultCompressMinSize)(write("application/json", large, http.StatusAccepted))).ServeHTTP(rec, req)
+
+		var entry struct {
+			Status int `json:"status"`
+			Bytes  int `json:"bytes"`
+		}
+		if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		// Logger видит статус обработчика и размер сжатого ответа
+		if entry.Status != http.StatusAccepted || entry.Bytes != rec.Body.Len() || entry.Bytes >= len(large) {
+			t.Errorf("unexpected log entry %+v, compressed size %d", entry, rec.Body.Len())
+		}
+	})
+}
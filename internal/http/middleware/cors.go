@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig задает политику CORS. Элемент AllowedOrigins - точный origin
+// ("https://app.example.com"), шаблон поддоменов ("https://*.example.com")
+// или "*" для любого origin. Пустой список отключает CORS.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// AllowsOrigin проверяет origin по списку разрешенных. Используется и для
+// проверки Origin при открытии WebSocket.
+func (c *CORSConfig) AllowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" || matchOrigin(strings.ToLower(pattern), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin сравнивает origin с шаблоном. "*." в шаблоне соответствует
+// одному или нескольким поддоменам, но не самому домену.
+func matchOrigin(pattern, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(pattern, "*.")
+	if !wildcard {
+		return pattern == origin
+	}
+	if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, "."+suffix) {
+		return false
+	}
+
+	sub := origin[len(prefix) : len(origin)-len(suffix)-1]
+	if sub == "" {
+		return false
+	}
+	for _, c := range sub {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return !strings.HasPrefix(sub, ".") && !strings.Contains(sub, "..")
+}
+
+// CORS добавляет заголовки CORS для разрешенных origin и отвечает на
+// preflight запросы (OPTIONS с Access-Control-Request-Method), не передавая
+// их дальше, поэтому они не требуют аутентификации. Настройки читаются при
+// каждом запросе, что позволяет менять их на лету.
+func CORS(config func() CORSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := config()
+			if len(cfg.AllowedOrigins) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+			if origin == "" || !cfg.AllowsOrigin(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// С учетными данными "*" запрещен, поэтому origin повторяется
+			if slices.Contains(cfg.AllowedOrigins, "*") && !cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(cfg.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := r.Header.Get("Access-Control-Request-Method")
+			if !containsFold(cfg.AllowedMethods, method) && !isSimpleMethod(method) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			requested := r.Header.Get("Access-Control-Request-Headers")
+			for _, name := range strings.Split(requested, ",") {
+				if name = strings.TrimSpace(name); name != "" && !containsFold(cfg.AllowedHeaders, name) {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+
+			h.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			if requested != "" {
+				h.Set("Access-Control-Allow-Headers", requested)
+			}
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func isSimpleMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodPost
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
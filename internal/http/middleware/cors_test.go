@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern, origin string
+		want            bool
+	}{
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com", "http://app.example.com", false},
+		{"https://*.example.com", "https://a.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://evil-example.com", false},
+		{"https://*.example.com", "https://a.example.com.evil.org", false},
+		{"https://*.example.com", "https://a/b.example.com", false},
+		{"http://*.localhost:3000", "http://app.localhost:3000", true},
+		{"http://*.localhost:3000", "http://app.localhost:4000", false},
+	}
+	for _, tc := range tests {
+		if got := matchOrigin(tc.pattern, tc.origin); got != tc.want {
+			t.Errorf("matchOrigin(%q, %q) = %v, want %v", tc.pattern, tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"ETag", "X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	var reached bool
+	h := CORS(func() CORSConfig { return cfg })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	do := func(method, origin string, headers ...string) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(method, "/todos/1", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		rec := do(http.MethodOptions, "https://api.example.org",
+			"Access-Control-Request-Method", "PUT",
+			"Access-Control-Request-Headers", "content-type, authorization")
+
+		if reached {
+			t.Error("preflight must not reach the handler")
+		}
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", rec.Code)
+		}
+		want := map[string]string{
+			"Access-Control-Allow-Origin":      "https://api.example.org",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE",
+			"Access-Control-Allow-Headers":     "content-type, authorization",
+			"Access-Control-Max-Age":           "600",
+		}
+		for name, value := range want {
+			if got := rec.Header().Get(name); got != value {
+				t.Errorf("%s: expected %q, got %q", name, value, got)
+			}
+		}
+	})
+
+	t.Run("preflight с запрещенным заголовком", func(t *testing.T) {
+		rec := do(http.MethodOptions, "https://app.example.com",
+			"Access-Control-Request-Method", "PUT",
+			"Access-Control-Request-Headers", "X-Secret")
+
+		if rec.Header().Get("Access-Control-Allow-Headers") != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+			t.Errorf("disallowed headers must not be approved: %v", rec.Header())
+		}
+	})
+
+	t.Run("обычный запрос", func(t *testing.T) {
+		rec := do(http.MethodGet, "https://app.example.com")
+
+		if !reached {
+			t.Fatal("request must reach the handler")
+		}
+		if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+			rec.Header().Get("Access-Control-Expose-Headers") != "ETag, X-Request-ID" {
+			t.Errorf("unexpected CORS headers %v", rec.Header())
+		}
+		if rec.Header().Values("Vary")[0] != "Origin" {
+			t.Errorf("expected Vary: Origin, got %v", rec.Header().Values("Vary"))
+		}
+	})
+
+	t.Run("чужой origin", func(t *testing.T) {
+		rec := do(http.MethodGet, "https://evil.com")
+
+		if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("foreign origin must get no CORS headers: %v", rec.Header())
+		}
+		if rec.Header().Get("Vary") != "Origin" {
+			t.Error("Vary: Origin must be set for cacheability")
+		}
+	})
+
+	t.Run("любой origin без учетных данных", func(t *testing.T) {
+		cfg.AllowedOrigins = []string{"*"}
+		cfg.AllowCredentials = false
+		rec := do(http.MethodGet, "https://any.com")
+
+		if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Errorf("unexpected CORS headers %v", rec.Header())
+		}
+	})
+
+	t.Run("CORS выключен", func(t *testing.T) {
+		cfg.AllowedOrigins = nil
+		rec := do(http.MethodGet, "https://app.example.com")
+
+		if len(rec.Header()) != 0 {
+			t.Errorf("expected no headers, got %v", rec.Header())
+		}
+	})
+}
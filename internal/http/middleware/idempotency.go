@@ -0,0 +1,318 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"todo/internal/auth"
+	"todo/internal/http/problem"
+)
+
+// Заголовки идемпотентных запросов
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+const (
+	maxIdempotencyKeyLength   = 255
+	idempotencySweepFrequency = time.Minute
+)
+
+// ErrIdempotencyKeyReused - ключ уже использован с другим запросом
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+
+// IdempotencyStore запоминает ответы на запросы с ключом идемпотентности.
+// Записи живут ttl после завершения запроса и удаляются при обращениях к
+// хранилищу. Записанных ответов хранится не больше maxEntries: при
+// переполнении вытесняются самые старые, чтобы клиент, присылающий новый
+// ключ с каждым запросом, не занимал память на весь ttl.
+type IdempotencyStore struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// Завершенные записи в порядке завершения, а значит, и истечения:
+	// спереди - самые старые
+	completed *list.List
+	lastSweep time.Time
+}
+
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{} // закрывается, когда запрос завершен или прерван
+	response    *recordedResponse
+	expires     time.Time
+	elem        *list.Element // в completed, когда ответ записан
+}
+
+type recordedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewIdempotencyStore создает хранилище с временем жизни записей ttl,
+// запоминающее не больше maxEntries ответов
+func NewIdempotencyStore(ttl time.Duration, maxEntries int) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*idempotencyEntry),
+		completed:  list.New(),
+	}
+}
+
+// begin регистрирует запрос с ключом key. Если ответ уже записан, он
+// возвращается для повтора. Если такой же запрос еще выполняется, begin ждет
+// его завершения. owner означает, что вызывающий должен выполнить запрос и
+// вызвать complete или abort.
+func (s *IdempotencyStore) begin(ctx context.Context, key string, fingerprint [sha256.Size]byte) (resp *recordedResponse, owner bool, err error) {
+	for {
+		s.mu.Lock()
+		now := s.now()
+		s.sweep(now)
+
+		e, ok := s.entries[key]
+		if ok && e.response != nil && !now.Before(e.expires) {
+			s.remove(key, e)
+			ok = false
+		}
+		if !ok {
+			s.entries[key] = &idempotencyEntry{fingerprint: fingerprint, done: make(chan struct{})}
+			s.mu.Unlock()
+			return nil, true, nil
+		}
+		response := e.response
+		s.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		if response != nil {
+			return response, false, nil
+		}
+
+		// Тот же запрос выполняется параллельно: ждем его результата. Если
+		// он прерван, запись удалена, и следующая итерация повторит попытку.
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// complete сохраняет ответ владельца ключа
+func (s *IdempotencyStore) complete(key string, resp *recordedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.response == nil {
+		e.response = resp
+		e.expires = s.now().Add(s.ttl)
+		e.elem = s.completed.PushBack(key)
+		close(e.done)
+	}
+	for s.completed.Len() > s.maxEntries {
+		oldest := s.completed.Front().Value.(string)
+		s.remove(oldest, s.entries[oldest])
+	}
+}
+
+// abort удаляет ключ, чтобы повтор запроса выполнился заново
+func (s *IdempotencyStore) abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.response == nil {
+		delete(s.entries, key)
+		close(e.done)
+	}
+}
+
+// remove удаляет завершенную запись; вызывается под s.mu
+func (s *IdempotencyStore) remove(key string, e *idempotencyEntry) {
+	delete(s.entries, key)
+	s.completed.Remove(e.elem)
+}
+
+// sweep удаляет истекшие записи не чаще раза в минуту; вызывается под s.mu
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < idempotencySweepFrequency {
+		return
+	}
+	s.lastSweep = now
+	for s.completed.Len() > 0 {
+		key := s.completed.Front().Value.(string)
+		e := s.entries[key]
+		if now.Before(e.expires) {
+			break
+		}
+		s.remove(key, e)
+	}
+}
+
+// Idempotency обеспечивает идемпотентность POST и PATCH запросов с заголовком
+// Idempotency-Key. Ключи хранятся отдельно для каждого принципала. Повтор с
+// тем же телом получает записанный ответ с заголовком Idempotent-Replayed,
+// а повтор с другим телом - 422. Ответы 5xx и прерванные запросы не
+// запоминаются, чтобы клиент мог повторить попытку. Тела больше maxBodyBytes
+// передаются обработчику без обработки ключа, он их и отклонит.
+func Idempotency(store *IdempotencyStore, maxBodyBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validToken(key, maxIdempotencyKeyLength) {
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidIdempotencyKey,
+					"Idempotency-Key must be 1-255 visible ASCII characters")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+			if err != nil {
+				problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidBody, "Failed to read request body")
+				return
+			}
+			if int64(len(body)) > maxBodyBytes {
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			h := sha256.New()
+			io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+			h.Write(body)
+			var fingerprint [sha256.Size]byte
+			h.Sum(fingerprint[:0])
+
+			storeKey := auth.PrincipalFromContext(r.Context()) + "\x00" + key
+			resp, owner, err := store.begin(r.Context(), storeKey, fingerprint)
+			switch {
+			case errors.Is(err, ErrIdempotencyKeyReused):
+				problem.Error(w, r, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused,
+					"Idempotency-Key was already used with a different request")
+				return
+			case err != nil:
+				// Клиент ушел, пока ждал параллельный запрос
+				return
+			case !owner:
+				dst := w.Header()
+				for k, v := range resp.header {
+					dst[k] = v
+				}
+				dst.Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(resp.status)
+				w.Write(resp.body)
+				return
+			}
+
+			// Заголовки, выставленные внешними middleware (X-Request-ID,
+			// traceparent), относятся к текущему запросу и не повторяются
+			before := w.Header().Clone()
+			rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					store.abort(storeKey)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			if replayable(rec.status) {
+				store.complete(storeKey, &recordedResponse{
+					status: rec.status,
+					header: headerDiff(before, w.Header()),
+					body:   rec.body.Bytes(),
+				})
+				completed = true
+			}
+		})
+	}
+}
+
+// replayable сообщает, можно ли повторять ответ со статусом code. Ошибки
+// сервера, таймауты, лимиты и отмененные клиентом запросы (499) стоит
+// выполнить заново.
+func replayable(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, 499:
+		return false
+	}
+	return code < http.StatusInternalServerError
+}
+
+// recordingWriter передает ответ клиенту и одновременно записывает его
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *recordingWriter) Flush() {
+	rw.wroteHeader = true
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// encodingHeaders описывают кодирование тела, которое выбирают внешние
+// middleware (Compress) по заголовкам текущего запроса. Записывается тело
+// до кодирования, поэтому повтор кодируется заново и эти заголовки не
+// наследует.
+var encodingHeaders = map[string]bool{
+	"Content-Encoding": true,
+	"Content-Length":   true,
+	"Vary":             true,
+}
+
+// headerDiff возвращает заголовки after, добавленные или измененные
+// относительно before, кроме encodingHeaders
+func headerDiff(before, after http.Header) http.Header {
+	diff := make(http.Header)
+	for key, values := range after {
+		if !encodingHeaders[key] && !slices.Equal(before[key], values) {
+			diff[key] = slices.Clone(values)
+		}
+	}
+	return diff
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
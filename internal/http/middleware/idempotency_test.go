@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"todo/internal/auth"
+)
+
+func TestIdempotency(t *testing.T) {
+	newHandler := func(store *IdempotencyStore, calls *atomic.Int64, status int) http.Handler {
+		return Idempotency(store, 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := calls.Add(1)
+			w.Header().Set("Location", fmt.Sprintf("/todos/%d", n))
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"id":%d}`, n)
+		}))
+	}
+
+	do := func(h http.Handler, key, principal, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		if principal != "" {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("повтор возвращает записанный ответ", func(t *testing.T) {
+		var calls atomic.Int64
+		h := newHandler(NewIdempotencyStore(time.Hour, 100), &calls, http.StatusCreated)
+
+		first := do(h, "k1", "", `{"title":"a"}`)
+		second := do(h, "k1", "", `{"title":"a"}`)
+
+		if calls.Load() != 1 {
+			t.Fatalf("expected handler to run once, ran %d times", calls.Load())
+		}
+		if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() ||
+			second.Header().Get("Location") != "/todos/1" {
+			t.Errorf("unexpected replay %d %q %v", second.Code, second.Body.String(), second.Header())
+		}
+		if second.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+			t.Error("only the replay must be marked as replayed")
+		}
+	})
+
+	t.Run("другое тело с тем же ключом", func(t *testing.T) {
+		var calls atomic.Int64
+		h := newHandler(NewIdempotencyStore(time.Hour, 100), &calls, http.StatusCreated)
+
+		do(h, "k1", "", `{"title":"a"}`)
+		rec := do(h, "k1", "", `{"title":"b"}`)
+
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "idempotency_key_reused") {
+			t.Errorf("expected 422 problem, got %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("ключи принципалов независимы", func(t *testing.T) {
+		var calls atomic.Int64
+		h := newHandler(NewIdempotencyStore(time.Hour, 100), &calls, http.StatusCreated)
+
+		do(h, "k1", "alice", `{"title":"a"}`)
+		do(h, "k1", "bob", `{"title":"b"}`)
+
+		if calls.Load() != 2 {
+			t.Errorf("expected a separate execution per principal, got %d", calls.Load())
+		}
+	})
+
+	t.Run("без ключа и для GET не действует", func(t *testing.T) {
+		var calls atomic.Int64
+		h := newHandler(NewIdempotencyStore(time.Hour, 100), &calls, http.StatusCreated)
+
+		do(h, "", "", `{}`)
+		do(h, "", "", `{}`)
+		req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if calls.Load() != 4 {
+			t.Errorf("expected 4 executions, got %d", calls.Load())
+		}
+	})
+
+	t.Run("некорректный ключ", func(t *testing.T) {
+		var calls atomic.Int64
+		h := newHandler(NewIdempotencyStore(time.Hour, 100), &calls, http.StatusCreated)
+
+		if rec := do(h, strings.Repeat("k", 256), "", `{}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("ошибки сервера не запоминаются", func(t *testing.T) {
+		var calls atomic.Int64
+		h := newHandler(NewIdempotencyStore(time.Hour, 100), &calls, http.StatusInternalServerError)
+
+		do(h, "k1", "", `{}`)
+		do(h, "k1", "", `{}`)
+
+		if calls.Load() != 2 {
+			t.Errorf("expected retry after 5xx to run again, got %d executions", calls.Load())
+		}
+	})
+
+	t.Run("истечение TTL", func(t *testing.T) {
+		var calls atomic.Int64
+		store := NewIdempotencyStore(time.Hour, 100)
+		now := time.Now()
+		store.now = func() time.Time { return now }
+		h := newHandler(store, &calls, http.StatusCreated)
+
+		do(h, "k1", "", `{}`)
+		now = now.Add(2 * time.Hour)
+		do(h, "k1", "", `{}`)
+
+		if calls.Load() != 2 {
+			t.Errorf("expected expired key to run again, got %d executions", calls.Load())
+		}
+		if len(store.entries) != 1 {
+			t.Errorf("expected expired entries to be removed, have %d", len(store.entries))
+		}
+	})
+
+	t.Run("вытеснение старых ответов", func(t *testing.T) {
+		var calls atomic.Int64
+		store := NewIdempotencyStore(time.Hour, 2)
+		h := newHandler(store, &calls, http.StatusCreated)
+
+		// Новый ключ с каждым запросом не растит хранилище сверх предела
+		for i := range 10 {
+			do(h, fmt.Sprintf("k%d", i), "", `{}`)
+		}
+		if len(store.entries) != 2 || store.completed.Len() != 2 {
+			t.Fatalf("expected 2 entries, have %d", len(store.entries))
+		}
+
+		// Последние ключи повторяются, а вытесненный выполняется заново
+		do(h, "k9", "", `{}`)
+		if calls.Load() != 10 {
+			t.Errorf("expected the newest key to be replayed, got %d executions", calls.Load())
+		}
+		do(h, "k0", "", `{}`)
+		if calls.Load() != 11 {
+			t.Errorf("expected the evicted key to run again, got %d executions", calls.Load())
+		}
+	})
+
+	t.Run("параллельные дубликаты", func(t *testing.T) {
+		var calls atomic.Int64
+		release := make(chan struct{})
+		h := Idempotency(NewIdempotencyStore(time.Hour, 100), 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":1}`))
+		}))
+
+		const n = 10
+		var wg sync.WaitGroup
+		codes := make(chan int, n)
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes <- do(h, "k1", "", `{"title":"a"}`).Code
+			}()
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(codes)
+
+		if calls.Load() != 1 {
+			t.Errorf("expected handler to run once, ran %d times", calls.Load())
+		}
+		for code := range codes {
+			if code != http.StatusCreated {
+				t.Errorf("expected every duplicate to get 201, got %d", code)
+			}
+		}
+	})
+}
+
+func TestIdempotency_Compress(t *testing.T) {
+	large := strings.Repeat(`{"title":"Купить молоко"},`, 100)
+	var calls atomic.Int64
+	h := Compress(DefaultCompressMinSize)(Idempotency(NewIdempotencyStore(time.Hour, 100), 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, large)
+	})))
+	do := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`{"title":"a"}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if first := do("gzip"); first.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected the first response to be compressed, got %v", first.Header())
+	}
+
+	// Повтор кодируется по своему Accept-Encoding, а не по первому запросу
+	plain := do("")
+	if plain.Header().Get("Content-Encoding") != "" || plain.Body.String() != large {
+		t.Errorf("expected a plain replay, got %v %.40q", plain.Header(), plain.Body.String())
+	}
+	gzipped := do("gzip")
+	if gzipped.Header().Get("Content-Encoding") != "gzip" || gzipped.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("expected a compressed replay, got %v", gzipped.Header())
+	}
+	zr, err := gzip.NewReader(gzipped.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != large {
+		t.Error("decompressed replay differs from the original")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected handler to run once, ran %d times", calls.Load())
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo/internal/metrics"
+)
+
+// Metrics собирает метрики HTTP запросов: счетчик запросов по маршруту,
+// методу и статусу, гистограмму задержек и число обрабатываемых запросов.
+// Метка маршрута - шаблон из routes (см. Routes.Label): middleware работает
+// до аутентификации, и число серий не должно зависеть от клиентов.
+func Metrics(reg *metrics.Registry, routes *Routes) func(http.Handler) http.Handler {
+	requests := reg.NewCounterVec("http_requests_total",
+		"Total number of HTTP requests.", "method", "route", "status")
+	duration := reg.NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency in seconds.", metrics.DefaultBuckets, "method", "route")
+	inFlight := reg.NewGaugeVec("http_requests_in_flight",
+		"Number of HTTP requests currently being served.").With()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapper, r)
+
+			route, method := routes.Label(r.URL.Path), methodLabel(r.Method)
+			requests.With(method, route, strconv.Itoa(wrapper.statusCode)).Inc()
+			duration.With(method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -1,81 +1,294 @@
 package middleware
 
 import (
+	"bufio"
+	"bytes"
 	"context"
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
+
+	"todo/internal/http/problem"
+	"todo/internal/logging"
 )
 
-// Logger логирует HTTP запросы
-func Logger(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// RequestIDHeader - заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
 
-		// Обертка для отслеживания статус кода
-		wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+// maxRequestIDLength ограничивает длину идентификатора, принятого от клиента
+const maxRequestIDLength = 128
 
-		next.ServeHTTP(wrapper, r)
+// RequestID присваивает запросу идентификатор: берет его из заголовка
+// X-Request-ID, если клиент или прокси его передал, иначе генерирует новый.
+// Идентификатор возвращается в ответе и сохраняется в контексте запроса.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validToken(id, maxRequestIDLength) {
+			id = newRequestID()
+		}
 
-		log.Printf(
-			"%s %s %d %s",
-			r.Method,
-			r.URL.Path,
-			wrapper.statusCode,
-			time.Since(start),
-		)
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
 	})
 }
 
+// validToken проверяет, что значение заголовка непустое, не длиннее maxLen и
+// состоит из видимых ASCII символов
+func validToken(id string, maxLen int) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Logger логирует HTTP запросы
+func Logger(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// Обертка для отслеживания статус кода и размера ответа
+			wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(wrapper, r)
+
+			level := slog.LevelInfo
+			if wrapper.statusCode >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			log.LogAttrs(r.Context(), level, "HTTP request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", wrapper.statusCode),
+				slog.Duration("duration", time.Since(start)),
+				slog.Int64("bytes", wrapper.bytes),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
 // responseWriter оборачивает http.ResponseWriter для отслеживания статус кода
+// и количества записанных байт
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
+	if !rw.wroteHeader {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush отправляет буферизованные данные клиенту; нужен потоковым ответам
+func (rw *responseWriter) Flush() {
+	rw.wroteHeader = true
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Unwrap открывает исходный writer для http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack передает соединение обработчику (WebSocket); запрос учитывается со
+// статусом 101
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil && !rw.wroteHeader {
+		rw.statusCode = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
 // Recovery восстанавливает приложение после паники
-func Recovery(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			}
-		}()
+func Recovery(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
+					log.ErrorContext(r.Context(), "Panic recovered",
+						"panic", err,
+						"stack", string(debug.Stack()),
+					)
+					problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Internal Server Error")
+				}
+			}()
 
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TimeoutConfig задает таймауты обработки запросов.
+// Routes переопределяет Default для отдельных маршрутов: ключ, оканчивающийся
+// на "/", совпадает со всеми путями с этим префиксом (как в http.ServeMux),
+// остальные ключи совпадают только с путем целиком. Нулевое или
+// отрицательное значение отключает таймаут для маршрута.
+type TimeoutConfig struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// forPath возвращает таймаут для пути запроса
+func (c TimeoutConfig) forPath(path string) time.Duration {
+	timeout, best := c.Default, -1
+	for pattern, d := range c.Routes {
+		matched := pattern == path ||
+			(strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern))
+		if matched && len(pattern) > best {
+			timeout, best = d, len(pattern)
+		}
+	}
+	return timeout
 }
 
 // Timeout добавляет таймаут к запросам
 func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return TimeoutByRoute(TimeoutConfig{Default: timeout})
+}
+
+// TimeoutByRoute добавляет таймаут к запросам с настройкой по маршрутам.
+//
+// Обработчик пишет ответ в буфер, а не в исходный http.ResponseWriter, поэтому
+// в исходный writer пишет ровно одна горутина: либо ответ обработчика
+// копируется после его завершения, либо по истечении таймаута отправляется
+// 504 Gateway Timeout в JSON формате, а дальнейшие записи обработчика
+// отклоняются с http.ErrHandlerTimeout. Паника в обработчике передается в
+// горутину запроса, чтобы ее обработал Recovery.
+func TimeoutByRoute(cfg TimeoutConfig) func(http.Handler) http.Handler {
+	return DynamicTimeout(func() TimeoutConfig { return cfg })
+}
+
+// DynamicTimeout работает как TimeoutByRoute, но читает настройки через
+// config при каждом запросе, что позволяет менять их без перезапуска.
+// Потоковым маршрутам (Server-Sent Events, WebSocket) таймаут нужно
+// отключить в Routes: буфер задержал бы события до 504, а захват
+// соединения был бы невозможен. Маршрут выбирается только по пути, а не по
+// заголовкам, которые задает клиент.
+func DynamicTimeout(config func() TimeoutConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := config().forPath(r.URL.Path)
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
 			defer cancel()
 
 			r = r.WithContext(ctx)
+			tw := &timeoutWriter{header: make(http.Header), code: http.StatusOK}
 
 			done := make(chan struct{})
+			panicChan := make(chan any, 1)
 			go func() {
-				next.ServeHTTP(w, r)
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
 				close(done)
 			}()
 
 			select {
+			case p := <-panicChan:
+				panic(p)
 			case <-done:
-				// Запрос завершился успешно
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				dst := w.Header()
+				for key, values := range tw.header {
+					dst[key] = values
+				}
+				w.WriteHeader(tw.code)
+				w.Write(tw.buf.Bytes())
 			case <-ctx.Done():
-				// Таймаут
-				if ctx.Err() == context.DeadlineExceeded {
-					http.Error(w, "Request timeout", http.StatusRequestTimeout)
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				tw.timedOut = true
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					problem.Error(w, r, http.StatusGatewayTimeout, problem.CodeTimeout, "Request timed out")
 				}
+				// Иначе клиент сам закрыл соединение, и отвечать некому
 			}
 		})
 	}
 }
+
+// timeoutWriter буферизует ответ обработчика до его завершения
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.code = code
+}
@@ -0,0 +1,317 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todo/internal/http/problem"
+	"todo/internal/logging"
+	"todo/internal/metrics"
+	"todo/internal/tracing"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	t.Run("генерация идентификатора", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos", nil))
+
+		if seen == "" {
+			t.Fatal("expected request ID in context")
+		}
+		if rec.Header().Get(RequestIDHeader) != seen {
+			t.Errorf("expected response header %q, got %q", seen, rec.Header().Get(RequestIDHeader))
+		}
+	})
+
+	t.Run("передача идентификатора клиента", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		req.Header.Set(RequestIDHeader, "client-id-42")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if seen != "client-id-42" {
+			t.Errorf("expected propagated ID, got %q", seen)
+		}
+	})
+
+	t.Run("некорректный идентификатор заменяется", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		req.Header.Set(RequestIDHeader, "bad id\n")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if seen == "bad id\n" || seen == "" {
+			t.Errorf("expected generated ID, got %q", seen)
+		}
+	})
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logging.New(&buf, logging.FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := RequestID(Logger(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("12345"))
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/todos", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.Header.Set("User-Agent", "test-agent")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected JSON log line, got %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]any{
+		"method":             "POST",
+		"path":               "/todos",
+		"status":             float64(http.StatusTeapot),
+		"bytes":              float64(5),
+		"user_agent":         "test-agent",
+		logging.RequestIDKey: "req-1",
+	}
+	for key, want := range expected {
+		if entry[key] != want {
+			t.Errorf("expected %s=%v, got %v", key, want, entry[key])
+		}
+	}
+	if entry["remote_addr"] == "" {
+		t.Error("expected remote_addr to be logged")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	t.Run("быстрый обработчик", func(t *testing.T) {
+		h := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Test", "value")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos", nil))
+
+		if rec.Code != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, rec.Code)
+		}
+		if rec.Header().Get("X-Test") != "value" {
+			t.Error("expected handler headers to be copied")
+		}
+		if rec.Body.String() != "created" {
+			t.Errorf("expected body %q, got %q", "created", rec.Body.String())
+		}
+	})
+
+	t.Run("истечение таймаута", func(t *testing.T) {
+		writeErr := make(chan error, 1)
+		h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			// Обработчик продолжает писать после таймаута
+			time.Sleep(10 * time.Millisecond)
+			w.Header().Set("X-Late", "late")
+			w.WriteHeader(http.StatusOK)
+			_, err := w.Write([]byte("late"))
+			writeErr <- err
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos", nil))
+
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Errorf("expected status %d, got %d", http.StatusGatewayTimeout, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+			t.Errorf("expected problem content type, got %q", ct)
+		}
+
+		var body problem.Problem
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != problem.CodeTimeout || body.Status != http.StatusGatewayTimeout {
+			t.Errorf("expected timeout problem, got %+v (%v)", body, err)
+		}
+
+		if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Errorf("expected ErrHandlerTimeout for late write, got %v", err)
+		}
+		if rec.Header().Get("X-Late") != "" {
+			t.Error("late headers must not reach the client")
+		}
+	})
+
+	t.Run("паника передается вызывающему", func(t *testing.T) {
+		h := Recovery(discardLogger())(Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos", nil))
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+}
+
+func TestTimeoutByRoute(t *testing.T) {
+	cfg := TimeoutConfig{
+		Default: time.Second,
+		Routes: map[string]time.Duration{
+			"/slow/":       10 * time.Millisecond,
+			"/slow/stream": 0,
+		},
+	}
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h := TimeoutByRoute(cfg)(slow)
+
+	testCases := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{path: "/todos", expectedStatus: http.StatusOK},
+		{path: "/slow/1", expectedStatus: http.StatusGatewayTimeout},
+		{path: "/slow/stream", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTimeout_Streaming(t *testing.T) {
+	// Потоковый маршрут без таймаута: события уходят клиенту сразу, а
+	// соединение можно захватить
+	h := TimeoutByRoute(TimeoutConfig{
+		Default: 10 * time.Millisecond,
+		Routes:  map[string]time.Duration{"/todos/events": 0},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/todos/events" {
+			if _, ok := w.(http.Flusher); !ok {
+				t.Error("expected the original writer to be passed through")
+			}
+			if _, ok := r.Context().Deadline(); ok {
+				t.Error("expected no deadline for a stream")
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		<-r.Context().Done()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/todos/events", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for the stream, got %d", rec.Code)
+	}
+
+	// Заголовки клиента не отключают таймаут обычного маршрута
+	for name, header := range map[string][2]string{
+		"SSE":       {"Accept", "text/event-stream"},
+		"WebSocket": {"Upgrade", "websocket"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/sync", nil)
+			req.Header.Set(header[0], header[1])
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusGatewayTimeout {
+				t.Errorf("expected 504, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	h := Metrics(reg, NewRoutes("/todos", "/todos/{id}", "/todos/{id}/move"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	// Неизвестные пути и методы сводятся к одной серии
+	for _, path := range []string{"/todos/1", "/todos/2", "/wp-admin/setup.php", "/todos/abc", "/todos/1/move/x"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/todos", nil))
+
+	var buf bytes.Buffer
+	reg.WriteTo(&buf)
+	out := buf.String()
+
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/todos/{id}",status="404"} 2`,
+		`http_requests_total{method="GET",route="other",status="404"} 3`,
+		`http_requests_total{method="other",route="/todos",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/todos/{id}"} 2`,
+		`http_requests_in_flight 0`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in output:\n%s", line, out)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	tracer := tracing.NewTracer(tracing.AlwaysSample(), nil)
+
+	var inner tracing.SpanContext
+	h := Tracing(tracer, NewRoutes("/todos/{id}"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = tracing.SpanFromContext(r.Context()).SpanContext()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(tracing.TracestateHeader, "vendor=value")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if inner.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected incoming trace ID to be continued, got %s", inner.TraceID)
+	}
+	if inner.SpanID.String() == "00f067aa0ba902b7" {
+		t.Error("expected a new span ID for the server span")
+	}
+
+	out, err := tracing.ParseTraceparent(rec.Header().Get(tracing.TraceparentHeader))
+	if err != nil || out != (tracing.SpanContext{TraceID: inner.TraceID, SpanID: inner.SpanID, Flags: inner.Flags, Remote: true}) {
+		t.Errorf("expected response traceparent for server span, got %q", rec.Header().Get(tracing.TraceparentHeader))
+	}
+	if rec.Header().Get(tracing.TracestateHeader) != "vendor=value" {
+		t.Errorf("expected tracestate to be propagated, got %q", rec.Header().Get(tracing.TracestateHeader))
+	}
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"todo/internal/auth"
+	"todo/internal/http/problem"
+)
+
+// Заголовки ограничения частоты запросов (draft-ietf-httpapi-ratelimit-headers)
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// rateLimitSweepFrequency - как часто удаляются простаивающие корзины
+const rateLimitSweepFrequency = time.Minute
+
+// TokenBucket - параметры корзины токенов: Rate токенов в секунду и не больше
+// Burst токенов в запасе. Rate <= 0 снимает ограничение.
+type TokenBucket struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitConfig задает ограничения для чтения (GET, HEAD, OPTIONS) и
+// изменения данных. Корзины, не использовавшиеся IdleTimeout, удаляются.
+type RateLimitConfig struct {
+	Read        TokenBucket
+	Write       TokenBucket
+	IdleTimeout time.Duration
+}
+
+// RateLimiter хранит корзины токенов клиентов. Настройки можно менять на
+// лету через Update.
+type RateLimiter struct {
+	cfg atomic.Pointer[RateLimitConfig]
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter создает ограничитель с настройками cfg
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{now: time.Now, buckets: make(map[string]*bucket)}
+	l.Update(cfg)
+	return l
+}
+
+// Update заменяет настройки. Накопленные токены сохраняются, но не
+// превышают новый Burst.
+func (l *RateLimiter) Update(cfg RateLimitConfig) {
+	l.cfg.Store(&cfg)
+}
+
+// rateLimitResult - состояние корзины после попытки взять токен
+type rateLimitResult struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration // до полного восстановления корзины
+	retryAfter time.Duration // до появления токена, если запрос отклонен
+}
+
+// take пытается взять токен из корзины key
+func (l *RateLimiter) take(key string, tb TokenBucket) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	burst := float64(tb.Burst)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*tb.Rate)
+	b.last = now
+
+	res := rateLimitResult{limit: tb.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.allowed = true
+	} else {
+		res.retryAfter = seconds((1 - b.tokens) / tb.Rate)
+	}
+	res.remaining = int(b.tokens)
+	res.reset = seconds((burst - b.tokens) / tb.Rate)
+	return res
+}
+
+// sweep удаляет корзины, простаивающие дольше IdleTimeout; вызывается под
+// l.mu. Простаивающая корзина уже восстановилась или почти восстановилась,
+// поэтому ее удаление лишь немного ослабляет ограничение.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepFrequency {
+		return
+	}
+	l.lastSweep = now
+
+	idle := l.cfg.Load().IdleTimeout
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimit ограничивает частоту запросов клиента: предъявившего верный
+// ключ из keys - по его принципалу, остальных - по IP адресу. Чтение и
+// изменение данных учитываются в разных корзинах. Ответы содержат
+// заголовки RateLimit-*, а при превышении возвращается 429 с Retry-After.
+// Пути publicPaths не ограничиваются.
+//
+// RateLimit ставится перед Auth, чтобы ограничивать и запросы с неверными
+// ключами: иначе подбор ключа получает 401 без ограничений.
+func RateLimit(l *RateLimiter, keys *auth.KeySet, publicPaths ...string) func(http.Handler) http.Handler {
+	public := make(map[string]bool, len(publicPaths))
+	for _, p := range publicPaths {
+		public[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := l.cfg.Load()
+			class, tb := "write", cfg.Write
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				class, tb = "read", cfg.Read
+			}
+			if tb.Rate <= 0 || public[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res := l.take(clientKey(r, keys)+"|"+class, tb)
+
+			h := w.Header()
+			h.Set(RateLimitLimitHeader, strconv.Itoa(res.limit))
+			h.Set(RateLimitRemainingHeader, strconv.Itoa(res.remaining))
+			h.Set(RateLimitResetHeader, strconv.Itoa(int(res.reset/time.Second)))
+
+			if !res.allowed {
+				h.Set("Retry-After", strconv.Itoa(int(res.retryAfter/time.Second)))
+				problem.Error(w, r, http.StatusTooManyRequests, problem.CodeRateLimited, "Rate limit exceeded, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey определяет, чью корзину расходует запрос. Неверные ключи
+// расходуют корзину адреса, поэтому перебор ключей не получает новых корзин.
+func clientKey(r *http.Request, keys *auth.KeySet) string {
+	if key := APIKey(r); key != "" && keys.Enabled() {
+		if p, ok := keys.Authenticate(key); ok {
+			return "principal:" + p
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds переводит секунды в длительность, округляя вверх до целой секунды
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
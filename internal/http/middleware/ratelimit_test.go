@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todo/internal/auth"
+	"todo/internal/http/problem"
+)
+
+func TestRateLimit(t *testing.T) {
+	newLimiter := func() (*RateLimiter, *time.Time) {
+		l := NewRateLimiter(RateLimitConfig{
+			Read:        TokenBucket{Rate: 1, Burst: 2},
+			Write:       TokenBucket{Rate: 0.5, Burst: 1},
+			IdleTimeout: time.Minute,
+		})
+		now := time.Now()
+		l.now = func() time.Time { return now }
+		return l, &now
+	}
+
+	keys := auth.NewKeySet(map[string]string{"alice-key": "alice", "bob-key": "bob"})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	do := func(h http.Handler, method, path, remote, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remote
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("исчерпание и восстановление корзины", func(t *testing.T) {
+		l, now := newLimiter()
+		h := RateLimit(l, keys)(ok)
+
+		first := do(h, http.MethodGet, "/todos", "10.0.0.1:1000", "")
+		if first.Code != http.StatusOK || first.Header().Get(RateLimitLimitHeader) != "2" ||
+			first.Header().Get(RateLimitRemainingHeader) != "1" || first.Header().Get(RateLimitResetHeader) != "1" {
+			t.Fatalf("unexpected first response %d %v", first.Code, first.Header())
+		}
+		do(h, http.MethodGet, "/todos", "10.0.0.1:1000", "")
+
+		limited := do(h, http.MethodGet, "/todos", "10.0.0.1:1001", "")
+		if limited.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", limited.Code)
+		}
+		if limited.Header().Get("Retry-After") != "1" || limited.Header().Get(RateLimitRemainingHeader) != "0" {
+			t.Errorf("unexpected limit headers %v", limited.Header())
+		}
+		if limited.Header().Get("Content-Type") != problem.ContentType || !strings.Contains(limited.Body.String(), problem.CodeRateLimited) {
+			t.Errorf("expected rate_limited problem, got %s", limited.Body.String())
+		}
+
+		*now = now.Add(time.Second)
+		if rec := do(h, http.MethodGet, "/todos", "10.0.0.1:1000", ""); rec.Code != http.StatusOK {
+			t.Errorf("expected a token after 1s, got %d", rec.Code)
+		}
+	})
+
+	t.Run("чтение и запись учитываются раздельно", func(t *testing.T) {
+		l, _ := newLimiter()
+		h := RateLimit(l, keys)(ok)
+
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected first write to pass, got %d", rec.Code)
+		}
+		rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", "")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+			t.Errorf("expected second write to be limited for 2s, got %d %v", rec.Code, rec.Header())
+		}
+		if rec := do(h, http.MethodGet, "/todos", "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+			t.Errorf("reads must have their own bucket, got %d", rec.Code)
+		}
+	})
+
+	t.Run("ключ клиента", func(t *testing.T) {
+		l, _ := newLimiter()
+		h := RateLimit(l, keys, "/livez")(ok)
+
+		do(h, http.MethodPost, "/todos", "10.0.0.1:1", "alice-key")
+		// Тот же принципал с другого адреса расходует ту же корзину
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.2:1", "alice-key"); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected principal bucket to be shared, got %d", rec.Code)
+		}
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", "bob-key"); rec.Code != http.StatusOK {
+			t.Errorf("other principals must not be affected, got %d", rec.Code)
+		}
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+			t.Errorf("anonymous clients are limited by IP separately, got %d", rec.Code)
+		}
+		for range 3 {
+			if rec := do(h, http.MethodPost, "/livez", "10.0.0.1:1", ""); rec.Code != http.StatusOK {
+				t.Errorf("public paths must not be limited, got %d", rec.Code)
+			}
+		}
+	})
+
+	t.Run("подбор ключа", func(t *testing.T) {
+		l, _ := newLimiter()
+		h := RateLimit(l, keys)(Auth(keys)(ok))
+
+		// Неверные ключи с одного адреса расходуют одну корзину
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", "guess-1"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rec.Code)
+		}
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", "guess-2"); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected key guessing to be limited, got %d", rec.Code)
+		}
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", "alice-key"); rec.Code != http.StatusOK {
+			t.Errorf("a valid key must use its own bucket, got %d", rec.Code)
+		}
+	})
+
+	t.Run("удаление простаивающих корзин", func(t *testing.T) {
+		l, now := newLimiter()
+		h := RateLimit(l, keys)(ok)
+
+		do(h, http.MethodGet, "/todos", "10.0.0.1:1", "")
+		do(h, http.MethodGet, "/todos", "10.0.0.2:1", "")
+		*now = now.Add(2 * time.Minute)
+		do(h, http.MethodGet, "/todos", "10.0.0.3:1", "")
+
+		if len(l.buckets) != 1 {
+			t.Errorf("expected idle buckets to be evicted, have %d", len(l.buckets))
+		}
+	})
+
+	t.Run("обновление настроек", func(t *testing.T) {
+		l, _ := newLimiter()
+		h := RateLimit(l, keys)(ok)
+
+		do(h, http.MethodPost, "/todos", "10.0.0.1:1", "")
+		l.Update(RateLimitConfig{IdleTimeout: time.Minute})
+		if rec := do(h, http.MethodPost, "/todos", "10.0.0.1:1", ""); rec.Code != http.StatusOK || rec.Header().Get(RateLimitLimitHeader) != "" {
+			t.Errorf("expected limit to be disabled, got %d %v", rec.Code, rec.Header())
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OtherRoute - метка для путей, не совпавших ни с одним из известных
+// маршрутов, и для нестандартных методов
+const OtherRoute = "other"
+
+// Routes - известные маршруты сервера, по которым метрики и трассировка
+// называют запросы. Набор фиксирован, поэтому число меток не зависит от
+// путей, которые присылают клиенты.
+type Routes struct {
+	routes   []string
+	patterns [][]string
+}
+
+// NewRoutes создает набор маршрутов из шаблонов вида /todos/{id}/move:
+// сегмент {id} совпадает с числом, остальные - только с таким же сегментом
+// пути
+func NewRoutes(routes ...string) *Routes {
+	patterns := make([][]string, len(routes))
+	for i, route := range routes {
+		patterns[i] = strings.Split(strings.Trim(route, "/"), "/")
+	}
+	return &Routes{routes: routes, patterns: patterns}
+}
+
+// Label возвращает шаблон маршрута, с которым совпадает путь, или
+// OtherRoute; для nil набора - всегда OtherRoute
+func (rs *Routes) Label(path string) string {
+	if rs == nil {
+		return OtherRoute
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, pattern := range rs.patterns {
+		if matchRoute(pattern, segments) {
+			return rs.routes[i]
+		}
+	}
+	return OtherRoute
+}
+
+func matchRoute(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, p := range pattern {
+		if p == "{id}" {
+			if _, err := strconv.Atoi(segments[i]); err != nil {
+				return false
+			}
+		} else if p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// knownMethods - методы, которые попадают в метки как есть
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// methodLabel возвращает метод для метки или OtherRoute для нестандартного
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return OtherRoute
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"todo/internal/tracing"
+)
+
+// Tracing создает серверный спан для каждого запроса. Контекст трассы
+// берется из заголовков traceparent и tracestate, если клиент их передал,
+// и возвращается в тех же заголовках ответа. Имя спана строится из шаблона
+// маршрута (см. Routes.Label), а точный путь записывается в url.path.
+func Tracing(tracer *tracing.Tracer, routes *Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routes.Label(r.URL.Path)
+
+			ctx, span := tracer.Start(r.Context(), methodLabel(r.Method)+" "+route,
+				tracing.WithKind(tracing.SpanKindServer),
+				tracing.WithRemoteParent(tracing.Extract(r.Header)),
+				tracing.WithAttributes(
+					tracing.Attr("http.request.method", r.Method),
+					tracing.Attr("http.route", route),
+					tracing.Attr("url.path", r.URL.Path),
+					tracing.Attr("user_agent.original", r.UserAgent()),
+				),
+			)
+			defer span.End()
+
+			tracing.Inject(span.SpanContext(), w.Header())
+
+			wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapper, r.WithContext(ctx))
+
+			span.SetAttributes(tracing.Attr("http.response.status_code", wrapper.statusCode))
+			if wrapper.statusCode >= http.StatusInternalServerError {
+				span.RecordError(errors.New(http.StatusText(wrapper.statusCode)))
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Package problem описывает ответы об ошибках в формате RFC 9457
+// (application/problem+json), общие для обработчиков и middleware.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType - тип содержимого ответов об ошибках
+const ContentType = "application/problem+json"
+
+// TypeBase - префикс URI типа проблемы; к нему добавляется код
+const TypeBase = "urn:todo:problem:"
+
+// Стабильные машиночитаемые коды ошибок. Клиенты могут на них полагаться,
+// поэтому существующие коды не переименовываются.
+const (
+	CodeValidationFailed = "validation_failed"
+	CodeInvalidBody      = "invalid_body"
+	CodeBodyTooLarge     = "body_too_large"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeInvalidID        = "invalid_id"
+	CodeNotFound         = "not_found"
+	CodeAlreadyExists    = "already_exists"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeUnauthorized     = "unauthorized"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeSyncTokenExpired = "sync_token_expired"
+	CodeInvalidQuery     = "invalid_query"
+	CodeInvalidMove      = "invalid_move"
+
+	CodeInvalidHandshake = "invalid_handshake"
+	CodeOriginNotAllowed = "origin_not_allowed"
+
+	CodeInvalidIdempotencyKey = "invalid_idempotency_key"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+
+	CodeRateLimited = "rate_limited"
+	CodeTimeout     = "timeout"
+	CodeUnavailable = "unavailable"
+	CodeCanceled    = "canceled"
+	CodeInternal    = "internal_error"
+)
+
+// FieldError - нарушение, относящееся к полю запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem - тело ответа об ошибке. Code, Errors и Position - расширения
+// RFC 9457.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	// Position - номер символа с ошибкой в запросе ?q= (считая с 1)
+	Position int `json:"position,omitempty"`
+}
+
+// New создает проблему со статусом status и кодом code
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   TypeBase + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write записывает проблему в ответ. Если Instance не задан, им становится
+// путь запроса r.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" && r != nil {
+		p.Instance = r.URL.Path
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error - короткая запись New и Write
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, r, New(status, code, detail))
+}
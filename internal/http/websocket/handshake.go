@@ -0,0 +1,166 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"todo/internal/http/problem"
+)
+
+// ErrBadHandshake возвращается Dial, если сервер не перешел на WebSocket
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+// Upgrade проверяет запрос на открытие WebSocket, захватывает соединение и
+// отправляет 101 Switching Protocols вместе с заголовками, уже выставленными
+// в w (например X-Request-ID). При ошибке ответ problem+json уже отправлен
+// клиенту. Проверка Origin остается за вызывающим.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		problem.Error(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "WebSocket handshake must use GET")
+		return nil, errors.New("websocket: handshake method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		problem.Error(w, r, http.StatusUpgradeRequired, problem.CodeInvalidHandshake, "Expected a WebSocket upgrade request")
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		problem.Error(w, r, http.StatusUpgradeRequired, problem.CodeInvalidHandshake, "Unsupported WebSocket version, expected 13")
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidHandshake, "Invalid Sec-WebSocket-Key")
+		return nil, errors.New("websocket: invalid key")
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Connection cannot be upgraded")
+		return nil, fmt.Errorf("websocket: hijack: %w", err)
+	}
+	// Сроки ReadTimeout и WriteTimeout сервера относятся к HTTP запросу,
+	// а не к долгоживущему соединению
+	netConn.SetDeadline(time.Time{})
+
+	header := w.Header().Clone()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", acceptKey(key))
+
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return newConn(netConn, brw.Reader, brw.Writer, true), nil
+}
+
+// headerContains проверяет, что заголовок содержит токен (без учета
+// регистра) в списке через запятую
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Dial открывает соединение с сервером по адресу ws:// или wss:// с
+// дополнительными заголовками header. Если сервер ответил не 101, вместе с
+// ErrBadHandshake возвращается его ответ с прочитанным телом.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dialer interface {
+		DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	}
+	port := "80"
+	switch u.Scheme {
+	case "ws":
+		u.Scheme, dialer = "http", &net.Dialer{}
+	case "wss":
+		u.Scheme, dialer, port = "https", &tls.Dialer{}, "443"
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(deadline)
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+		Host:       u.Host,
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		netConn.Close()
+		return nil, resp, ErrBadHandshake
+	}
+
+	netConn.SetDeadline(time.Time{})
+	return newConn(netConn, br, bufio.NewWriter(netConn), false), resp, nil
+}
@@ -0,0 +1,439 @@
+// Package websocket реализует протокол WebSocket (RFC 6455) поверх net/http:
+// рукопожатие с захватом соединения через http.ResponseController, кадры с
+// маскированием, фрагментированные сообщения и управляющие кадры ping, pong
+// и close. Расширения (permessage-deflate) не поддерживаются. Dial - минимальный
+// клиент для тестов и утилит.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType - тип сообщения с данными
+type MessageType int
+
+// Типы сообщений совпадают с кодами операций их первых кадров
+const (
+	TextMessage   MessageType = opText
+	BinaryMessage MessageType = opBinary
+)
+
+// Коды операций кадров
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Коды закрытия соединения (RFC 6455, раздел 7.4)
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+	CloseTryAgainLater   = 1013
+)
+
+// Ограничения протокола и значения по умолчанию
+const (
+	maxControlPayload = 125
+	DefaultReadLimit  = 1 << 20
+)
+
+// ErrCloseSent возвращается при записи после отправки кадра close
+var ErrCloseSent = errors.New("websocket: close frame already sent")
+
+// CloseError описывает закрытие соединения на уровне протокола: кадр close
+// от собеседника или нарушение протокола, после которого соединение
+// закрыто этой стороной
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return "websocket: close " + strconv.Itoa(e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Reason)
+}
+
+// acceptGUID - константа из RFC 6455 для вычисления Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// Conn - соединение WebSocket. ReadMessage вызывается из одной горутины;
+// методы записи безопасны для конкурентного вызова и пишут кадры целиком.
+// Настройки (SetReadLimit, SetWriteTimeout, SetPongHandler) задаются до
+// начала обмена.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	server bool
+
+	readLimit   int64
+	pongHandler func()
+
+	wmu          sync.Mutex
+	bw           *bufio.Writer
+	writeTimeout time.Duration
+	closeSent    bool
+
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, bw *bufio.Writer, server bool) *Conn {
+	return &Conn{
+		conn:      conn,
+		br:        br,
+		bw:        bw,
+		server:    server,
+		readLimit: DefaultReadLimit,
+	}
+}
+
+// SetReadLimit ограничивает размер собранного сообщения. Сообщение больше
+// лимита закрывает соединение с кодом 1009.
+func (c *Conn) SetReadLimit(n int64) {
+	if n > 0 {
+		c.readLimit = n
+	}
+}
+
+// SetWriteTimeout задает срок записи одного кадра; медленный собеседник,
+// не принимающий данные, получает ошибку записи вместо бесконечной блокировки
+func (c *Conn) SetWriteTimeout(d time.Duration) {
+	c.writeTimeout = d
+}
+
+// SetPongHandler задает функцию, вызываемую при получении pong
+func (c *Conn) SetPongHandler(f func()) {
+	c.pongHandler = f
+}
+
+// SetReadDeadline задает срок чтения из соединения
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// RemoteAddr возвращает адрес собеседника
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close закрывает TCP соединение без обмена кадрами close
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() { err = c.conn.Close() })
+	return err
+}
+
+// WriteMessage отправляет сообщение одним кадром
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", typ)
+	}
+	return c.writeFrame(int(typ), data)
+}
+
+// Ping отправляет кадр ping
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame payload too long")
+	}
+	return c.writeFrame(opPing, data)
+}
+
+// WriteClose начинает закрытие соединения: отправляет кадр close с кодом и
+// причиной. Собеседник должен ответить своим close, который вернет
+// ReadMessage. Повторный вызов ничего не делает.
+func (c *Conn) WriteClose(code int, reason string) error {
+	err := c.writeFrame(opClose, closePayload(code, reason))
+	if errors.Is(err, ErrCloseSent) {
+		return nil
+	}
+	return err
+}
+
+func closePayload(code int, reason string) []byte {
+	if code == CloseNoStatus {
+		return nil
+	}
+	// Причина обрезается по границе символа, чтобы кадр оставался
+	// управляющим
+	for len(reason) > maxControlPayload-2 {
+		_, size := utf8.DecodeLastRuneInString(reason)
+		reason = reason[:len(reason)-size]
+	}
+	p := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(p, uint16(code))
+	copy(p[2:], reason)
+	return p
+}
+
+func (c *Conn) writeFrame(op int, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+
+	var header [14]byte
+	header[0] = 0x80 | byte(op) // FIN: сообщения отправляются одним кадром
+	n := 2
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+		n += 2
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+		n += 8
+	}
+
+	// Клиент обязан маскировать кадры, сервер - не должен
+	if !c.server {
+		header[1] |= 0x80
+		var key [4]byte
+		rand.Read(key[:])
+		copy(header[n:], key[:])
+		n += 4
+		masked := make([]byte, len(payload))
+		copy(masked, payload)
+		maskBytes(key, masked)
+		payload = masked
+	}
+
+	if _, err := c.bw.Write(header[:n]); err != nil {
+		return err
+	}
+	if _, err := c.bw.Write(payload); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
+
+// frame - заголовок прочитанного кадра
+type frame struct {
+	fin    bool
+	op     int
+	length int64
+	masked bool
+	key    [4]byte
+}
+
+func (c *Conn) readHeader() (frame, error) {
+	var f frame
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return f, err
+	}
+
+	f.fin = b[0]&0x80 != 0
+	f.op = int(b[0] & 0x0F)
+	f.masked = b[1]&0x80 != 0
+	if b[0]&0x70 != 0 {
+		return f, c.fail(CloseProtocolError, "reserved bits set without a negotiated extension")
+	}
+
+	switch length := int64(b[1] & 0x7F); length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return f, err
+		}
+		f.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return f, err
+		}
+		u := binary.BigEndian.Uint64(b[:8])
+		if u>>63 != 0 {
+			return f, c.fail(CloseProtocolError, "invalid payload length")
+		}
+		f.length = int64(u)
+	default:
+		f.length = length
+	}
+
+	if f.masked {
+		if _, err := io.ReadFull(c.br, f.key[:]); err != nil {
+			return f, err
+		}
+	}
+
+	switch {
+	case f.masked != c.server:
+		if c.server {
+			return f, c.fail(CloseProtocolError, "client frames must be masked")
+		}
+		return f, c.fail(CloseProtocolError, "server frames must not be masked")
+	case f.op >= opClose && f.op <= opPong:
+		if !f.fin || f.length > maxControlPayload {
+			return f, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	case f.op > opBinary:
+		return f, c.fail(CloseProtocolError, "unknown opcode "+strconv.Itoa(f.op))
+	}
+	return f, nil
+}
+
+func (c *Conn) readPayload(f frame, dst []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, f.length)...)
+	if _, err := io.ReadFull(c.br, dst[start:]); err != nil {
+		return nil, err
+	}
+	if f.masked {
+		maskBytes(f.key, dst[start:])
+	}
+	return dst, nil
+}
+
+// ReadMessage возвращает следующее сообщение с данными, собирая его из
+// фрагментов. На ping отвечает pong, на pong вызывает обработчик. Кадр close
+// от собеседника подтверждается ответным close и возвращается как
+// *CloseError; нарушение протокола закрывает соединение с соответствующим
+// кодом и тоже возвращается как *CloseError.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		typ  MessageType
+		data []byte
+		more bool // собирается фрагментированное сообщение
+	)
+	for {
+		f, err := c.readHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.op {
+		case opPing, opPong, opClose:
+			payload, err := c.readPayload(f, nil)
+			if err != nil {
+				return 0, nil, err
+			}
+			switch f.op {
+			case opPing:
+				if err := c.writeFrame(opPong, payload); err != nil && !errors.Is(err, ErrCloseSent) {
+					return 0, nil, err
+				}
+			case opPong:
+				if c.pongHandler != nil {
+					c.pongHandler()
+				}
+			case opClose:
+				return 0, nil, c.handleClose(payload)
+			}
+			continue
+		case opContinuation:
+			if !more {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			if more {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			typ, more = MessageType(f.op), true
+		}
+
+		if int64(len(data))+f.length > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message exceeds "+strconv.FormatInt(c.readLimit, 10)+" bytes")
+		}
+		if data, err = c.readPayload(f, data); err != nil {
+			return 0, nil, err
+		}
+		if !f.fin {
+			continue
+		}
+
+		if typ == TextMessage && !utf8.Valid(data) {
+			return 0, nil, c.fail(CloseInvalidPayload, "text message is not valid UTF-8")
+		}
+		if data == nil {
+			data = []byte{}
+		}
+		return typ, data, nil
+	}
+}
+
+// handleClose разбирает кадр close собеседника и отвечает на него
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(CloseInvalidPayload, "close reason is not valid UTF-8")
+		}
+	}
+
+	code := closeErr.Code
+	if code == CloseNoStatus {
+		code = CloseNormal
+	}
+	c.WriteClose(code, "")
+	return closeErr
+}
+
+// validCloseCode проверяет, что код допустимо передавать в кадре close
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail отправляет close с кодом нарушения и возвращает его как ошибку
+func (c *Conn) fail(code int, reason string) error {
+	c.WriteClose(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
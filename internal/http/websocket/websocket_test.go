@@ -0,0 +1,268 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer отвечает на каждое сообщение им же
+func echoServer(t *testing.T, readLimit int64) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-1")
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(readLimit)
+
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(typ, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dial(t *testing.T, srv *httptest.Server) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, resp, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if got := resp.Header.Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want headers set before Upgrade", got)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Пример из RFC 6455, раздел 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %q", got)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	conn := dial(t, echoServer(t, 0))
+
+	for _, msg := range []string{"", "привет", strings.Repeat("x", 200), strings.Repeat("y", 70000)} {
+		if err := conn.WriteMessage(TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+		typ, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		if typ != TextMessage || string(data) != msg {
+			t.Errorf("echo of %d bytes: got type %d, %d bytes", len(msg), typ, len(data))
+		}
+	}
+}
+
+func TestPingPong(t *testing.T) {
+	conn := dial(t, echoServer(t, 0))
+
+	pong := make(chan struct{}, 1)
+	conn.SetPongHandler(func() { pong <- struct{}{} })
+	if err := conn.Ping([]byte("p")); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	// Pong обрабатывается внутри ReadMessage, поэтому после ping
+	// отправляем сообщение и читаем его эхо
+	conn.WriteMessage(TextMessage, []byte("after ping"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "after ping" {
+		t.Fatalf("ReadMessage = %q, %v", data, err)
+	}
+	select {
+	case <-pong:
+	default:
+		t.Error("pong handler was not called")
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	conn := dial(t, echoServer(t, 0))
+
+	if err := conn.WriteClose(4000, "bye"); err != nil {
+		t.Fatalf("WriteClose: %v", err)
+	}
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != 4000 {
+		t.Fatalf("ReadMessage error = %v, want close 4000 echoed", err)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("late")); !errors.Is(err, ErrCloseSent) {
+		t.Errorf("write after close = %v, want ErrCloseSent", err)
+	}
+}
+
+func TestMessageTooBig(t *testing.T) {
+	conn := dial(t, echoServer(t, 10))
+
+	conn.WriteMessage(TextMessage, []byte("01234567890"))
+	_, _, err := conn.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Fatalf("ReadMessage error = %v, want close %d", err, CloseMessageTooBig)
+	}
+}
+
+// rawConn открывает соединение и возвращает его без обертки Conn, чтобы
+// отправлять кадры, которые Conn не создает
+func rawConn(t *testing.T, srv *httptest.Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	c := dial(t, srv)
+	return c.conn, c.br
+}
+
+// frameBytes собирает маскированный кадр клиента
+func frameBytes(fin bool, op int, payload []byte, masked bool) []byte {
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	out := []byte{b0, byte(len(payload))}
+	if masked {
+		out[1] |= 0x80
+		key := [4]byte{1, 2, 3, 4}
+		out = append(out, key[:]...)
+		p := append([]byte(nil), payload...)
+		maskBytes(key, p)
+		payload = p
+	}
+	return append(out, payload...)
+}
+
+// readCloseCode читает кадры сервера до close и возвращает его код
+func readCloseCode(t *testing.T, br *bufio.Reader) int {
+	t.Helper()
+	c := newConn(nil, br, nil, false)
+	for {
+		f, err := c.readHeader()
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		payload, err := c.readPayload(f, nil)
+		if err != nil {
+			t.Fatalf("read payload: %v", err)
+		}
+		if f.op == opClose {
+			if len(payload) < 2 {
+				return CloseNoStatus
+			}
+			return int(binary.BigEndian.Uint16(payload))
+		}
+	}
+}
+
+func TestProtocolViolations(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames [][]byte
+		code   int
+	}{
+		{"немаскированный кадр", [][]byte{frameBytes(true, opText, []byte("hi"), false)}, CloseProtocolError},
+		{"неизвестный opcode", [][]byte{frameBytes(true, 0x3, nil, true)}, CloseProtocolError},
+		{"фрагментированный ping", [][]byte{frameBytes(false, opPing, nil, true)}, CloseProtocolError},
+		{"продолжение без начала", [][]byte{frameBytes(true, opContinuation, []byte("x"), true)}, CloseProtocolError},
+		{"новое сообщение внутри фрагментированного", [][]byte{
+			frameBytes(false, opText, []byte("a"), true),
+			frameBytes(true, opText, []byte("b"), true),
+		}, CloseProtocolError},
+		{"некорректный UTF-8", [][]byte{frameBytes(true, opText, []byte{0xff, 0xfe}, true)}, CloseInvalidPayload},
+		{"недопустимый код закрытия", [][]byte{frameBytes(true, opClose, []byte{0x03, 0xED}, true)}, CloseProtocolError},
+	}
+
+	srv := echoServer(t, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, br := rawConn(t, srv)
+			for _, f := range tt.frames {
+				conn.Write(f)
+			}
+			if code := readCloseCode(t, br); code != tt.code {
+				t.Errorf("close code = %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestFragmentedMessageWithInterleavedPing(t *testing.T) {
+	conn, br := rawConn(t, echoServer(t, 0))
+	conn.Write(frameBytes(false, opText, []byte("при"), true))
+	conn.Write(frameBytes(true, opPing, []byte("p"), true))
+	conn.Write(frameBytes(true, opContinuation, []byte("вет"), true))
+
+	c := newConn(nil, br, nil, false)
+	var ops []int
+	var text string
+	for len(ops) < 2 {
+		f, err := c.readHeader()
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		payload, _ := c.readPayload(f, nil)
+		ops = append(ops, f.op)
+		if f.op == opText {
+			text = string(payload)
+		}
+	}
+	if ops[0] != opPong || ops[1] != opText || text != "привет" {
+		t.Errorf("got ops %v and text %q, want pong then the reassembled message", ops, text)
+	}
+}
+
+func TestUpgradeRejectsBadHandshake(t *testing.T) {
+	srv := echoServer(t, 0)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"не upgrade", map[string]string{}, http.StatusUpgradeRequired},
+		{"старая версия", map[string]string{
+			"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==",
+		}, http.StatusUpgradeRequired},
+		{"неверный ключ", map[string]string{
+			"Connection": "keep-alive, Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13", "Sec-WebSocket-Key": "short",
+		}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
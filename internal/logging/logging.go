@@ -0,0 +1,84 @@
+// Package logging настраивает slog и переносит идентификатор запроса через
+// context.Context, чтобы его получали все записи журнала, сделанные в рамках
+// запроса: в middleware, use case и репозитории.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Поддерживаемые форматы вывода
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// RequestIDKey - имя атрибута с идентификатором запроса
+const RequestIDKey = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID возвращает контекст с идентификатором запроса
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// New создает логгер с заданным форматом и уровнем. Записи, сделанные с
+// контекстом запроса (InfoContext и т.п.), получают атрибут request_id.
+func New(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return slog.New(&ContextHandler{Handler: h}), nil
+}
+
+// ParseLevel разбирает уровень логирования: debug, info, warn или error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+// ContextHandler добавляет к записям атрибуты из контекста
+type ContextHandler struct {
+	slog.Handler
+}
+
+// Handle добавляет request_id и передает запись дальше
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs сохраняет обертку для производных логгеров
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup сохраняет обертку для производных логгеров
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"todo/internal/domain"
+)
+
+// RuntimeCollector отдает статистику среды выполнения Go
+func RuntimeCollector() Collector {
+	start := time.Now()
+
+	return CollectorFunc(func(w *Writer) {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+
+		w.Family("go_info", "Information about the Go environment.", "gauge")
+		w.Sample("go_info", []Label{{Name: "version", Value: runtime.Version()}}, 1)
+		w.Gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+		w.Gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc))
+		w.Counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(ms.TotalAlloc))
+		w.Gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(ms.Sys))
+		w.Gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(ms.HeapInuse))
+		w.Gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(ms.HeapObjects))
+		w.Counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(ms.NumGC))
+		w.Counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time.", float64(ms.PauseTotalNs)/1e9)
+		w.Gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(start.Unix()))
+	})
+}
+
+// TodoStatsSource возвращает сводную статистику по задачам
+type TodoStatsSource interface {
+	GetStats(ctx context.Context) (domain.TodoStats, error)
+}
+
+// TodoCollector отдает доменные измерители по задачам. Статистика читается
+// один раз за сбор; если источник не ответил за timeout, метрики пропускаются.
+func TodoCollector(source TodoStatsSource, timeout time.Duration) Collector {
+	return CollectorFunc(func(w *Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		stats, err := source.GetStats(ctx)
+		if err != nil {
+			slog.Warn("Failed to collect todo metrics", "error", err)
+			return
+		}
+
+		w.Gauge("todo_items", "Number of stored todos.", float64(stats.Total))
+		w.Gauge("todo_items_completed", "Number of completed todos.", float64(stats.Completed))
+		w.Gauge("todo_items_overdue", "Number of open todos past their due date.", float64(stats.Overdue))
+	})
+}
@@ -0,0 +1,430 @@
+// Package metrics реализует метрики в текстовом формате Prometheus без
+// внешних зависимостей: счетчики, измерители и гистограммы с метками, а
+// также коллекторы, вычисляющие значения в момент сбора.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType - тип содержимого текстового формата Prometheus
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets - границы гистограмм задержек по умолчанию, в секундах
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector отдает метрики при каждом сборе
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// CollectorFunc позволяет использовать функцию как Collector
+type CollectorFunc func(w *Writer)
+
+// Collect вызывает f(w)
+func (f CollectorFunc) Collect(w *Writer) {
+	f(w)
+}
+
+// Registry хранит зарегистрированные метрики и отдает их по HTTP
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+	names      map[string]bool
+}
+
+// NewRegistry создает пустой реестр
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Register добавляет коллектор. Метрики выводятся в порядке регистрации.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// claim резервирует имя метрики и паникует при повторной регистрации,
+// как это делают стандартные клиенты Prometheus
+func (r *Registry) claim(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+	r.names[name] = true
+}
+
+// NewCounterVec регистрирует счетчик с метками
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	r.claim(name)
+	c := &CounterVec{vec: newVec[*value](name, help, labels, func() *value { return &value{} })}
+	r.Register(c)
+	return c
+}
+
+// NewGaugeVec регистрирует измеритель с метками
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	r.claim(name)
+	g := &GaugeVec{vec: newVec[*value](name, help, labels, func() *value { return &value{} })}
+	r.Register(g)
+	return g
+}
+
+// NewHistogramVec регистрирует гистограмму с метками и границами buckets
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	r.claim(name)
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		vec: newVec[*Histogram](name, help, labels, func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		}),
+	}
+	r.Register(h)
+	return h
+}
+
+// WriteTo выводит все метрики в текстовом формате Prometheus
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.RUnlock()
+
+	w := &Writer{w: bufio.NewWriter(out)}
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+	if err := w.w.Flush(); err != nil {
+		return w.n, err
+	}
+	return w.n, w.err
+}
+
+// ServeHTTP отдает метрики (эндпоинт /metrics)
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// Label - пара имя-значение метки
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer форматирует семейства метрик. Для каждого семейства сначала
+// вызывается Family, затем Sample для каждого значения.
+type Writer struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// Family выводит строки HELP и TYPE
+func (w *Writer) Family(name, help, typ string) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample выводит одно значение метрики
+func (w *Writer) Sample(name string, labels []Label, v float64) {
+	w.printf("%s%s %s\n", name, formatLabels(labels), formatFloat(v))
+}
+
+// Gauge выводит измеритель из одного значения
+func (w *Writer) Gauge(name, help string, v float64) {
+	w.Family(name, help, "gauge")
+	w.Sample(name, nil, v)
+}
+
+// Counter выводит счетчик из одного значения
+func (w *Writer) Counter(name, help string, v float64) {
+	w.Family(name, help, "counter")
+	w.Sample(name, nil, v)
+}
+
+func (w *Writer) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// vec хранит значения метрики для каждого набора значений меток
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+	create func() T
+
+	mu     sync.RWMutex
+	keys   []string
+	values map[string]T
+	sets   map[string][]string
+}
+
+func newVec[T any](name, help string, labels []string, create func() T) vec[T] {
+	return vec[T]{
+		name:   name,
+		help:   help,
+		labels: labels,
+		create: create,
+		values: make(map[string]T),
+		sets:   make(map[string][]string),
+	}
+}
+
+func (v *vec[T]) with(labelValues []string) T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.RLock()
+	m, ok := v.values[key]
+	v.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if m, ok := v.values[key]; ok {
+		return m
+	}
+	m = v.create()
+	v.values[key] = m
+	v.sets[key] = append([]string(nil), labelValues...)
+	v.keys = append(v.keys, key)
+	sort.Strings(v.keys)
+	return m
+}
+
+// each обходит значения в стабильном порядке
+func (v *vec[T]) each(fn func(labels []Label, m T)) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, key := range v.keys {
+		values := v.sets[key]
+		labels := make([]Label, len(values))
+		for i, val := range values {
+			labels[i] = Label{Name: v.labels[i], Value: val}
+		}
+		fn(labels, v.values[key])
+	}
+}
+
+// value - число с плавающей точкой с атомарным доступом
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if v.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) {
+	v.bits.Store(math.Float64bits(f))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+// Counter - монотонно растущий счетчик
+type Counter struct{ v *value }
+
+// Inc увеличивает счетчик на 1
+func (c Counter) Inc() { c.v.add(1) }
+
+// Add увеличивает счетчик на delta; отрицательные значения игнорируются
+func (c Counter) Add(delta float64) {
+	if delta > 0 {
+		c.v.add(delta)
+	}
+}
+
+// CounterVec - счетчик с метками
+type CounterVec struct {
+	vec[*value]
+}
+
+// With возвращает счетчик для значений меток
+func (c *CounterVec) With(labelValues ...string) Counter {
+	return Counter{v: c.with(labelValues)}
+}
+
+// Collect выводит счетчик
+func (c *CounterVec) Collect(w *Writer) {
+	w.Family(c.name, c.help, "counter")
+	c.each(func(labels []Label, v *value) {
+		w.Sample(c.name, labels, v.get())
+	})
+}
+
+// Gauge - значение, которое может расти и уменьшаться
+type Gauge struct{ v *value }
+
+// Set устанавливает значение
+func (g Gauge) Set(f float64) { g.v.set(f) }
+
+// Add изменяет значение на delta
+func (g Gauge) Add(delta float64) { g.v.add(delta) }
+
+// Inc увеличивает значение на 1
+func (g Gauge) Inc() { g.v.add(1) }
+
+// Dec уменьшает значение на 1
+func (g Gauge) Dec() { g.v.add(-1) }
+
+// GaugeVec - измеритель с метками
+type GaugeVec struct {
+	vec[*value]
+}
+
+// With возвращает измеритель для значений меток
+func (g *GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{v: g.with(labelValues)}
+}
+
+// Collect выводит измеритель
+func (g *GaugeVec) Collect(w *Writer) {
+	w.Family(g.name, g.help, "gauge")
+	g.each(func(labels []Label, v *value) {
+		w.Sample(g.name, labels, v.get())
+	})
+}
+
+// Histogram считает распределение наблюдений по границам
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// Observe добавляет наблюдение
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// HistogramVec - гистограмма с метками
+type HistogramVec struct {
+	vec[*Histogram]
+}
+
+// With возвращает гистограмму для значений меток
+func (h *HistogramVec) With(labelValues ...string) *Histogram {
+	return h.with(labelValues)
+}
+
+// Collect выводит гистограмму с накопленными значениями по границам
+func (h *HistogramVec) Collect(w *Writer) {
+	w.Family(h.name, h.help, "histogram")
+	h.each(func(labels []Label, hist *Histogram) {
+		hist.mu.Lock()
+		counts := append([]uint64(nil), hist.counts...)
+		count, sum := hist.count, hist.sum
+		hist.mu.Unlock()
+
+		var cumulative uint64
+		for i, upper := range hist.buckets {
+			cumulative += counts[i]
+			w.Sample(h.name+"_bucket", withLabel(labels, "le", formatFloat(upper)), float64(cumulative))
+		}
+		w.Sample(h.name+"_bucket", withLabel(labels, "le", "+Inf"), float64(count))
+		w.Sample(h.name+"_sum", labels, sum)
+		w.Sample(h.name+"_count", labels, float64(count))
+	})
+}
+
+func withLabel(labels []Label, name, value string) []Label {
+	out := make([]Label, 0, len(labels)+1)
+	out = append(out, labels...)
+	return append(out, Label{Name: name, Value: value})
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	reg := NewRegistry()
+
+	requests := reg.NewCounterVec("requests_total", "Total requests.", "method", "path")
+	requests.With("GET", "/todos").Inc()
+	requests.With("GET", "/todos").Add(2)
+	requests.With("POST", `/a"b\c`).Inc()
+
+	inFlight := reg.NewGaugeVec("in_flight", "In flight.\nSecond line.")
+	inFlight.With().Inc()
+	inFlight.With().Inc()
+	inFlight.With().Dec()
+
+	latency := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "method")
+	latency.With("GET").Observe(0.05)
+	latency.With("GET").Observe(0.5)
+	latency.With("GET").Observe(5)
+
+	var buf strings.Builder
+	if _, err := reg.WriteTo(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET",path="/todos"} 3
+requests_total{method="POST",path="/a\"b\\c"} 1
+# HELP in_flight In flight.\nSecond line.
+# TYPE in_flight gauge
+in_flight 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GET",le="0.1"} 1
+latency_seconds_bucket{method="GET",le="1"} 2
+latency_seconds_bucket{method="GET",le="+Inf"} 3
+latency_seconds_sum{method="GET"} 5.55
+latency_seconds_count{method="GET"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestRegistry_DuplicateName(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("dup", "First.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate metric")
+		}
+	}()
+	reg.NewGaugeVec("dup", "Second.")
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(RuntimeCollector())
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != ContentType {
+		t.Errorf("unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "go_goroutines ") {
+		t.Error("expected runtime metrics in output")
+	}
+}
+
+type statsSource struct {
+	stats domain.TodoStats
+}
+
+func (s statsSource) GetStats(ctx context.Context) (domain.TodoStats, error) {
+	return s.stats, nil
+}
+
+func TestTodoCollector(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(TodoCollector(statsSource{domain.TodoStats{Total: 5, Completed: 2, Overdue: 1}}, time.Second))
+
+	var buf strings.Builder
+	reg.WriteTo(&buf)
+
+	for _, line := range []string{"todo_items 5\n", "todo_items_completed 2\n", "todo_items_overdue 1\n"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("expected %q in output:\n%s", line, buf.String())
+		}
+	}
+}
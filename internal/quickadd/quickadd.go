@@ -0,0 +1,672 @@
+// Package quickadd разбирает задачу, записанную одной строкой свободного
+// текста, например
+//
+//	Call Anna tomorrow 3pm #work !high every monday
+//	Позвонить маме в пятницу в 19:00 #семья
+//
+// Из текста извлекаются теги (#work), приоритет (!high), срок и правило
+// повторения; оставшиеся слова в исходном порядке становятся названием.
+// Даты и время понимаются на английском и русском:
+//
+//   - today, tonight, tomorrow, day after tomorrow, сегодня, завтра,
+//     послезавтра;
+//   - дни недели: friday, on fri, next monday, в пятницу, до среды;
+//   - даты: March 5, 5th of March, 5 марта 2027, 2026-03-05, 05.03;
+//   - относительные сроки: in 3 days, in an hour, через неделю,
+//     через 2 часа, next week, на следующей неделе;
+//   - время: 3pm, 3:30 pm, 15:00, at 9, noon, in the morning, в 15,
+//     в 3 часа дня, в полдень, вечером;
+//   - повторение: daily, every 2 weeks, every other day, every mon and
+//     thu, every weekday, ежедневно, каждые 3 дня, каждую среду,
+//     по будням, по понедельникам и пятницам.
+//
+// Каждая часть срока берется из первого подходящего выражения; повторное
+// выражение того же рода остается в названии.
+package quickadd
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo/internal/domain"
+)
+
+// endOfDay - время срока, если в тексте указан только день
+var endOfDay = at(23, 59)
+
+var (
+	clockRe   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm|a\.m|p\.m)?$`)
+	dayRe     = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+	yearRe    = regexp.MustCompile(`^(\d{4})$`)
+	isoDateRe = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	dotDateRe = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{4}|\d{2}))?$`)
+)
+
+// Parse разбирает текст задачи. now задает текущий момент и часовой пояс,
+// в котором понимаются даты; срок возвращается в этом поясе.
+//
+// Если указан только день, срок - конец дня (23:59). Время без дня
+// относится к сегодня, а если оно уже прошло - к завтра. Дата без года,
+// которая в этом году уже прошла, относится к следующему году. День недели
+// означает ближайший такой день после сегодняшнего. Повторение без даты
+// начинается с ближайшего подходящего дня, срок которого еще не наступил.
+//
+// Parse не проверяет задачу: пустое название, например, выявит
+// domain.Todo.Validate.
+func Parse(text string, now time.Time) *domain.Todo {
+	p := newParser(text, now)
+	for i := 0; i < len(p.words); {
+		if n := p.match(i); n > 0 {
+			i += n
+			continue
+		}
+		p.title = append(p.title, p.words[i])
+		i++
+	}
+
+	todo := &domain.Todo{
+		Title:    strings.Join(p.title, " "),
+		DueDate:  p.due(),
+		Tags:     p.tags,
+		Priority: p.priority,
+	}
+	if p.rule != nil {
+		todo.Recurrence = p.rule.String()
+	}
+	return todo
+}
+
+// parser - состояние разбора одного текста
+type parser struct {
+	now   time.Time
+	today time.Time // полночь текущего дня в поясе now
+
+	words []string // слова текста как есть
+	lower []string // слова в нижнем регистре без завершающей пунктуации
+
+	title    []string
+	tags     []string
+	priority domain.Priority
+	rule     *domain.Recurrence
+
+	date    *time.Time // день срока (полночь)
+	clock   *clockTime
+	instant *time.Time // точный срок: in 2 hours, через 30 минут
+	tonight bool       // время по умолчанию - вечер, а не конец дня
+}
+
+func newParser(text string, now time.Time) *parser {
+	p := &parser{
+		now:   now,
+		today: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		words: strings.Fields(text),
+	}
+	p.lower = make([]string, len(p.words))
+	for i, w := range p.words {
+		w = strings.ReplaceAll(strings.ToLower(w), "ё", "е")
+		p.lower[i] = strings.TrimRight(w, ",;.!?")
+	}
+	return p
+}
+
+// word возвращает нормализованное слово i или "" за концом текста
+func (p *parser) word(i int) string {
+	if i < len(p.lower) {
+		return p.lower[i]
+	}
+	return ""
+}
+
+// match пробует распознать выражение, начинающееся со слова i, и
+// возвращает число поглощенных слов (0 - слово относится к названию)
+func (p *parser) match(i int) int {
+	for _, m := range []func(int) int{p.matchTag, p.matchPriority, p.matchRecurrence, p.matchDate, p.matchTime} {
+		if n := m(i); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// matchTag распознает #тег
+func (p *parser) matchTag(i int) int {
+	tag, ok := strings.CutPrefix(p.words[i], "#")
+	tag = strings.TrimRight(tag, ",;.!?")
+	if !ok || tag == "" {
+		return 0
+	}
+	if !slices.Contains(p.tags, tag) {
+		p.tags = append(p.tags, tag)
+	}
+	return 1
+}
+
+// matchPriority распознает !high, !2, !!! и т.п.
+func (p *parser) matchPriority(i int) int {
+	if p.priority != "" {
+		return 0
+	}
+	priority, ok := priorities[strings.TrimRight(strings.ToLower(p.words[i]), ",;.")]
+	if !ok {
+		return 0
+	}
+	p.priority = priority
+	return 1
+}
+
+// matchRecurrence распознает правило повторения
+func (p *parser) matchRecurrence(i int) int {
+	if p.rule != nil {
+		return 0
+	}
+	w := p.word(i)
+	if freq, ok := repeats[w]; ok {
+		p.rule = &domain.Recurrence{Freq: freq, Interval: 1}
+		return 1
+	}
+
+	if w == "по" {
+		// по будням, по выходным, по понедельникам и средам
+		switch p.word(i + 1) {
+		case "будням":
+			p.repeatOn(workdays, 1)
+			return 2
+		case "выходным":
+			p.repeatOn(weekend, 1)
+			return 2
+		}
+		if days, n := p.weekdayList(i+1, weekdaysPlural); n > 0 {
+			p.repeatOn(days, 1)
+			return n + 1
+		}
+		return 0
+	}
+
+	if !everyWords[w] {
+		return 0
+	}
+	j, interval := i+1, 1
+	if p.word(j) == "other" {
+		interval, j = 2, j+1
+	} else if n, err := strconv.Atoi(p.word(j)); err == nil {
+		if n < 1 || n > domain.MaxRecurrenceInterval {
+			return 0
+		}
+		interval, j = n, j+1
+	}
+
+	if u, ok := units[p.word(j)]; ok {
+		freq, ok := frequencies[u]
+		if !ok {
+			return 0
+		}
+		p.rule = &domain.Recurrence{Freq: freq, Interval: interval}
+		return j + 1 - i
+	}
+	switch p.word(j) {
+	case "weekday", "workday":
+		p.repeatOn(workdays, interval)
+		return j + 1 - i
+	case "weekend":
+		p.repeatOn(weekend, interval)
+		return j + 1 - i
+	}
+	if days, n := p.weekdayList(j, weekdays, weekdaysAfterPrep); n > 0 {
+		p.repeatOn(days, interval)
+		return j + n - i
+	}
+	return 0
+}
+
+// repeatOn задает еженедельное повторение по дням days
+func (p *parser) repeatOn(days []time.Weekday, interval int) {
+	p.rule = &domain.Recurrence{Freq: domain.FreqWeekly, Interval: interval, ByDay: slices.Clone(days)}
+}
+
+// weekdayList распознает дни недели через запятую, "and" или "и", начиная
+// со слова j, и возвращает их и число поглощенных слов
+func (p *parser) weekdayList(j int, dicts ...map[string]time.Weekday) ([]time.Weekday, int) {
+	lookup := func(w string) (time.Weekday, bool) {
+		for _, dict := range dicts {
+			if d, ok := dict[w]; ok {
+				return d, true
+			}
+		}
+		return 0, false
+	}
+
+	var days []time.Weekday
+	n := 0
+	for {
+		k := j + n
+		if len(days) > 0 && andWords[p.word(k)] {
+			k++
+		}
+		d, ok := lookup(p.word(k))
+		if !ok {
+			return days, n
+		}
+		if !slices.Contains(days, d) {
+			days = append(days, d)
+		}
+		n = k + 1 - j
+	}
+}
+
+// matchDate распознает день срока, возможно с предлогом перед ним
+func (p *parser) matchDate(i int) int {
+	if p.date != nil || p.instant != nil {
+		return 0
+	}
+	if n := p.relative(i); n > 0 {
+		return n
+	}
+	if n := p.dateAt(i, false); n > 0 {
+		return n
+	}
+	if datePreps[p.word(i)] {
+		if n := p.dateAt(i+1, true); n > 0 {
+			return n + 1
+		}
+	}
+	return 0
+}
+
+// relative распознает срок относительно текущего момента: in 3 days,
+// in an hour, через неделю, через 2 часа
+func (p *parser) relative(i int) int {
+	w := p.word(i)
+	if w != "in" && w != "через" {
+		return 0
+	}
+	j, n := i+1, 1
+	if v, err := strconv.Atoi(p.word(j)); err == nil {
+		n, j = v, j+1
+	} else if w == "in" {
+		// in a week, in an hour; по-русски число можно опустить: через час
+		if a := p.word(j); a != "a" && a != "an" {
+			return 0
+		}
+		j++
+	}
+	u, ok := units[p.word(j)]
+	if !ok || n < 0 {
+		return 0
+	}
+
+	switch u {
+	case unitMinute:
+		p.setInstant(p.now.Add(time.Duration(n) * time.Minute))
+	case unitHour:
+		p.setInstant(p.now.Add(time.Duration(n) * time.Hour))
+	default:
+		p.setDate(addUnits(p.today, u, n))
+	}
+	return j + 1 - i
+}
+
+// dateAt распознает день, начинающийся со слова j. afterPrep разрешает
+// формы, которые без предлога слишком похожи на обычные слова.
+func (p *parser) dateAt(j int, afterPrep bool) int {
+	w := p.word(j)
+	switch w {
+	case "today", "сегодня":
+		p.setDate(p.today)
+		return 1
+	case "tonight":
+		p.setDate(p.today)
+		p.tonight = true
+		return 1
+	case "tomorrow", "завтра":
+		p.setDate(p.today.AddDate(0, 0, 1))
+		return 1
+	case "послезавтра":
+		p.setDate(p.today.AddDate(0, 0, 2))
+		return 1
+	case "day":
+		if p.word(j+1) == "after" && p.word(j+2) == "tomorrow" {
+			p.setDate(p.today.AddDate(0, 0, 2))
+			return 3
+		}
+	case "next":
+		switch p.word(j + 1) {
+		case "week":
+			p.setDate(nextWeek(p.today))
+			return 2
+		case "month":
+			p.setDate(nextMonth(p.today))
+			return 2
+		}
+	case "следующей":
+		// на следующей неделе
+		if afterPrep && p.word(j+1) == "неделе" {
+			p.setDate(nextWeek(p.today))
+			return 2
+		}
+	case "следующем":
+		// в следующем месяце
+		if afterPrep && p.word(j+1) == "месяце" {
+			p.setDate(nextMonth(p.today))
+			return 2
+		}
+	}
+
+	// friday, next friday, в пятницу, в следующую пятницу
+	k := j
+	if nextWords[w] {
+		k++
+	}
+	if d, ok := weekdays[p.word(k)]; ok {
+		p.setDate(nextWeekday(p.today, d))
+		return k + 1 - j
+	}
+	if d, ok := weekdaysAfterPrep[p.word(k)]; ok && (afterPrep || k > j) {
+		p.setDate(nextWeekday(p.today, d))
+		return k + 1 - j
+	}
+
+	if n := p.monthDay(j); n > 0 {
+		return n
+	}
+	return p.numericDate(j)
+}
+
+// monthDay распознает дату с названием месяца: March 5, March 5th 2027,
+// 5 March, 5th of March, 5 марта, 5 марта 2027 года
+func (p *parser) monthDay(j int) int {
+	var (
+		month  time.Month
+		day, n int
+	)
+	if m, ok := months[p.word(j)]; ok {
+		// March 5
+		d := dayRe.FindStringSubmatch(p.word(j + 1))
+		if d == nil {
+			return 0
+		}
+		month, n = m, 2
+		day, _ = strconv.Atoi(d[1])
+	} else {
+		// 5 March, 5th of March
+		d := dayRe.FindStringSubmatch(p.word(j))
+		if d == nil {
+			return 0
+		}
+		k := j + 1
+		if p.word(k) == "of" {
+			k++
+		}
+		m, ok := months[p.word(k)]
+		if !ok {
+			return 0
+		}
+		month, n = m, k+1-j
+		day, _ = strconv.Atoi(d[1])
+	}
+
+	year := 0
+	if y := yearRe.FindStringSubmatch(p.word(j + n)); y != nil {
+		year, _ = strconv.Atoi(y[1])
+		n++
+		if w := p.word(j + n); w == "года" || w == "г" {
+			n++
+		}
+	}
+	if !p.setCalendarDate(year, month, day) {
+		return 0
+	}
+	return n
+}
+
+// numericDate распознает 2026-03-05, 05.03 и 05.03.2026
+func (p *parser) numericDate(j int) int {
+	w := p.word(j)
+	if m := isoDateRe.FindStringSubmatch(w); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		if !p.setCalendarDate(year, time.Month(month), day) {
+			return 0
+		}
+		return 1
+	}
+	if m := dotDateRe.FindStringSubmatch(w); m != nil {
+		day, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		year := 0
+		if m[3] != "" {
+			year, _ = strconv.Atoi(m[3])
+			if year < 100 {
+				year += 2000
+			}
+		}
+		if !p.setCalendarDate(year, time.Month(month), day) {
+			return 0
+		}
+		return 1
+	}
+	return 0
+}
+
+// setCalendarDate задает срок на день календаря. Год 0 - ближайший год, в
+// котором этот день еще не прошел. Несуществующая дата (31.02) не
+// принимается.
+func (p *parser) setCalendarDate(year int, month time.Month, day int) bool {
+	explicit := year != 0
+	if !explicit {
+		year = p.today.Year()
+	}
+	if month < time.January || month > time.December {
+		return false
+	}
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.now.Location())
+	if date.Day() != day {
+		return false
+	}
+	if !explicit && date.Before(p.today) {
+		date = time.Date(year+1, month, day, 0, 0, 0, 0, p.now.Location())
+		if date.Day() != day {
+			// 29 февраля следующего года может не быть
+			return false
+		}
+	}
+	p.setDate(date)
+	return true
+}
+
+// matchTime распознает время срока, возможно с предлогом перед ним
+func (p *parser) matchTime(i int) int {
+	if p.clock != nil || p.instant != nil {
+		return 0
+	}
+	if n := p.clockAt(i, false); n > 0 {
+		return n
+	}
+	if timePreps[p.word(i)] {
+		if n := p.clockAt(i+1, true); n > 0 {
+			return n + 1
+		}
+	}
+
+	// in the morning, this evening, утром
+	if c, ok := dayPartsRu[p.word(i)]; ok {
+		p.setClock(c)
+		return 1
+	}
+	if p.word(i) == "this" {
+		if c, ok := dayParts[p.word(i+1)]; ok {
+			p.setClock(c)
+			return 2
+		}
+	}
+	if p.word(i) == "in" && p.word(i+1) == "the" {
+		if c, ok := dayParts[p.word(i+2)]; ok {
+			p.setClock(c)
+			return 3
+		}
+	}
+	return 0
+}
+
+// clockAt распознает время, начинающееся со слова j: 3pm, 3:30 pm, 15:00,
+// noon, 3 часа дня, 10 утра. Час без минут и уточнений ("at 9", "в 15")
+// принимается только после предлога.
+func (p *parser) clockAt(j int, afterPrep bool) int {
+	if c, ok := namedTimes[p.word(j)]; ok {
+		p.setClock(c)
+		return 1
+	}
+	m := clockRe.FindStringSubmatch(p.word(j))
+	if m == nil {
+		return 0
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+
+	n, suffix, period := 1, m[3], ""
+	if suffix == "" {
+		if w := p.word(j + 1); amWords[w] || pmWords[w] {
+			suffix, n = w, n+1
+		}
+	}
+	hourWord := false
+	if suffix == "" {
+		if hourWords[p.word(j+n)] {
+			hourWord, n = true, n+1
+		}
+		switch w := p.word(j + n); w {
+		case periodMorning, periodDay, periodEvening, periodNight:
+			period, n = w, n+1
+		}
+	}
+	if m[2] == "" && suffix == "" && !hourWord && period == "" && !afterPrep {
+		return 0
+	}
+
+	switch {
+	case amWords[suffix] || pmWords[suffix]:
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		hour %= 12
+		if pmWords[suffix] {
+			hour += 12
+		}
+	case period == periodDay || period == periodEvening:
+		if hour < 12 {
+			hour += 12
+		}
+	case period == periodNight && hour == 12:
+		hour = 0
+	}
+	if hour > 23 || minute > 59 {
+		return 0
+	}
+	p.setClock(at(hour, minute))
+	return n
+}
+
+func (p *parser) setDate(date time.Time) {
+	p.date = &date
+}
+
+func (p *parser) setClock(c clockTime) {
+	p.clock = &c
+}
+
+func (p *parser) setInstant(t time.Time) {
+	p.instant = &t
+}
+
+// due вычисляет срок из найденных частей (см. Parse)
+func (p *parser) due() *time.Time {
+	if p.instant != nil {
+		return p.instant
+	}
+	if p.date == nil && p.clock == nil && p.rule == nil {
+		return nil
+	}
+
+	clock := endOfDay
+	if p.tonight {
+		clock = tonight
+	}
+	if p.clock != nil {
+		clock = *p.clock
+	}
+	on := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), int(clock)/60, int(clock)%60, 0, 0, p.now.Location())
+	}
+
+	var due time.Time
+	switch {
+	case p.date != nil:
+		due = on(*p.date)
+	case p.rule != nil && len(p.rule.ByDay) > 0:
+		day := p.today
+		for !slices.Contains(p.rule.ByDay, day.Weekday()) || !on(day).After(p.now) {
+			day = day.AddDate(0, 0, 1)
+		}
+		due = on(day)
+	case p.rule != nil:
+		due = on(p.today)
+		if !due.After(p.now) {
+			due = on(addUnits(p.today, unitOf(p.rule.Freq), p.rule.Interval))
+		}
+	default:
+		due = on(p.today)
+		if !due.After(p.now) {
+			due = on(p.today.AddDate(0, 0, 1))
+		}
+	}
+	return &due
+}
+
+// addUnits прибавляет к дню n единиц от дня и больше
+func addUnits(day time.Time, u unit, n int) time.Time {
+	switch u {
+	case unitWeek:
+		return day.AddDate(0, 0, 7*n)
+	case unitMonth:
+		return day.AddDate(0, n, 0)
+	case unitYear:
+		return day.AddDate(n, 0, 0)
+	default:
+		return day.AddDate(0, 0, n)
+	}
+}
+
+// unitOf возвращает единицу периода повторения
+func unitOf(freq domain.Frequency) unit {
+	for u, f := range frequencies {
+		if f == freq {
+			return u
+		}
+	}
+	return unitDay
+}
+
+// nextWeekday возвращает ближайший после day день недели d
+func nextWeekday(day time.Time, d time.Weekday) time.Time {
+	offset := (int(d) - int(day.Weekday()) + 7) % 7
+	if offset == 0 {
+		offset = 7
+	}
+	return day.AddDate(0, 0, offset)
+}
+
+// nextWeek возвращает понедельник следующей недели
+func nextWeek(day time.Time) time.Time {
+	return nextWeekday(day, time.Monday)
+}
+
+// nextMonth возвращает первое число следующего месяца
+func nextMonth(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, day.Location())
+}
@@ -0,0 +1,111 @@
+package quickadd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+)
+
+func TestParse(t *testing.T) {
+	// Вторник, 14:00
+	now := time.Date(2026, 3, 10, 14, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	tests := []struct {
+		text       string
+		title      string
+		due        string // "2006-01-02 15:04" в поясе now, "" - без срока
+		tags       string
+		priority   domain.Priority
+		recurrence string
+	}{
+		{"Call Anna tomorrow 3pm #work !high every monday", "Call Anna", "2026-03-11 15:00", "work", domain.PriorityHigh, "FREQ=WEEKLY;BYDAY=MO"},
+		{"Buy milk", "Buy milk", "", "", "", ""},
+		{"Buy milk today", "Buy milk", "2026-03-10 23:59", "", "", ""},
+		{"Dinner tonight", "Dinner", "2026-03-10 20:00", "", "", ""},
+		{"Dinner tonight at 9pm", "Dinner", "2026-03-10 21:00", "", "", ""},
+		{"Report due friday", "Report", "2026-03-13 23:59", "", "", ""},
+		{"Standup on Tue at 9:30am", "Standup", "2026-03-17 09:30", "", "", ""},
+		{"Review next tuesday", "Review", "2026-03-17 23:59", "", "", ""},
+		{"Party day after tomorrow at 7 pm", "Party", "2026-03-12 19:00", "", "", ""},
+		{"Pay taxes by April 15th", "Pay taxes", "2026-04-15 23:59", "", "", ""},
+		{"Anniversary 5th of March", "Anniversary", "2027-03-05 23:59", "", "", ""},
+		{"Conference Mar 20, 2027 at noon", "Conference", "2027-03-20 12:00", "", "", ""},
+		{"Deploy 2026-04-01 15:00", "Deploy", "2026-04-01 15:00", "", "", ""},
+		{"Check oven in 2 hours", "Check oven", "2026-03-10 16:00", "", "", ""},
+		{"Renew passport in a month", "Renew passport", "2026-04-10 23:59", "", "", ""},
+		{"Plan sprint next week in the morning", "Plan sprint", "2026-03-16 09:00", "", "", ""},
+		{"Call mom at 9", "Call mom", "2026-03-11 09:00", "", "", ""},
+		{"Lunch 13:30", "Lunch", "2026-03-11 13:30", "", "", ""},
+		{"Water plants every other day !low", "Water plants", "2026-03-10 23:59", "", domain.PriorityLow, "FREQ=DAILY;INTERVAL=2"},
+		{"Gym every mon, wed and fri at 7am", "Gym", "2026-03-11 07:00", "", "", "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{"Standup every weekday 10am", "Standup", "2026-03-11 10:00", "", "", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"Backup daily at 3am #ops #infra #ops", "Backup", "2026-03-11 03:00", "ops,infra", "", "FREQ=DAILY"},
+		{"Rent every month", "Rent", "2026-03-10 23:59", "", "", "FREQ=MONTHLY"},
+		{"Sun cream in 3 bags", "Sun cream in 3 bags", "", "", "", ""},
+		{"Meet on Monday or Friday", "Meet or Friday", "2026-03-16 23:59", "", "", ""},
+
+		{"Позвонить маме в пятницу в 19:00 #семья", "Позвонить маме", "2026-03-13 19:00", "семья", "", ""},
+		{"Купить хлеб завтра утром", "Купить хлеб", "2026-03-11 09:00", "", "", ""},
+		{"Сдать отчет послезавтра в 3 часа дня !высокий", "Сдать отчет", "2026-03-12 15:00", "", domain.PriorityHigh, ""},
+		{"Встреча 5 марта в 10 утра", "Встреча", "2027-03-05 10:00", "", "", ""},
+		{"Встреча 20 марта 2026 года", "Встреча", "2026-03-20 23:59", "", "", ""},
+		{"Продлить полис до 01.04", "Продлить полис", "2026-04-01 23:59", "", "", ""},
+		{"Проверить почту через 30 минут", "Проверить почту", "2026-03-10 14:30", "", "", ""},
+		{"Отпуск через 2 недели", "Отпуск", "2026-03-24 23:59", "", "", ""},
+		{"Ретро на следующей неделе в полдень", "Ретро", "2026-03-16 12:00", "", "", ""},
+		{"Созвон в 15", "Созвон", "2026-03-10 15:00", "", "", ""},
+		{"Сегодня вечером кино", "кино", "2026-03-10 19:00", "", "", ""},
+		{"Зарядка по будням в 8:00", "Зарядка", "2026-03-11 08:00", "", "", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"Полить цветы по понедельникам и четвергам", "Полить цветы", "2026-03-12 23:59", "", "", "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"Планерка каждую среду в 11", "Планерка", "2026-03-11 11:00", "", "", "FREQ=WEEKLY;BYDAY=WE"},
+		{"Бэкап каждые 3 дня", "Бэкап", "2026-03-10 23:59", "", "", "FREQ=DAILY;INTERVAL=3"},
+		{"Налоги ежегодно 25 апреля", "Налоги", "2026-04-25 23:59", "", "", "FREQ=YEARLY"},
+		{"Настроить среда разработки", "Настроить среда разработки", "", "", "", ""},
+		{"В среднем в офисе", "В среднем в офисе", "", "", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			todo := Parse(tc.text, now)
+
+			due := ""
+			if todo.DueDate != nil {
+				if todo.DueDate.Location() != now.Location() {
+					t.Errorf("expected due date in %v, got %v", now.Location(), todo.DueDate.Location())
+				}
+				due = todo.DueDate.Format("2006-01-02 15:04")
+			}
+			if todo.Title != tc.title || due != tc.due || strings.Join(todo.Tags, ",") != tc.tags ||
+				todo.Priority != tc.priority || todo.Recurrence != tc.recurrence {
+				t.Errorf("expected %q %q [%s] %q %q, got %q %q [%s] %q %q",
+					tc.title, tc.due, tc.tags, tc.priority, tc.recurrence,
+					todo.Title, due, strings.Join(todo.Tags, ","), todo.Priority, todo.Recurrence)
+			}
+			if todo.Recurrence != "" {
+				if _, err := domain.ParseRecurrence(todo.Recurrence); err != nil {
+					t.Errorf("invalid recurrence: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_TimePassed(t *testing.T) {
+	now := time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC)
+
+	// Прошедшее время без дня переносится на завтра
+	if due := Parse("Call at 9pm", now).DueDate; due == nil || !due.Equal(time.Date(2026, 3, 11, 21, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected tomorrow 21:00, got %v", due)
+	}
+	// Повторение по вторникам начинается через неделю, если сегодняшний
+	// срок прошел
+	if due := Parse("Yoga every tuesday at 8am", now).DueDate; due == nil || !due.Equal(time.Date(2026, 3, 17, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected next tuesday 08:00, got %v", due)
+	}
+	// Несуществующая дата остается в названии
+	if todo := Parse("Party 31.02", now); todo.DueDate != nil || todo.Title != "Party 31.02" {
+		t.Errorf("expected an invalid date to stay in the title, got %+v", todo)
+	}
+}
@@ -0,0 +1,177 @@
+package quickadd
+
+import (
+	"time"
+
+	"todo/internal/domain"
+)
+
+// unit - единица относительного срока или периода повторения
+type unit int
+
+const (
+	unitMinute unit = iota
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+// units - слова единиц во всех встречающихся формах
+var units = map[string]unit{
+	"minute": unitMinute, "minutes": unitMinute, "min": unitMinute, "mins": unitMinute,
+	"hour": unitHour, "hours": unitHour, "hr": unitHour, "hrs": unitHour,
+	"day": unitDay, "days": unitDay,
+	"week": unitWeek, "weeks": unitWeek,
+	"month": unitMonth, "months": unitMonth,
+	"year": unitYear, "years": unitYear,
+
+	"минуту": unitMinute, "минуты": unitMinute, "минут": unitMinute,
+	"час": unitHour, "часа": unitHour, "часов": unitHour,
+	"день": unitDay, "дня": unitDay, "дней": unitDay,
+	"неделя": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth,
+	"год": unitYear, "года": unitYear, "лет": unitYear,
+}
+
+// frequencies - период повторения для единиц от дня
+var frequencies = map[unit]domain.Frequency{
+	unitDay:   domain.FreqDaily,
+	unitWeek:  domain.FreqWeekly,
+	unitMonth: domain.FreqMonthly,
+	unitYear:  domain.FreqYearly,
+}
+
+// weekdays - полные английские названия дней недели; они распознаются и
+// без предлога
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	"sunday": time.Sunday,
+}
+
+// weekdaysAfterPrep - сокращения и русские формы дней недели. Они слишком
+// похожи на обычные слова ("sun", "среда"), поэтому распознаются только
+// после предлога или "every"/"каждый".
+var weekdaysAfterPrep = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "fri": time.Friday,
+	"sat": time.Saturday, "sun": time.Sunday,
+
+	"понедельник": time.Monday, "понедельника": time.Monday, "понедельнику": time.Monday,
+	"вторник": time.Tuesday, "вторника": time.Tuesday, "вторнику": time.Tuesday,
+	"среда": time.Wednesday, "среду": time.Wednesday, "среды": time.Wednesday, "среде": time.Wednesday,
+	"четверг": time.Thursday, "четверга": time.Thursday, "четвергу": time.Thursday,
+	"пятница": time.Friday, "пятницу": time.Friday, "пятницы": time.Friday, "пятнице": time.Friday,
+	"суббота": time.Saturday, "субботу": time.Saturday, "субботы": time.Saturday, "субботе": time.Saturday,
+	"воскресенье": time.Sunday, "воскресенья": time.Sunday, "воскресенью": time.Sunday,
+}
+
+// weekdaysPlural - дни недели после "по": по понедельникам и средам
+var weekdaysPlural = map[string]time.Weekday{
+	"понедельникам": time.Monday, "вторникам": time.Tuesday, "средам": time.Wednesday,
+	"четвергам": time.Thursday, "пятницам": time.Friday, "субботам": time.Saturday,
+	"воскресеньям": time.Sunday,
+}
+
+var (
+	workdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	weekend  = []time.Weekday{time.Saturday, time.Sunday}
+)
+
+// months - английские названия и сокращения месяцев и русские в
+// родительном падеже (5 марта)
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January, "february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March, "april": time.April, "apr": time.April,
+	"may": time.May, "june": time.June, "jun": time.June, "july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August, "september": time.September, "sep": time.September,
+	"sept": time.September, "october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November, "december": time.December, "dec": time.December,
+
+	"января": time.January, "февраля": time.February, "марта": time.March, "апреля": time.April,
+	"мая": time.May, "июня": time.June, "июля": time.July, "августа": time.August,
+	"сентября": time.September, "октября": time.October, "ноября": time.November,
+	"декабря": time.December,
+}
+
+// Предлоги, после которых ожидается дата или время. Сам предлог не
+// попадает в название, только если за ним нашлась дата или время.
+var (
+	datePreps = set("on", "by", "due", "until", "в", "во", "до", "к", "ко", "на")
+	timePreps = set("at", "@", "by", "в", "во", "до", "к")
+	// nextWords - "next monday", "в следующую пятницу"
+	nextWords = set("next", "следующий", "следующую", "следующее")
+	// andWords разделяют дни недели в списке
+	andWords = set("and", "&", "и")
+	// everyWords начинают повторение: every monday, каждую среду
+	everyWords = set("every", "каждый", "каждую", "каждое", "каждые")
+)
+
+// repeats - повторение одним словом
+var repeats = map[string]domain.Frequency{
+	"daily": domain.FreqDaily, "weekly": domain.FreqWeekly, "monthly": domain.FreqMonthly,
+	"yearly": domain.FreqYearly, "annually": domain.FreqYearly,
+	"ежедневно": domain.FreqDaily, "еженедельно": domain.FreqWeekly,
+	"ежемесячно": domain.FreqMonthly, "ежегодно": domain.FreqYearly,
+}
+
+// clockTime - время дня в минутах от полуночи
+type clockTime int
+
+func at(hour, minute int) clockTime {
+	return clockTime(hour*60 + minute)
+}
+
+// namedTimes распознаются сами по себе и после предлога: at noon, в полдень
+var namedTimes = map[string]clockTime{
+	"noon": at(12, 0), "midday": at(12, 0), "midnight": at(0, 0),
+	"полдень": at(12, 0), "полночь": at(0, 0),
+}
+
+// dayParts - время для частей дня: in the morning, this evening, утром
+var (
+	dayParts = map[string]clockTime{
+		"morning": at(9, 0), "afternoon": at(14, 0), "evening": at(19, 0),
+	}
+	dayPartsRu = map[string]clockTime{
+		"утром": at(9, 0), "днем": at(14, 0), "вечером": at(19, 0),
+	}
+)
+
+// tonight - время для "tonight"
+var tonight = at(20, 0)
+
+// Слова после часа: 3 pm, 3 часа дня
+var (
+	amWords   = set("am", "a.m")
+	pmWords   = set("pm", "p.m")
+	hourWords = set("час", "часа", "часов")
+)
+
+// Части суток после часа по-русски: 10 утра, 3 дня, 9 вечера, 2 ночи
+const (
+	periodMorning = "утра"
+	periodDay     = "дня"
+	periodEvening = "вечера"
+	periodNight   = "ночи"
+)
+
+// priorities - отметки приоритета; сравниваются без учета регистра
+var priorities = map[string]domain.Priority{
+	"!high": domain.PriorityHigh, "!h": domain.PriorityHigh, "!1": domain.PriorityHigh,
+	"!!!": domain.PriorityHigh, "!высокий": domain.PriorityHigh,
+	"!medium": domain.PriorityMedium, "!med": domain.PriorityMedium, "!m": domain.PriorityMedium,
+	"!2": domain.PriorityMedium, "!!": domain.PriorityMedium, "!средний": domain.PriorityMedium,
+	"!low": domain.PriorityLow, "!l": domain.PriorityLow, "!3": domain.PriorityLow,
+	"!низкий": domain.PriorityLow,
+}
+
+func set(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"todo/internal/domain"
+)
+
+// FileTodoRepository хранит задачи в памяти и после каждого изменения
+// атомарно сохраняет их снимок в JSON файл (запись во временный файл и
+// переименование), поэтому данные переживают перезапуск
+type FileTodoRepository struct {
+	mu   sync.Mutex // упорядочивает изменения и запись снимков
+	mem  *InMemoryTodoRepository
+	path string
+}
+
+// fileSnapshot - формат файла данных. Журнал изменений сохраняется, чтобы
+// токены синхронизации клиентов оставались действительными после
+// перезапуска, очередь исходящих событий - чтобы они не терялись, а
+// история реплик - чтобы после перезапуска сливались правки, сделанные до
+// него.
+type fileSnapshot struct {
+	NextID     int                      `json:"next_id"`
+	Seq        uint64                   `json:"seq,omitempty"`
+	Horizon    uint64                   `json:"horizon,omitempty"`
+	Todos      []*domain.Todo           `json:"todos"`
+	Tombstones []domain.Change          `json:"tombstones,omitempty"`
+	Outbox     []domain.TodoEvent       `json:"outbox,omitempty"`
+	Replicas   map[int][]replicaVersion `json:"replicas,omitempty"`
+}
+
+// NewFileTodoRepository открывает хранилище в файле path, создавая его при
+// первом сохранении
+func NewFileTodoRepository(path string) (*FileTodoRepository, error) {
+	r := &FileTodoRepository{mem: NewInMemoryTodoRepository(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var snapshot fileSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	r.mem.seq, r.mem.horizon = snapshot.Seq, snapshot.Horizon
+	for _, todo := range snapshot.Todos {
+		r.mem.todos[todo.ID] = todo
+		r.mem.reindex(todo.ID, nil, todo)
+		if todo.ID >= r.mem.nextID {
+			r.mem.nextID = todo.ID + 1
+		}
+		r.mem.seq = max(r.mem.seq, todo.Version)
+	}
+	for _, tombstone := range snapshot.Tombstones {
+		r.mem.tombstones[tombstone.ID] = tombstone.Seq
+		r.mem.seq = max(r.mem.seq, tombstone.Seq)
+	}
+	r.mem.outbox = snapshot.Outbox
+	for id, history := range snapshot.Replicas {
+		if _, exists := r.mem.todos[id]; !exists || len(history) == 0 {
+			continue
+		}
+		r.mem.replicas[id] = history
+		// Новые записи должны получать метки новее сохраненных, даже если
+		// часы машины отстают
+		for _, v := range history {
+			r.mem.clock.Observe(v.Replica.Latest())
+		}
+	}
+	if snapshot.NextID > r.mem.nextID {
+		r.mem.nextID = snapshot.NextID
+	}
+	// Файлы, записанные до появления журнала, не содержат версий, а
+	// записанные до ручного порядка - рангов: такие задачи встают в конец
+	// своих списков в порядке ID
+	for _, todo := range snapshot.Todos {
+		if todo.Version == 0 {
+			r.mem.seq++
+			todo.Version = r.mem.seq
+		}
+	}
+	// Истории реплик в таких файлах нет: она начинается с текущей версии
+	for _, todo := range snapshot.Todos {
+		if len(r.mem.replicas[todo.ID]) == 0 {
+			r.mem.record(todo, domain.NewReplicatedTodo(todo, r.mem.clock.Now()))
+		}
+	}
+	sort.Slice(snapshot.Todos, func(i, j int) bool { return snapshot.Todos[i].ID < snapshot.Todos[j].ID })
+	for _, todo := range snapshot.Todos {
+		if !domain.ValidRank(todo.Rank) {
+			r.mem.appendRank(todo)
+			r.mem.tails[listKey(todo.Project)] = todo.Rank
+		}
+	}
+
+	return r, nil
+}
+
+// Create создает новую задачу
+func (r *FileTodoRepository) Create(ctx context.Context, todo *domain.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.mem.state(todo.ID)
+	if err := r.mem.Create(ctx, todo); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		// Откатываем изменение, чтобы память не расходилась с файлом
+		r.mem.restore(todo.ID, previous)
+		return err
+	}
+	return nil
+}
+
+// GetAll возвращает все задачи
+func (r *FileTodoRepository) GetAll(ctx context.Context) ([]*domain.Todo, error) {
+	return r.mem.GetAll(ctx)
+}
+
+// GetByID возвращает задачу по идентификатору
+func (r *FileTodoRepository) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
+	return r.mem.GetByID(ctx, id)
+}
+
+// Update обновляет существующую задачу
+func (r *FileTodoRepository) Update(ctx context.Context, todo *domain.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.mem.state(todo.ID)
+	if err := r.mem.Update(ctx, todo); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		r.mem.restore(todo.ID, previous)
+		return err
+	}
+	return nil
+}
+
+// Delete удаляет задачу по идентификатору
+func (r *FileTodoRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.mem.state(id)
+	if err := r.mem.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		r.mem.restore(id, previous)
+		return err
+	}
+	return nil
+}
+
+// Move ставит задачу рядом с соседом anchor в ручном порядке ее списка
+func (r *FileTodoRepository) Move(ctx context.Context, id int, anchor domain.Anchor) (*domain.Todo, []*domain.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.mem.listStates(id)
+	moved, changed, err := r.mem.Move(ctx, id, anchor)
+	if err != nil || len(changed) == 0 {
+		return moved, changed, err
+	}
+	if err := r.save(); err != nil {
+		for _, todo := range changed {
+			r.mem.restore(todo.ID, previous[todo.ID])
+		}
+		return nil, nil, err
+	}
+	return moved, changed, nil
+}
+
+// Merge сливает правку todo, сделанную поверх версии base, с правками
+// задачи id, записанными после base
+func (r *FileTodoRepository) Merge(ctx context.Context, id int, base uint64, todo *domain.Todo) (*domain.Todo, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.mem.state(id)
+	merged, concurrent, err := r.mem.Merge(ctx, id, base, todo)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := r.save(); err != nil {
+		r.mem.restore(id, previous)
+		return nil, false, err
+	}
+	return merged, concurrent, nil
+}
+
+// Changes возвращает журнал изменений после since
+func (r *FileTodoRepository) Changes(ctx context.Context, since uint64, limit int) ([]domain.Change, uint64, error) {
+	return r.mem.Changes(ctx, since, limit)
+}
+
+// Search ищет задачи по словам названия и описания
+func (r *FileTodoRepository) Search(ctx context.Context, query string, limit int) ([]domain.SearchHit, int, error) {
+	return r.mem.Search(ctx, query, limit)
+}
+
+// Find возвращает задачи, подходящие под запрос
+func (r *FileTodoRepository) Find(ctx context.Context, expr domain.QueryExpr) ([]*domain.Todo, error) {
+	return r.mem.Find(ctx, expr)
+}
+
+// OutboxEvents возвращает события очереди с ID больше after
+func (r *FileTodoRepository) OutboxEvents(ctx context.Context, after uint64, limit int) ([]domain.TodoEvent, uint64, error) {
+	return r.mem.OutboxEvents(ctx, after, limit)
+}
+
+// AckOutbox удаляет из очереди события с ID не больше upTo. Если снимок не
+// удалось записать, события вернутся в очередь после перезапуска и будут
+// отправлены повторно.
+func (r *FileTodoRepository) AckOutbox(ctx context.Context, upTo uint64) error {
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mem.mu.Lock()
+	acked := r.mem.ack(upTo)
+	r.mem.mu.Unlock()
+	if !acked {
+		return nil
+	}
+	return r.save()
+}
+
+// Exists проверяет существование задачи
+func (r *FileTodoRepository) Exists(ctx context.Context, id int) bool {
+	return r.mem.Exists(ctx, id)
+}
+
+// Ping проверяет, что каталог с файлом данных доступен
+func (r *FileTodoRepository) Ping(ctx context.Context) error {
+	_, err := os.Stat(filepath.Dir(r.path))
+	return err
+}
+
+// Path возвращает путь к файлу данных
+func (r *FileTodoRepository) Path() string {
+	return r.path
+}
+
+// save записывает снимок; вызывается под r.mu
+func (r *FileTodoRepository) save() error {
+	r.mem.mu.RLock()
+	snapshot := fileSnapshot{
+		NextID:  r.mem.nextID,
+		Seq:     r.mem.seq,
+		Horizon: r.mem.horizon,
+		Todos:   make([]*domain.Todo, 0, len(r.mem.todos)),
+	}
+	for _, todo := range r.mem.todos {
+		snapshot.Todos = append(snapshot.Todos, todo)
+	}
+	for id, seq := range r.mem.tombstones {
+		snapshot.Tombstones = append(snapshot.Tombstones, domain.Change{Seq: seq, ID: id, Deleted: true})
+	}
+	sort.Slice(snapshot.Todos, func(i, j int) bool { return snapshot.Todos[i].ID < snapshot.Todos[j].ID })
+	sort.Slice(snapshot.Tombstones, func(i, j int) bool { return snapshot.Tombstones[i].Seq < snapshot.Tombstones[j].Seq })
+	// Очередь кодируется под блокировкой: подтверждение сдвигает ее на месте
+	snapshot.Outbox = r.mem.outbox
+	snapshot.Replicas = r.mem.replicas
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	r.mem.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("save todos: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic записывает data во временный файл рядом с path и
+// переименовывает его в path, поэтому при сбое на диске остается либо
+// прежний, либо новый файл целиком
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"todo/internal/domain"
+	"todo/internal/tracing"
+)
+
+// InMemoryFilterRepository хранит сохраненные фильтры в памяти. Фильтры
+// возвращаются копиями, чтобы вызывающий мог их менять.
+type InMemoryFilterRepository struct {
+	mu      sync.RWMutex
+	filters map[int]*domain.Filter
+	nextID  int
+}
+
+// NewInMemoryFilterRepository создает пустое хранилище фильтров
+func NewInMemoryFilterRepository() *InMemoryFilterRepository {
+	return &InMemoryFilterRepository{
+		filters: make(map[int]*domain.Filter),
+		nextID:  1,
+	}
+}
+
+// CreateFilter сохраняет новый фильтр и назначает ему ID
+func (r *InMemoryFilterRepository) CreateFilter(ctx context.Context, filter *domain.Filter) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryFilterRepository.CreateFilter")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filter.ID = r.nextID
+	r.nextID++
+	r.filters[filter.ID] = filter.Clone()
+	return nil
+}
+
+// GetFilters возвращает фильтры принципала owner по возрастанию ID
+func (r *InMemoryFilterRepository) GetFilters(ctx context.Context, owner string) (_ []*domain.Filter, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryFilterRepository.GetFilters")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	filters := []*domain.Filter{}
+	for _, filter := range r.filters {
+		if filter.Owner == owner {
+			filters = append(filters, filter.Clone())
+		}
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].ID < filters[j].ID })
+	return filters, nil
+}
+
+// GetFilter возвращает фильтр по ID
+func (r *InMemoryFilterRepository) GetFilter(ctx context.Context, id int) (_ *domain.Filter, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryFilterRepository.GetFilter")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	filter, ok := r.filters[id]
+	if !ok {
+		return nil, domain.ErrFilterNotFound
+	}
+	return filter.Clone(), nil
+}
+
+// UpdateFilter заменяет фильтр, сохраняя владельца и дату создания
+func (r *InMemoryFilterRepository) UpdateFilter(ctx context.Context, filter *domain.Filter) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryFilterRepository.UpdateFilter")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.filters[filter.ID]
+	if !ok {
+		return domain.ErrFilterNotFound
+	}
+	filter.Owner, filter.CreatedAt = stored.Owner, stored.CreatedAt
+	r.filters[filter.ID] = filter.Clone()
+	return nil
+}
+
+// DeleteFilter удаляет фильтр
+func (r *InMemoryFilterRepository) DeleteFilter(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryFilterRepository.DeleteFilter")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.filters[id]; !ok {
+		return domain.ErrFilterNotFound
+	}
+	delete(r.filters, id)
+	return nil
+}
+
+// filterSnapshot - формат файла фильтров и копия состояния для отката
+type filterSnapshot struct {
+	NextID  int              `json:"next_id"`
+	Filters []*domain.Filter `json:"filters"`
+}
+
+// export возвращает независимую копию состояния
+func (r *InMemoryFilterRepository) export() filterSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := filterSnapshot{NextID: r.nextID, Filters: make([]*domain.Filter, 0, len(r.filters))}
+	for _, filter := range r.filters {
+		s.Filters = append(s.Filters, filter.Clone())
+	}
+	sort.Slice(s.Filters, func(i, j int) bool { return s.Filters[i].ID < s.Filters[j].ID })
+	return s
+}
+
+// load заменяет состояние снимком
+func (r *InMemoryFilterRepository) load(s filterSnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID = max(s.NextID, 1)
+	r.filters = make(map[int]*domain.Filter, len(s.Filters))
+	for _, filter := range s.Filters {
+		r.filters[filter.ID] = filter
+		r.nextID = max(r.nextID, filter.ID+1)
+	}
+}
+
+// FileFilterRepository хранит фильтры в памяти и после каждого изменения
+// атомарно сохраняет их в JSON файл, как FileWebhookRepository
+type FileFilterRepository struct {
+	mu   sync.Mutex // упорядочивает изменения и запись снимков
+	mem  *InMemoryFilterRepository
+	path string
+}
+
+// NewFileFilterRepository открывает хранилище фильтров в файле path,
+// создавая его при первом сохранении
+func NewFileFilterRepository(path string) (*FileFilterRepository, error) {
+	r := &FileFilterRepository{mem: NewInMemoryFilterRepository(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var snapshot filterSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	r.mem.load(snapshot)
+	return r, nil
+}
+
+// update выполняет изменение и сохраняет снимок, откатывая изменение, если
+// записать его не удалось
+func (r *FileFilterRepository) update(change func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.mem.export()
+	if err := change(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r.mem.export(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(r.path, data)
+	}
+	if err != nil {
+		r.mem.load(previous)
+		return fmt.Errorf("save filters: %w", err)
+	}
+	return nil
+}
+
+// CreateFilter сохраняет новый фильтр и назначает ему ID
+func (r *FileFilterRepository) CreateFilter(ctx context.Context, filter *domain.Filter) error {
+	return r.update(func() error { return r.mem.CreateFilter(ctx, filter) })
+}
+
+// GetFilters возвращает фильтры принципала owner по возрастанию ID
+func (r *FileFilterRepository) GetFilters(ctx context.Context, owner string) ([]*domain.Filter, error) {
+	return r.mem.GetFilters(ctx, owner)
+}
+
+// GetFilter возвращает фильтр по ID
+func (r *FileFilterRepository) GetFilter(ctx context.Context, id int) (*domain.Filter, error) {
+	return r.mem.GetFilter(ctx, id)
+}
+
+// UpdateFilter заменяет фильтр
+func (r *FileFilterRepository) UpdateFilter(ctx context.Context, filter *domain.Filter) error {
+	return r.update(func() error { return r.mem.UpdateFilter(ctx, filter) })
+}
+
+// DeleteFilter удаляет фильтр
+func (r *FileFilterRepository) DeleteFilter(ctx context.Context, id int) error {
+	return r.update(func() error { return r.mem.DeleteFilter(ctx, id) })
+}
@@ -0,0 +1,120 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"todo/internal/domain"
+	"todo/internal/repository"
+)
+
+func TestFilterRepositories(t *testing.T) {
+	factories := map[string]func(t *testing.T) domain.FilterRepository{
+		"InMemory": func(*testing.T) domain.FilterRepository {
+			return repository.NewInMemoryFilterRepository()
+		},
+		"File": func(t *testing.T) domain.FilterRepository {
+			repo, err := repository.NewFileFilterRepository(filepath.Join(t.TempDir(), "filters.json"))
+			if err != nil {
+				t.Fatalf("failed to open repository: %v", err)
+			}
+			return repo
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("фильтры владельца", func(t *testing.T) {
+				repo := factory(t)
+				mine, other, second := newTestFilter("alice"), newTestFilter("bob"), newTestFilter("alice")
+				for _, f := range []*domain.Filter{mine, other, second} {
+					if err := repo.CreateFilter(ctx, f); err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				}
+
+				filters, err := repo.GetFilters(ctx, "alice")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(filters) != 2 || filters[0].ID != mine.ID || filters[1].ID != second.ID {
+					t.Errorf("expected alice's filters in ID order, got %+v", filters)
+				}
+				if filters, _ := repo.GetFilters(ctx, "carol"); filters == nil || len(filters) != 0 {
+					t.Errorf("expected an empty list, got %#v", filters)
+				}
+			})
+
+			t.Run("обновление сохраняет владельца", func(t *testing.T) {
+				repo := factory(t)
+				f := newTestFilter("alice")
+				repo.CreateFilter(ctx, f)
+
+				update := &domain.Filter{ID: f.ID, Name: "Срочное", Owner: "mallory", Query: "tag:urgent"}
+				if err := repo.UpdateFilter(ctx, update); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got, _ := repo.GetFilter(ctx, f.ID)
+				if got.Name != "Срочное" || got.Query != "tag:urgent" || got.Owner != "alice" || !got.CreatedAt.Equal(f.CreatedAt) {
+					t.Errorf("expected new fields with the old owner, got %+v", got)
+				}
+				if err := repo.UpdateFilter(ctx, &domain.Filter{ID: 999}); !errors.Is(err, domain.ErrFilterNotFound) {
+					t.Errorf("expected ErrFilterNotFound, got %v", err)
+				}
+			})
+
+			t.Run("удаление", func(t *testing.T) {
+				repo := factory(t)
+				f := newTestFilter("alice")
+				repo.CreateFilter(ctx, f)
+
+				if err := repo.DeleteFilter(ctx, f.ID); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, err := repo.GetFilter(ctx, f.ID); !errors.Is(err, domain.ErrFilterNotFound) {
+					t.Errorf("expected ErrFilterNotFound, got %v", err)
+				}
+				if err := repo.DeleteFilter(ctx, f.ID); !errors.Is(err, domain.ErrFilterNotFound) {
+					t.Errorf("expected ErrFilterNotFound, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestFileFilterRepository_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.json")
+	ctx := context.Background()
+
+	repo, err := repository.NewFileFilterRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deleted, kept := newTestFilter("alice"), newTestFilter("alice")
+	repo.CreateFilter(ctx, deleted)
+	repo.CreateFilter(ctx, kept)
+	repo.DeleteFilter(ctx, deleted.ID)
+
+	reopened, err := repository.NewFileFilterRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	filters, _ := reopened.GetFilters(ctx, "alice")
+	if len(filters) != 1 || filters[0].ID != kept.ID || filters[0].Query != kept.Query || filters[0].Sort != kept.Sort {
+		t.Fatalf("expected only the kept filter after reopen, got %+v", filters)
+	}
+
+	next := newTestFilter("alice")
+	reopened.CreateFilter(ctx, next)
+	if next.ID <= kept.ID {
+		t.Errorf("expected IDs not to be reused after reopen, got %d", next.ID)
+	}
+}
+
+func newTestFilter(owner string) *domain.Filter {
+	return &domain.Filter{Name: "Работа", Owner: owner, Query: "project:work status:open", Sort: "due"}
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"todo/internal/domain"
+	"todo/internal/tracing"
+)
+
+// MaxReplicaHistory ограничивает число хранимых версий реплики каждой
+// задачи. Правку поверх более старой версии слить нельзя (см. Merge).
+const MaxReplicaHistory = 16
+
+// replicaNode - идентификатор реплики хранилища в метках часов
+const replicaNode = "server"
+
+// replicaVersion - реплика задачи в версии Version. Сохраненные реплики не
+// изменяются, поэтому их можно разделять между версиями и снимками.
+type replicaVersion struct {
+	Version uint64                 `json:"version"`
+	Replica *domain.ReplicatedTodo `json:"replica"`
+}
+
+// record добавляет в историю реплик задачи ее новую версию, отбрасывая
+// самые старые; вызывается под r.mu
+func (r *InMemoryTodoRepository) record(todo *domain.Todo, replica *domain.ReplicatedTodo) {
+	// Историю не сдвигаем на месте: срез может принадлежать состоянию,
+	// сохраненному для отката (см. state)
+	history := append(r.replicas[todo.ID], replicaVersion{Version: todo.Version, Replica: replica})
+	if len(history) > MaxReplicaHistory {
+		history = history[len(history)-MaxReplicaHistory:]
+	}
+	r.replicas[todo.ID] = history
+}
+
+// replica возвращает реплику текущей версии задачи; вызывается под r.mu
+func (r *InMemoryTodoRepository) replica(todo *domain.Todo) *domain.ReplicatedTodo {
+	if history := r.replicas[todo.ID]; len(history) > 0 {
+		return history[len(history)-1].Replica
+	}
+	return domain.NewReplicatedTodo(todo, r.clock.Now())
+}
+
+// replicaAt возвращает реплику версии version задачи id или nil, если ее
+// нет в истории; вызывается под r.mu
+func (r *InMemoryTodoRepository) replicaAt(id int, version uint64) *domain.ReplicatedTodo {
+	for _, v := range r.replicas[id] {
+		if v.Version == version {
+			return v.Replica
+		}
+	}
+	return nil
+}
+
+// Merge сливает правку todo, сделанную поверх версии base, с правками
+// задачи id, записанными после base
+func (r *InMemoryTodoRepository) Merge(ctx context.Context, id int, base uint64, todo *domain.Todo) (_ *domain.Todo, concurrent bool, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Merge")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.todos[id]
+	if !exists {
+		return nil, false, domain.ErrTodoNotFound
+	}
+	baseReplica := r.replicaAt(id, base)
+	if baseReplica == nil {
+		return nil, false, domain.ErrVersionUnavailable
+	}
+
+	// Правка клиента - реплика базовой версии с его изменениями. Поля,
+	// которые клиент не менял, сохраняют метки base и уступают правкам,
+	// записанным после нее.
+	client := baseReplica.Clone()
+	client.Edit(todo, r.clock.Now())
+	replica := domain.Merge(r.replica(stored), client)
+
+	merged := replica.Todo()
+	merged.ID = id
+	merged.Tags = orderTags(replica, stored.Tags, todo.Tags)
+	if err := merged.Validate(); err != nil {
+		return nil, false, err
+	}
+	if listKey(merged.Project) == listKey(stored.Project) {
+		merged.Rank = stored.Rank
+	} else {
+		r.appendRank(merged)
+	}
+	r.seq++
+	merged.Version = r.seq
+	r.todos[id] = merged
+	r.reindex(id, stored, merged)
+	r.enqueue(domain.EventTodoUpdated, merged)
+	r.record(merged, replica)
+	return merged, base != stored.Version, nil
+}
+
+// orderTags возвращает теги реплики в порядке, в котором они перечислены
+// в orders: OR-set порядка не хранит, а клиентам он важен
+func orderTags(replica *domain.ReplicatedTodo, orders ...[]string) []string {
+	var tags []string
+	for _, order := range orders {
+		for _, tag := range order {
+			if replica.Tags.Contains(tag) && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
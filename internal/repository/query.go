@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"todo/internal/domain"
+	"todo/internal/tracing"
+)
+
+// idSet - множество ID задач
+type idSet map[int]struct{}
+
+// fieldIndex - вторичный индекс: значение поля в нижнем регистре -> задачи
+type fieldIndex map[string]idSet
+
+func (ix fieldIndex) add(key string, id int) {
+	key = strings.ToLower(key)
+	ids := ix[key]
+	if ids == nil {
+		ids = make(idSet)
+		ix[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func (ix fieldIndex) remove(key string, id int) {
+	key = strings.ToLower(key)
+	if delete(ix[key], id); len(ix[key]) == 0 {
+		delete(ix, key)
+	}
+}
+
+// reindex обновляет индексы задачи id при замене old на todo (nil - задачи
+// нет); вызывается под r.mu
+func (r *InMemoryTodoRepository) reindex(id int, old, todo *domain.Todo) {
+	if old != nil {
+		r.byProject.remove(old.Project, id)
+		for _, tag := range old.Tags {
+			r.byTag.remove(tag, id)
+		}
+		delete(r.completed, id)
+	}
+	if todo == nil {
+		r.index.Remove(id)
+		return
+	}
+
+	if old == nil || todo.Title != old.Title || todo.Description != old.Description {
+		r.index.Add(id, todo.Title, todo.Description)
+	}
+	r.byProject.add(todo.Project, id)
+	for _, tag := range todo.Tags {
+		r.byTag.add(tag, id)
+	}
+	if todo.Completed {
+		r.completed[id] = struct{}{}
+	}
+	if key := listKey(todo.Project); todo.Rank > r.tails[key] && domain.ValidRank(todo.Rank) {
+		r.tails[key] = todo.Rank
+	}
+}
+
+// Find возвращает задачи, подходящие под запрос, по возрастанию ID. Если
+// запрос позволяет, задачи-кандидаты берутся из индексов (см. candidates),
+// иначе проверяются все задачи.
+func (r *InMemoryTodoRepository) Find(ctx context.Context, expr domain.QueryExpr) (_ []*domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Find")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids, indexed := r.candidates(expr)
+	span.SetAttributes(tracing.Attr("query.indexed", indexed))
+
+	var todos []*domain.Todo
+	check := func(todo *domain.Todo, n int) error {
+		// Периодически проверяем отмену, чтобы не перебирать большой список зря
+		if n%cancelCheckInterval == 0 {
+			if err := domain.ContextErr(ctx); err != nil {
+				return err
+			}
+		}
+		if expr.Match(todo) {
+			todos = append(todos, todo)
+		}
+		return nil
+	}
+
+	n := 0
+	if indexed {
+		for id := range ids {
+			if err := check(r.todos[id], n); err != nil {
+				return nil, err
+			}
+			n++
+		}
+	} else {
+		for _, todo := range r.todos {
+			if err := check(todo, n); err != nil {
+				return nil, err
+			}
+			n++
+		}
+	}
+
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos, nil
+}
+
+// candidates возвращает множество задач, в котором есть все подходящие под
+// expr, если его можно получить из индексов: проект, тег, status:done и
+// полнотекстовые условия берутся из индексов, AND пересекает те условия,
+// для которых это возможно, а OR объединяет условия, если возможно для
+// всех. Остальные условия (срок, NOT) требуют перебора. Множество может
+// быть индексом хранилища и не должно изменяться; вызывается под r.mu.
+func (r *InMemoryTodoRepository) candidates(expr domain.QueryExpr) (idSet, bool) {
+	switch e := expr.(type) {
+	case *domain.FieldExpr:
+		switch {
+		case e.Field == domain.FieldProject:
+			return r.byProject[strings.ToLower(e.Value)], true
+		case e.Field == domain.FieldTag:
+			return r.byTag[strings.ToLower(e.Value)], true
+		case e.Field == domain.FieldStatus && e.Value == domain.StatusDone:
+			return r.completed, true
+		}
+
+	case *domain.TextExpr:
+		hits := r.index.Search(e.Query)
+		ids := make(idSet, len(hits))
+		for _, hit := range hits {
+			ids[hit.ID] = struct{}{}
+		}
+		return ids, true
+
+	case *domain.AndExpr:
+		var sets []idSet
+		for _, term := range e.Terms {
+			if ids, ok := r.candidates(term); ok {
+				sets = append(sets, ids)
+			}
+		}
+		if len(sets) == 0 {
+			return nil, false
+		}
+		sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+		if len(sets) == 1 {
+			return sets[0], true
+		}
+		ids := make(idSet, len(sets[0]))
+		for id := range sets[0] {
+			if inAll(id, sets[1:]) {
+				ids[id] = struct{}{}
+			}
+		}
+		return ids, true
+
+	case *domain.OrExpr:
+		ids := make(idSet)
+		for _, term := range e.Terms {
+			termIDs, ok := r.candidates(term)
+			if !ok {
+				return nil, false
+			}
+			for id := range termIDs {
+				ids[id] = struct{}{}
+			}
+		}
+		return ids, true
+	}
+	return nil, false
+}
+
+func inAll(id int, sets []idSet) bool {
+	for _, ids := range sets {
+		if _, ok := ids[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"todo/internal/domain"
+	"todo/internal/tracing"
+)
+
+// listKey - ключ списка задачи: задачи одного проекта (без учета регистра)
+// образуют один список с общим ручным порядком
+func listKey(project string) string {
+	return strings.ToLower(project)
+}
+
+// compareRank упорядочивает задачи списка по рангу, а при равных рангах -
+// по ID
+func compareRank(a, b *domain.Todo) int {
+	if c := strings.Compare(a.Rank, b.Rank); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// appendRank назначает задаче ранг в конце ее списка; вызывается под r.mu
+func (r *InMemoryTodoRepository) appendRank(todo *domain.Todo) {
+	todo.Rank = domain.RankBetween(r.tails[listKey(todo.Project)], "")
+}
+
+// list возвращает задачи списка project, кроме задачи except, в ручном
+// порядке; вызывается под r.mu
+func (r *InMemoryTodoRepository) list(project string, except int) []*domain.Todo {
+	ids := r.byProject[listKey(project)]
+	todos := make([]*domain.Todo, 0, len(ids))
+	for id := range ids {
+		if id != except {
+			todos = append(todos, r.todos[id])
+		}
+	}
+	slices.SortFunc(todos, compareRank)
+	return todos
+}
+
+// Move ставит задачу рядом с соседом anchor в ручном порядке ее списка
+func (r *InMemoryTodoRepository) Move(ctx context.Context, id int, anchor domain.Anchor) (_ *domain.Todo, _ []*domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Move")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, exists := r.todos[id]
+	if !exists {
+		return nil, nil, domain.ErrTodoNotFound
+	}
+	neighbor, exists := r.todos[anchor.ID()]
+	switch {
+	case anchor.ID() == id:
+		return nil, nil, fmt.Errorf("%w: a todo cannot be moved next to itself", domain.ErrInvalidMove)
+	case !exists:
+		return nil, nil, fmt.Errorf("%w: todo %d not found", domain.ErrInvalidMove, anchor.ID())
+	case listKey(neighbor.Project) != listKey(todo.Project):
+		return nil, nil, fmt.Errorf("%w: todo %d is in another project", domain.ErrInvalidMove, anchor.ID())
+	}
+
+	// Соседи, между которыми встанет задача
+	list := r.list(todo.Project, id)
+	pos := slices.Index(list, neighbor)
+	if anchor.After != 0 {
+		pos++
+	}
+	var prev, next *domain.Todo
+	if pos > 0 {
+		prev = list[pos-1]
+	}
+	if pos < len(list) {
+		next = list[pos]
+	}
+
+	// Задача уже на месте
+	if (prev == nil || compareRank(prev, todo) < 0) && (next == nil || compareRank(todo, next) < 0) {
+		return todo, nil, nil
+	}
+
+	var prevRank, nextRank string
+	if prev != nil {
+		prevRank = prev.Rank
+	}
+	if next != nil {
+		nextRank = next.Rank
+	}
+	ordered := (prev == nil || domain.ValidRank(prevRank)) && (next == nil || domain.ValidRank(nextRank)) &&
+		(prev == nil || next == nil || prevRank < nextRank)
+	if ordered {
+		if rank := domain.RankBetween(prevRank, nextRank); len(rank) <= domain.MaxRankLength {
+			moved := r.setRank(todo, rank)
+			return moved, []*domain.Todo{moved}, nil
+		}
+	}
+
+	// Ранг вышел слишком длинным или у соседей равные ранги:
+	// перераспределяем ранги всего списка
+	list = slices.Insert(list, pos, todo)
+	ranks := domain.Ranks(len(list))
+	moved, changed := todo, make([]*domain.Todo, 0, len(list))
+	for i, t := range list {
+		if t.Rank == ranks[i] {
+			continue
+		}
+		t = r.setRank(t, ranks[i])
+		if t.ID == id {
+			moved = t
+		}
+		changed = append(changed, t)
+	}
+	r.tails[listKey(todo.Project)] = ranks[len(ranks)-1]
+	span.SetAttributes(tracing.Attr("rank.rebalanced", len(changed)))
+	return moved, changed, nil
+}
+
+// setRank записывает в журнал изменение ранга задачи и возвращает ее новую
+// версию; вызывается под r.mu
+func (r *InMemoryTodoRepository) setRank(todo *domain.Todo, rank string) *domain.Todo {
+	moved := todo.Clone()
+	moved.Rank = rank
+	r.seq++
+	moved.Version = r.seq
+	r.todos[moved.ID] = moved
+	r.reindex(moved.ID, todo, moved)
+	r.enqueue(domain.EventTodoUpdated, moved)
+	r.record(moved, r.replica(todo))
+	return moved
+}
+
+// listStates возвращает состояния задач списка задачи id для отката
+// перестановки
+func (r *InMemoryTodoRepository) listStates(id int) map[int]todoState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, exists := r.todos[id]
+	if !exists {
+		return nil
+	}
+	states := make(map[int]todoState)
+	for id := range r.byProject[listKey(todo.Project)] {
+		states[id] = todoState{todo: r.todos[id], tombstone: r.tombstones[id], seq: r.seq, replicas: r.replicas[id]}
+	}
+	return states
+}
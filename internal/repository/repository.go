@@ -2,28 +2,88 @@ package repository
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"todo/internal/domain"
+	"todo/internal/search"
+	"todo/internal/tracing"
 )
 
+// cancelCheckInterval задает, как часто длинные циклы проверяют отмену контекста
+const cancelCheckInterval = 1024
+
+// MaxTombstones ограничивает число хранимых надгробий удаленных задач. При
+// превышении самые старые удаляются, и токены синхронизации старше них
+// перестают приниматься.
+const MaxTombstones = 10000
+
+// MaxOutbox ограничивает очередь исходящих событий на случай, если их никто
+// не забирает. При превышении самые старые события удаляются.
+const MaxOutbox = 10000
+
+// snippetWidth - длина фрагмента описания в результатах поиска, символов
+const snippetWidth = 160
+
 // InMemoryTodoRepository реализует хранилище задач в памяти
 type InMemoryTodoRepository struct {
 	mu     sync.RWMutex
 	todos  map[int]*domain.Todo
 	nextID int
+
+	// Журнал изменений: номер последнего изменения, надгробия удаленных
+	// задач (ID -> номер удаления) и номер самого нового удаленного
+	// надгробия, до которого журнал неполон
+	seq        uint64
+	tombstones map[int]uint64
+	horizon    uint64
+
+	// Очередь исходящих событий по возрастанию ID
+	outbox []domain.TodoEvent
+
+	// Полнотекстовый индекс названий и описаний и вторичные индексы для
+	// запросов (см. Find)
+	index     *search.Index
+	byProject fieldIndex
+	byTag     fieldIndex
+	completed idSet
+
+	// Наибольший ранг в каждом списке (см. listKey): новые задачи
+	// добавляются после него
+	tails map[string]string
+
+	// История версий реплик задач для слияния правок (см. Merge) и часы,
+	// которыми метятся записи реплик
+	replicas map[int][]replicaVersion
+	clock    *domain.Clock
 }
 
 // NewInMemoryTodoRepository создает новый экземпляр репозитория
 func NewInMemoryTodoRepository() *InMemoryTodoRepository {
 	return &InMemoryTodoRepository{
-		todos:  make(map[int]*domain.Todo),
-		nextID: 1,
+		todos:      make(map[int]*domain.Todo),
+		nextID:     1,
+		tombstones: make(map[int]uint64),
+		index:      search.NewIndex(),
+		byProject:  make(fieldIndex),
+		byTag:      make(fieldIndex),
+		completed:  make(idSet),
+		tails:      make(map[string]string),
+		replicas:   make(map[int][]replicaVersion),
+		clock:      domain.NewClock(replicaNode, nil),
 	}
 }
 
-// Create создает новую задачу
-func (r *InMemoryTodoRepository) Create(ctx context.Context, todo *domain.Todo) error {
+// Create создает новую задачу в конце списка ее проекта
+func (r *InMemoryTodoRepository) Create(ctx context.Context, todo *domain.Todo) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Create")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -42,25 +102,54 @@ func (r *InMemoryTodoRepository) Create(ctx context.Context, todo *domain.Todo)
 		}
 	}
 
+	delete(r.tombstones, todo.ID)
+	r.appendRank(todo)
+	r.seq++
+	todo.Version = r.seq
 	r.todos[todo.ID] = todo
+	r.reindex(todo.ID, nil, todo)
+	r.enqueue(domain.EventTodoCreated, todo)
+	r.record(todo, domain.NewReplicatedTodo(todo, r.clock.Now()))
 	return nil
 }
 
-// GetAll возвращает все задачи
-func (r *InMemoryTodoRepository) GetAll(ctx context.Context) ([]*domain.Todo, error) {
+// GetAll возвращает все задачи в ручном порядке: по проектам, а в проекте -
+// по рангу
+func (r *InMemoryTodoRepository) GetAll(ctx context.Context) (_ []*domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.GetAll")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	todos := make([]*domain.Todo, 0, len(r.todos))
 	for _, todo := range r.todos {
+		// Периодически проверяем отмену, чтобы не копировать большой список зря
+		if len(todos)%cancelCheckInterval == 0 {
+			if err := domain.ContextErr(ctx); err != nil {
+				return nil, err
+			}
+		}
 		todos = append(todos, todo)
 	}
+	domain.SortTodos(todos, domain.SortRank)
 
 	return todos, nil
 }
 
 // GetByID возвращает задачу по идентификатору
-func (r *InMemoryTodoRepository) GetByID(ctx context.Context, id int) (*domain.Todo, error) {
+func (r *InMemoryTodoRepository) GetByID(ctx context.Context, id int) (_ *domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.GetByID")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -73,33 +162,273 @@ func (r *InMemoryTodoRepository) GetByID(ctx context.Context, id int) (*domain.T
 }
 
 // Update обновляет существующую задачу
-func (r *InMemoryTodoRepository) Update(ctx context.Context, todo *domain.Todo) error {
+func (r *InMemoryTodoRepository) Update(ctx context.Context, todo *domain.Todo) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Update")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.todos[todo.ID]; !exists {
+	stored, exists := r.todos[todo.ID]
+	if !exists {
 		return domain.ErrTodoNotFound
 	}
 
+	// Ранг меняет только Move; задача, перенесенная в другой проект,
+	// встает в конец его списка
+	if listKey(todo.Project) == listKey(stored.Project) {
+		todo.Rank = stored.Rank
+	} else {
+		r.appendRank(todo)
+	}
+	replica := r.replica(stored).Clone()
+	replica.Edit(todo, r.clock.Now())
+	r.seq++
+	todo.Version = r.seq
 	r.todos[todo.ID] = todo
+	r.reindex(todo.ID, stored, todo)
+	r.enqueue(domain.EventTodoUpdated, todo)
+	r.record(todo, replica)
 	return nil
 }
 
-// Delete удаляет задачу по идентификатору
-func (r *InMemoryTodoRepository) Delete(ctx context.Context, id int) error {
+// Delete удаляет задачу по идентификатору и оставляет в журнале надгробие
+func (r *InMemoryTodoRepository) Delete(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Delete")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.todos[id]; !exists {
+	stored, exists := r.todos[id]
+	if !exists {
 		return domain.ErrTodoNotFound
 	}
 
 	delete(r.todos, id)
+	delete(r.replicas, id)
+	r.reindex(id, stored, nil)
+	r.seq++
+	r.tombstones[id] = r.seq
+	r.pruneTombstones()
+	r.enqueue(domain.EventTodoDeleted, stored)
+	return nil
+}
+
+// enqueue записывает в очередь событие текущего изменения с копией задачи;
+// вызывается под r.mu
+func (r *InMemoryTodoRepository) enqueue(eventType domain.EventType, todo *domain.Todo) {
+	// Удаляем с запасом, чтобы не сдвигать очередь при каждом изменении
+	if len(r.outbox) >= MaxOutbox {
+		r.outbox = append(r.outbox[:0], r.outbox[len(r.outbox)-MaxOutbox*9/10:]...)
+	}
+	r.outbox = append(r.outbox, domain.TodoEvent{ID: r.seq, Type: eventType, Todo: todo.Clone(), Time: time.Now()})
+}
+
+// OutboxEvents возвращает события очереди с ID больше after
+func (r *InMemoryTodoRepository) OutboxEvents(ctx context.Context, after uint64, limit int) (_ []domain.TodoEvent, _ uint64, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.OutboxEvents")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := sort.Search(len(r.outbox), func(i int) bool { return r.outbox[i].ID > after })
+	events := r.outbox[i:]
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return append([]domain.TodoEvent(nil), events...), r.seq, nil
+}
+
+// AckOutbox удаляет из очереди события с ID не больше upTo
+func (r *InMemoryTodoRepository) AckOutbox(ctx context.Context, upTo uint64) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.AckOutbox")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ack(upTo)
 	return nil
 }
 
+// ack удаляет подтвержденные события и сообщает, были ли такие; вызывается
+// под r.mu
+func (r *InMemoryTodoRepository) ack(upTo uint64) bool {
+	i := sort.Search(len(r.outbox), func(i int) bool { return r.outbox[i].ID > upTo })
+	if i == 0 {
+		return false
+	}
+	r.outbox = append(r.outbox[:0], r.outbox[i:]...)
+	return true
+}
+
+// pruneTombstones удаляет самые старые надгробия сверх MaxTombstones,
+// оставляя запас, чтобы не сортировать их при каждом удалении; вызывается
+// под r.mu
+func (r *InMemoryTodoRepository) pruneTombstones() {
+	if len(r.tombstones) <= MaxTombstones {
+		return
+	}
+	seqs := make([]uint64, 0, len(r.tombstones))
+	for _, seq := range r.tombstones {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	cutoff := seqs[len(seqs)-MaxTombstones*9/10-1]
+	for id, seq := range r.tombstones {
+		if seq <= cutoff {
+			delete(r.tombstones, id)
+		}
+	}
+	r.horizon = max(r.horizon, cutoff)
+}
+
+// Changes возвращает последние изменения задач с номером больше since в
+// порядке номеров, не больше limit (0 - без ограничения), и номер последнего
+// изменения. При since = 0 надгробия не возвращаются: клиенту без данных
+// нечего удалять. Токен из будущего (хранилище пересоздано) или старше
+// удаленных надгробий дает ErrSyncTokenExpired.
+func (r *InMemoryTodoRepository) Changes(ctx context.Context, since uint64, limit int) (_ []domain.Change, _ uint64, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Changes")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if since > r.seq || (since != 0 && since < r.horizon) {
+		return nil, 0, domain.ErrSyncTokenExpired
+	}
+
+	var changes []domain.Change
+	for id, todo := range r.todos {
+		if todo.Version > since {
+			changes = append(changes, domain.Change{Seq: todo.Version, ID: id, Todo: todo})
+		}
+	}
+	if since != 0 {
+		for id, seq := range r.tombstones {
+			if seq > since {
+				changes = append(changes, domain.Change{Seq: seq, ID: id, Deleted: true})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Seq < changes[j].Seq })
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, r.seq, nil
+}
+
+// Search ищет задачи по словам названия и описания
+func (r *InMemoryTodoRepository) Search(ctx context.Context, query string, limit int) (_ []domain.SearchHit, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Search")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	q := search.Parse(query)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := r.index.Search(q)
+	total := len(found)
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+
+	hits := make([]domain.SearchHit, len(found))
+	for i, hit := range found {
+		todo := r.todos[hit.ID]
+		hits[i] = domain.SearchHit{Todo: todo, Score: hit.Score}
+		hits[i].Snippets.Title, _ = search.Snippet(todo.Title, q, 0)
+		if snippet, ok := search.Snippet(todo.Description, q, snippetWidth); ok {
+			hits[i].Snippets.Description = snippet
+		}
+	}
+	return hits, total, nil
+}
+
+// todoState - состояние задачи в хранилище для отката неудавшегося
+// изменения
+type todoState struct {
+	todo      *domain.Todo
+	tombstone uint64
+	seq       uint64
+	replicas  []replicaVersion
+}
+
+// state возвращает состояние задачи id
+func (r *InMemoryTodoRepository) state(id int) todoState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return todoState{todo: r.todos[id], tombstone: r.tombstones[id], seq: r.seq, replicas: r.replicas[id]}
+}
+
+// restore возвращает задаче id прежнее состояние и убирает из очереди
+// событие отмененного изменения. Номер журнала не откатывается: пропуск
+// номера клиентам не мешает.
+func (r *InMemoryTodoRepository) restore(id int, s todoState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reindex(id, r.todos[id], s.todo)
+	if s.todo != nil {
+		r.todos[id] = s.todo
+	} else {
+		delete(r.todos, id)
+	}
+	if s.replicas != nil {
+		r.replicas[id] = s.replicas
+	} else {
+		delete(r.replicas, id)
+	}
+	if s.tombstone != 0 {
+		r.tombstones[id] = s.tombstone
+	} else {
+		delete(r.tombstones, id)
+	}
+	for n := len(r.outbox); n > 0 && r.outbox[n-1].ID > s.seq; n-- {
+		r.outbox = r.outbox[:n-1]
+	}
+}
+
 // Exists проверяет существование задачи
 func (r *InMemoryTodoRepository) Exists(ctx context.Context, id int) bool {
+	ctx, span := tracing.Start(ctx, "InMemoryTodoRepository.Exists")
+	defer span.End()
+
+	if ctx.Err() != nil {
+		return false
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
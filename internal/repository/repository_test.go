@@ -1,13 +1,238 @@
 package repository_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"todo/internal/domain"
 	"todo/internal/repository"
+	"todo/internal/repository/repositorytest"
+	"todo/internal/tracing"
 )
 
+func TestInMemoryTodoRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func() domain.TodoRepository {
+		return repository.NewInMemoryTodoRepository()
+	})
+}
+
+func TestFileTodoRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func() domain.TodoRepository {
+		repo, err := repository.NewFileTodoRepository(filepath.Join(t.TempDir(), "todos.json"))
+		if err != nil {
+			t.Fatalf("failed to open repository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestFileTodoRepository_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	ctx := context.Background()
+
+	repo, err := repository.NewFileTodoRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	kept := &domain.Todo{Title: "Kept", Completed: true}
+	deleted := &domain.Todo{Title: "Deleted"}
+	repo.Create(ctx, kept)
+	repo.Create(ctx, deleted)
+	repo.Delete(ctx, deleted.ID)
+
+	reopened, err := repository.NewFileTodoRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	todos, _ := reopened.GetAll(ctx)
+	if len(todos) != 1 || todos[0].Title != "Kept" || !todos[0].Completed {
+		t.Fatalf("expected only the kept todo after reopen, got %+v", todos)
+	}
+
+	next := &domain.Todo{Title: "Next"}
+	reopened.Create(ctx, next)
+	if next.ID <= deleted.ID {
+		t.Errorf("expected IDs not to be reused after reopen, got %d", next.ID)
+	}
+
+	// Журнал изменений переживает перезапуск
+	changes, _, err := reopened.Changes(ctx, kept.Version, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 2 || changes[0].ID != deleted.ID || !changes[0].Deleted || changes[1].ID != next.ID {
+		t.Errorf("expected the tombstone of deleted and next after reopen, got %+v", changes)
+	}
+
+	// Поисковый индекс восстанавливается из файла
+	hits, _, err := reopened.Search(ctx, "kept", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Todo.ID != kept.ID {
+		t.Errorf("expected the kept todo to be searchable after reopen, got %+v", hits)
+	}
+
+	// Недоставленные события вебхуков тоже
+	events, _, err := reopened.OutboxEvents(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 4 || events[2].Type != domain.EventTodoDeleted || events[2].Todo.ID != deleted.ID {
+		t.Errorf("expected the outbox to survive reopen, got %+v", events)
+	}
+
+	// И история реплик: правка поверх версии до перезапуска сливается
+	reopened.Update(ctx, &domain.Todo{ID: kept.ID, Title: "Kept", Description: "after reopen", Completed: true})
+	merged, _, err := reopened.Merge(ctx, kept.ID, kept.Version, &domain.Todo{Title: "Kept offline", Completed: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged.Title != "Kept offline" || merged.Description != "after reopen" {
+		t.Errorf("expected both edits to survive reopen, got %+v", merged)
+	}
+}
+
+func TestFileTodoRepository_Ranks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todos.json")
+	ctx := context.Background()
+
+	// Файл прежнего формата без рангов: ранги назначаются по возрастанию ID
+	legacy := `{"next_id": 4, "todos": [
+		{"id": 3, "title": "C"},
+		{"id": 1, "title": "A"},
+		{"id": 2, "title": "B"}
+	]}`
+	if err := os.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := repository.NewFileTodoRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	titles := func(repo domain.TodoRepository) string {
+		todos, _ := repo.GetAll(ctx)
+		var b strings.Builder
+		for _, todo := range todos {
+			b.WriteString(todo.Title)
+		}
+		return b.String()
+	}
+	if got := titles(repo); got != "ABC" {
+		t.Fatalf("expected ABC, got %s", got)
+	}
+
+	// Перестановка сохраняется в файл
+	if _, _, err := repo.Move(ctx, 3, domain.Anchor{Before: 1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reopened, err := repository.NewFileTodoRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := titles(reopened); got != "CAB" {
+		t.Errorf("expected CAB after reopen, got %s", got)
+	}
+
+	// Новая задача встает в конец, а не между прежними
+	reopened.Create(ctx, &domain.Todo{Title: "D"})
+	if got := titles(reopened); got != "CABD" {
+		t.Errorf("expected CABD, got %s", got)
+	}
+}
+
+func TestInMemoryTodoRepository_TombstoneHorizon(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryTodoRepository()
+
+	first := &domain.Todo{Title: "first"}
+	repo.Create(ctx, first)
+	for i := 0; i < repository.MaxTombstones+1; i++ {
+		todo := &domain.Todo{Title: "t"}
+		repo.Create(ctx, todo)
+		repo.Delete(ctx, todo.ID)
+	}
+
+	if _, _, err := repo.Changes(ctx, first.Version, 0); !errors.Is(err, domain.ErrSyncTokenExpired) {
+		t.Errorf("token older than pruned tombstones must expire, got %v", err)
+	}
+	changes, latest, err := repo.Changes(ctx, 0, 0)
+	if err != nil || len(changes) != 1 {
+		t.Fatalf("full sync must still work, got %d changes, %v", len(changes), err)
+	}
+	if _, _, err := repo.Changes(ctx, latest-1, 0); err != nil {
+		t.Errorf("recent token must be accepted, got %v", err)
+	}
+}
+
+func TestInMemoryTodoRepository_ReplicaHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryTodoRepository()
+
+	todo := &domain.Todo{Title: "v0"}
+	repo.Create(ctx, todo)
+	versions := []uint64{todo.Version}
+	for i := 1; i <= repository.MaxReplicaHistory; i++ {
+		edit := &domain.Todo{ID: todo.ID, Title: fmt.Sprintf("v%d", i)}
+		repo.Update(ctx, edit)
+		versions = append(versions, edit.Version)
+	}
+
+	if _, _, err := repo.Merge(ctx, todo.ID, versions[0], &domain.Todo{Title: "offline"}); !errors.Is(err, domain.ErrVersionUnavailable) {
+		t.Errorf("version older than the history must not be merged, got %v", err)
+	}
+	if _, _, err := repo.Merge(ctx, todo.ID, versions[1], &domain.Todo{Title: "offline"}); err != nil {
+		t.Errorf("oldest kept version must be merged, got %v", err)
+	}
+}
+
+func TestInMemoryTodoRepository_SpanErrors(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := tracing.NewTracer(tracing.AlwaysSample(), tracing.NewWriterExporter(&buf, "test"))
+	ctx, root := tracer.Start(context.Background(), "root")
+
+	repo := repository.NewInMemoryTodoRepository()
+	if _, err := repo.GetByID(ctx, 42); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Fatalf("expected ErrTodoNotFound, got %v", err)
+	}
+	root.End()
+	tracer.Shutdown(context.Background())
+
+	// Ошибка хранилища записывается в его собственный спан
+	var out struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					Name   string `json:"name"`
+					Status struct {
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("unexpected export %q: %v", buf.String(), err)
+	}
+	for _, span := range out.ResourceSpans[0].ScopeSpans[0].Spans {
+		if span.Name == "InMemoryTodoRepository.GetByID" {
+			if span.Status.Message != domain.ErrTodoNotFound.Error() {
+				t.Errorf("expected the span to record %q, got %+v", domain.ErrTodoNotFound, span.Status)
+			}
+			return
+		}
+	}
+	t.Errorf("expected a repository span in %s", buf.String())
+}
+
 func TestInMemoryTodoRepository_Create(t *testing.T) {
 	repo := repository.NewInMemoryTodoRepository()
 	ctx := context.Background()
@@ -0,0 +1,986 @@
+// Package repositorytest содержит набор тестов соответствия для реализаций
+// domain.TodoRepository. Любое новое хранилище проверяется одной строкой:
+//
+//	func TestMyRepository(t *testing.T) {
+//		repositorytest.Run(t, func() domain.TodoRepository { return NewMyRepository() })
+//	}
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+)
+
+// Factory создает новый пустой экземпляр проверяемого репозитория.
+// Вызывается отдельно для каждого подтеста.
+type Factory func() domain.TodoRepository
+
+// Run прогоняет все тесты соответствия для репозитория, созданного factory
+func Run(t *testing.T, factory Factory) {
+	t.Helper()
+
+	t.Run("Create", func(t *testing.T) { testCreate(t, factory) })
+	t.Run("GetAll", func(t *testing.T) { testGetAll(t, factory) })
+	t.Run("GetByID", func(t *testing.T) { testGetByID(t, factory) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, factory) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, factory) })
+	t.Run("Exists", func(t *testing.T) { testExists(t, factory) })
+	t.Run("IDAllocation", func(t *testing.T) { testIDAllocation(t, factory) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, factory) })
+	t.Run("ContextCancellation", func(t *testing.T) { testContextCancellation(t, factory) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, factory) })
+	t.Run("Changes", func(t *testing.T) { testChanges(t, factory) })
+	t.Run("Outbox", func(t *testing.T) { testOutbox(t, factory) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, factory) })
+	t.Run("Find", func(t *testing.T) { testFind(t, factory) })
+	t.Run("Move", func(t *testing.T) { testMove(t, factory) })
+	t.Run("Merge", func(t *testing.T) { testMerge(t, factory) })
+}
+
+func testCreate(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("создание задачи без ID", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{Title: "Test Todo", Description: "Test Description"}
+
+		if err := repo.Create(ctx, todo); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if todo.ID <= 0 {
+			t.Errorf("expected positive ID to be assigned, got %d", todo.ID)
+		}
+	})
+
+	t.Run("создание задачи с ID", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{ID: 100, Title: "Test Todo with ID"}
+
+		if err := repo.Create(ctx, todo); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if todo.ID != 100 {
+			t.Errorf("expected ID to be 100, got %d", todo.ID)
+		}
+	})
+
+	t.Run("создание задачи с дублирующим ID", func(t *testing.T) {
+		repo := factory()
+		mustCreate(t, repo, &domain.Todo{ID: 200, Title: "First Todo"})
+
+		err := repo.Create(ctx, &domain.Todo{ID: 200, Title: "Second Todo"})
+		if !errors.Is(err, domain.ErrTodoAlreadyExists) {
+			t.Fatalf("expected ErrTodoAlreadyExists, got %v", err)
+		}
+
+		stored, err := repo.GetByID(ctx, 200)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stored.Title != "First Todo" {
+			t.Errorf("duplicate create must not overwrite, got title %q", stored.Title)
+		}
+	})
+
+	t.Run("сохранение всех полей", func(t *testing.T) {
+		repo := factory()
+		due := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)
+		todo := &domain.Todo{Title: "Title", Description: "Description", Completed: true, DueDate: &due}
+		mustCreate(t, repo, todo)
+
+		stored, err := repo.GetByID(ctx, todo.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assertTodoEqual(t, todo, stored)
+	})
+}
+
+func testGetAll(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("получение пустого списка", func(t *testing.T) {
+		repo := factory()
+
+		todos, err := repo.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(todos) != 0 {
+			t.Errorf("expected empty list, got %d items", len(todos))
+		}
+	})
+
+	t.Run("получение списка с задачами", func(t *testing.T) {
+		repo := factory()
+		created := map[int]string{}
+		for _, title := range []string{"Todo 1", "Todo 2", "Todo 3"} {
+			todo := &domain.Todo{Title: title}
+			mustCreate(t, repo, todo)
+			created[todo.ID] = title
+		}
+
+		todos, err := repo.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(todos) != len(created) {
+			t.Fatalf("expected %d todos, got %d", len(created), len(todos))
+		}
+		for _, todo := range todos {
+			if created[todo.ID] != todo.Title {
+				t.Errorf("unexpected todo %d with title %q", todo.ID, todo.Title)
+			}
+		}
+	})
+
+	t.Run("удаленные задачи не возвращаются", func(t *testing.T) {
+		repo := factory()
+		keep := &domain.Todo{Title: "Keep"}
+		drop := &domain.Todo{Title: "Drop"}
+		mustCreate(t, repo, keep)
+		mustCreate(t, repo, drop)
+
+		if err := repo.Delete(ctx, drop.ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		todos, err := repo.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(todos) != 1 || todos[0].ID != keep.ID {
+			t.Errorf("expected only todo %d, got %v", keep.ID, todos)
+		}
+	})
+}
+
+func testGetByID(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("получение существующей задачи", func(t *testing.T) {
+		repo := factory()
+		original := &domain.Todo{Title: "Test Todo", Description: "Description"}
+		mustCreate(t, repo, original)
+
+		retrieved, err := repo.GetByID(ctx, original.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assertTodoEqual(t, original, retrieved)
+	})
+
+	t.Run("получение несуществующей задачи", func(t *testing.T) {
+		repo := factory()
+
+		_, err := repo.GetByID(ctx, 9999)
+		if !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+	})
+}
+
+func testUpdate(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("обновление существующей задачи", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{Title: "Original"}
+		mustCreate(t, repo, todo)
+
+		changed := &domain.Todo{ID: todo.ID, Title: "Updated", Description: "New", Completed: true}
+		if err := repo.Update(ctx, changed); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		updated, err := repo.GetByID(ctx, todo.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		assertTodoEqual(t, changed, updated)
+	})
+
+	t.Run("обновление несуществующей задачи", func(t *testing.T) {
+		repo := factory()
+
+		err := repo.Update(ctx, &domain.Todo{ID: 9999, Title: "Non-existent"})
+		if !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Fatalf("expected ErrTodoNotFound, got %v", err)
+		}
+		if repo.Exists(ctx, 9999) {
+			t.Error("update of missing todo must not create it")
+		}
+	})
+}
+
+func testDelete(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("удаление существующей задачи", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{Title: "To Delete"}
+		mustCreate(t, repo, todo)
+
+		if err := repo.Delete(ctx, todo.ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err := repo.GetByID(ctx, todo.ID)
+		if !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("todo was not deleted, got %v", err)
+		}
+	})
+
+	t.Run("повторное удаление", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{Title: "To Delete"}
+		mustCreate(t, repo, todo)
+
+		if err := repo.Delete(ctx, todo.ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := repo.Delete(ctx, todo.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+	})
+
+	t.Run("удаление несуществующей задачи", func(t *testing.T) {
+		repo := factory()
+
+		if err := repo.Delete(ctx, 9999); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+	})
+}
+
+func testExists(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory()
+
+	todo := &domain.Todo{Title: "Test"}
+	mustCreate(t, repo, todo)
+
+	if !repo.Exists(ctx, todo.ID) {
+		t.Error("expected todo to exist")
+	}
+	if repo.Exists(ctx, 9999) {
+		t.Error("expected todo not to exist")
+	}
+
+	if err := repo.Delete(ctx, todo.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.Exists(ctx, todo.ID) {
+		t.Error("expected deleted todo not to exist")
+	}
+}
+
+func testIDAllocation(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("уникальные последовательные ID", func(t *testing.T) {
+		repo := factory()
+		seen := map[int]bool{}
+		for i := 0; i < 10; i++ {
+			todo := &domain.Todo{Title: "Todo"}
+			mustCreate(t, repo, todo)
+			if seen[todo.ID] {
+				t.Fatalf("ID %d allocated twice", todo.ID)
+			}
+			seen[todo.ID] = true
+		}
+	})
+
+	t.Run("ID после явно заданного", func(t *testing.T) {
+		repo := factory()
+		mustCreate(t, repo, &domain.Todo{ID: 50, Title: "Explicit"})
+
+		todo := &domain.Todo{Title: "Generated"}
+		mustCreate(t, repo, todo)
+		if todo.ID <= 50 {
+			t.Errorf("expected generated ID greater than 50, got %d", todo.ID)
+		}
+	})
+
+	t.Run("ID не переиспользуются после удаления", func(t *testing.T) {
+		repo := factory()
+		first := &domain.Todo{Title: "First"}
+		mustCreate(t, repo, first)
+		if err := repo.Delete(ctx, first.ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		second := &domain.Todo{Title: "Second"}
+		mustCreate(t, repo, second)
+		if second.ID == first.ID {
+			t.Errorf("ID %d was reused after delete", first.ID)
+		}
+	})
+}
+
+func testConcurrency(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory()
+
+	const workers = 20
+	const perWorker = 10
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = map[int]bool{}
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				todo := &domain.Todo{Title: "Concurrent"}
+				if err := repo.Create(ctx, todo); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+
+				mu.Lock()
+				if ids[todo.ID] {
+					t.Errorf("ID %d allocated twice", todo.ID)
+				}
+				ids[todo.ID] = true
+				mu.Unlock()
+
+				repo.GetAll(ctx)
+				repo.Exists(ctx, todo.ID)
+				if err := repo.Update(ctx, &domain.Todo{ID: todo.ID, Title: "Updated"}); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	todos, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(todos) != workers*perWorker {
+		t.Errorf("expected %d todos, got %d", workers*perWorker, len(todos))
+	}
+}
+
+func testContextCancellation(t *testing.T, factory Factory) {
+	repo := factory()
+	existing := &domain.Todo{Title: "Existing"}
+	mustCreate(t, repo, existing)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	operations := map[string]func() error{
+		"Create": func() error { return repo.Create(ctx, &domain.Todo{Title: "Canceled"}) },
+		"GetAll": func() error { _, err := repo.GetAll(ctx); return err },
+		"GetByID": func() error {
+			_, err := repo.GetByID(ctx, existing.ID)
+			return err
+		},
+		"Update": func() error {
+			return repo.Update(ctx, &domain.Todo{ID: existing.ID, Title: "Canceled"})
+		},
+		"Delete": func() error { return repo.Delete(ctx, existing.ID) },
+	}
+
+	for name, op := range operations {
+		t.Run(name, func(t *testing.T) {
+			err := op()
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+			if !errors.Is(err, domain.ErrCanceled) {
+				t.Errorf("expected domain.ErrCanceled, got %v", err)
+			}
+		})
+	}
+
+	t.Run("истекший дедлайн", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := repo.GetByID(ctx, existing.ID)
+		if !errors.Is(err, domain.ErrDeadlineExceeded) {
+			t.Errorf("expected domain.ErrDeadlineExceeded, got %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("отмененные операции не меняют данные", func(t *testing.T) {
+		todos, err := repo.GetAll(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(todos) != 1 {
+			t.Fatalf("expected 1 todo, got %d", len(todos))
+		}
+		assertTodoEqual(t, existing, todos[0])
+	})
+}
+
+func testVersions(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("версия растет с каждым изменением", func(t *testing.T) {
+		repo := factory()
+		a := &domain.Todo{Title: "A"}
+		b := &domain.Todo{Title: "B"}
+		mustCreate(t, repo, a)
+		mustCreate(t, repo, b)
+		if a.Version == 0 || b.Version <= a.Version {
+			t.Fatalf("expected increasing versions, got %d and %d", a.Version, b.Version)
+		}
+
+		changed := &domain.Todo{ID: a.ID, Title: "A2"}
+		if err := repo.Update(ctx, changed); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if changed.Version <= b.Version {
+			t.Errorf("update must get the next version, got %d after %d", changed.Version, b.Version)
+		}
+	})
+}
+
+func testChanges(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory()
+
+	a := &domain.Todo{Title: "A"}
+	b := &domain.Todo{Title: "B"}
+	c := &domain.Todo{Title: "C"}
+	mustCreate(t, repo, a)
+	mustCreate(t, repo, b)
+	mustCreate(t, repo, c)
+
+	_, token, err := repo.Changes(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	repo.Update(ctx, &domain.Todo{ID: a.ID, Title: "A1"})
+	repo.Update(ctx, &domain.Todo{ID: a.ID, Title: "A2"})
+	repo.Delete(ctx, b.ID)
+
+	t.Run("полная выгрузка без надгробий", func(t *testing.T) {
+		changes, _, err := repo.Changes(ctx, 0, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(changes) != 2 || changes[0].ID != c.ID || changes[1].ID != a.ID {
+			t.Fatalf("expected c and a in sequence order, got %+v", changes)
+		}
+	})
+
+	t.Run("изменения после токена", func(t *testing.T) {
+		changes, latest, err := repo.Changes(ctx, token, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(changes) != 2 {
+			t.Fatalf("expected the latest change of a and the tombstone of b, got %+v", changes)
+		}
+		if changes[0].ID != a.ID || changes[0].Deleted || changes[0].Todo.Title != "A2" {
+			t.Errorf("expected a at its latest state, got %+v", changes[0])
+		}
+		if changes[1].ID != b.ID || !changes[1].Deleted || changes[1].Todo != nil {
+			t.Errorf("expected a tombstone for b, got %+v", changes[1])
+		}
+		if changes[0].Seq >= changes[1].Seq || latest != changes[1].Seq {
+			t.Errorf("expected increasing sequence ending at %d, got %+v", latest, changes)
+		}
+
+		none, again, _ := repo.Changes(ctx, latest, 0)
+		if len(none) != 0 || again != latest {
+			t.Errorf("expected no changes after the latest token, got %+v", none)
+		}
+	})
+
+	t.Run("ограничение числа изменений", func(t *testing.T) {
+		changes, _, _ := repo.Changes(ctx, token, 1)
+		if len(changes) != 1 || changes[0].ID != a.ID {
+			t.Fatalf("expected only the first change, got %+v", changes)
+		}
+	})
+
+	t.Run("токен из будущего", func(t *testing.T) {
+		_, latest, _ := repo.Changes(ctx, 0, 0)
+		if _, _, err := repo.Changes(ctx, latest+1, 0); !errors.Is(err, domain.ErrSyncTokenExpired) {
+			t.Errorf("expected ErrSyncTokenExpired, got %v", err)
+		}
+	})
+}
+
+func testOutbox(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory()
+
+	todo := &domain.Todo{Title: "A"}
+	mustCreate(t, repo, todo)
+	repo.Update(ctx, &domain.Todo{ID: todo.ID, Title: "A1"})
+	repo.Delete(ctx, todo.ID)
+	// Неудачные изменения событий не порождают
+	repo.Update(ctx, &domain.Todo{ID: todo.ID, Title: "A2"})
+	repo.Delete(ctx, todo.ID)
+
+	t.Run("события изменений по порядку", func(t *testing.T) {
+		events, head, err := repo.OutboxEvents(ctx, 0, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []domain.EventType{domain.EventTodoCreated, domain.EventTodoUpdated, domain.EventTodoDeleted}
+		if len(events) != len(want) {
+			t.Fatalf("expected %d events, got %+v", len(want), events)
+		}
+		for i, event := range events {
+			if event.Type != want[i] || event.Todo == nil || event.Todo.ID != todo.ID {
+				t.Errorf("event %d: expected %s of todo %d, got %+v", i, want[i], todo.ID, event)
+			}
+			if i > 0 && event.ID <= events[i-1].ID {
+				t.Errorf("expected increasing event IDs, got %+v", events)
+			}
+		}
+		if head != events[2].ID {
+			t.Errorf("expected head %d, got %d", events[2].ID, head)
+		}
+		if events[1].Todo.Title != "A1" {
+			t.Errorf("expected the update event to carry the new state, got %q", events[1].Todo.Title)
+		}
+	})
+
+	t.Run("ограничение и продолжение", func(t *testing.T) {
+		first, _, _ := repo.OutboxEvents(ctx, 0, 1)
+		if len(first) != 1 {
+			t.Fatalf("expected one event, got %+v", first)
+		}
+		rest, _, _ := repo.OutboxEvents(ctx, first[0].ID, 0)
+		if len(rest) != 2 || rest[0].Type != domain.EventTodoUpdated {
+			t.Fatalf("expected the events after the first one, got %+v", rest)
+		}
+	})
+
+	t.Run("подтверждение удаляет события", func(t *testing.T) {
+		events, head, _ := repo.OutboxEvents(ctx, 0, 0)
+		if err := repo.AckOutbox(ctx, events[1].ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		left, again, _ := repo.OutboxEvents(ctx, 0, 0)
+		if len(left) != 1 || left[0].ID != events[2].ID {
+			t.Fatalf("expected only the last event, got %+v", left)
+		}
+		if again != head {
+			t.Errorf("expected head to stay %d after ack, got %d", head, again)
+		}
+
+		repo.AckOutbox(ctx, head)
+		if empty, _, _ := repo.OutboxEvents(ctx, 0, 0); len(empty) != 0 {
+			t.Errorf("expected an empty outbox, got %+v", empty)
+		}
+	})
+}
+
+func testSearch(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory()
+
+	milk := &domain.Todo{Title: "Купить молоко", Description: "Обезжиренное, в магазине у дома"}
+	call := &domain.Todo{Title: "Позвонить маме", Description: "Спросить, нужно ли молоко"}
+	report := &domain.Todo{Title: "Prepare the quarterly report"}
+	mustCreate(t, repo, milk)
+	mustCreate(t, repo, call)
+	mustCreate(t, repo, report)
+
+	t.Run("ранжирование и фрагменты", func(t *testing.T) {
+		hits, total, err := repo.Search(ctx, "молока", 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if total != 2 || len(hits) != 2 || hits[0].Todo.ID != milk.ID || hits[1].Todo.ID != call.ID {
+			t.Fatalf("expected milk before call, got %d %+v", total, hits)
+		}
+		if hits[0].Score <= hits[1].Score {
+			t.Errorf("expected descending scores, got %v and %v", hits[0].Score, hits[1].Score)
+		}
+		if hits[0].Snippets.Title != "Купить <mark>молоко</mark>" || hits[0].Snippets.Description != "" {
+			t.Errorf("unexpected snippets %+v", hits[0].Snippets)
+		}
+		if hits[1].Snippets.Title != "Позвонить маме" || hits[1].Snippets.Description != "Спросить, нужно ли <mark>молоко</mark>" {
+			t.Errorf("unexpected snippets %+v", hits[1].Snippets)
+		}
+	})
+
+	t.Run("ограничение числа результатов", func(t *testing.T) {
+		hits, total, _ := repo.Search(ctx, "молоко", 1)
+		if total != 2 || len(hits) != 1 || hits[0].Todo.ID != milk.ID {
+			t.Errorf("expected the best of 2 hits, got %d %+v", total, hits)
+		}
+	})
+
+	t.Run("индекс следует за изменениями", func(t *testing.T) {
+		if err := repo.Update(ctx, &domain.Todo{ID: report.ID, Title: "Отчет за квартал"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := repo.Delete(ctx, call.ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if hits, _, _ := repo.Search(ctx, "report", 0); len(hits) != 0 {
+			t.Errorf("expected the old title not to match, got %+v", hits)
+		}
+		if hits, _, _ := repo.Search(ctx, "отчеты", 0); len(hits) != 1 || hits[0].Todo.ID != report.ID {
+			t.Errorf("expected the new title to match, got %+v", hits)
+		}
+		if hits, _, _ := repo.Search(ctx, "молоко", 0); len(hits) != 1 || hits[0].Todo.ID != milk.ID {
+			t.Errorf("expected the deleted todo not to match, got %+v", hits)
+		}
+	})
+}
+
+func testFind(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory()
+
+	now := time.Now()
+	soon, past := now.Add(48*time.Hour), now.Add(-48*time.Hour)
+	todos := []*domain.Todo{
+		{Title: "Купить молоко", Project: "Home", Tags: []string{"urgent"}, DueDate: &past},
+		{Title: "Написать отчет", Project: "work", DueDate: &soon},
+		{Title: "Позвонить маме", Project: "personal", Tags: []string{"Urgent", "family"}},
+		{Title: "Прочитать книгу", Project: "home", Completed: true},
+	}
+	for _, todo := range todos {
+		mustCreate(t, repo, todo)
+	}
+
+	find := func(t *testing.T, query string) []int {
+		t.Helper()
+		expr, err := domain.ParseQuery(query, now)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", query, err)
+		}
+		found, err := repo.Find(ctx, expr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ids := make([]int, len(found))
+		for i, todo := range found {
+			ids[i] = todo.ID
+		}
+		return ids
+	}
+	ids := func(indexes ...int) []int {
+		out := make([]int, len(indexes))
+		for i, n := range indexes {
+			out[i] = todos[n].ID
+		}
+		return out
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"project:home", ids(0, 3)},
+		{"tag:URGENT", ids(0, 2)},
+		{"status:done", ids(3)},
+		{"status:open AND (tag:urgent OR due<7d) AND NOT project:personal", ids(0, 1)},
+		{"tag:urgent OR status:done", ids(0, 2, 3)},
+		{"project:home молоко", ids(0)},
+		{"due:none", ids(2, 3)},
+		{"NOT project:work", ids(0, 2, 3)},
+		{"project:nothing", ids()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			if got := find(t, tc.query); !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("индексы следуют за изменениями", func(t *testing.T) {
+		if err := repo.Update(ctx, &domain.Todo{ID: todos[1].ID, Title: todos[1].Title, Project: "Home", Completed: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := repo.Delete(ctx, todos[0].ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := find(t, "project:home"); !slices.Equal(got, ids(1, 3)) {
+			t.Errorf("expected the moved todo, got %v", got)
+		}
+		if got := find(t, "project:work"); len(got) != 0 {
+			t.Errorf("expected the old project not to match, got %v", got)
+		}
+		if got := find(t, "status:done"); !slices.Equal(got, ids(1, 3)) {
+			t.Errorf("expected completed todos, got %v", got)
+		}
+		if got := find(t, "tag:urgent"); !slices.Equal(got, ids(2)) {
+			t.Errorf("expected the deleted todo not to match, got %v", got)
+		}
+	})
+}
+
+func testMove(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (domain.TodoRepository, []*domain.Todo) {
+		repo := factory()
+		todos := []*domain.Todo{
+			{Title: "A", Project: "work"},
+			{Title: "B", Project: "Work"},
+			{Title: "C", Project: "work"},
+			{Title: "D", Project: "work"},
+			{Title: "X", Project: "home"},
+		}
+		for _, todo := range todos {
+			mustCreate(t, repo, todo)
+		}
+		return repo, todos
+	}
+	// order возвращает названия задач проекта work в порядке GetAll
+	order := func(t *testing.T, repo domain.TodoRepository) string {
+		t.Helper()
+		all, err := repo.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var titles []string
+		for _, todo := range all {
+			if !domain.ValidRank(todo.Rank) {
+				t.Errorf("todo %d has invalid rank %q", todo.ID, todo.Rank)
+			}
+			if strings.EqualFold(todo.Project, "work") {
+				titles = append(titles, todo.Title)
+			}
+		}
+		return strings.Join(titles, "")
+	}
+
+	t.Run("новые задачи в конце списка", func(t *testing.T) {
+		repo, _ := setup(t)
+		if got := order(t, repo); got != "ABCD" {
+			t.Errorf("expected ABCD, got %s", got)
+		}
+	})
+
+	t.Run("перед и после соседа", func(t *testing.T) {
+		repo, todos := setup(t)
+		a, c, d := todos[0], todos[2], todos[3]
+
+		moved, changed, err := repo.Move(ctx, d.ID, domain.Anchor{Before: a.ID})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(changed) != 1 || changed[0].ID != d.ID || moved.Rank >= a.Rank || moved.Version <= d.Version {
+			t.Errorf("expected only D to change, got %+v %+v", moved, changed)
+		}
+		if got := order(t, repo); got != "DABC" {
+			t.Errorf("expected DABC, got %s", got)
+		}
+
+		if _, _, err := repo.Move(ctx, a.ID, domain.Anchor{After: c.ID}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := order(t, repo); got != "DBCA" {
+			t.Errorf("expected DBCA, got %s", got)
+		}
+		if stored, _ := repo.GetByID(ctx, a.ID); stored.Title != "A" || stored.Project != "work" {
+			t.Errorf("expected other fields to stay intact, got %+v", stored)
+		}
+	})
+
+	t.Run("задача уже на месте", func(t *testing.T) {
+		repo, todos := setup(t)
+		moved, changed, err := repo.Move(ctx, todos[1].ID, domain.Anchor{After: todos[0].ID})
+		if err != nil || len(changed) != 0 || moved.Version != todos[1].Version {
+			t.Errorf("expected no changes, got %+v %+v %v", moved, changed, err)
+		}
+	})
+
+	t.Run("ошибки", func(t *testing.T) {
+		repo, todos := setup(t)
+		a, x := todos[0], todos[4]
+		tests := []struct {
+			name   string
+			id     int
+			anchor domain.Anchor
+			want   error
+		}{
+			{"нет задачи", 999, domain.Anchor{Before: a.ID}, domain.ErrTodoNotFound},
+			{"рядом с собой", a.ID, domain.Anchor{After: a.ID}, domain.ErrInvalidMove},
+			{"нет соседа", a.ID, domain.Anchor{Before: 999}, domain.ErrInvalidMove},
+			{"сосед в другом проекте", a.ID, domain.Anchor{Before: x.ID}, domain.ErrInvalidMove},
+		}
+		for _, tc := range tests {
+			if _, _, err := repo.Move(ctx, tc.id, tc.anchor); !errors.Is(err, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+			}
+		}
+		if got := order(t, repo); got != "ABCD" {
+			t.Errorf("expected the order to stay ABCD, got %s", got)
+		}
+	})
+
+	t.Run("смена проекта ставит задачу в конец", func(t *testing.T) {
+		repo, todos := setup(t)
+		x := todos[4].Clone()
+		x.Project = "WORK"
+		if err := repo.Update(ctx, x); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := order(t, repo); got != "ABCDX" {
+			t.Errorf("expected ABCDX, got %s", got)
+		}
+	})
+
+	t.Run("перераспределение рангов", func(t *testing.T) {
+		repo, todos := setup(t)
+		a, b, c, d := todos[0], todos[1], todos[2], todos[3]
+
+		// C и D по очереди встают сразу после A: промежуток сужается, пока
+		// ранги не перераспределятся
+		rebalanced := false
+		for i := 0; i < 1000 && !rebalanced; i++ {
+			id := c.ID
+			if i%2 == 1 {
+				id = d.ID
+			}
+			moved, changed, err := repo.Move(ctx, id, domain.Anchor{After: a.ID})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(moved.Rank) > domain.MaxRankLength {
+				t.Fatalf("rank %q is longer than %d", moved.Rank, domain.MaxRankLength)
+			}
+			rebalanced = len(changed) > 1
+		}
+		if !rebalanced {
+			t.Fatal("expected ranks to be rebalanced")
+		}
+		if got := order(t, repo); got != "ACDB" && got != "ADCB" {
+			t.Errorf("expected A, then C and D, then B, got %s", got)
+		}
+		if _, _, err := repo.Move(ctx, b.ID, domain.Anchor{Before: a.ID}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := order(t, repo); got[0] != 'B' {
+			t.Errorf("expected B first after rebalancing, got %s", got)
+		}
+	})
+}
+
+func testMerge(t *testing.T, factory Factory) {
+	ctx := context.Background()
+
+	t.Run("правки разных полей сохраняются", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{Title: "A", Tags: []string{"x"}}
+		mustCreate(t, repo, todo)
+		base := todo.Version
+		concurrent := &domain.Todo{ID: todo.ID, Title: "A", Description: "elsewhere", Tags: []string{"x"}}
+		if err := repo.Update(ctx, concurrent); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		merged, isConcurrent, err := repo.Merge(ctx, todo.ID, base, &domain.Todo{Title: "B", Tags: []string{"x", "y"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !isConcurrent || merged.Version <= concurrent.Version {
+			t.Errorf("expected a concurrent merge with a new version, got %v %d", isConcurrent, merged.Version)
+		}
+		if merged.Title != "B" || merged.Description != "elsewhere" || !slices.Equal(merged.Tags, []string{"x", "y"}) {
+			t.Errorf("expected both edits to survive, got %+v", merged)
+		}
+		stored, _ := repo.GetByID(ctx, todo.ID)
+		assertTodoEqual(t, merged, stored)
+	})
+
+	t.Run("слияние без параллельных правок", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{Title: "A", Project: "work"}
+		mustCreate(t, repo, todo)
+
+		merged, isConcurrent, err := repo.Merge(ctx, todo.ID, todo.Version, &domain.Todo{Title: "A", Completed: true, Project: "work"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if isConcurrent || !merged.Completed || merged.Rank != todo.Rank {
+			t.Errorf("expected the edit to be applied in place, got %v %+v", isConcurrent, merged)
+		}
+	})
+
+	t.Run("неизвестная версия", func(t *testing.T) {
+		repo := factory()
+		todo := &domain.Todo{Title: "A"}
+		mustCreate(t, repo, todo)
+
+		if _, _, err := repo.Merge(ctx, todo.ID, todo.Version+100, &domain.Todo{Title: "B"}); !errors.Is(err, domain.ErrVersionUnavailable) {
+			t.Errorf("expected ErrVersionUnavailable, got %v", err)
+		}
+		if _, _, err := repo.Merge(ctx, todo.ID+1, todo.Version, &domain.Todo{Title: "B"}); !errors.Is(err, domain.ErrTodoNotFound) {
+			t.Errorf("expected ErrTodoNotFound, got %v", err)
+		}
+	})
+
+	t.Run("результат не проходит валидацию", func(t *testing.T) {
+		repo := factory()
+		tags := make([]string, domain.MaxTags)
+		for i := range tags {
+			tags[i] = string(rune('a' + i))
+		}
+		todo := &domain.Todo{Title: "A", Tags: tags[:domain.MaxTags-1]}
+		mustCreate(t, repo, todo)
+		base := todo.Version
+		repo.Update(ctx, &domain.Todo{ID: todo.ID, Title: "A", Tags: append(tags[:domain.MaxTags-1:domain.MaxTags-1], "elsewhere")})
+
+		_, _, err := repo.Merge(ctx, todo.ID, base, &domain.Todo{Title: "A", Tags: append(tags[:domain.MaxTags-1:domain.MaxTags-1], "offline")})
+		var verr *domain.ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected a validation error for too many merged tags, got %v", err)
+		}
+		if stored, _ := repo.GetByID(ctx, todo.ID); stored.HasTag("offline") {
+			t.Errorf("invalid merge must not be applied, got %+v", stored)
+		}
+	})
+}
+
+func mustCreate(t *testing.T, repo domain.TodoRepository, todo *domain.Todo) {
+	t.Helper()
+	if err := repo.Create(context.Background(), todo); err != nil {
+		t.Fatalf("failed to create todo: %v", err)
+	}
+}
+
+func assertTodoEqual(t *testing.T, want, got *domain.Todo) {
+	t.Helper()
+	if got == nil {
+		t.Fatal("expected todo, got nil")
+	}
+	if got.ID != want.ID || got.Title != want.Title ||
+		got.Description != want.Description || got.Completed != want.Completed ||
+		!equalTime(got.DueDate, want.DueDate) {
+		t.Errorf("expected %+v, got %+v", *want, *got)
+	}
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
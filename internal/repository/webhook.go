@@ -0,0 +1,331 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+
+	"todo/internal/domain"
+	"todo/internal/tracing"
+)
+
+// InMemoryWebhookRepository хранит подписки на вебхуки в памяти. Подписки
+// возвращаются копиями: их меняют и отправитель, и обработчики запросов.
+type InMemoryWebhookRepository struct {
+	mu         sync.RWMutex
+	hooks      map[int]*domain.Webhook
+	deliveries map[int][]domain.WebhookDelivery // от старых к новым
+	nextID     int
+}
+
+// NewInMemoryWebhookRepository создает пустое хранилище подписок
+func NewInMemoryWebhookRepository() *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{
+		hooks:      make(map[int]*domain.Webhook),
+		deliveries: make(map[int][]domain.WebhookDelivery),
+		nextID:     1,
+	}
+}
+
+// CreateWebhook сохраняет новую подписку и назначает ей ID
+func (r *InMemoryWebhookRepository) CreateWebhook(ctx context.Context, hook *domain.Webhook) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.CreateWebhook")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hook.ID = r.nextID
+	r.nextID++
+	r.hooks[hook.ID] = hook.Clone()
+	return nil
+}
+
+// GetWebhooks возвращает все подписки по возрастанию ID
+func (r *InMemoryWebhookRepository) GetWebhooks(ctx context.Context) (_ []*domain.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.GetWebhooks")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hooks := make([]*domain.Webhook, 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		hooks = append(hooks, hook.Clone())
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, nil
+}
+
+// GetWebhook возвращает подписку по ID
+func (r *InMemoryWebhookRepository) GetWebhook(ctx context.Context, id int) (_ *domain.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.GetWebhook")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hook, ok := r.hooks[id]
+	if !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return hook.Clone(), nil
+}
+
+// UpdateWebhook заменяет настройки подписки, сохраняя дату создания и
+// состояние доставки
+func (r *InMemoryWebhookRepository) UpdateWebhook(ctx context.Context, hook *domain.Webhook) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.UpdateWebhook")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.hooks[hook.ID]
+	if !ok {
+		return domain.ErrWebhookNotFound
+	}
+	hook.CreatedAt, hook.State = stored.CreatedAt, stored.State
+	r.hooks[hook.ID] = hook.Clone()
+	return nil
+}
+
+// SetWebhookState заменяет состояние доставки подписки
+func (r *InMemoryWebhookRepository) SetWebhookState(ctx context.Context, id int, state domain.WebhookState) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.SetWebhookState")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.hooks[id]
+	if !ok {
+		return domain.ErrWebhookNotFound
+	}
+	updated := *stored
+	updated.State = state
+	r.hooks[id] = updated.Clone()
+	return nil
+}
+
+// DeleteWebhook удаляет подписку вместе с ее журналом
+func (r *InMemoryWebhookRepository) DeleteWebhook(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.DeleteWebhook")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[id]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	delete(r.hooks, id)
+	delete(r.deliveries, id)
+	return nil
+}
+
+// AddDelivery добавляет попытку доставки в журнал подписки
+func (r *InMemoryWebhookRepository) AddDelivery(ctx context.Context, id int, delivery domain.WebhookDelivery) (err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.AddDelivery")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[id]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	log := append(r.deliveries[id], delivery)
+	if len(log) > domain.MaxWebhookDeliveries {
+		log = slices.Clone(log[len(log)-domain.MaxWebhookDeliveries:])
+	}
+	r.deliveries[id] = log
+	return nil
+}
+
+// Deliveries возвращает журнал подписки, начиная с последней попытки
+func (r *InMemoryWebhookRepository) Deliveries(ctx context.Context, id int) (_ []domain.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "InMemoryWebhookRepository.Deliveries")
+	defer func() { span.EndWithError(err) }()
+
+	if err := domain.ContextErr(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.hooks[id]; !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	log := slices.Clone(r.deliveries[id])
+	slices.Reverse(log)
+	return log, nil
+}
+
+// webhookSnapshot - формат файла подписок и копия состояния для отката
+type webhookSnapshot struct {
+	NextID     int                              `json:"next_id"`
+	Webhooks   []*domain.Webhook                `json:"webhooks"`
+	Deliveries map[int][]domain.WebhookDelivery `json:"deliveries,omitempty"`
+}
+
+// export возвращает независимую копию состояния
+func (r *InMemoryWebhookRepository) export() webhookSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := webhookSnapshot{
+		NextID:     r.nextID,
+		Webhooks:   make([]*domain.Webhook, 0, len(r.hooks)),
+		Deliveries: make(map[int][]domain.WebhookDelivery, len(r.deliveries)),
+	}
+	for _, hook := range r.hooks {
+		s.Webhooks = append(s.Webhooks, hook.Clone())
+	}
+	sort.Slice(s.Webhooks, func(i, j int) bool { return s.Webhooks[i].ID < s.Webhooks[j].ID })
+	for id, log := range r.deliveries {
+		s.Deliveries[id] = slices.Clone(log)
+	}
+	return s
+}
+
+// load заменяет состояние снимком
+func (r *InMemoryWebhookRepository) load(s webhookSnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID = max(s.NextID, 1)
+	r.hooks = make(map[int]*domain.Webhook, len(s.Webhooks))
+	for _, hook := range s.Webhooks {
+		r.hooks[hook.ID] = hook
+		r.nextID = max(r.nextID, hook.ID+1)
+	}
+	r.deliveries = s.Deliveries
+	if r.deliveries == nil {
+		r.deliveries = make(map[int][]domain.WebhookDelivery)
+	}
+}
+
+// FileWebhookRepository хранит подписки в памяти и после каждого изменения
+// атомарно сохраняет их в JSON файл, как FileTodoRepository
+type FileWebhookRepository struct {
+	mu   sync.Mutex // упорядочивает изменения и запись снимков
+	mem  *InMemoryWebhookRepository
+	path string
+}
+
+// NewFileWebhookRepository открывает хранилище подписок в файле path,
+// создавая его при первом сохранении
+func NewFileWebhookRepository(path string) (*FileWebhookRepository, error) {
+	r := &FileWebhookRepository{mem: NewInMemoryWebhookRepository(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var snapshot webhookSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	r.mem.load(snapshot)
+	return r, nil
+}
+
+// update выполняет изменение и сохраняет снимок, откатывая изменение, если
+// записать его не удалось
+func (r *FileWebhookRepository) update(change func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := r.mem.export()
+	if err := change(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r.mem.export(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(r.path, data)
+	}
+	if err != nil {
+		r.mem.load(previous)
+		return fmt.Errorf("save webhooks: %w", err)
+	}
+	return nil
+}
+
+// CreateWebhook сохраняет новую подписку и назначает ей ID
+func (r *FileWebhookRepository) CreateWebhook(ctx context.Context, hook *domain.Webhook) error {
+	return r.update(func() error { return r.mem.CreateWebhook(ctx, hook) })
+}
+
+// GetWebhooks возвращает все подписки по возрастанию ID
+func (r *FileWebhookRepository) GetWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	return r.mem.GetWebhooks(ctx)
+}
+
+// GetWebhook возвращает подписку по ID
+func (r *FileWebhookRepository) GetWebhook(ctx context.Context, id int) (*domain.Webhook, error) {
+	return r.mem.GetWebhook(ctx, id)
+}
+
+// UpdateWebhook заменяет настройки подписки
+func (r *FileWebhookRepository) UpdateWebhook(ctx context.Context, hook *domain.Webhook) error {
+	return r.update(func() error { return r.mem.UpdateWebhook(ctx, hook) })
+}
+
+// SetWebhookState заменяет состояние доставки подписки
+func (r *FileWebhookRepository) SetWebhookState(ctx context.Context, id int, state domain.WebhookState) error {
+	return r.update(func() error { return r.mem.SetWebhookState(ctx, id, state) })
+}
+
+// DeleteWebhook удаляет подписку вместе с ее журналом
+func (r *FileWebhookRepository) DeleteWebhook(ctx context.Context, id int) error {
+	return r.update(func() error { return r.mem.DeleteWebhook(ctx, id) })
+}
+
+// AddDelivery добавляет попытку доставки в журнал подписки
+func (r *FileWebhookRepository) AddDelivery(ctx context.Context, id int, delivery domain.WebhookDelivery) error {
+	return r.update(func() error { return r.mem.AddDelivery(ctx, id, delivery) })
+}
+
+// Deliveries возвращает журнал подписки, начиная с последней попытки
+func (r *FileWebhookRepository) Deliveries(ctx context.Context, id int) ([]domain.WebhookDelivery, error) {
+	return r.mem.Deliveries(ctx, id)
+}
@@ -0,0 +1,164 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/repository"
+)
+
+func TestWebhookRepositories(t *testing.T) {
+	factories := map[string]func(t *testing.T) domain.WebhookRepository{
+		"InMemory": func(*testing.T) domain.WebhookRepository {
+			return repository.NewInMemoryWebhookRepository()
+		},
+		"File": func(t *testing.T) domain.WebhookRepository {
+			repo, err := repository.NewFileWebhookRepository(filepath.Join(t.TempDir(), "webhooks.json"))
+			if err != nil {
+				t.Fatalf("failed to open repository: %v", err)
+			}
+			return repo
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			t.Run("создание и чтение", func(t *testing.T) {
+				repo := factory(t)
+				hook := newTestWebhook()
+				if err := repo.CreateWebhook(ctx, hook); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if hook.ID <= 0 {
+					t.Fatalf("expected positive ID, got %d", hook.ID)
+				}
+
+				// Изменение возвращенной копии не затрагивает хранилище
+				got, err := repo.GetWebhook(ctx, hook.ID)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got.Events[0] = domain.EventTodoDeleted
+				again, _ := repo.GetWebhook(ctx, hook.ID)
+				if again.Events[0] != domain.EventTodoCreated {
+					t.Errorf("expected stored events to be isolated, got %v", again.Events)
+				}
+
+				if _, err := repo.GetWebhook(ctx, 999); !errors.Is(err, domain.ErrWebhookNotFound) {
+					t.Errorf("expected ErrWebhookNotFound, got %v", err)
+				}
+			})
+
+			t.Run("обновление сохраняет состояние", func(t *testing.T) {
+				repo := factory(t)
+				hook := newTestWebhook()
+				repo.CreateWebhook(ctx, hook)
+				repo.SetWebhookState(ctx, hook.ID, domain.WebhookState{Cursor: 7, Failures: 2})
+
+				update := newTestWebhook()
+				update.ID, update.URL = hook.ID, "https://example.com/other"
+				if err := repo.UpdateWebhook(ctx, update); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				got, _ := repo.GetWebhook(ctx, hook.ID)
+				if got.URL != "https://example.com/other" || got.State.Cursor != 7 || got.State.Failures != 2 {
+					t.Errorf("expected new URL with the old state, got %+v", got)
+				}
+			})
+
+			t.Run("журнал доставки", func(t *testing.T) {
+				repo := factory(t)
+				hook := newTestWebhook()
+				repo.CreateWebhook(ctx, hook)
+				for i := range domain.MaxWebhookDeliveries + 5 {
+					if err := repo.AddDelivery(ctx, hook.ID, domain.WebhookDelivery{EventID: uint64(i + 1)}); err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				}
+
+				log, err := repo.Deliveries(ctx, hook.ID)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(log) != domain.MaxWebhookDeliveries {
+					t.Fatalf("expected the log to be capped at %d, got %d", domain.MaxWebhookDeliveries, len(log))
+				}
+				if log[0].EventID != domain.MaxWebhookDeliveries+5 || log[len(log)-1].EventID != 6 {
+					t.Errorf("expected newest first without the oldest attempts, got %d..%d", log[0].EventID, log[len(log)-1].EventID)
+				}
+			})
+
+			t.Run("удаление", func(t *testing.T) {
+				repo := factory(t)
+				hook := newTestWebhook()
+				repo.CreateWebhook(ctx, hook)
+				repo.AddDelivery(ctx, hook.ID, domain.WebhookDelivery{EventID: 1})
+
+				if err := repo.DeleteWebhook(ctx, hook.ID); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, err := repo.Deliveries(ctx, hook.ID); !errors.Is(err, domain.ErrWebhookNotFound) {
+					t.Errorf("expected ErrWebhookNotFound, got %v", err)
+				}
+				if err := repo.AddDelivery(ctx, hook.ID, domain.WebhookDelivery{}); !errors.Is(err, domain.ErrWebhookNotFound) {
+					t.Errorf("expected ErrWebhookNotFound, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestFileWebhookRepository_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	ctx := context.Background()
+
+	repo, err := repository.NewFileWebhookRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deleted, kept := newTestWebhook(), newTestWebhook()
+	repo.CreateWebhook(ctx, deleted)
+	repo.CreateWebhook(ctx, kept)
+	repo.DeleteWebhook(ctx, deleted.ID)
+	disabledAt := time.Now().UTC().Truncate(time.Second)
+	repo.SetWebhookState(ctx, kept.ID, domain.WebhookState{Cursor: 3, Failures: 10, Disabled: true, DisabledAt: &disabledAt})
+	repo.AddDelivery(ctx, kept.ID, domain.WebhookDelivery{EventID: 3, StatusCode: 500})
+
+	reopened, err := repository.NewFileWebhookRepository(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hooks, _ := reopened.GetWebhooks(ctx)
+	if len(hooks) != 1 || hooks[0].ID != kept.ID || hooks[0].Secret != kept.Secret {
+		t.Fatalf("expected only the kept webhook after reopen, got %+v", hooks)
+	}
+	state := hooks[0].State
+	if state.Cursor != 3 || !state.Disabled || state.DisabledAt == nil || !state.DisabledAt.Equal(disabledAt) {
+		t.Errorf("expected the delivery state to survive reopen, got %+v", state)
+	}
+	if log, _ := reopened.Deliveries(ctx, kept.ID); len(log) != 1 || log[0].StatusCode != 500 {
+		t.Errorf("expected the delivery log to survive reopen, got %+v", log)
+	}
+
+	next := newTestWebhook()
+	reopened.CreateWebhook(ctx, next)
+	if next.ID <= kept.ID {
+		t.Errorf("expected IDs not to be reused after reopen, got %d", next.ID)
+	}
+}
+
+func newTestWebhook() *domain.Webhook {
+	return &domain.Webhook{
+		URL:    "https://example.com/hook",
+		Events: []domain.EventType{domain.EventTodoCreated},
+		Secret: "0123456789abcdef",
+		Active: true,
+	}
+}
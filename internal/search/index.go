@@ -0,0 +1,296 @@
+// Package search реализует полнотекстовый поиск задач: инвертированный
+// индекс по названию и описанию, который хранилище обновляет при каждом
+// изменении, разбор запросов, ранжирование BM25 и фрагменты текста с
+// отмеченными совпадениями. Слова приводятся к основе стеммерами Snowball
+// для английского и русского языков.
+package search
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Параметры BM25
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// titleBoost - вес вхождения в названии относительно описания
+const titleBoost = 2
+
+// fieldGap - сдвиг позиций описания, чтобы фраза не совпадала на стыке
+// названия и описания
+const fieldGap = 1 << 16
+
+// Hit - найденный документ
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+// Index - инвертированный индекс документов из названия и описания. Не
+// безопасен для конкурентного использования: хранилище обращается к нему
+// под своей блокировкой.
+type Index struct {
+	// postings: основа -> документ -> позиции по возрастанию
+	postings map[string]map[int][]int
+	docs     map[int]*document
+	totalLen float64
+
+	// Слова документов по алфавиту и документы с каждым словом - для
+	// поиска по префиксу
+	words    []string
+	wordDocs map[string]map[int]struct{}
+}
+
+// document - сведения о проиндексированном документе
+type document struct {
+	length float64
+	terms  []string
+	words  []string
+}
+
+// NewIndex создает пустой индекс
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[int][]int),
+		docs:     make(map[int]*document),
+		wordDocs: make(map[string]map[int]struct{}),
+	}
+}
+
+// Len возвращает число документов в индексе
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Add индексирует документ id, заменяя прежнюю версию
+func (ix *Index) Add(id int, title, description string) {
+	ix.Remove(id)
+
+	positions := make(map[string][]int)
+	words := make(map[string]bool)
+	titleTokens := Tokenize(title)
+	descTokens := Tokenize(description)
+	for _, t := range titleTokens {
+		positions[t.Term] = append(positions[t.Term], t.Pos)
+		words[t.Word] = true
+	}
+	for _, t := range descTokens {
+		positions[t.Term] = append(positions[t.Term], fieldGap+t.Pos)
+		words[t.Word] = true
+	}
+
+	doc := &document{length: float64(titleBoost*len(titleTokens) + len(descTokens))}
+	for term, pos := range positions {
+		docs := ix.postings[term]
+		if docs == nil {
+			docs = make(map[int][]int)
+			ix.postings[term] = docs
+		}
+		docs[id] = pos
+		doc.terms = append(doc.terms, term)
+	}
+	for word := range words {
+		docs := ix.wordDocs[word]
+		if docs == nil {
+			docs = make(map[int]struct{})
+			ix.wordDocs[word] = docs
+			i, _ := slices.BinarySearch(ix.words, word)
+			ix.words = slices.Insert(ix.words, i, word)
+		}
+		docs[id] = struct{}{}
+		doc.words = append(doc.words, word)
+	}
+	ix.docs[id] = doc
+	ix.totalLen += doc.length
+}
+
+// Remove удаляет документ id из индекса
+func (ix *Index) Remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range doc.terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	for _, word := range doc.words {
+		if delete(ix.wordDocs[word], id); len(ix.wordDocs[word]) > 0 {
+			continue
+		}
+		delete(ix.wordDocs, word)
+		if i, found := slices.BinarySearch(ix.words, word); found {
+			ix.words = slices.Delete(ix.words, i, i+1)
+		}
+	}
+	delete(ix.docs, id)
+	ix.totalLen -= doc.length
+}
+
+// Search возвращает документы, подходящие под все условия запроса, по
+// убыванию релевантности BM25 (при равенстве - по возрастанию ID)
+func (ix *Index) Search(q Query) []Hit {
+	if q.IsEmpty() || len(ix.docs) == 0 {
+		return nil
+	}
+
+	// Начинаем с самого редкого условия, остальные только проверяем
+	scores := make([]map[int]float64, len(q.Clauses))
+	for i, c := range q.Clauses {
+		scores[i] = ix.clauseScores(c)
+		if len(scores[i]) == 0 {
+			return nil
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool { return len(scores[i]) < len(scores[j]) })
+
+	var hits []Hit
+	for id, score := range scores[0] {
+		matched := true
+		for _, other := range scores[1:] {
+			s, ok := other[id]
+			if !ok {
+				matched = false
+				break
+			}
+			score += s
+		}
+		if matched {
+			hits = append(hits, Hit{ID: id, Score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+// clauseScores возвращает вклад условия в оценку каждого подходящего
+// документа
+func (ix *Index) clauseScores(c Clause) map[int]float64 {
+	switch {
+	case c.Prefix != "":
+		// Слово оценивается по его основе, а документ с несколькими словами
+		// на префикс - по лучшему из них
+		scores := make(map[int]float64)
+		terms := make(map[string]map[int]float64)
+		for _, word := range ix.prefixWords(c.Prefix) {
+			term := Stem(word)
+			if terms[term] == nil {
+				terms[term] = ix.termScores(term)
+			}
+			for id := range ix.wordDocs[word] {
+				scores[id] = max(scores[id], terms[term][id])
+			}
+		}
+		return scores
+	case c.IsPhrase():
+		return ix.phraseScores(c)
+	default:
+		return ix.termScores(c.Terms[0])
+	}
+}
+
+// prefixWords возвращает слова индекса, начинающиеся с prefix
+func (ix *Index) prefixWords(prefix string) []string {
+	i, _ := slices.BinarySearch(ix.words, prefix)
+	j := i
+	for j < len(ix.words) && strings.HasPrefix(ix.words[j], prefix) {
+		j++
+	}
+	return ix.words[i:j]
+}
+
+func (ix *Index) termScores(term string) map[int]float64 {
+	docs := ix.postings[term]
+	scores := make(map[int]float64, len(docs))
+	idf := ix.idf(len(docs))
+	for id, positions := range docs {
+		scores[id] = ix.bm25(idf, weightedFreq(positions), id)
+	}
+	return scores
+}
+
+// phraseScores оценивает фразу как отдельный термин: частота - число
+// вхождений фразы, документная частота - число документов с ней
+func (ix *Index) phraseScores(c Clause) map[int]float64 {
+	lists := make([]map[int][]int, len(c.Terms))
+	for i, term := range c.Terms {
+		if lists[i] = ix.postings[term]; lists[i] == nil {
+			return nil
+		}
+	}
+
+	freqs := make(map[int]float64)
+	for id, first := range lists[0] {
+		var occurrences []int
+		for _, p := range first {
+			if phraseAt(lists, c.Offsets, id, p) {
+				occurrences = append(occurrences, p)
+			}
+		}
+		if len(occurrences) > 0 {
+			freqs[id] = weightedFreq(occurrences)
+		}
+	}
+
+	scores := make(map[int]float64, len(freqs))
+	idf := ix.idf(len(freqs))
+	for id, freq := range freqs {
+		scores[id] = ix.bm25(idf, freq, id)
+	}
+	return scores
+}
+
+// phraseAt сообщает, что фраза начинается в документе id с позиции p
+func phraseAt(lists []map[int][]int, offsets []int, id, p int) bool {
+	for i := 1; i < len(lists); i++ {
+		positions, ok := lists[i][id]
+		if !ok {
+			return false
+		}
+		if _, found := slices.BinarySearch(positions, p+offsets[i]); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// weightedFreq возвращает частоту вхождений с учетом веса названия
+func weightedFreq(positions []int) float64 {
+	var freq float64
+	for _, p := range positions {
+		if p < fieldGap {
+			freq += titleBoost
+		} else {
+			freq++
+		}
+	}
+	return freq
+}
+
+// idf - обратная документная частота в варианте BM25, неотрицательная и
+// для терминов, которые есть почти во всех документах
+func (ix *Index) idf(df int) float64 {
+	n := float64(len(ix.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+func (ix *Index) bm25(idf, freq float64, id int) float64 {
+	avg := ix.totalLen / float64(len(ix.docs))
+	norm := 1 - bm25B
+	if avg > 0 {
+		norm += bm25B * ix.docs[id].length / avg
+	}
+	return idf * freq * (bm25K1 + 1) / (freq + bm25K1*norm)
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestIndex() *Index {
+	ix := NewIndex()
+	ix.Add(1, "Купить молоко", "В магазине у дома, обезжиренное")
+	ix.Add(2, "Позвонить маме", "Спросить про молоко и хлеб")
+	ix.Add(3, "Buy milk and bread", "Running out of milk again")
+	ix.Add(4, "Молочные продукты", "Купить творог, кефир и молоко")
+	ix.Add(5, "Prepare the report", "Quarterly reporting for the board")
+	return ix
+}
+
+func ids(hits []Hit) []int {
+	out := make([]int, len(hits))
+	for i, h := range hits {
+		out[i] = h.ID
+	}
+	return out
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := newTestIndex()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		// Совпадение в названии весит больше, чем в описании, а в коротком
+		// описании - больше, чем в длинном
+		{"слово в любой форме", "молока", []int{1, 2, 4}},
+		{"все слова обязательны", "купить молоко", []int{1, 4}},
+		{"английская морфология", "reports", []int{5}},
+		{"регистр и стоп-слова", "MILK and", []int{3}},
+		{"фраза", `"купить молоко"`, []int{1}},
+		{"фраза со стоп-словом", `"кефир и молоко"`, []int{4}},
+		{"фраза не совпадает без стоп-слова", `"кефир молоко"`, nil},
+		{"фраза не переходит из названия в описание", `"молоко магазине"`, nil},
+		{"префикс", "мол*", []int{4, 1, 2}},
+		// "про" в описании задачи 2 не индексируется, но как префикс работает
+		{"префикс-стоп-слово", "про*", []int{4}},
+		{"нет совпадений", "кефир хлеб", nil},
+		{"пустой запрос", "и, или", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(ix.Search(Parse(tt.query))); !equalIDs(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_Ranking(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(1, "Отчет", "Подготовить отчет и отправить отчет руководителю до пятницы")
+	ix.Add(2, "Подготовить отчет", "")
+	ix.Add(3, "Разное", "Отчет упоминается один раз среди длинного описания с множеством других слов")
+	ix.Add(4, "Купить хлеб", "")
+
+	hits := ix.Search(Parse("отчет"))
+	if got := ids(hits); !equalIDs(got, []int{1, 2, 3}) {
+		t.Fatalf("expected frequent and short matches first, got %v", got)
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Errorf("expected descending scores, got %+v", hits)
+		}
+	}
+
+	// Редкое слово весит больше частого
+	both := ix.Search(Parse("подготовить пятницы"))
+	if len(both) != 1 || both[0].ID != 1 {
+		t.Fatalf("unexpected hits %+v", both)
+	}
+}
+
+func TestIndex_Incremental(t *testing.T) {
+	ix := newTestIndex()
+
+	ix.Add(1, "Купить кефир", "")
+	if got := ids(ix.Search(Parse("купить молоко"))); !equalIDs(got, []int{4}) {
+		t.Errorf("expected the replaced document to stop matching, got %v", got)
+	}
+	if got := ids(ix.Search(Parse("кефир"))); !equalIDs(got, []int{1, 4}) {
+		t.Errorf("expected the new text to match, got %v", got)
+	}
+
+	ix.Remove(4)
+	ix.Remove(4)
+	if got := ids(ix.Search(Parse("кефир"))); !equalIDs(got, []int{1}) {
+		t.Errorf("expected the removed document to stop matching, got %v", got)
+	}
+
+	// Слова удаленных документов не раскрываются из префикса
+	ix.Remove(2)
+	if got := ix.prefixWords("хле"); len(got) != 0 {
+		t.Errorf("expected no words for a removed document, got %v", got)
+	}
+	if ix.Len() != 3 {
+		t.Errorf("expected 3 documents, got %d", ix.Len())
+	}
+}
+
+func TestParse(t *testing.T) {
+	q := Parse(`  купил "молоко и хлеб" мол* e-mail "незакрытая`)
+	if len(q.Clauses) != 6 {
+		t.Fatalf("expected 6 clauses, got %+v", q.Clauses)
+	}
+	if c := q.Clauses[0]; c.IsPhrase() || c.Terms[0] != "куп" {
+		t.Errorf("unexpected term clause %+v", c)
+	}
+	if c := q.Clauses[1]; !c.IsPhrase() || strings.Join(c.Terms, " ") != "молок хлеб" || c.Offsets[1] != 2 {
+		t.Errorf("unexpected phrase clause %+v", c)
+	}
+	if c := q.Clauses[2]; c.Prefix != "мол" {
+		t.Errorf("unexpected prefix clause %+v", c)
+	}
+	if c := q.Clauses[3]; c.Terms[0] != "e" || q.Clauses[4].Terms[0] != "mail" {
+		t.Errorf("expected e-mail to split into words, got %+v", q.Clauses[3:5])
+	}
+	if c := q.Clauses[5]; c.Terms[0] != "незакрыт" {
+		t.Errorf("expected the unclosed quote to be closed at the end, got %+v", c)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	q := Parse("молоко <b>")
+
+	got, ok := Snippet("Купить <молоко> & хлеб", q, 0)
+	if !ok || got != "Купить &lt;<mark>молоко</mark>&gt; &amp; хлеб" {
+		t.Errorf("unexpected snippet %q", got)
+	}
+
+	if _, ok := Snippet("Купить хлеб", q, 0); ok {
+		t.Error("expected no match")
+	}
+
+	long := strings.Repeat("слово ", 40) + "свежее молоко " + strings.Repeat("текст ", 40)
+	got, ok = Snippet(long, q, 60)
+	if !ok || !strings.HasPrefix(got, Ellipsis) || !strings.HasSuffix(got, Ellipsis) ||
+		!strings.Contains(got, "свежее <mark>молоко</mark>") {
+		t.Errorf("unexpected snippet %q", got)
+	}
+	body := strings.TrimSuffix(strings.TrimPrefix(got, Ellipsis), Ellipsis)
+	body = strings.NewReplacer(MarkStart, "", MarkEnd, "").Replace(body)
+	if n := len([]rune(body)); n > 60 || strings.HasPrefix(body, " ") || strings.HasSuffix(body, " ") {
+		t.Errorf("expected a trimmed window of at most 60 characters, got %d: %q", n, body)
+	}
+	if !strings.HasPrefix(body, "слово") || !strings.HasSuffix(body, "текст") {
+		t.Errorf("expected the window to start and end on word boundaries, got %q", body)
+	}
+}
@@ -0,0 +1,162 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Query - разобранный поисковый запрос. Задача подходит, если выполнены
+// все условия запроса.
+type Query struct {
+	Clauses []Clause
+}
+
+// Clause - условие запроса: слово, префикс слова или фраза
+type Clause struct {
+	// Terms - основы слов; у фразы их несколько в порядке следования
+	Terms []string
+	// Offsets - позиции слов фразы относительно первого с учетом
+	// пропущенных стоп-слов
+	Offsets []int
+	// Prefix - начало слова для условия "молок*", приведенное normalize
+	Prefix string
+}
+
+// IsPhrase сообщает, что условие - фраза
+func (c Clause) IsPhrase() bool {
+	return len(c.Terms) > 1
+}
+
+// Parse разбирает запрос. Слова через пробел должны встретиться все, в
+// любом порядке и любой форме ("купил молоко" находит "Купить молока"),
+// "слова в кавычках" - подряд, а слово со звездочкой на конце (молок*)
+// задает префикс. Незакрытая кавычка закрывается в конце запроса.
+// Стоп-слова вне фраз не учитываются.
+func Parse(query string) Query {
+	var q Query
+	for rest := query; rest != ""; {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		if rest[0] == '"' {
+			phrase, after, _ := strings.Cut(rest[1:], `"`)
+			rest = after
+			q.addPhrase(Tokenize(phrase))
+			continue
+		}
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(rest)
+		}
+		chunk := rest[:end]
+		rest = rest[end:]
+
+		if trimmed := strings.TrimRight(chunk, "*"); trimmed != chunk {
+			// Префиксом служит последнее слово; стоп-слово тоже может быть
+			// началом нужного слова (по* - покупки)
+			tokens := scan(trimmed, true)
+			if len(tokens) == 0 {
+				continue
+			}
+			last := tokens[len(tokens)-1]
+			for _, t := range tokens[:len(tokens)-1] {
+				if !stopWords[t.Word] {
+					q.Clauses = append(q.Clauses, Clause{Terms: []string{t.Term}})
+				}
+			}
+			q.Clauses = append(q.Clauses, Clause{Prefix: last.Word})
+			continue
+		}
+		for _, t := range Tokenize(chunk) {
+			q.Clauses = append(q.Clauses, Clause{Terms: []string{t.Term}})
+		}
+	}
+	return q
+}
+
+// addPhrase добавляет условие-фразу; фраза из одного слова становится
+// обычным словом
+func (q *Query) addPhrase(tokens []Token) {
+	switch len(tokens) {
+	case 0:
+		return
+	case 1:
+		q.Clauses = append(q.Clauses, Clause{Terms: []string{tokens[0].Term}})
+		return
+	}
+	c := Clause{Terms: make([]string, len(tokens)), Offsets: make([]int, len(tokens))}
+	for i, t := range tokens {
+		c.Terms[i], c.Offsets[i] = t.Term, t.Pos-tokens[0].Pos
+	}
+	q.Clauses = append(q.Clauses, c)
+}
+
+// IsEmpty сообщает, что в запросе нет условий (например, только
+// стоп-слова или знаки препинания)
+func (q Query) IsEmpty() bool {
+	return len(q.Clauses) == 0
+}
+
+// matches сообщает, относится ли слово текста к одному из условий запроса
+func (q Query) matches(t Token) bool {
+	for _, c := range q.Clauses {
+		if c.Prefix != "" {
+			if strings.HasPrefix(t.Word, c.Prefix) {
+				return true
+			}
+			continue
+		}
+		for _, term := range c.Terms {
+			if term == t.Term {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches сообщает, подходят ли название и описание под все условия
+// запроса, не обращаясь к индексу; результат совпадает с Index.Search
+func Matches(q Query, title, description string) bool {
+	if q.IsEmpty() {
+		return false
+	}
+	titleTokens, descTokens := Tokenize(title), Tokenize(description)
+	for _, c := range q.Clauses {
+		if !clauseMatches(c, titleTokens) && !clauseMatches(c, descTokens) {
+			return false
+		}
+	}
+	return true
+}
+
+// clauseMatches проверяет условие по словам одного поля
+func clauseMatches(c Clause, tokens []Token) bool {
+	if c.Prefix != "" {
+		for _, t := range tokens {
+			if strings.HasPrefix(t.Word, c.Prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	positions := make(map[string][]int)
+	for _, t := range tokens {
+		positions[t.Term] = append(positions[t.Term], t.Pos)
+	}
+	for _, p := range positions[c.Terms[0]] {
+		matched := true
+		for i := 1; i < len(c.Terms) && matched; i++ {
+			matched = slices.Contains(positions[c.Terms[i]], p+c.Offsets[i])
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
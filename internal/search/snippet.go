@@ -0,0 +1,100 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Теги, которыми отмечаются совпадения во фрагментах
+const (
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+)
+
+// Ellipsis обозначает обрезанный текст во фрагменте
+const Ellipsis = "…"
+
+// Snippet возвращает фрагмент text не длиннее width символов (0 - весь
+// текст) вокруг первого совпадения с запросом и сообщает, было ли
+// совпадение. Совпавшие слова обрамляются MarkStart и MarkEnd, остальной
+// текст экранируется как HTML, поэтому фрагмент можно вставлять в
+// разметку как есть.
+func Snippet(text string, q Query, width int) (string, bool) {
+	var marks []Token
+	for _, t := range scan(text, false) {
+		if q.matches(t) {
+			marks = append(marks, t)
+		}
+	}
+
+	start, end := 0, len(text)
+	if width > 0 && utf8.RuneCountInString(text) > width {
+		anchor := 0
+		if len(marks) > 0 {
+			anchor = marks[0].Start
+		}
+		start, end = window(text, anchor, width)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString(Ellipsis)
+	}
+	pos := start
+	for _, m := range marks {
+		if m.Start < start {
+			continue
+		}
+		if m.End > end {
+			break
+		}
+		b.WriteString(html.EscapeString(text[pos:m.Start]))
+		b.WriteString(MarkStart)
+		b.WriteString(html.EscapeString(text[m.Start:m.End]))
+		b.WriteString(MarkEnd)
+		pos = m.End
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString(Ellipsis)
+	}
+	return b.String(), len(marks) > 0
+}
+
+// window выбирает байтовые границы окна из width символов, в котором
+// позиция anchor стоит ближе к началу, чтобы было видно, что идет за
+// совпадением. Границы сдвигаются внутрь окна до границ слов.
+func window(text string, anchor, width int) (start, end int) {
+	runes := []rune(text)
+	at := utf8.RuneCountInString(text[:anchor])
+	from := max(0, at-width/4)
+	to := min(len(runes), from+width)
+	from = max(0, to-width)
+
+	// Не начинаем и не заканчиваем посреди слова
+	if from > 0 && isWordRune(runes[from-1]) {
+		for from < at && !unicode.IsSpace(runes[from]) {
+			from++
+		}
+	}
+	if limit := to; to < len(runes) && isWordRune(runes[to]) {
+		for to > from && !unicode.IsSpace(runes[to-1]) {
+			to--
+		}
+		// Одно слово длиннее окна режем как есть
+		if to == from {
+			to = limit
+		}
+	}
+	for from < to && unicode.IsSpace(runes[from]) {
+		from++
+	}
+	for to > from && unicode.IsSpace(runes[to-1]) {
+		to--
+	}
+
+	start = len(string(runes[:from]))
+	return start, start + len(string(runes[from:to]))
+}
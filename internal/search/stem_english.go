@@ -0,0 +1,297 @@
+package search
+
+import "strings"
+
+// Стеммер английского языка по алгоритму Snowball English (Porter2):
+// https://snowballstem.org/algorithms/english/stemmer.html
+
+// englishExceptions - слова, которые алгоритм обрабатывает особо
+var englishExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// englishExceptions1a - слова, которые не меняются после шага 1a
+var englishExceptions1a = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+// englishStemmer хранит слово в процессе обработки. Согласная y
+// обозначается как Y.
+type englishStemmer struct {
+	w      []byte
+	r1, r2 int
+}
+
+// stemEnglish возвращает основу английского слова из строчных латинских
+// букв
+func stemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if stem, ok := englishExceptions[word]; ok {
+		return stem
+	}
+
+	s := &englishStemmer{w: []byte(strings.TrimPrefix(word, "'"))}
+	s.markConsonantY()
+	s.regions()
+
+	s.step0()
+	s.step1a()
+	if englishExceptions1a[string(s.w)] {
+		return s.result()
+	}
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return s.result()
+}
+
+func (s *englishStemmer) result() string {
+	return strings.ReplaceAll(string(s.w), "Y", "y")
+}
+
+func isEnglishVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// markConsonantY заменяет на Y начальную y и y после гласной
+func (s *englishStemmer) markConsonantY() {
+	for i, c := range s.w {
+		if c == 'y' && (i == 0 || isEnglishVowel(s.w[i-1])) {
+			s.w[i] = 'Y'
+		}
+	}
+}
+
+// regions вычисляет R1 и R2: части слова после первой согласной, идущей за
+// гласной, в слове и в R1 соответственно
+func (s *englishStemmer) regions() {
+	s.r1 = len(s.w)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(s.w), prefix) {
+			s.r1 = len(prefix)
+			break
+		}
+	}
+	if s.r1 == len(s.w) {
+		s.r1 = s.regionAfter(0)
+	}
+	s.r2 = s.regionAfter(s.r1)
+}
+
+func (s *englishStemmer) regionAfter(start int) int {
+	for i := start + 1; i < len(s.w); i++ {
+		if !isEnglishVowel(s.w[i]) && isEnglishVowel(s.w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(s.w)
+}
+
+func (s *englishStemmer) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(s.w), suffix)
+}
+
+// replace заменяет окончание suffix на repl
+func (s *englishStemmer) replace(suffix, repl string) {
+	s.w = append(s.w[:len(s.w)-len(suffix)], repl...)
+}
+
+// longest возвращает самое длинное из окончаний слова или ""
+func (s *englishStemmer) longest(suffixes ...string) string {
+	found := ""
+	for _, suffix := range suffixes {
+		if len(suffix) > len(found) && s.hasSuffix(suffix) {
+			found = suffix
+		}
+	}
+	return found
+}
+
+func (s *englishStemmer) inR1(suffix string) bool { return len(s.w)-len(suffix) >= s.r1 }
+func (s *englishStemmer) inR2(suffix string) bool { return len(s.w)-len(suffix) >= s.r2 }
+
+// hasVowel сообщает, есть ли гласная в первых n буквах
+func (s *englishStemmer) hasVowel(n int) bool {
+	for _, c := range s.w[:n] {
+		if isEnglishVowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsShortSyllable сообщает, заканчиваются ли первые n букв коротким
+// слогом: согласная, гласная и согласная, кроме w, x и Y, либо гласная и
+// согласная в начале слова
+func (s *englishStemmer) endsShortSyllable(n int) bool {
+	w := s.w[:n]
+	switch {
+	case n >= 3:
+		c := w[n-1]
+		return !isEnglishVowel(w[n-3]) && isEnglishVowel(w[n-2]) && !isEnglishVowel(c) && c != 'w' && c != 'x' && c != 'Y'
+	case n == 2:
+		return isEnglishVowel(w[0]) && !isEnglishVowel(w[1])
+	}
+	return false
+}
+
+func (s *englishStemmer) isShort() bool {
+	return s.r1 >= len(s.w) && s.endsShortSyllable(len(s.w))
+}
+
+func (s *englishStemmer) step0() {
+	if suffix := s.longest("'", "'s", "'s'"); suffix != "" {
+		s.replace(suffix, "")
+	}
+}
+
+func (s *englishStemmer) step1a() {
+	switch suffix := s.longest("sses", "ied", "ies", "s", "us", "ss"); suffix {
+	case "sses":
+		s.replace(suffix, "ss")
+	case "ied", "ies":
+		if len(s.w) > 4 {
+			s.replace(suffix, "i")
+		} else {
+			s.replace(suffix, "ie")
+		}
+	case "s":
+		if len(s.w) >= 3 && s.hasVowel(len(s.w)-2) {
+			s.replace(suffix, "")
+		}
+	}
+}
+
+func (s *englishStemmer) step1b() {
+	switch suffix := s.longest("eed", "eedly", "ed", "edly", "ing", "ingly"); suffix {
+	case "eed", "eedly":
+		if s.inR1(suffix) {
+			s.replace(suffix, "ee")
+		}
+	case "ed", "edly", "ing", "ingly":
+		if !s.hasVowel(len(s.w) - len(suffix)) {
+			return
+		}
+		s.replace(suffix, "")
+		switch {
+		case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+			s.w = append(s.w, 'e')
+		case s.endsDouble():
+			s.w = s.w[:len(s.w)-1]
+		case s.isShort():
+			s.w = append(s.w, 'e')
+		}
+	}
+}
+
+func (s *englishStemmer) endsDouble() bool {
+	for _, d := range []string{"bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt"} {
+		if s.hasSuffix(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *englishStemmer) step1c() {
+	n := len(s.w)
+	if n > 2 && (s.w[n-1] == 'y' || s.w[n-1] == 'Y') && !isEnglishVowel(s.w[n-2]) {
+		s.w[n-1] = 'i'
+	}
+}
+
+var englishStep2 = map[string]string{
+	"tional": "tion", "enci": "ence", "anci": "ance", "abli": "able", "entli": "ent",
+	"izer": "ize", "ization": "ize", "ational": "ate", "ation": "ate", "ator": "ate",
+	"alism": "al", "aliti": "al", "alli": "al", "fulness": "ful", "ousli": "ous", "ousness": "ous",
+	"iveness": "ive", "iviti": "ive", "biliti": "ble", "bli": "ble", "ogi": "og", "fulli": "ful",
+	"lessli": "less", "li": "",
+}
+
+var englishStep2Suffixes = keys(englishStep2)
+
+func (s *englishStemmer) step2() {
+	suffix := s.longest(englishStep2Suffixes...)
+	if suffix == "" || !s.inR1(suffix) {
+		return
+	}
+	before := len(s.w) - len(suffix)
+	switch suffix {
+	case "ogi":
+		if before == 0 || s.w[before-1] != 'l' {
+			return
+		}
+	case "li":
+		if before == 0 || !strings.ContainsRune("cdeghkmnrt", rune(s.w[before-1])) {
+			return
+		}
+	}
+	s.replace(suffix, englishStep2[suffix])
+}
+
+var englishStep3 = map[string]string{
+	"tional": "tion", "ational": "ate", "alize": "al", "icate": "ic", "iciti": "ic",
+	"ical": "ic", "ful": "", "ness": "", "ative": "",
+}
+
+var englishStep3Suffixes = keys(englishStep3)
+
+func (s *englishStemmer) step3() {
+	suffix := s.longest(englishStep3Suffixes...)
+	if suffix == "" || !s.inR1(suffix) {
+		return
+	}
+	if suffix == "ative" && !s.inR2(suffix) {
+		return
+	}
+	s.replace(suffix, englishStep3[suffix])
+}
+
+func (s *englishStemmer) step4() {
+	suffix := s.longest("al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement",
+		"ment", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion")
+	if suffix == "" || !s.inR2(suffix) {
+		return
+	}
+	if suffix == "ion" {
+		before := len(s.w) - len(suffix)
+		if before == 0 || (s.w[before-1] != 's' && s.w[before-1] != 't') {
+			return
+		}
+	}
+	s.replace(suffix, "")
+}
+
+func (s *englishStemmer) step5() {
+	n := len(s.w)
+	switch {
+	case s.hasSuffix("e"):
+		if s.inR2("e") || (s.inR1("e") && !s.endsShortSyllable(n-1)) {
+			s.w = s.w[:n-1]
+		}
+	case s.hasSuffix("l"):
+		if s.inR2("l") && n >= 2 && s.w[n-2] == 'l' {
+			s.w = s.w[:n-1]
+		}
+	}
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
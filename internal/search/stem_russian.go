@@ -0,0 +1,159 @@
+package search
+
+// Стеммер русского языка по алгоритму Snowball Russian:
+// https://snowballstem.org/algorithms/russian/stemmer.html
+
+// Группы окончаний. Окончания первой группы в группах с двумя частями
+// отсекаются, только если перед ними стоит а или я.
+var (
+	ruPerfectiveGerund1 = []string{"в", "вши", "вшись"}
+	ruPerfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	ruAdjective         = []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruReflexive   = []string{"ся", "сь"}
+	ruVerb1       = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	ruVerb2       = []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю"}
+	ruNoun = []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я"}
+	ruDerivational = []string{"ост", "ость"}
+	ruSuperlative  = []string{"ейш", "ейше"}
+)
+
+func isRussianVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+	return false
+}
+
+// russianStemmer хранит слово в процессе обработки; rv и r2 - начала
+// областей RV и R2
+type russianStemmer struct {
+	w      []rune
+	rv, r2 int
+}
+
+// stemRussian возвращает основу русского слова из строчных букв кириллицы
+// (ё заменяется на е при токенизации)
+func stemRussian(word string) string {
+	s := &russianStemmer{w: []rune(word)}
+	s.regions()
+
+	s.step1()
+	// Шаг 2: отсекаем и
+	s.removeAny([]string{"и"}, s.rv)
+	// Шаг 3: словообразовательное окончание в R2
+	s.removeAny(ruDerivational, s.r2)
+	s.step4()
+	return string(s.w)
+}
+
+// regions вычисляет RV (после первой гласной) и R2 (R1 внутри R1, где R1 -
+// после первой согласной, идущей за гласной)
+func (s *russianStemmer) regions() {
+	n := len(s.w)
+	s.rv, s.r2 = n, n
+	for i, r := range s.w {
+		if isRussianVowel(r) {
+			s.rv = i + 1
+			break
+		}
+	}
+	r1 := s.regionAfter(0)
+	s.r2 = s.regionAfter(r1)
+}
+
+func (s *russianStemmer) regionAfter(start int) int {
+	for i := start + 1; i < len(s.w); i++ {
+		if !isRussianVowel(s.w[i]) && isRussianVowel(s.w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(s.w)
+}
+
+// longest возвращает длину самого длинного из окончаний, целиком лежащего
+// после позиции limit, или 0
+func (s *russianStemmer) longest(suffixes []string, limit int) int {
+	best := 0
+	for _, suffix := range suffixes {
+		n := len([]rune(suffix))
+		if n <= best || len(s.w)-n < limit {
+			continue
+		}
+		if string(s.w[len(s.w)-n:]) == suffix {
+			best = n
+		}
+	}
+	return best
+}
+
+// removeAny отсекает самое длинное из окончаний и сообщает, удалось ли
+func (s *russianStemmer) removeAny(suffixes []string, limit int) bool {
+	n := s.longest(suffixes, limit)
+	if n == 0 {
+		return false
+	}
+	s.w = s.w[:len(s.w)-n]
+	return true
+}
+
+// removeGrouped отсекает самое длинное окончание из двух групп. Окончание
+// первой группы должно следовать за а или я в пределах RV; если это не так,
+// более короткие окончания не проверяются.
+func (s *russianStemmer) removeGrouped(group1, group2 []string) bool {
+	n1, n2 := s.longest(group1, s.rv), s.longest(group2, s.rv)
+	switch {
+	case n2 >= n1 && n2 > 0:
+		s.w = s.w[:len(s.w)-n2]
+		return true
+	case n1 > 0:
+		before := len(s.w) - n1 - 1
+		if before < s.rv || (s.w[before] != 'а' && s.w[before] != 'я') {
+			return false
+		}
+		s.w = s.w[:len(s.w)-n1]
+		return true
+	}
+	return false
+}
+
+func (s *russianStemmer) step1() {
+	if s.removeGrouped(ruPerfectiveGerund1, ruPerfectiveGerund2) {
+		return
+	}
+	s.removeAny(ruReflexive, s.rv)
+	if s.removeAny(ruAdjective, s.rv) {
+		// Причастие перед окончанием прилагательного
+		s.removeGrouped(ruParticiple1, ruParticiple2)
+		return
+	}
+	if s.removeGrouped(ruVerb1, ruVerb2) {
+		return
+	}
+	s.removeAny(ruNoun, s.rv)
+}
+
+func (s *russianStemmer) step4() {
+	if s.undoubleN() {
+		return
+	}
+	if s.removeAny(ruSuperlative, s.rv) {
+		s.undoubleN()
+		return
+	}
+	s.removeAny([]string{"ь"}, s.rv)
+}
+
+// undoubleN заменяет конечное нн на н
+func (s *russianStemmer) undoubleN() bool {
+	if s.longest([]string{"нн"}, s.rv) == 0 {
+		return false
+	}
+	s.w = s.w[:len(s.w)-1]
+	return true
+}
@@ -0,0 +1,81 @@
+package search
+
+import "testing"
+
+// Ожидаемые основы взяты из словарей-примеров Snowball
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		// Английский
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"ties":           "tie",
+		"cats":           "cat",
+		"running":        "run",
+		"hopping":        "hop",
+		"agreed":         "agre",
+		"generously":     "generous",
+		"generalization": "general",
+		"connection":     "connect",
+		"happiness":      "happi",
+		"consigned":      "consign",
+		"consistently":   "consist",
+		"knightly":       "knight",
+		"knackeries":     "knackeri",
+		"sensational":    "sensat",
+		"itemization":    "item",
+		"news":           "news",
+		"dying":          "die",
+		"user's":         "user",
+		// Русский
+		"книгами":          "книг",
+		"молока":           "молок",
+		"купить":           "куп",
+		"купила":           "куп",
+		"покупки":          "покупк",
+		"красивая":         "красив",
+		"бегающий":         "бега",
+		"важнейшие":        "важн",
+		"вагонов":          "вагон",
+		"программирование": "программирован",
+		"сделавшись":       "сдела",
+		"радостью":         "радост",
+		"длинный":          "длин",
+		// Числа и смешанные слова не меняются
+		"2024":   "2024",
+		"mp3":    "mp3",
+		"café":   "café",
+		"iphone": "iphon",
+	}
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	text := "Купить Ёлку и don’t forget: 2 pcs!"
+	tokens := Tokenize(text)
+
+	want := []Token{
+		{Word: "купить", Term: "куп", Pos: 0},
+		{Word: "елку", Term: "елк", Pos: 1},
+		// "и" - стоп-слово, но позицию занимает
+		{Word: "don't", Term: "don't", Pos: 3},
+		{Word: "forget", Term: "forget", Pos: 4},
+		{Word: "2", Term: "2", Pos: 5},
+		{Word: "pcs", Term: "pcs", Pos: 6},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("expected %d tokens, got %+v", len(want), tokens)
+	}
+	for i, w := range want {
+		got := tokens[i]
+		if got.Word != w.Word || got.Term != w.Term || got.Pos != w.Pos {
+			t.Errorf("token %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+	if got := text[tokens[2].Start:tokens[2].End]; got != "don’t" {
+		t.Errorf("expected offsets to point into the original text, got %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token - слово текста. Word - слово в нижнем регистре (ё заменена на е),
+// Term - его основа, под которой слово попадает в индекс. Pos - номер
+// слова в тексте с учетом стоп-слов, Start и End - границы слова в байтах
+// исходного текста.
+type Token struct {
+	Word       string
+	Term       string
+	Pos        int
+	Start, End int
+}
+
+// Tokenize разбивает текст на слова из букв и цифр; апостроф между буквами
+// считается частью слова (user's, don't). Стоп-слова пропускаются, но
+// занимают позицию, чтобы фраза "купить и продать" не совпала с "купить
+// продать".
+func Tokenize(text string) []Token {
+	return scan(text, false)
+}
+
+// scan разбивает текст на слова; keepStop оставляет стоп-слова
+func scan(text string, keepStop bool) []Token {
+	var tokens []Token
+	pos := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isWordRune(r) {
+			i += size
+			continue
+		}
+
+		start := i
+		for i < len(text) {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if isWordRune(r) {
+				i += size
+				continue
+			}
+			if isApostrophe(r) && i > start {
+				next, _ := utf8.DecodeRuneInString(text[i+size:])
+				if unicode.IsLetter(next) {
+					i += size
+					continue
+				}
+			}
+			break
+		}
+
+		word := normalize(text[start:i])
+		if keepStop || !stopWords[word] {
+			tokens = append(tokens, Token{Word: word, Term: Stem(word), Pos: pos, Start: start, End: i})
+		}
+		pos++
+	}
+	return tokens
+}
+
+// Stem возвращает основу слова, приведенного normalize. Слова из латиницы
+// обрабатываются английским стеммером, из кириллицы - русским, остальные
+// (числа, смешанные) не меняются.
+func Stem(word string) string {
+	latin, cyrillic := true, true
+	for _, r := range word {
+		latin = latin && (r >= 'a' && r <= 'z' || r == '\'')
+		cyrillic = cyrillic && r >= 'а' && r <= 'я'
+	}
+	switch {
+	case latin:
+		return stemEnglish(word)
+	case cyrillic:
+		return stemRussian(word)
+	default:
+		return word
+	}
+}
+
+// normalize приводит слово к нижнему регистру, заменяет ё на е и
+// типографский апостроф на обычный
+func normalize(word string) string {
+	return strings.Map(func(r rune) rune {
+		switch r = unicode.ToLower(r); r {
+		case 'ё':
+			return 'е'
+		case '’':
+			return '\''
+		}
+		return r
+	}, word)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// stopWords - частые служебные слова английского и русского языков. Они не
+// индексируются: почти в каждой задаче они есть, а требование их наличия
+// в запросе только отсеивало бы подходящие задачи.
+var stopWords = makeSet(
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "from", "if", "in", "into", "is", "it",
+	"of", "on", "or", "so", "than", "that", "the", "their", "then", "there", "these", "they", "this",
+	"to", "was", "were", "will", "with",
+	"а", "без", "бы", "в", "во", "вот", "да", "для", "до", "же", "за", "и", "из", "или", "к", "как", "ко",
+	"ли", "на", "над", "о", "об", "от", "по", "под", "при", "про", "с", "со", "то", "у", "что", "чтобы",
+)
+
+func makeSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
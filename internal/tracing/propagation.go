@@ -0,0 +1,153 @@
+// Package tracing реализует легковесную распределенную трассировку:
+// распространение контекста по стандарту W3C Trace Context (заголовки
+// traceparent и tracestate), спаны с семплированием и экспорт завершенных
+// спанов в формате OTLP JSON.
+package tracing
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Заголовки W3C Trace Context
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// maxTracestateLength - максимальная длина tracestate, которую мы передаем дальше
+const maxTracestateLength = 512
+
+// FlagSampled - флаг traceparent, означающий, что трасса записывается
+const FlagSampled byte = 0x01
+
+// ErrInvalidTraceparent возвращается при разборе некорректного заголовка
+var ErrInvalidTraceparent = errors.New("invalid traceparent header")
+
+// TraceID - идентификатор трассы
+type TraceID [16]byte
+
+// IsValid сообщает, что идентификатор не нулевой
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// String возвращает идентификатор в шестнадцатеричном виде
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID - идентификатор спана
+type SpanID [8]byte
+
+// IsValid сообщает, что идентификатор не нулевой
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// String возвращает идентификатор в шестнадцатеричном виде
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext - часть спана, передаваемая между сервисами
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	Remote     bool
+}
+
+// IsValid сообщает, что контекст содержит идентификаторы трассы и спана
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled сообщает, что трасса записывается
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent форматирует контекст как значение заголовка traceparent
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent разбирает заголовок traceparent. Версии новее 00
+// принимаются, если их начало совпадает с форматом версии 00.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || (len(value) > 55 && value[55] != '-') {
+		return sc, ErrInvalidTraceparent
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+
+	version, ok := decodeHex(value[0:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(value) != 55) {
+		return sc, ErrInvalidTraceparent
+	}
+
+	traceID, ok := decodeHex(value[3:35])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+	spanID, ok := decodeHex(value[36:52])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+	flags, ok := decodeHex(value[53:55])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	sc.Remote = true
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex декодирует строку только из строчных шестнадцатеричных цифр,
+// как того требует спецификация
+func decodeHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// Extract читает контекст трассы из заголовков. Если traceparent отсутствует
+// или некорректен, возвращается пустой контекст, а tracestate игнорируется.
+func Extract(h http.Header) SpanContext {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}
+	}
+
+	state := strings.TrimSpace(strings.Join(h.Values(TracestateHeader), ","))
+	if len(state) <= maxTracestateLength {
+		sc.TraceState = state
+	}
+	return sc
+}
+
+// Inject записывает контекст трассы в заголовки
+func Inject(sc SpanContext, h http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
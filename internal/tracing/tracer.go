@@ -0,0 +1,435 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind - роль спана в трассе (значения совпадают с OTLP)
+type SpanKind int
+
+// Виды спанов
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute - атрибут спана
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr создает атрибут
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData - неизменяемый снимок завершенного спана для экспорта
+type SpanData struct {
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	Name         string
+	Kind         SpanKind
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	Error        string
+}
+
+// Sampler решает, записывать ли новую трассу или спан
+type Sampler interface {
+	ShouldSample(parent SpanContext, traceID TraceID) bool
+}
+
+type samplerFunc func(parent SpanContext, traceID TraceID) bool
+
+func (f samplerFunc) ShouldSample(parent SpanContext, traceID TraceID) bool {
+	return f(parent, traceID)
+}
+
+// AlwaysSample записывает все трассы
+func AlwaysSample() Sampler {
+	return samplerFunc(func(SpanContext, TraceID) bool { return true })
+}
+
+// NeverSample не записывает трассы
+func NeverSample() Sampler {
+	return samplerFunc(func(SpanContext, TraceID) bool { return false })
+}
+
+// TraceIDRatio записывает долю ratio трасс. Решение детерминировано по
+// идентификатору трассы, поэтому все сервисы с той же долей согласованы.
+func TraceIDRatio(ratio float64) Sampler {
+	switch {
+	case ratio >= 1:
+		return AlwaysSample()
+	case ratio <= 0:
+		return NeverSample()
+	}
+
+	threshold := uint64(ratio * math.MaxUint64)
+	return samplerFunc(func(_ SpanContext, traceID TraceID) bool {
+		return binary.BigEndian.Uint64(traceID[8:]) < threshold
+	})
+}
+
+// ParentBased следует решению родительского спана, а для корневых спанов
+// использует root
+func ParentBased(root Sampler) Sampler {
+	return samplerFunc(func(parent SpanContext, traceID TraceID) bool {
+		if parent.IsValid() {
+			return parent.IsSampled()
+		}
+		return root.ShouldSample(parent, traceID)
+	})
+}
+
+// Exporter отправляет завершенные спаны
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Параметры пакетной отправки спанов
+const (
+	defaultQueueSize     = 2048
+	defaultBatchSize     = 512
+	defaultFlushInterval = 2 * time.Second
+)
+
+// Tracer создает спаны и пакетами передает завершенные в Exporter
+type Tracer struct {
+	sampler  Sampler
+	exporter Exporter
+
+	mu      sync.RWMutex // защищает closed и закрытие queue
+	closed  bool
+	queue   chan SpanData
+	flush   chan chan struct{}
+	stopped chan struct{}
+	dropped atomic.Int64
+}
+
+// NewTracer создает трассировщик. Если exporter равен nil, контекст трассы
+// распространяется, но спаны никуда не отправляются.
+func NewTracer(sampler Sampler, exporter Exporter) *Tracer {
+	t := &Tracer{
+		sampler:  sampler,
+		exporter: exporter,
+		queue:    make(chan SpanData, defaultQueueSize),
+		flush:    make(chan chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if exporter != nil {
+		go t.run()
+	} else {
+		close(t.stopped)
+	}
+	return t
+}
+
+// StartOption настраивает создаваемый спан
+type StartOption func(*startConfig)
+
+type startConfig struct {
+	kind         SpanKind
+	remoteParent SpanContext
+	attributes   []Attribute
+}
+
+// WithKind задает вид спана
+func WithKind(kind SpanKind) StartOption {
+	return func(c *startConfig) { c.kind = kind }
+}
+
+// WithRemoteParent задает родителя, полученного из другого сервиса
+func WithRemoteParent(sc SpanContext) StartOption {
+	return func(c *startConfig) { c.remoteParent = sc }
+}
+
+// WithAttributes задает начальные атрибуты спана
+func WithAttributes(attrs ...Attribute) StartOption {
+	return func(c *startConfig) { c.attributes = append(c.attributes, attrs...) }
+}
+
+// Start создает спан. Родителем становится спан из ctx или, если задан,
+// удаленный родитель из WithRemoteParent.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	cfg := startConfig{kind: SpanKindInternal}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	parent := cfg.remoteParent
+	if !parent.IsValid() {
+		if s := SpanFromContext(ctx); s != nil {
+			parent = s.sc
+		}
+	}
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+	}
+	if t.sampler.ShouldSample(parent, sc.TraceID) {
+		sc.Flags |= FlagSampled
+	}
+
+	span := &Span{
+		tracer:     t,
+		sc:         sc,
+		parent:     parent.SpanID,
+		name:       name,
+		kind:       cfg.kind,
+		start:      time.Now(),
+		attributes: cfg.attributes,
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Flush отправляет накопленные спаны и ждет завершения отправки
+func (t *Tracer) Flush(ctx context.Context) {
+	done := make(chan struct{})
+	select {
+	case t.flush <- done:
+	case <-t.stopped:
+		return
+	case <-ctx.Done():
+		return
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Shutdown отправляет оставшиеся спаны и закрывает экспортер
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	if t.exporter == nil {
+		return
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	// Спан завершился после Shutdown
+	if t.closed {
+		t.dropped.Add(1)
+		return
+	}
+
+	select {
+	case t.queue <- data:
+	default:
+		if t.dropped.Add(1)%defaultQueueSize == 1 {
+			slog.Warn("Tracing queue is full, dropping spans", "dropped", t.dropped.Load())
+		}
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(defaultFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, defaultBatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+			slog.Warn("Failed to export spans", "error", err, "spans", len(batch))
+		}
+		batch = make([]SpanData, 0, defaultBatchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-t.queue:
+			if !ok {
+				export()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= defaultBatchSize {
+				export()
+			}
+		case done := <-t.flush:
+			for drained := false; !drained; {
+				select {
+				case data, ok := <-t.queue:
+					if ok {
+						batch = append(batch, data)
+					} else {
+						drained = true
+					}
+				default:
+					drained = true
+				}
+			}
+			export()
+			close(done)
+		case <-ticker.C:
+			export()
+		}
+	}
+}
+
+// Span - выполняемая операция в трассе
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   SpanKind
+	start  time.Time
+
+	mu         sync.Mutex
+	attributes []Attribute
+	err        string
+	ended      bool
+}
+
+// SpanContext возвращает контекст спана для распространения
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes добавляет атрибуты
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil || !s.sc.IsSampled() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attributes = append(s.attributes, attrs...)
+}
+
+// RecordError отмечает спан как завершившийся ошибкой; nil игнорируется
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err.Error()
+}
+
+// End завершает спан. Повторные вызовы игнорируются.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		SpanContext:  s.sc,
+		ParentSpanID: s.parent,
+		Name:         s.name,
+		Kind:         s.kind,
+		Start:        s.start,
+		End:          time.Now(),
+		Attributes:   append([]Attribute(nil), s.attributes...),
+		Error:        s.err,
+	}
+	s.mu.Unlock()
+
+	if s.sc.IsSampled() {
+		s.tracer.enqueue(data)
+	}
+}
+
+// EndWithError записывает ошибку (если она есть) и завершает спан
+func (s *Span) EndWithError(err error) {
+	s.RecordError(err)
+	s.End()
+}
+
+type spanKey struct{}
+
+// ContextWithSpan возвращает контекст с текущим спаном
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext возвращает текущий спан или nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start создает дочерний спан текущего спана из ctx тем же трассировщиком.
+// Если в контексте нет спана, трассировка для запроса выключена и
+// возвращается nil-спан, методы которого ничего не делают.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, opts...)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
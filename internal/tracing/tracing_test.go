@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{name: "корректный заголовок", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true, sampled: true},
+		{name: "без семплирования", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", valid: true},
+		{name: "будущая версия с расширением", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", valid: true, sampled: true},
+		{name: "версия ff", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "версия 00 с лишними данными", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "нулевой trace-id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "нулевой span-id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "заглавные буквы", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "короткий заголовок", value: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+		{name: "пустой заголовок", value: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tc.value)
+			if tc.valid != (err == nil) {
+				t.Fatalf("expected valid=%v, got error %v", tc.valid, err)
+			}
+			if tc.valid && sc.IsSampled() != tc.sampled {
+				t.Errorf("expected sampled=%v", tc.sampled)
+			}
+		})
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	in := http.Header{}
+	in.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Add(TracestateHeader, "vendor1=a")
+	in.Add(TracestateHeader, "vendor2=b")
+
+	sc := Extract(in)
+	if !sc.IsValid() || !sc.Remote {
+		t.Fatalf("expected valid remote span context, got %+v", sc)
+	}
+	if sc.TraceState != "vendor1=a,vendor2=b" {
+		t.Errorf("unexpected tracestate %q", sc.TraceState)
+	}
+
+	out := http.Header{}
+	Inject(sc, out)
+	if out.Get(TraceparentHeader) != in.Get(TraceparentHeader) {
+		t.Errorf("expected traceparent %q, got %q", in.Get(TraceparentHeader), out.Get(TraceparentHeader))
+	}
+	if out.Get(TracestateHeader) != sc.TraceState {
+		t.Errorf("expected tracestate %q, got %q", sc.TraceState, out.Get(TracestateHeader))
+	}
+}
+
+func TestTraceIDRatio(t *testing.T) {
+	sampler := TraceIDRatio(0.25)
+
+	sampled := 0
+	const total = 10000
+	for i := 0; i < total; i++ {
+		if sampler.ShouldSample(SpanContext{}, newTraceID()) {
+			sampled++
+		}
+	}
+
+	if sampled < total*20/100 || sampled > total*30/100 {
+		t.Errorf("expected about 25%% sampled, got %d of %d", sampled, total)
+	}
+}
+
+func TestParentBased(t *testing.T) {
+	sampler := ParentBased(NeverSample())
+	parent := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Flags: FlagSampled}
+
+	if !sampler.ShouldSample(parent, parent.TraceID) {
+		t.Error("expected sampled parent to be followed")
+	}
+	if sampler.ShouldSample(SpanContext{}, newTraceID()) {
+		t.Error("expected root sampler to be used without parent")
+	}
+}
+
+func TestTracer_Export(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(AlwaysSample(), NewWriterExporter(&buf, "test"))
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tracer.Start(context.Background(), "root",
+		WithKind(SpanKindServer), WithRemoteParent(remote), WithAttributes(Attr("http.route", "/todos")))
+
+	_, child := Start(ctx, "child")
+	child.EndWithError(errors.New("boom"))
+	root.End()
+	root.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var req otlpTraces
+	if err := json.Unmarshal(buf.Bytes(), &req); err != nil {
+		t.Fatalf("expected OTLP JSON, got %q: %v", buf.String(), err)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	byName := map[string]otlpSpan{}
+	for _, s := range spans {
+		byName[s.Name] = s
+		if s.TraceID != remote.TraceID.String() {
+			t.Errorf("span %s: expected trace ID %s, got %s", s.Name, remote.TraceID, s.TraceID)
+		}
+	}
+
+	if byName["root"].ParentSpanID != remote.SpanID.String() {
+		t.Errorf("expected root parent %s, got %s", remote.SpanID, byName["root"].ParentSpanID)
+	}
+	if byName["root"].Kind != SpanKindServer {
+		t.Errorf("expected server span, got kind %d", byName["root"].Kind)
+	}
+	if byName["child"].ParentSpanID != byName["root"].SpanID {
+		t.Error("expected child to be nested in root")
+	}
+	if byName["child"].Status.Code != otlpStatusError || byName["child"].Status.Message != "boom" {
+		t.Errorf("expected error status, got %+v", byName["child"].Status)
+	}
+}
+
+func TestStart_WithoutTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("expected noop span without tracer in context")
+	}
+
+	// Методы nil-спана безопасны
+	span.SetAttributes(Attr("key", "value"))
+	span.EndWithError(errors.New("ignored"))
+}
@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/tracing"
+)
+
+// SmartList - встроенный список задач. Его запрос зависит от текущего дня,
+// поэтому строится при каждом обращении.
+type SmartList struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Sort  string `json:"sort"`
+
+	query func(now time.Time) string
+}
+
+// UpcomingDays - на сколько дней вперед, не считая сегодняшнего, смотрит
+// список Upcoming
+const UpcomingDays = 7
+
+// smartLists - встроенные списки в порядке показа
+var smartLists = []SmartList{
+	{ID: "today", Name: "Today", Sort: "due", query: func(time.Time) string {
+		return "status:open AND due:today"
+	}},
+	{ID: "upcoming", Name: "Upcoming", Sort: "due", query: func(now time.Time) string {
+		// Срок до начала дня после последнего из UpcomingDays
+		return "status:open AND due>today AND due<" + now.AddDate(0, 0, UpcomingDays+1).Format(time.DateOnly)
+	}},
+	{ID: "overdue", Name: "Overdue", Sort: "due", query: func(time.Time) string {
+		return "status:overdue"
+	}},
+	{ID: "no-due-date", Name: "No Due Date", query: func(time.Time) string {
+		return "status:open AND due:none"
+	}},
+}
+
+// FilterUseCase управляет сохраненными фильтрами и выполняет их и
+// встроенные списки. Фильтры видны только их владельцу: чужой фильтр
+// считается несуществующим.
+type FilterUseCase struct {
+	filters domain.FilterRepository
+	todos   domain.TodoRepository
+	now     func() time.Time
+}
+
+// NewFilterUseCase создает use case фильтров над хранилищем задач todos
+func NewFilterUseCase(filters domain.FilterRepository, todos domain.TodoRepository) *FilterUseCase {
+	return &FilterUseCase{filters: filters, todos: todos, now: time.Now}
+}
+
+// SmartLists возвращает встроенные списки с запросами на текущий момент
+func (uc *FilterUseCase) SmartLists() []SmartList {
+	now := uc.now()
+	lists := make([]SmartList, len(smartLists))
+	for i, list := range smartLists {
+		lists[i] = list
+		lists[i].Query = list.query(now)
+	}
+	return lists
+}
+
+// SmartList возвращает встроенный список по ID или ErrFilterNotFound
+func (uc *FilterUseCase) SmartList(id string) (SmartList, error) {
+	for _, list := range uc.SmartLists() {
+		if list.ID == id {
+			return list, nil
+		}
+	}
+	return SmartList{}, domain.ErrFilterNotFound
+}
+
+// SmartListTodos возвращает задачи встроенного списка id
+func (uc *FilterUseCase) SmartListTodos(ctx context.Context, id string) (_ []*domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "FilterUseCase.SmartListTodos", tracing.WithAttributes(tracing.Attr("filter.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	list, err := uc.SmartList(id)
+	if err != nil {
+		return nil, err
+	}
+	return uc.run(ctx, list.Query, list.Sort)
+}
+
+// CreateFilter сохраняет фильтр принципала owner
+func (uc *FilterUseCase) CreateFilter(ctx context.Context, owner string, filter *domain.Filter) (_ *domain.Filter, err error) {
+	ctx, span := tracing.Start(ctx, "FilterUseCase.CreateFilter")
+	defer func() { span.EndWithError(err) }()
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	filter.Owner = owner
+	filter.CreatedAt = uc.now().UTC()
+
+	if err := uc.filters.CreateFilter(ctx, filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// GetFilters возвращает фильтры принципала owner
+func (uc *FilterUseCase) GetFilters(ctx context.Context, owner string) (_ []*domain.Filter, err error) {
+	ctx, span := tracing.Start(ctx, "FilterUseCase.GetFilters")
+	defer func() { span.EndWithError(err) }()
+
+	return uc.filters.GetFilters(ctx, owner)
+}
+
+// GetFilter возвращает фильтр принципала owner по ID
+func (uc *FilterUseCase) GetFilter(ctx context.Context, owner string, id int) (_ *domain.Filter, err error) {
+	ctx, span := tracing.Start(ctx, "FilterUseCase.GetFilter", tracing.WithAttributes(tracing.Attr("filter.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	return uc.owned(ctx, owner, id)
+}
+
+// UpdateFilter заменяет фильтр принципала owner
+func (uc *FilterUseCase) UpdateFilter(ctx context.Context, owner string, id int, filter *domain.Filter) (_ *domain.Filter, err error) {
+	ctx, span := tracing.Start(ctx, "FilterUseCase.UpdateFilter", tracing.WithAttributes(tracing.Attr("filter.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	if _, err := uc.owned(ctx, owner, id); err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	filter.ID = id
+	if err := uc.filters.UpdateFilter(ctx, filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// DeleteFilter удаляет фильтр принципала owner
+func (uc *FilterUseCase) DeleteFilter(ctx context.Context, owner string, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "FilterUseCase.DeleteFilter", tracing.WithAttributes(tracing.Attr("filter.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	if _, err := uc.owned(ctx, owner, id); err != nil {
+		return err
+	}
+	return uc.filters.DeleteFilter(ctx, id)
+}
+
+// FilterTodos выполняет фильтр принципала owner: возвращает задачи,
+// подходящие под его запрос сейчас, в порядке фильтра
+func (uc *FilterUseCase) FilterTodos(ctx context.Context, owner string, id int) (_ []*domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "FilterUseCase.FilterTodos", tracing.WithAttributes(tracing.Attr("filter.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	filter, err := uc.owned(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	return uc.run(ctx, filter.Query, filter.Sort)
+}
+
+// owned возвращает фильтр, если он принадлежит owner, иначе
+// ErrFilterNotFound
+func (uc *FilterUseCase) owned(ctx context.Context, owner string, id int) (*domain.Filter, error) {
+	filter, err := uc.filters.GetFilter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Owner != owner {
+		return nil, domain.ErrFilterNotFound
+	}
+	return filter, nil
+}
+
+// run выполняет запрос и упорядочивает результат
+func (uc *FilterUseCase) run(ctx context.Context, query, order string) ([]*domain.Todo, error) {
+	expr, err := domain.ParseQuery(query, uc.now())
+	if err != nil {
+		return nil, err
+	}
+	todos, err := uc.todos.Find(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+	domain.SortTodos(todos, order)
+	return todos, nil
+}
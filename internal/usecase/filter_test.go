@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/repository"
+)
+
+func TestFilterUseCase_SmartLists(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.Local)
+	at := func(days, hour int) *time.Time {
+		d := time.Date(2025, 3, 10+days, hour, 0, 0, 0, time.Local)
+		return &d
+	}
+
+	todos := repository.NewInMemoryTodoRepository()
+	uc := NewFilterUseCase(repository.NewInMemoryFilterRepository(), todos)
+	uc.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	created := map[string]*domain.Todo{
+		"сегодня позже":       {Title: "a", DueDate: at(0, 20)},
+		"сегодня просрочена":  {Title: "b", DueDate: at(0, 9)},
+		"вчера":               {Title: "c", DueDate: at(-1, 9)},
+		"вчера выполнена":     {Title: "d", DueDate: at(-1, 9), Completed: true},
+		"завтра":              {Title: "e", DueDate: at(1, 9)},
+		"через неделю":        {Title: "f", DueDate: at(UpcomingDays, 23)},
+		"через восемь дней":   {Title: "g", DueDate: at(UpcomingDays+1, 0)},
+		"без срока":           {Title: "h"},
+		"без срока выполнена": {Title: "i", Completed: true},
+	}
+	for _, name := range []string{"через неделю", "сегодня позже", "без срока", "завтра", "вчера",
+		"сегодня просрочена", "вчера выполнена", "через восемь дней", "без срока выполнена"} {
+		if err := todos.Create(ctx, created[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"today", []string{"сегодня просрочена", "сегодня позже"}},
+		{"upcoming", []string{"завтра", "через неделю"}},
+		{"overdue", []string{"вчера", "сегодня просрочена"}},
+		{"no-due-date", []string{"без срока"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.list, func(t *testing.T) {
+			found, err := uc.SmartListTodos(ctx, tc.list)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var want []int
+			for _, name := range tc.want {
+				want = append(want, created[name].ID)
+			}
+			var got []int
+			for _, todo := range found {
+				got = append(got, todo.ID)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+
+	t.Run("неизвестный список", func(t *testing.T) {
+		if _, err := uc.SmartListTodos(ctx, "someday"); !errors.Is(err, domain.ErrFilterNotFound) {
+			t.Errorf("expected ErrFilterNotFound, got %v", err)
+		}
+	})
+}
+
+func TestFilterUseCase_SavedFilters(t *testing.T) {
+	todos := repository.NewInMemoryTodoRepository()
+	uc := NewFilterUseCase(repository.NewInMemoryFilterRepository(), todos)
+	ctx := context.Background()
+
+	for _, todo := range []*domain.Todo{
+		{Title: "Бюджет", Project: "work"},
+		{Title: "Отчет", Project: "work", Tags: []string{"urgent"}},
+		{Title: "Архив", Project: "work", Completed: true},
+	} {
+		if err := todos.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter, err := uc.CreateFilter(ctx, "alice", &domain.Filter{Name: "Работа", Query: "project:work status:open", Sort: "title"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filter.Owner != "alice" || filter.CreatedAt.IsZero() {
+		t.Errorf("expected owner and creation time to be set, got %+v", filter)
+	}
+
+	t.Run("выполнение по запросу и порядку фильтра", func(t *testing.T) {
+		found, err := uc.FilterTodos(ctx, "alice", filter.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(found) != 2 || found[0].Title != "Бюджет" || found[1].Title != "Отчет" {
+			t.Errorf("unexpected todos %+v", found)
+		}
+
+		// Фильтр выполняется заново при каждом обращении
+		todos.Create(ctx, &domain.Todo{Title: "Аудит", Project: "Work"})
+		if found, _ := uc.FilterTodos(ctx, "alice", filter.ID); len(found) != 3 || found[0].Title != "Аудит" {
+			t.Errorf("expected the new todo first, got %+v", found)
+		}
+	})
+
+	t.Run("чужой фильтр не виден", func(t *testing.T) {
+		if _, err := uc.GetFilter(ctx, "bob", filter.ID); !errors.Is(err, domain.ErrFilterNotFound) {
+			t.Errorf("expected ErrFilterNotFound, got %v", err)
+		}
+		if _, err := uc.FilterTodos(ctx, "bob", filter.ID); !errors.Is(err, domain.ErrFilterNotFound) {
+			t.Errorf("expected ErrFilterNotFound, got %v", err)
+		}
+		if err := uc.DeleteFilter(ctx, "bob", filter.ID); !errors.Is(err, domain.ErrFilterNotFound) {
+			t.Errorf("expected ErrFilterNotFound, got %v", err)
+		}
+		if filters, _ := uc.GetFilters(ctx, "bob"); len(filters) != 0 {
+			t.Errorf("expected no filters for bob, got %+v", filters)
+		}
+	})
+
+	t.Run("неверный запрос и порядок", func(t *testing.T) {
+		_, err := uc.UpdateFilter(ctx, "alice", filter.ID, &domain.Filter{Name: "x", Query: "tag:(", Sort: "priority"})
+		var verr *domain.ValidationError
+		if !errors.As(err, &verr) || len(verr.Violations) != 2 {
+			t.Fatalf("expected query and sort violations, got %v", err)
+		}
+		if verr.Violations[0].Field != "query" || verr.Violations[1].Field != "sort" {
+			t.Errorf("unexpected violations %+v", verr.Violations)
+		}
+	})
+}
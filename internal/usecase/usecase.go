@@ -2,24 +2,55 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"todo/internal/domain"
+	"todo/internal/quickadd"
+	"todo/internal/tracing"
 )
 
 // TodoUseCase содержит бизнес-логику для работы с задачами
 type TodoUseCase struct {
-	repo domain.TodoRepository
+	repo   domain.TodoRepository
+	events domain.EventPublisher
+}
+
+// Option настраивает TodoUseCase
+type Option func(*TodoUseCase)
+
+// WithEventPublisher публикует изменения задач в events
+func WithEventPublisher(events domain.EventPublisher) Option {
+	return func(uc *TodoUseCase) { uc.events = events }
 }
 
 // NewTodoUseCase создает новый экземпляр use case
-func NewTodoUseCase(repo domain.TodoRepository) *TodoUseCase {
-	return &TodoUseCase{
+func NewTodoUseCase(repo domain.TodoRepository, opts ...Option) *TodoUseCase {
+	uc := &TodoUseCase{
 		repo: repo,
 	}
+	for _, opt := range opts {
+		opt(uc)
+	}
+	return uc
+}
+
+// publish сообщает об изменении задачи, передавая ее копию, чтобы
+// подписчики не видели последующих изменений
+func (uc *TodoUseCase) publish(eventType domain.EventType, todo *domain.Todo) {
+	if uc.events == nil {
+		return
+	}
+	uc.events.Publish(domain.TodoEvent{Type: eventType, Todo: todo.Clone(), Time: time.Now()})
 }
 
 // CreateTodo создает новую задачу
-func (uc *TodoUseCase) CreateTodo(ctx context.Context, todo *domain.Todo) (*domain.Todo, error) {
+func (uc *TodoUseCase) CreateTodo(ctx context.Context, todo *domain.Todo) (_ *domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.CreateTodo")
+	defer func() { span.EndWithError(err) }()
+
 	// Валидация
 	if err := todo.Validate(); err != nil {
 		return nil, err
@@ -29,29 +60,69 @@ func (uc *TodoUseCase) CreateTodo(ctx context.Context, todo *domain.Todo) (*doma
 	if err := uc.repo.Create(ctx, todo); err != nil {
 		return nil, err
 	}
+	slog.DebugContext(ctx, "Todo created", "id", todo.ID)
+	uc.publish(domain.EventTodoCreated, todo)
 
 	return todo, nil
 }
 
-// GetAllTodos возвращает все задачи
-func (uc *TodoUseCase) GetAllTodos(ctx context.Context) ([]*domain.Todo, error) {
+// GetAllTodos возвращает все задачи в ручном порядке: по проектам, а в
+// проекте - по рангу
+func (uc *TodoUseCase) GetAllTodos(ctx context.Context) (_ []*domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.GetAllTodos")
+	defer func() { span.EndWithError(err) }()
+
 	return uc.repo.GetAll(ctx)
 }
 
+// GetStats возвращает сводную статистику по задачам
+func (uc *TodoUseCase) GetStats(ctx context.Context) (_ domain.TodoStats, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.GetStats")
+	defer func() { span.EndWithError(err) }()
+
+	todos, err := uc.repo.GetAll(ctx)
+	if err != nil {
+		return domain.TodoStats{}, err
+	}
+
+	now := time.Now()
+	stats := domain.TodoStats{Total: len(todos)}
+	for _, todo := range todos {
+		if todo.Completed {
+			stats.Completed++
+		}
+		if todo.IsOverdue(now) {
+			stats.Overdue++
+		}
+	}
+
+	return stats, nil
+}
+
 // GetTodoByID возвращает задачу по идентификатору
-func (uc *TodoUseCase) GetTodoByID(ctx context.Context, id int) (*domain.Todo, error) {
+func (uc *TodoUseCase) GetTodoByID(ctx context.Context, id int) (_ *domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.GetTodoByID", tracing.WithAttributes(tracing.Attr("todo.id", id)))
+	defer func() { span.EndWithError(err) }()
+
 	return uc.repo.GetByID(ctx, id)
 }
 
 // UpdateTodo обновляет существующую задачу
-func (uc *TodoUseCase) UpdateTodo(ctx context.Context, id int, todo *domain.Todo) (*domain.Todo, error) {
+func (uc *TodoUseCase) UpdateTodo(ctx context.Context, id int, todo *domain.Todo) (_ *domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.UpdateTodo", tracing.WithAttributes(tracing.Attr("todo.id", id)))
+	defer func() { span.EndWithError(err) }()
+
 	// Валидация
 	if err := todo.Validate(); err != nil {
 		return nil, err
 	}
 
-	// Проверка существования
+	// Проверка существования. Exists не возвращает ошибку, поэтому отмену
+	// контекста проверяем отдельно, чтобы не выдать ее за отсутствие задачи
 	if !uc.repo.Exists(ctx, id) {
+		if err := domain.ContextErr(ctx); err != nil {
+			return nil, err
+		}
 		return nil, domain.ErrTodoNotFound
 	}
 
@@ -62,11 +133,213 @@ func (uc *TodoUseCase) UpdateTodo(ctx context.Context, id int, todo *domain.Todo
 	if err := uc.repo.Update(ctx, todo); err != nil {
 		return nil, err
 	}
+	slog.DebugContext(ctx, "Todo updated", "id", todo.ID)
+	uc.publish(domain.EventTodoUpdated, todo)
 
 	return todo, nil
 }
 
+// MoveTodo ставит задачу id в ручном порядке ее списка рядом с anchor
+// (см. domain.TodoRepository.Move). Об изменении ранга каждой задачи, в том
+// числе при перераспределении рангов списка, публикуется событие.
+func (uc *TodoUseCase) MoveTodo(ctx context.Context, id int, anchor domain.Anchor) (_ *domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.MoveTodo", tracing.WithAttributes(tracing.Attr("todo.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	if err := anchor.Validate(); err != nil {
+		return nil, err
+	}
+
+	moved, changed, err := uc.repo.Move(ctx, id, anchor)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) > 1 {
+		slog.DebugContext(ctx, "Todo list rebalanced", "project", moved.Project, "todos", len(changed))
+	}
+	for _, todo := range changed {
+		uc.publish(domain.EventTodoUpdated, todo)
+	}
+	return moved, nil
+}
+
 // DeleteTodo удаляет задачу
-func (uc *TodoUseCase) DeleteTodo(ctx context.Context, id int) error {
-	return uc.repo.Delete(ctx, id)
+func (uc *TodoUseCase) DeleteTodo(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.DeleteTodo", tracing.WithAttributes(tracing.Attr("todo.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	// Событие удаления несет последнее состояние задачи, чтобы подписчики
+	// могли применить к нему свои фильтры
+	var deleted *domain.Todo
+	if uc.events != nil {
+		todo, err := uc.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		deleted = todo.Clone()
+	}
+
+	if err := uc.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Todo deleted", "id", id)
+	if deleted != nil {
+		uc.publish(domain.EventTodoDeleted, deleted)
+	}
+	return nil
+}
+
+// SearchTodos ищет задачи по словам названия и описания и возвращает не
+// больше limit лучших совпадений и общее число найденных задач
+func (uc *TodoUseCase) SearchTodos(ctx context.Context, query string, limit int) (_ []domain.SearchHit, total int, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.SearchTodos")
+	defer func() { span.EndWithError(err) }()
+
+	return uc.repo.Search(ctx, query, limit)
+}
+
+// QueryTodos возвращает задачи, подходящие под запрос на языке запросов
+// (см. domain.ParseQuery), в ручном порядке, как GetAllTodos. Относительные
+// сроки в запросе отсчитываются от текущего момента. Ошибка разбора -
+// *domain.QueryError.
+func (uc *TodoUseCase) QueryTodos(ctx context.Context, query string) (_ []*domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.QueryTodos")
+	defer func() { span.EndWithError(err) }()
+
+	expr, err := domain.ParseQuery(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(tracing.Attr("query", expr.String()))
+	todos, err := uc.repo.Find(ctx, expr)
+	if err != nil {
+		return nil, err
+	}
+	domain.SortTodos(todos, domain.SortRank)
+	return todos, nil
+}
+
+// QuickAdd создает задачу из строки свободного текста (см. quickadd.Parse).
+// Даты в тексте понимаются в часовом поясе loc. При preview задача только
+// разбирается и проверяется, но не сохраняется.
+func (uc *TodoUseCase) QuickAdd(ctx context.Context, text string, loc *time.Location, preview bool) (_ *domain.Todo, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.QuickAdd", tracing.WithAttributes(tracing.Attr("quickadd.preview", preview)))
+	defer func() { span.EndWithError(err) }()
+
+	todo := quickadd.Parse(text, time.Now().In(loc))
+	if preview {
+		if err := todo.Validate(); err != nil {
+			return nil, err
+		}
+		return todo, nil
+	}
+	return uc.CreateTodo(ctx, todo)
+}
+
+// Changes возвращает изменения задач после номера журнала since для
+// синхронизации клиента, не больше limit, и последний номер журнала
+func (uc *TodoUseCase) Changes(ctx context.Context, since uint64, limit int) (_ []domain.Change, latest uint64, err error) {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.Changes", tracing.WithAttributes(tracing.Attr("sync.since", since)))
+	defer func() { span.EndWithError(err) }()
+
+	return uc.repo.Changes(ctx, since, limit)
+}
+
+// ApplyChanges применяет изменения, накопленные клиентом без связи, по
+// порядку и независимо друг от друга: ошибка одного изменения не отменяет
+// остальные.
+//
+// Правка задачи, которая после BaseVersion менялась другими клиентами,
+// сливается с их правками по полям (см. TodoRepository.Merge): правки
+// разных полей сохраняются все, а одного поля - пришедшая позже. Удаление
+// побеждает параллельные правки: правка удаленной задачи не применяется, а
+// удаление применяется независимо от BaseVersion. Если BaseVersion
+// слишком старая для слияния, правка не применяется, а в результате
+// возвращается текущее состояние задачи, чтобы клиент перенес на него свою
+// правку и отправил ее заново.
+func (uc *TodoUseCase) ApplyChanges(ctx context.Context, changes []domain.SyncChange) []domain.SyncResult {
+	ctx, span := tracing.Start(ctx, "TodoUseCase.ApplyChanges", tracing.WithAttributes(tracing.Attr("sync.changes", len(changes))))
+	defer span.End()
+
+	results := make([]domain.SyncResult, len(changes))
+	for i, change := range changes {
+		// Прерванная синхронизация сообщает, какие изменения не применены
+		if err := domain.ContextErr(ctx); err != nil {
+			results[i] = domain.SyncResult{Status: domain.SyncRejected, Err: err}
+			continue
+		}
+		results[i] = uc.applyChange(ctx, change)
+	}
+	return results
+}
+
+func (uc *TodoUseCase) applyChange(ctx context.Context, change domain.SyncChange) domain.SyncResult {
+	switch change.Op {
+	case domain.SyncCreate:
+		todo, err := uc.CreateTodo(ctx, change.Todo)
+		if err != nil {
+			return domain.SyncResult{Status: domain.SyncRejected, Err: err}
+		}
+		return domain.SyncResult{Status: domain.SyncApplied, Todo: todo}
+
+	case domain.SyncUpdate:
+		todo := change.Todo
+		if err := todo.Validate(); err != nil {
+			return domain.SyncResult{Status: domain.SyncRejected, Err: err}
+		}
+		todo.ID = change.ID
+
+		merged, concurrent, err := uc.repo.Merge(ctx, change.ID, change.BaseVersion, todo)
+		switch {
+		case errors.Is(err, domain.ErrTodoNotFound):
+			// Правка не применена: клиент получает надгробие вместо задачи
+			return domain.SyncResult{Status: domain.SyncConflict, Deleted: true}
+		case errors.Is(err, domain.ErrVersionUnavailable):
+			return uc.conflict(ctx, change.ID)
+		case err != nil:
+			return domain.SyncResult{Status: domain.SyncRejected, Err: err}
+		}
+		slog.DebugContext(ctx, "Todo updated by sync", "id", merged.ID, "merged", concurrent)
+		uc.publish(domain.EventTodoUpdated, merged)
+		if concurrent {
+			return domain.SyncResult{Status: domain.SyncMerged, Todo: merged}
+		}
+		return domain.SyncResult{Status: domain.SyncApplied, Todo: merged}
+
+	case domain.SyncDelete:
+		current, err := uc.repo.GetByID(ctx, change.ID)
+		if errors.Is(err, domain.ErrTodoNotFound) {
+			return domain.SyncResult{Status: domain.SyncApplied, Deleted: true}
+		}
+		if err != nil {
+			return domain.SyncResult{Status: domain.SyncRejected, Err: err}
+		}
+		deleted := current.Clone()
+
+		err = uc.repo.Delete(ctx, change.ID)
+		switch {
+		case errors.Is(err, domain.ErrTodoNotFound):
+			return domain.SyncResult{Status: domain.SyncApplied, Deleted: true}
+		case err != nil:
+			return domain.SyncResult{Status: domain.SyncRejected, Err: err}
+		}
+		slog.DebugContext(ctx, "Todo deleted by sync", "id", change.ID)
+		uc.publish(domain.EventTodoDeleted, deleted)
+		return domain.SyncResult{Status: domain.SyncApplied, Deleted: true}
+	}
+
+	return domain.SyncResult{Status: domain.SyncRejected, Err: fmt.Errorf("%w: unknown sync operation %q", domain.ErrInvalidTodoData, change.Op)}
+}
+
+// conflict возвращает текущее состояние задачи, с которым столкнулось
+// изменение клиента
+func (uc *TodoUseCase) conflict(ctx context.Context, id int) domain.SyncResult {
+	current, err := uc.repo.GetByID(ctx, id)
+	switch {
+	case errors.Is(err, domain.ErrTodoNotFound):
+		return domain.SyncResult{Status: domain.SyncConflict, Deleted: true}
+	case err != nil:
+		return domain.SyncResult{Status: domain.SyncRejected, Err: err}
+	}
+	return domain.SyncResult{Status: domain.SyncConflict, Todo: current}
 }
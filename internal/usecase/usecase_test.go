@@ -2,7 +2,9 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"todo/internal/domain"
 	"todo/internal/repository"
@@ -183,3 +185,181 @@ func TestTodoUseCase_DeleteTodo(t *testing.T) {
 		}
 	})
 }
+
+func TestTodoUseCase_ContextCancellation(t *testing.T) {
+	repo := repository.NewInMemoryTodoRepository()
+	uc := NewTodoUseCase(repo)
+
+	created, _ := uc.CreateTodo(context.Background(), &domain.Todo{Title: "Existing"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("создание с отмененным контекстом", func(t *testing.T) {
+		_, err := uc.CreateTodo(ctx, &domain.Todo{Title: "Canceled"})
+		if !errors.Is(err, domain.ErrCanceled) {
+			t.Errorf("expected ErrCanceled, got %v", err)
+		}
+	})
+
+	t.Run("обновление с отмененным контекстом", func(t *testing.T) {
+		_, err := uc.UpdateTodo(ctx, created.ID, &domain.Todo{Title: "Canceled"})
+		if !errors.Is(err, domain.ErrCanceled) {
+			t.Errorf("expected ErrCanceled, got %v", err)
+		}
+	})
+
+	t.Run("истекший дедлайн", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+
+		_, err := uc.GetAllTodos(ctx)
+		if !errors.Is(err, domain.ErrDeadlineExceeded) {
+			t.Errorf("expected ErrDeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestTodoUseCase_GetStats(t *testing.T) {
+	repo := repository.NewInMemoryTodoRepository()
+	uc := NewTodoUseCase(repo)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	uc.CreateTodo(ctx, &domain.Todo{Title: "Open"})
+	uc.CreateTodo(ctx, &domain.Todo{Title: "Done", Completed: true, DueDate: &past})
+	uc.CreateTodo(ctx, &domain.Todo{Title: "Overdue", DueDate: &past})
+	uc.CreateTodo(ctx, &domain.Todo{Title: "Upcoming", DueDate: &future})
+
+	stats, err := uc.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := domain.TodoStats{Total: 4, Completed: 1, Overdue: 1}
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+type recordingPublisher struct {
+	events []domain.TodoEvent
+}
+
+func (p *recordingPublisher) Publish(event domain.TodoEvent) {
+	p.events = append(p.events, event)
+}
+
+func TestTodoUseCase_Events(t *testing.T) {
+	events := &recordingPublisher{}
+	uc := NewTodoUseCase(repository.NewInMemoryTodoRepository(), WithEventPublisher(events))
+	ctx := context.Background()
+
+	created, _ := uc.CreateTodo(ctx, &domain.Todo{Title: "a", Tags: []string{"x"}})
+	uc.UpdateTodo(ctx, created.ID, &domain.Todo{Title: "b", Tags: []string{"x"}})
+	uc.DeleteTodo(ctx, created.ID)
+
+	// Неудачные операции событий не порождают
+	uc.CreateTodo(ctx, &domain.Todo{})
+	uc.DeleteTodo(ctx, created.ID)
+
+	want := []struct {
+		typ   domain.EventType
+		title string
+	}{
+		{domain.EventTodoCreated, "a"},
+		{domain.EventTodoUpdated, "b"},
+		{domain.EventTodoDeleted, "b"},
+	}
+	if len(events.events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events.events))
+	}
+	for i, w := range want {
+		e := events.events[i]
+		if e.Type != w.typ || e.Todo.ID != created.ID || e.Todo.Title != w.title || e.Time.IsZero() {
+			t.Errorf("event %d: unexpected %+v", i, e)
+		}
+	}
+
+	// Событие несет копию, а не саму задачу
+	created.Tags[0] = "changed"
+	if events.events[0].Todo.Tags[0] != "x" {
+		t.Error("event must not share state with the stored todo")
+	}
+}
+
+func TestTodoUseCase_ApplyChanges(t *testing.T) {
+	events := &recordingPublisher{}
+	uc := NewTodoUseCase(repository.NewInMemoryTodoRepository(), WithEventPublisher(events))
+	ctx := context.Background()
+
+	kept, _ := uc.CreateTodo(ctx, &domain.Todo{Title: "kept"})
+	edited, _ := uc.CreateTodo(ctx, &domain.Todo{Title: "edited"})
+	removed, _ := uc.CreateTodo(ctx, &domain.Todo{Title: "removed"})
+	keptBase, editedBase, removedBase := kept.Version, edited.Version, removed.Version
+
+	// Пока клиент был без связи, другой клиент изменил описание edited и
+	// удалил removed
+	uc.UpdateTodo(ctx, edited.ID, &domain.Todo{Title: "edited", Description: "elsewhere"})
+	uc.DeleteTodo(ctx, removed.ID)
+	events.events = nil
+
+	results := uc.ApplyChanges(ctx, []domain.SyncChange{
+		{Op: domain.SyncCreate, Todo: &domain.Todo{Title: "new"}},
+		{Op: domain.SyncUpdate, ID: kept.ID, BaseVersion: keptBase, Todo: &domain.Todo{Title: "kept offline"}},
+		{Op: domain.SyncUpdate, ID: edited.ID, BaseVersion: editedBase, Todo: &domain.Todo{Title: "edited offline"}},
+		{Op: domain.SyncUpdate, ID: removed.ID, BaseVersion: removedBase, Todo: &domain.Todo{Title: "removed offline"}},
+		{Op: domain.SyncUpdate, ID: kept.ID, BaseVersion: removedBase, Todo: &domain.Todo{Title: "unknown base"}},
+		{Op: domain.SyncDelete, ID: edited.ID, BaseVersion: editedBase},
+		{Op: domain.SyncDelete, ID: removed.ID, BaseVersion: removedBase},
+		{Op: domain.SyncCreate, Todo: &domain.Todo{}},
+	})
+
+	want := []struct {
+		status  domain.SyncStatus
+		title   string
+		deleted bool
+	}{
+		{domain.SyncApplied, "new", false},
+		{domain.SyncApplied, "kept offline", false},
+		{domain.SyncMerged, "edited offline", false},
+		{domain.SyncConflict, "", true},
+		{domain.SyncConflict, "kept offline", false},
+		{domain.SyncApplied, "", true},
+		{domain.SyncApplied, "", true},
+		{domain.SyncRejected, "", false},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, w := range want {
+		r := results[i]
+		title := ""
+		if r.Todo != nil {
+			title = r.Todo.Title
+		}
+		if r.Status != w.status || title != w.title || r.Deleted != w.deleted {
+			t.Errorf("result %d: expected %s %q deleted=%v, got %+v", i, w.status, w.title, w.deleted, r)
+		}
+	}
+	if !errors.Is(results[7].Err, domain.ErrInvalidTodoData) {
+		t.Errorf("rejected change must carry the validation error, got %v", results[7].Err)
+	}
+
+	// Правки разных полей edited сохранились обе
+	if results[2].Todo.Description != "elsewhere" {
+		t.Errorf("merge must keep the concurrent edit of another field, got %+v", results[2].Todo)
+	}
+	if stored, _ := uc.GetTodoByID(ctx, kept.ID); stored.Title != "kept offline" {
+		t.Errorf("conflicting change must not be applied, got %q", stored.Title)
+	}
+	if _, err := uc.GetTodoByID(ctx, edited.ID); !errors.Is(err, domain.ErrTodoNotFound) {
+		t.Errorf("deletion must win over concurrent edits, got %v", err)
+	}
+	// События только для создания, двух обновлений и удаления edited
+	if len(events.events) != 4 {
+		t.Errorf("expected events only for applied changes, got %d", len(events.events))
+	}
+}
@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"net/netip"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/tracing"
+)
+
+// WebhookUseCase управляет подписками на вебхуки. Доставку событий
+// выполняет webhook.Dispatcher.
+type WebhookUseCase struct {
+	hooks   domain.WebhookRepository
+	outbox  domain.Outbox
+	allowed []netip.Prefix
+}
+
+// NewWebhookUseCase создает use case подписок. outbox нужен, чтобы новая
+// подписка получала только события, записанные после ее создания; allowed -
+// внутренние сети, в которые разрешены подписки (см.
+// domain.WebhookTargetAllowed).
+func NewWebhookUseCase(hooks domain.WebhookRepository, outbox domain.Outbox, allowed []netip.Prefix) *WebhookUseCase {
+	return &WebhookUseCase{hooks: hooks, outbox: outbox, allowed: allowed}
+}
+
+// CreateWebhook создает подписку. Если секрет не задан, он генерируется;
+// клиент видит его только в ответе на создание.
+func (uc *WebhookUseCase) CreateWebhook(ctx context.Context, hook *domain.Webhook) (_ *domain.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.CreateWebhook")
+	defer func() { span.EndWithError(err) }()
+
+	if hook.Secret == "" {
+		hook.Secret = newSecret()
+	}
+	if err := hook.Validate(uc.allowed...); err != nil {
+		return nil, err
+	}
+
+	head, err := uc.outboxHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hook.CreatedAt = time.Now().UTC()
+	hook.State = domain.WebhookState{Cursor: head}
+
+	if err := uc.hooks.CreateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// GetWebhooks возвращает все подписки
+func (uc *WebhookUseCase) GetWebhooks(ctx context.Context) (_ []*domain.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.GetWebhooks")
+	defer func() { span.EndWithError(err) }()
+
+	return uc.hooks.GetWebhooks(ctx)
+}
+
+// GetWebhook возвращает подписку по ID
+func (uc *WebhookUseCase) GetWebhook(ctx context.Context, id int) (_ *domain.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.GetWebhook", tracing.WithAttributes(tracing.Attr("webhook.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	return uc.hooks.GetWebhook(ctx, id)
+}
+
+// UpdateWebhook заменяет настройки подписки. Пустой секрет оставляет
+// прежний. Подписка, которую включают после отключения (вручную или из-за
+// неудач), продолжает с новых событий: пропущенные за это время уже удалены
+// из очереди.
+func (uc *WebhookUseCase) UpdateWebhook(ctx context.Context, id int, hook *domain.Webhook) (_ *domain.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.UpdateWebhook", tracing.WithAttributes(tracing.Attr("webhook.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	current, err := uc.hooks.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hook.Secret == "" {
+		hook.Secret = current.Secret
+	}
+	if err := hook.Validate(uc.allowed...); err != nil {
+		return nil, err
+	}
+
+	hook.ID = id
+	if err := uc.hooks.UpdateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	if hook.Active && !current.Deliverable() {
+		head, err := uc.outboxHead(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := uc.hooks.SetWebhookState(ctx, id, domain.WebhookState{Cursor: head}); err != nil {
+			return nil, err
+		}
+	}
+	return uc.hooks.GetWebhook(ctx, id)
+}
+
+// DeleteWebhook удаляет подписку
+func (uc *WebhookUseCase) DeleteWebhook(ctx context.Context, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.DeleteWebhook", tracing.WithAttributes(tracing.Attr("webhook.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	return uc.hooks.DeleteWebhook(ctx, id)
+}
+
+// Deliveries возвращает журнал доставки подписки, начиная с последней
+// попытки
+func (uc *WebhookUseCase) Deliveries(ctx context.Context, id int) (_ []domain.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookUseCase.Deliveries", tracing.WithAttributes(tracing.Attr("webhook.id", id)))
+	defer func() { span.EndWithError(err) }()
+
+	return uc.hooks.Deliveries(ctx, id)
+}
+
+// outboxHead возвращает ID последнего события очереди: запрос событий
+// после максимального ID ничего не читает
+func (uc *WebhookUseCase) outboxHead(ctx context.Context) (uint64, error) {
+	_, head, err := uc.outbox.OutboxEvents(ctx, math.MaxUint64, 1)
+	return head, err
+}
+
+// newSecret возвращает случайный секрет из 32 байт в hex
+func newSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,407 @@
+// Package webhook доставляет события изменений задач подписчикам вебхуков.
+// События берутся из очереди исходящих событий хранилища (domain.Outbox),
+// поэтому изменение, записанное хранилищем, будет доставлено даже после
+// перезапуска. Каждая подписка получает события по порядку: пока текущее
+// событие не доставлено, следующие ждут. Доставка выполняется "хотя бы
+// один раз": получатель может увидеть событие повторно и должен отсеивать
+// повторы по X-Webhook-ID.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"todo/internal/domain"
+)
+
+// Заголовки запроса с событием
+const (
+	// IDHeader - ID события; одинаков при повторных попытках
+	IDHeader = "X-Webhook-ID"
+	// EventHeader - вид события
+	EventHeader = "X-Webhook-Event"
+	// TimestampHeader - время отправки попытки, Unix секунды
+	TimestampHeader = "X-Webhook-Timestamp"
+	// SignatureHeader - "sha256=" и HMAC-SHA256 от "<timestamp>.<тело>" в hex
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Параметры по умолчанию
+const (
+	DefaultTimeout        = 10 * time.Second
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Hour
+	DefaultMaxFailures    = 10
+	DefaultPollInterval   = time.Second
+	DefaultBatchSize      = 100
+)
+
+// maxResponseBytes - сколько байт ответа получателя читается, чтобы
+// переиспользовать соединение
+const maxResponseBytes = 64 << 10
+
+// Config - настройки доставки. Нулевые поля заменяются значениями по
+// умолчанию.
+type Config struct {
+	// Timeout - срок одной попытки
+	Timeout time.Duration
+	// InitialBackoff и MaxBackoff задают паузу перед повторной попыткой:
+	// она удваивается с каждой неудачей подряд, но не превышает MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxFailures - число неудачных попыток подряд, после которого
+	// подписка отключается
+	MaxFailures int
+	// PollInterval - как часто проверяются очередь и сроки повторов
+	PollInterval time.Duration
+	// BatchSize - сколько событий подписки читается из очереди за раз
+	BatchSize int
+	// AllowedNetworks - внутренние сети, в которые разрешена доставка (см.
+	// domain.WebhookTargetAllowed)
+	AllowedNetworks []netip.Prefix
+	// Heartbeat, если задан, получает Beat на каждой итерации Run - не
+	// реже PollInterval, пока отправитель не завис (см. health.Heartbeat)
+	Heartbeat interface{ Beat() }
+}
+
+func (c *Config) setDefaults() {
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	c.MaxBackoff = max(c.MaxBackoff, c.InitialBackoff)
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = DefaultMaxFailures
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+}
+
+// Sign возвращает значение SignatureHeader для тела body, отправленного в
+// момент timestamp (Unix секунды)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Ошибки проверки подписи
+var (
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// ErrForbiddenTarget - адрес получателя во внутренней сети, не входящей в
+// Config.AllowedNetworks
+var ErrForbiddenTarget = errors.New("webhook target address is not allowed")
+
+// Verify проверяет подпись запроса с телом body для получателя на Go.
+// Запросы, отправленные больше чем за tolerance до now или после него,
+// отклоняются, чтобы перехваченный запрос нельзя было повторить позже.
+func Verify(secret string, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	if d := now.Sub(time.Unix(timestamp, 0)); d > tolerance || d < -tolerance {
+		return ErrStaleTimestamp
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Dispatcher доставляет события очереди подпискам. Подписки обслуживаются
+// параллельно, события одной подписки - по очереди. Очередь подтверждается
+// до самого отстающего курсора включенных подписок.
+type Dispatcher struct {
+	hooks  domain.WebhookRepository
+	outbox domain.Outbox
+	config Config
+	client *http.Client
+	wake   chan struct{}
+
+	mu      sync.Mutex
+	busy    map[int]bool
+	retryAt map[int]time.Time
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher создает отправителя событий outbox подпискам из hooks
+func NewDispatcher(hooks domain.WebhookRepository, outbox domain.Outbox, config Config) *Dispatcher {
+	config.setDefaults()
+	return &Dispatcher{
+		hooks:  hooks,
+		outbox: outbox,
+		config: config,
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: transport(config.AllowedNetworks),
+			// Перенаправление считается неудачей: подписку нужно исправить,
+			// а не отправлять подписанные события по новому адресу
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		wake:    make(chan struct{}, 1),
+		busy:    make(map[int]bool),
+		retryAt: make(map[int]time.Time),
+	}
+}
+
+// transport возвращает транспорт, который соединяется только с адресами,
+// разрешенными domain.WebhookTargetAllowed. Адрес проверяется после
+// разрешения имени, непосредственно перед соединением, поэтому имя, которое
+// при создании подписки указывало на внешний адрес, а потом на внутренний
+// (DNS rebinding), не помогает обойти проверку. Прокси не используется: он
+// соединялся бы с получателем сам, в обход проверки.
+func transport(allowed []netip.Prefix) *http.Transport {
+	dialer := &net.Dialer{
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !domain.WebhookTargetAllowed(addr.Addr(), allowed) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, addr.Addr())
+			}
+			return nil
+		},
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// Notify будит отправителя, не дожидаясь PollInterval. Не блокируется,
+// поэтому подходит для вызова из подписки на шину событий.
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run доставляет события, пока ctx не отменен, затем дожидается текущих
+// попыток (они прерываются тем же ctx и не считаются неудачами)
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if d.config.Heartbeat != nil {
+			d.config.Heartbeat.Beat()
+		}
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			d.wg.Wait()
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// dispatch запускает доставку для подписок, которые не заняты и не ждут
+// повторной попытки, и подтверждает обработанную всеми часть очереди
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	hooks, err := d.hooks.GetWebhooks(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to load webhooks", "error", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	upTo, deliverable := uint64(math.MaxUint64), false
+	for _, hook := range hooks {
+		if !hook.Deliverable() {
+			continue
+		}
+		upTo, deliverable = min(upTo, hook.State.Cursor), true
+
+		d.mu.Lock()
+		skip := d.busy[hook.ID] || now.Before(d.retryAt[hook.ID])
+		if !skip {
+			d.busy[hook.ID] = true
+		}
+		d.mu.Unlock()
+		if skip {
+			continue
+		}
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			more := d.deliver(ctx, hook)
+
+			d.mu.Lock()
+			delete(d.busy, hook.ID)
+			d.mu.Unlock()
+			// Очередь прочитана не до конца: продолжаем сразу
+			if more {
+				d.Notify()
+			}
+		}()
+	}
+
+	// Без включенных подписок события никому не нужны
+	if !deliverable {
+		if _, upTo, err = d.outbox.OutboxEvents(ctx, math.MaxUint64, 1); err != nil {
+			return
+		}
+	}
+	if err := d.outbox.AckOutbox(ctx, upTo); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Failed to acknowledge outbox events", "error", err)
+	}
+}
+
+// deliver отправляет подписке события после ее курсора, пока они
+// доставляются, и сохраняет новое состояние. Возвращает true, если в
+// очереди могут остаться события для подписки.
+func (d *Dispatcher) deliver(ctx context.Context, hook *domain.Webhook) (more bool) {
+	events, _, err := d.outbox.OutboxEvents(ctx, hook.State.Cursor, d.config.BatchSize)
+	if err != nil {
+		return false
+	}
+
+	state := hook.State
+	for _, event := range events {
+		if !hook.Subscribed(event.Type) {
+			state.Cursor = event.ID
+			continue
+		}
+
+		delivery := d.send(ctx, hook, event, state.Failures+1)
+		if ctx.Err() != nil {
+			// Остановка сервера: попытка не считается
+			break
+		}
+		if err := d.hooks.AddDelivery(ctx, hook.ID, delivery); errors.Is(err, domain.ErrWebhookNotFound) {
+			return false
+		}
+
+		if delivery.Success {
+			state.Cursor, state.Failures = event.ID, 0
+			d.mu.Lock()
+			delete(d.retryAt, hook.ID)
+			d.mu.Unlock()
+			if !d.saveState(ctx, hook.ID, state) {
+				return false
+			}
+			continue
+		}
+
+		state.Failures++
+		log := slog.With("webhook_id", hook.ID, "event_id", event.ID, "attempt", delivery.Attempt,
+			"status", delivery.StatusCode, "error", delivery.Error)
+		if state.Failures >= d.config.MaxFailures {
+			at := time.Now()
+			state.Disabled, state.DisabledAt = true, &at
+			log.ErrorContext(ctx, "Webhook disabled after repeated delivery failures")
+		} else {
+			delay := d.backoff(state.Failures)
+			d.mu.Lock()
+			d.retryAt[hook.ID] = time.Now().Add(delay)
+			d.mu.Unlock()
+			log.WarnContext(ctx, "Webhook delivery failed, will retry", "retry_in", delay)
+		}
+		d.saveState(ctx, hook.ID, state)
+		return false
+	}
+
+	if state != hook.State {
+		d.saveState(ctx, hook.ID, state)
+	}
+	return len(events) == d.config.BatchSize
+}
+
+func (d *Dispatcher) saveState(ctx context.Context, id int, state domain.WebhookState) bool {
+	err := d.hooks.SetWebhookState(ctx, id, state)
+	if err != nil && !errors.Is(err, domain.ErrWebhookNotFound) && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Failed to save webhook state", "webhook_id", id, "error", err)
+	}
+	return err == nil
+}
+
+// send выполняет одну попытку доставки события
+func (d *Dispatcher) send(ctx context.Context, hook *domain.Webhook, event domain.TodoEvent, attempt int) domain.WebhookDelivery {
+	start := time.Now()
+	delivery := domain.WebhookDelivery{EventID: event.ID, EventType: event.Type, Attempt: attempt, Time: start}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	timestamp := start.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, strconv.FormatUint(event.ID, 10))
+	req.Header.Set(EventHeader, string(event.Type))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	delivery.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = "unexpected status " + resp.Status
+	}
+	return delivery
+}
+
+// backoff возвращает паузу перед попыткой после failures неудач подряд:
+// случайную в [b/2, b], где b растет вдвое с каждой неудачей. Разброс не
+// дает повторам к получателю, который был недоступен для многих подписок,
+// прийти одновременно.
+func (d *Dispatcher) backoff(failures int) time.Duration {
+	b := d.config.InitialBackoff
+	for i := 1; i < failures && b < d.config.MaxBackoff; i++ {
+		b *= 2
+	}
+	b = min(b, d.config.MaxBackoff)
+	return b/2 + time.Duration(rand.Int64N(int64(b/2)+1))
+}
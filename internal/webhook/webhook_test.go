@@ -0,0 +1,338 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"todo/internal/domain"
+	"todo/internal/repository"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receiver - получатель вебхуков, проверяющий подпись каждого запроса
+type receiver struct {
+	t      *testing.T
+	server *httptest.Server
+	// status возвращает код ответа на n-й запрос (с 1)
+	status func(n int) int
+
+	mu       sync.Mutex
+	requests int
+	events   []domain.TodoEvent
+	ids      []string
+}
+
+func newReceiver(t *testing.T, status func(n int) int) *receiver {
+	rc := &receiver{t: t, status: status}
+	rc.server = httptest.NewServer(http.HandlerFunc(rc.serve))
+	t.Cleanup(rc.server.Close)
+	return rc
+}
+
+func (rc *receiver) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := Verify(testSecret, r.Header, body, time.Now(), time.Minute); err != nil {
+		rc.t.Errorf("invalid signature: %v", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	rc.mu.Lock()
+	rc.requests++
+	n := rc.requests
+	code := rc.status(n)
+	if code < 300 {
+		var event domain.TodoEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			rc.t.Errorf("invalid body: %v", err)
+		}
+		rc.events = append(rc.events, event)
+		rc.ids = append(rc.ids, r.Header.Get(IDHeader))
+	}
+	rc.mu.Unlock()
+	w.WriteHeader(code)
+}
+
+func (rc *receiver) received() ([]domain.TodoEvent, int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]domain.TodoEvent(nil), rc.events...), rc.requests
+}
+
+// setup создает хранилища, подписку на url и отправителя с быстрыми
+// повторами, которому разрешена доставка на loopback тестового получателя
+func setup(t *testing.T, url string, events []domain.EventType, config Config) (*repository.InMemoryTodoRepository, *repository.InMemoryWebhookRepository, *domain.Webhook, *Dispatcher) {
+	t.Helper()
+	todos := repository.NewInMemoryTodoRepository()
+	hooks := repository.NewInMemoryWebhookRepository()
+	hook := &domain.Webhook{URL: url, Events: events, Secret: testSecret, Active: true}
+	if err := hooks.CreateWebhook(context.Background(), hook); err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+	if config.InitialBackoff == 0 {
+		config.InitialBackoff = 5 * time.Millisecond
+		config.MaxBackoff = 20 * time.Millisecond
+	}
+	config.PollInterval = 5 * time.Millisecond
+	config.AllowedNetworks = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	return todos, hooks, hook, NewDispatcher(hooks, todos, config)
+}
+
+// run запускает отправителя до конца теста
+func run(t *testing.T, d *Dispatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcher_Delivers(t *testing.T) {
+	rc := newReceiver(t, func(int) int { return http.StatusNoContent })
+	todos, hooks, hook, d := setup(t, rc.server.URL, []domain.EventType{domain.EventTodoCreated, domain.EventTodoDeleted}, Config{})
+	ctx := context.Background()
+
+	todo := &domain.Todo{Title: "Купить молоко"}
+	todos.Create(ctx, todo)
+	todos.Update(ctx, &domain.Todo{ID: todo.ID, Title: "Купить кефир"})
+	todos.Delete(ctx, todo.ID)
+	run(t, d)
+
+	waitFor(t, "two deliveries", func() bool {
+		events, _ := rc.received()
+		return len(events) == 2
+	})
+
+	events, _ := rc.received()
+	if events[0].Type != domain.EventTodoCreated || events[0].Todo.Title != "Купить молоко" {
+		t.Errorf("expected the created event first, got %+v", events[0])
+	}
+	// Обновление не входит в подписку и пропускается
+	if events[1].Type != domain.EventTodoDeleted {
+		t.Errorf("expected the deleted event second, got %+v", events[1])
+	}
+
+	waitFor(t, "the outbox to be acknowledged", func() bool {
+		left, _, _ := todos.OutboxEvents(ctx, 0, 0)
+		return len(left) == 0
+	})
+	stored, _ := hooks.GetWebhook(ctx, hook.ID)
+	if stored.State.Cursor != events[1].ID || stored.State.Failures != 0 {
+		t.Errorf("expected the cursor at the last event, got %+v", stored.State)
+	}
+	log, _ := hooks.Deliveries(ctx, hook.ID)
+	if len(log) != 2 || !log[0].Success || log[0].StatusCode != http.StatusNoContent || log[0].EventID != events[1].ID {
+		t.Errorf("expected two successful deliveries newest first, got %+v", log)
+	}
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	// Первые две попытки неудачны
+	rc := newReceiver(t, func(n int) int {
+		if n <= 2 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	todos, hooks, hook, d := setup(t, rc.server.URL, nil, Config{})
+	ctx := context.Background()
+
+	todos.Create(ctx, &domain.Todo{Title: "A"})
+	todos.Create(ctx, &domain.Todo{Title: "B"})
+	run(t, d)
+
+	waitFor(t, "both events", func() bool {
+		events, _ := rc.received()
+		return len(events) == 2
+	})
+
+	// События не обгоняют друг друга, пока первое не доставлено
+	events, requests := rc.received()
+	if events[0].Todo.Title != "A" || events[1].Todo.Title != "B" {
+		t.Errorf("expected events in order, got %+v", events)
+	}
+	if requests != 4 {
+		t.Errorf("expected 4 requests, got %d", requests)
+	}
+
+	log, _ := hooks.Deliveries(ctx, hook.ID)
+	if len(log) != 4 {
+		t.Fatalf("expected 4 logged attempts, got %+v", log)
+	}
+	// Журнал начинается с последней попытки
+	attempts := []int{1, 3, 2, 1}
+	for i, delivery := range log {
+		if delivery.Attempt != attempts[i] {
+			t.Errorf("delivery %d: expected attempt %d, got %d", i, attempts[i], delivery.Attempt)
+		}
+	}
+	if log[3].Success || log[3].StatusCode != http.StatusServiceUnavailable || log[3].Error == "" {
+		t.Errorf("expected the first attempt to be logged as failed, got %+v", log[3])
+	}
+	if stored, _ := hooks.GetWebhook(ctx, hook.ID); stored.State.Failures != 0 {
+		t.Errorf("expected failures to reset after success, got %+v", stored.State)
+	}
+}
+
+func TestDispatcher_DisablesAfterRepeatedFailures(t *testing.T) {
+	rc := newReceiver(t, func(int) int { return http.StatusInternalServerError })
+	todos, hooks, hook, d := setup(t, rc.server.URL, nil, Config{MaxFailures: 3})
+	ctx := context.Background()
+
+	todos.Create(ctx, &domain.Todo{Title: "A"})
+	run(t, d)
+
+	waitFor(t, "the webhook to be disabled", func() bool {
+		stored, _ := hooks.GetWebhook(ctx, hook.ID)
+		return stored.State.Disabled
+	})
+
+	stored, _ := hooks.GetWebhook(ctx, hook.ID)
+	if stored.State.Failures != 3 || stored.State.DisabledAt == nil || stored.Deliverable() {
+		t.Errorf("expected the webhook disabled after 3 failures, got %+v", stored.State)
+	}
+	// Отключенная подписка больше не получает событий, а очередь ее не ждет
+	todos.Create(ctx, &domain.Todo{Title: "B"})
+	waitFor(t, "the outbox to be acknowledged", func() bool {
+		left, _, _ := todos.OutboxEvents(ctx, 0, 0)
+		return len(left) == 0
+	})
+	if _, requests := rc.received(); requests != 3 {
+		t.Errorf("expected exactly 3 requests, got %d", requests)
+	}
+}
+
+func TestDispatcher_UnreachableReceiver(t *testing.T) {
+	rc := newReceiver(t, func(int) int { return http.StatusOK })
+	url := rc.server.URL
+	rc.server.Close()
+
+	todos, hooks, hook, d := setup(t, url, nil, Config{MaxFailures: 2})
+	todos.Create(context.Background(), &domain.Todo{Title: "A"})
+	d.dispatch(context.Background())
+	d.wg.Wait()
+
+	log, _ := hooks.Deliveries(context.Background(), hook.ID)
+	if len(log) != 1 || log[0].Success || log[0].StatusCode != 0 || log[0].Error == "" {
+		t.Errorf("expected a failed attempt with a transport error, got %+v", log)
+	}
+}
+
+func TestDispatcher_ForbiddenTarget(t *testing.T) {
+	rc := newReceiver(t, func(int) int { return http.StatusOK })
+	// Имя, которое разрешается во внутренний адрес, проверяется при
+	// соединении, даже если подписка прошла валидацию
+	url := strings.Replace(rc.server.URL, "127.0.0.1", "localhost", 1)
+
+	todos, hooks, hook, _ := setup(t, url, nil, Config{})
+	d := NewDispatcher(hooks, todos, Config{})
+	todos.Create(context.Background(), &domain.Todo{Title: "A"})
+	d.dispatch(context.Background())
+	d.wg.Wait()
+
+	log, _ := hooks.Deliveries(context.Background(), hook.ID)
+	if len(log) != 1 || log[0].Success || !strings.Contains(log[0].Error, ErrForbiddenTarget.Error()) {
+		t.Errorf("expected the internal address to be refused, got %+v", log)
+	}
+	if _, requests := rc.received(); requests != 0 {
+		t.Errorf("expected no requests to reach the receiver, got %d", requests)
+	}
+}
+
+// beats считает сигналы Heartbeat
+type beats struct{ n atomic.Int32 }
+
+func (b *beats) Beat() { b.n.Add(1) }
+
+func TestDispatcher_Heartbeat(t *testing.T) {
+	hb := &beats{}
+	_, _, _, d := setup(t, "https://example.com/hook", nil, Config{Heartbeat: hb})
+	run(t, d)
+
+	// Цикл подает сигнал на каждой итерации, даже когда доставлять нечего
+	waitFor(t, "repeated heartbeats", func() bool { return hb.n.Load() >= 3 })
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(nil, nil, Config{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	tests := []struct {
+		failures int
+		base     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{1000, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			got := d.backoff(tt.failures)
+			if got < tt.base/2 || got > tt.base {
+				t.Fatalf("backoff(%d) = %v, expected within [%v, %v]", tt.failures, got, tt.base/2, tt.base)
+			}
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	signed := func(ts time.Time, body []byte) http.Header {
+		h := http.Header{}
+		h.Set(TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+		h.Set(SignatureHeader, Sign(testSecret, ts.Unix(), body))
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		secret string
+		want   error
+	}{
+		{"верная подпись", signed(now, body), testSecret, nil},
+		{"измененное тело", signed(now, []byte(`{"id":2}`)), testSecret, ErrInvalidSignature},
+		{"чужой секрет", signed(now, body), "fedcba9876543210", ErrInvalidSignature},
+		{"устаревший запрос", signed(now.Add(-time.Hour), body), testSecret, ErrStaleTimestamp},
+		{"запрос из будущего", signed(now.Add(time.Hour), body), testSecret, ErrStaleTimestamp},
+		{"без заголовков", http.Header{}, testSecret, ErrStaleTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, body, now, 5*time.Minute); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
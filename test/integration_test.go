@@ -3,6 +3,8 @@ package test_test
 import (
 	"bytes"
 	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -24,11 +26,15 @@ func setupTestServer() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/todos", h.HandleTodos)
 	mux.HandleFunc("/todos/", h.HandleTodoByID)
+	mux.HandleFunc("/sync", h.HandleSync)
 
 	// Применяем middleware
-	return middleware.Logger(
-		middleware.Recovery(
-			middleware.Timeout(5 * time.Second)(mux),
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return middleware.RequestID(
+		middleware.Logger(log)(
+			middleware.Recovery(log)(
+				middleware.Timeout(5 * time.Second)(mux),
+			),
 		),
 	)
 }
@@ -45,6 +51,7 @@ func TestIntegration_FullTodoLifecycle(t *testing.T) {
 
 	body, _ := json.Marshal(createTodo)
 	req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	server.ServeHTTP(rec, req)
 
@@ -84,6 +91,7 @@ func TestIntegration_FullTodoLifecycle(t *testing.T) {
 
 	body, _ = json.Marshal(updateTodo)
 	req = httptest.NewRequest(http.MethodPut, "/todos/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
 	rec = httptest.NewRecorder()
 	server.ServeHTTP(rec, req)
 
@@ -137,6 +145,60 @@ func TestIntegration_FullTodoLifecycle(t *testing.T) {
 	}
 }
 
+func TestIntegration_SyncMerge(t *testing.T) {
+	server := setupTestServer()
+
+	body, _ := json.Marshal(domain.Todo{Title: "Купить хлеб", Tags: []string{"shop"}})
+	req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	var base domain.Todo
+	json.NewDecoder(rec.Body).Decode(&base)
+
+	// Два клиента без связи правят разные поля одной версии задачи и
+	// синхронизируются по очереди
+	sync := func(todo map[string]any) (status string, merged domain.Todo) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"changes": []any{map[string]any{
+			"op": "update", "id": base.ID, "base_version": base.Version, "todo": todo,
+		}}})
+		req := httptest.NewRequest(http.MethodPost, "/sync", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var resp struct {
+			Results []struct {
+				Status string      `json:"status"`
+				Todo   domain.Todo `json:"todo"`
+			} `json:"results"`
+		}
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return resp.Results[0].Status, resp.Results[0].Todo
+	}
+	if status, _ := sync(map[string]any{"title": "Купить багет", "tags": []string{"shop"}}); status != "applied" {
+		t.Fatalf("Expected the first edit to be applied, got %s", status)
+	}
+	status, merged := sync(map[string]any{"title": "Купить хлеб", "completed": true, "tags": []string{"shop", "today"}})
+	if status != "merged" {
+		t.Fatalf("Expected the second edit to be merged, got %s", status)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	var stored domain.Todo
+	json.NewDecoder(rec.Body).Decode(&stored)
+	for _, todo := range []domain.Todo{merged, stored} {
+		if todo.Title != "Купить багет" || !todo.Completed || len(todo.Tags) != 2 || todo.Tags[1] != "today" {
+			t.Errorf("Expected both edits to survive, got %+v", todo)
+		}
+	}
+}
+
 func TestIntegration_MultipleTodos(t *testing.T) {
 	server := setupTestServer()
 
@@ -150,6 +212,7 @@ func TestIntegration_MultipleTodos(t *testing.T) {
 	for _, todo := range todos {
 		body, _ := json.Marshal(todo)
 		req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
 		rec := httptest.NewRecorder()
 		server.ServeHTTP(rec, req)
 
@@ -199,6 +262,7 @@ func TestIntegration_ValidationErrors(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			body, _ := json.Marshal(tc.todo)
 			req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
 			rec := httptest.NewRecorder()
 			server.ServeHTTP(rec, req)
 
@@ -241,6 +305,7 @@ func TestIntegration_NotFoundErrors(t *testing.T) {
 			if tc.method == http.MethodPut {
 				body, _ := json.Marshal(domain.Todo{Title: "Test"})
 				req = httptest.NewRequest(tc.method, tc.path, bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
 			} else {
 				req = httptest.NewRequest(tc.method, tc.path, nil)
 			}
@@ -272,6 +337,7 @@ func TestIntegration_ConcurrentRequests(t *testing.T) {
 
 			body, _ := json.Marshal(todo)
 			req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
 			rec := httptest.NewRecorder()
 			server.ServeHTTP(rec, req)
 